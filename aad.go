@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Azure AD Tokens ---
+
+// clientCredentials fetches app tokens for one resource with the OAuth
+// client credentials flow, caching each until shortly before it expires.
+type clientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scope        string // e.g. https://graph.microsoft.com/.default
+	client       *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newClientCredentials(tenant, clientID, secret, scope string, client *http.Client) *clientCredentials {
+	return &clientCredentials{
+		tokenURL:     "https://login.microsoftonline.com/" + url.PathEscape(tenant) + "/oauth2/v2.0/token",
+		clientID:     clientID,
+		clientSecret: secret,
+		scope:        scope,
+		client:       client,
+	}
+}
+
+// Token returns a cached token, fetching a new one shortly before the old
+// one expires.
+func (c *clientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"scope":         {c.scope},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	c.token = out.AccessToken
+	c.expires = time.Now().Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Brute-Force Protection ---
+
+const (
+	// DefaultAuthMaxFailures is how many wrong keys an IP may present
+	// within the window before it's locked out
+	DefaultAuthMaxFailures = 10
+	DefaultAuthWindow      = 15 * time.Minute
+	DefaultAuthLockout     = 15 * time.Minute
+
+	// authBlockedKey is a sorted set of blocked IPs scored by when their
+	// lockout ends. Blocks are instance-wide, so keys aren't tenant-scoped.
+	authBlockedKey     = "auth:blocked"
+	authFailuresPrefix = "auth:failures:"
+)
+
+// lockoutPolicy locks out IPs presenting too many wrong keys; a zero
+// maxFailures disables it.
+type lockoutPolicy struct {
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+}
+
+// newLockoutPolicy reads AUTH_MAX_FAILURES, AUTH_FAILURE_WINDOW and
+// AUTH_LOCKOUT.
+func newLockoutPolicy() lockoutPolicy {
+	return lockoutPolicy{
+		maxFailures: envInt("AUTH_MAX_FAILURES", DefaultAuthMaxFailures),
+		window:      envDuration("AUTH_FAILURE_WINDOW", DefaultAuthWindow),
+		lockout:     envDuration("AUTH_LOCKOUT", DefaultAuthLockout),
+	}
+}
+
+// lockedOutUntil returns when ip's lockout ends, or the zero time when it
+// isn't locked out. Redis errors let the request through.
+func (app *App) lockedOutUntil(ctx context.Context, ip string) time.Time {
+	if app.lockout.maxFailures <= 0 {
+		return time.Time{}
+	}
+	score, err := app.RedisClient.ZScore(ctx, authBlockedKey, ip).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error checking lockout of %s: %v", ip, err)
+		}
+		return time.Time{}
+	}
+	until := time.Unix(int64(score), 0)
+	if !until.After(time.Now()) {
+		return time.Time{}
+	}
+	return until
+}
+
+// countFailure bumps a failure counter, starting its window with the
+// first failure in the same step, so a counter can't be left without a
+// TTL to lock an IP out for good. EXPIRE's NX option would do, but needs
+// Redis 7.
+var countFailure = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// recordAuthFailure counts a wrong key from ip, locking it out once it
+// reaches the limit within the window.
+func (app *App) recordAuthFailure(ctx context.Context, ip string) {
+	if app.lockout.maxFailures <= 0 {
+		return
+	}
+	key := authFailuresPrefix + ip
+	n, err := countFailure.Run(ctx, app.RedisClient, []string{key}, app.lockout.window.Milliseconds()).Int64()
+	if err != nil {
+		log.Printf("Error counting auth failures of %s: %v", ip, err)
+		return
+	}
+	if n < int64(app.lockout.maxFailures) {
+		return
+	}
+	until := time.Now().Add(app.lockout.lockout)
+	app.RedisClient.ZAdd(ctx, authBlockedKey, redis.Z{Score: float64(until.Unix()), Member: ip})
+	app.RedisClient.Del(ctx, key)
+	log.Printf("Locked out %s until %s after %d failed authentications", ip, formatTime(until), n)
+}
+
+// clearAuthFailures forgets ip's failures after it authenticated.
+func (app *App) clearAuthFailures(ctx context.Context, ip string) {
+	if app.lockout.maxFailures > 0 {
+		app.RedisClient.Del(ctx, authFailuresPrefix+ip)
+	}
+}
+
+// respondLockedOut answers 429 until the lockout ends.
+func respondLockedOut(w http.ResponseWriter, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+	respondError(w, http.StatusTooManyRequests, "Too many failed attempts")
+}
+
+type AuthBlockResponse struct {
+	IP    string `json:"ip"`
+	Until string `json:"until"`
+}
+
+// listAuthBlocks serves GET /admin/blocks: the IPs currently locked out.
+func (app *App) listAuthBlocks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	app.RedisClient.ZRemRangeByScore(ctx, authBlockedKey, "-inf", now)
+	blocked, err := app.RedisClient.ZRangeWithScores(ctx, authBlockedKey, 0, -1).Result()
+	if err != nil {
+		serverError(w, r, "Failed to load blocks", err)
+		return
+	}
+	out := make([]AuthBlockResponse, 0, len(blocked))
+	for _, z := range blocked {
+		ip, _ := z.Member.(string)
+		out = append(out, AuthBlockResponse{IP: ip, Until: formatTime(time.Unix(int64(z.Score), 0))})
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// clearAuthBlock serves DELETE /admin/blocks?ip=, lifting the lockout
+// and forgetting the failures of ip.
+func (app *App) clearAuthBlock(w http.ResponseWriter, r *http.Request) {
+	ip := r.URL.Query().Get("ip")
+	if net.ParseIP(ip) == nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"ip": "must be an IP address"},
+		})
+		return
+	}
+	ctx := r.Context()
+	removed, err := app.RedisClient.ZRem(ctx, authBlockedKey, ip).Result()
+	if err != nil {
+		serverError(w, r, "Failed to clear block", err)
+		return
+	}
+	app.RedisClient.Del(ctx, authFailuresPrefix+ip)
+	if removed == 0 {
+		respondError(w, http.StatusNotFound, "IP is not blocked")
+		return
+	}
+	app.audit(r, "auth.unblock", "ip:"+ip)
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "unblocked"})
+}
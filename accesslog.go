@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// --- Access Log ---
+
+// DefaultSlowRequest is the duration past which a request is logged in
+// full, whatever the sampling.
+const DefaultSlowRequest = time.Second
+
+// accessLogPolicy decides what the access log writes, and how.
+type accessLogPolicy struct {
+	json   bool
+	sample []pathRate // longest prefix first
+	slow   time.Duration
+}
+
+// pathRate is the share of requests under prefix that are logged.
+type pathRate struct {
+	prefix string
+	rate   float64
+}
+
+// newAccessLogPolicy reads ACCESS_LOG_FORMAT (text or json),
+// ACCESS_LOG_SAMPLE (prefix=rate pairs such as "/health=0,/todos=0.1";
+// other paths are all logged) and ACCESS_LOG_SLOW (0 turns full capture
+// off).
+func newAccessLogPolicy() accessLogPolicy {
+	p := accessLogPolicy{
+		json: os.Getenv("ACCESS_LOG_FORMAT") == "json",
+		slow: envDuration("ACCESS_LOG_SLOW", DefaultSlowRequest),
+	}
+	for _, pair := range splitList(os.Getenv("ACCESS_LOG_SAMPLE")) {
+		prefix, raw, _ := strings.Cut(pair, "=")
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate < 0 || rate > 1 || !strings.HasPrefix(prefix, "/") {
+			log.Printf("Invalid ACCESS_LOG_SAMPLE entry %q, ignoring", pair)
+			continue
+		}
+		p.sample = append(p.sample, pathRate{prefix: prefix, rate: rate})
+	}
+	sort.Slice(p.sample, func(i, j int) bool { return len(p.sample[i].prefix) > len(p.sample[j].prefix) })
+	return p
+}
+
+// sampleRate is the share of requests to path that are logged.
+func (p accessLogPolicy) sampleRate(path string) float64 {
+	for _, s := range p.sample {
+		if strings.HasPrefix(path, s.prefix) {
+			return s.rate
+		}
+	}
+	return 1
+}
+
+// AccessEntry is one line of the access log.
+type AccessEntry struct {
+	Time       string       `json:"time"`
+	RequestID  string       `json:"requestId,omitempty"`
+	Method     string       `json:"method"`
+	URL        string       `json:"url"`
+	Route      string       `json:"route,omitempty"`
+	Proto      string       `json:"proto"`
+	Status     int          `json:"status"`
+	Bytes      int          `json:"bytes"`
+	DurationMs float64      `json:"durationMs"`
+	Remote     string       `json:"remote"`
+	SampleRate float64      `json:"sampleRate,omitempty"` // set when sampled
+	Slow       *SlowCapture `json:"slow,omitempty"`
+}
+
+// SlowCapture is what's kept of a request slower than ACCESS_LOG_SLOW.
+type SlowCapture struct {
+	UserAgent       string            `json:"userAgent,omitempty"`
+	RequestBytes    int64             `json:"requestBytes"` // -1 when unknown
+	RequestHeaders  map[string]string `json:"requestHeaders"`
+	ResponseHeaders map[string]string `json:"responseHeaders"`
+	CacheMs         float64           `json:"cacheMs,omitempty"` // with SERVER_TIMING
+	DBMs            float64           `json:"dbMs,omitempty"`
+}
+
+// sensitiveHeaders are never written to the log.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Csrf-Token":        true,
+}
+
+func captureHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveHeaders[name] {
+			out[name] = "REDACTED"
+			continue
+		}
+		out[name] = redactSecrets(strings.Join(values, ", "))
+	}
+	return out
+}
+
+// accessLog replaces chi's middleware.Logger: it writes one line per
+// request to out, sampled per path prefix. Server errors and slow requests
+// are always logged, the latter with their headers and backend timings.
+func accessLog(p accessLogPolicy, out io.Writer) func(http.Handler) http.Handler {
+	text := log.New(out, "", log.LstdFlags)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			elapsed := time.Since(start)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			slow := p.slow > 0 && elapsed >= p.slow
+			rate := p.sampleRate(r.URL.Path)
+			if !slow && status < 500 && rate < 1 && rand.Float64() >= rate {
+				return
+			}
+
+			e := AccessEntry{
+				Time:       start.UTC().Format(time.RFC3339Nano),
+				RequestID:  middleware.GetReqID(r.Context()),
+				Method:     r.Method,
+				URL:        redactSecrets(r.URL.RequestURI()),
+				Proto:      r.Proto,
+				Status:     status,
+				Bytes:      ww.BytesWritten(),
+				DurationMs: ms(elapsed),
+				Remote:     clientIP(r),
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				e.Route = rctx.RoutePattern()
+			}
+			if rate < 1 {
+				e.SampleRate = rate
+			}
+			if slow {
+				e.Slow = &SlowCapture{
+					UserAgent:       r.UserAgent(),
+					RequestBytes:    r.ContentLength,
+					RequestHeaders:  captureHeaders(r.Header),
+					ResponseHeaders: captureHeaders(ww.Header()),
+				}
+				if t := timingsFrom(r.Context()); t != nil {
+					e.Slow.CacheMs = ms(time.Duration(t.cache.Load()))
+					e.Slow.DBMs = ms(time.Duration(t.db.Load()))
+				}
+			}
+
+			if p.json {
+				data, _ := json.Marshal(e)
+				out.Write(append(data, '\n'))
+				return
+			}
+			text.Print(e.String())
+		})
+	}
+}
+
+// String formats e like chi's middleware.Logger, followed by what it
+// doesn't show.
+func (e AccessEntry) String() string {
+	var b strings.Builder
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, "[%s] ", e.RequestID)
+	}
+	fmt.Fprintf(&b, "%q from %s - %03d %dB in %.3fms", e.Method+" "+e.URL+" "+e.Proto, e.Remote, e.Status, e.Bytes, e.DurationMs)
+	if e.SampleRate > 0 {
+		fmt.Fprintf(&b, " (sampled at %g)", e.SampleRate)
+	}
+	if s := e.Slow; s != nil {
+		data, _ := json.Marshal(s)
+		fmt.Fprintf(&b, " SLOW %s", data)
+	}
+	return b.String()
+}
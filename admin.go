@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// --- Admin Access ---
+
+// requireAdmin guards admin routes with the shared ADMIN_API_KEY, presented
+// as a bearer token or X-API-Key header. Without a configured key the admin
+// API is disabled.
+func (app *App) requireAdmin(key string) func(http.Handler) http.Handler {
+	return app.requireKey(key, "Admin API")
+}
+
+// requireKey guards routes with a shared key presented like the admin
+// key, or by a session signed in with it; api names the guarded API in
+// the error when no key is configured. IPs presenting too many wrong keys
+// are locked out for a while.
+func (app *App) requireKey(key, api string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				respondError(w, http.StatusForbidden, api+" is not configured")
+				return
+			}
+			ctx, ip := r.Context(), clientIP(r)
+			if until := app.lockedOutUntil(ctx, ip); !until.IsZero() {
+				respondLockedOut(w, until)
+				return
+			}
+			presented := requestAPIKey(r)
+			if _, err := r.Cookie(SessionCookieName); err == nil && presented == "" {
+				s, ok := app.session(r, key)
+				if !ok {
+					// Likely expired, not guessed: don't count it against the IP
+					respondError(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, sessionContextKey{}, s)))
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(key)) != 1 {
+				app.recordAuthFailure(ctx, ip)
+				respondError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			app.clearAuthFailures(ctx, ip)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestAPIKey returns the credential presented via X-API-Key or an
+// Authorization bearer token.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// apiClientID identifies the caller for usage accounting without storing
+// the credential itself: its mTLS identity, a short fingerprint of its API
+// key (also for sessions signed in with the key), or "anonymous".
+func apiClientID(r *http.Request) string {
+	if name := mtlsIdentity(r.Context()); name != "" {
+		return "mtls:" + name
+	}
+	if s, ok := sessionFrom(r.Context()); ok {
+		return s.Subject
+	}
+	key := requestAPIKey(r)
+	if key == "" {
+		return "anonymous"
+	}
+	return keyFingerprint(key)
+}
+
+// keyFingerprint identifies an API key without revealing it.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- AI Suggestions ---
+
+const (
+	// DefaultAITimeout bounds a single model call
+	DefaultAITimeout = 20 * time.Second
+	// DefaultOpenAIAPIVersion is the Azure OpenAI REST API version used
+	DefaultOpenAIAPIVersion = "2024-02-01"
+	// aiCacheTTL is how long model answers are reused
+	aiCacheTTL = 24 * time.Hour
+	// maxSuggestionTodos caps how many open todos are sent to the model
+	maxSuggestionTodos = 50
+)
+
+// assistant answers a prompt with a JSON document. Implementations must
+// honour ctx so a slow model can't hold requests open.
+type assistant interface {
+	CompleteJSON(ctx context.Context, system, user string) (string, error)
+}
+
+// newAssistant returns the Azure OpenAI assistant when AI_SUGGESTIONS is
+// enabled and configured, or nil to disable the AI endpoints.
+func newAssistant() assistant {
+	if enabled, _ := strconv.ParseBool(os.Getenv("AI_SUGGESTIONS")); !enabled {
+		return nil
+	}
+	endpoint := strings.TrimRight(os.Getenv("AZURE_OPENAI_ENDPOINT"), "/")
+	key := os.Getenv("AZURE_OPENAI_API_KEY")
+	deployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT")
+	if endpoint == "" || key == "" || deployment == "" {
+		log.Printf("AI_SUGGESTIONS is on but AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY or AZURE_OPENAI_DEPLOYMENT is missing; disabling it")
+		return nil
+	}
+	version := os.Getenv("AZURE_OPENAI_API_VERSION")
+	if version == "" {
+		version = DefaultOpenAIAPIVersion
+	}
+	return &azureOpenAI{
+		url:    fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s", endpoint, deployment, version),
+		apiKey: key,
+		client: &http.Client{Timeout: envDuration("AI_TIMEOUT", DefaultAITimeout)},
+	}
+}
+
+// azureOpenAI calls the chat completions API of an Azure OpenAI deployment.
+type azureOpenAI struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (a *azureOpenAI) CompleteJSON(ctx context.Context, system, user string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"messages": []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		"temperature":     0.2,
+		"response_format": map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api-key", a.apiKey)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	var out struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if len(out.Choices) == 0 {
+		return "", errors.New("model returned no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
+
+// askCached answers from Redis under key when possible, otherwise asks the
+// assistant and decodes its JSON answer into v, caching it on success.
+func (app *App) askCached(ctx context.Context, key, system, user string, v interface{}) error {
+	key = redisKey(ctx, key)
+	if cached, err := app.RedisClient.Get(ctx, key).Bytes(); err == nil {
+		if json.Unmarshal(cached, v) == nil {
+			return nil
+		}
+	}
+	answer, err := app.assistant.CompleteJSON(ctx, system, user)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(answer), v); err != nil {
+		return fmt.Errorf("decoding model answer: %w", err)
+	}
+	if data, err := json.Marshal(v); err == nil {
+		app.RedisClient.Set(ctx, key, data, aiCacheTTL)
+	}
+	return nil
+}
+
+// requireAssistant answers 404 and reports false when AI is disabled.
+func (app *App) requireAssistant(w http.ResponseWriter) bool {
+	if app.assistant == nil {
+		respondError(w, http.StatusNotFound, "AI suggestions are disabled")
+		return false
+	}
+	return true
+}
+
+type Subtask struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+type BreakdownResponse struct {
+	TodoID   string    `json:"todoId"`
+	Subtasks []Subtask `json:"subtasks"`
+}
+
+const breakdownPrompt = `You help people plan tasks. Split the user's task into 2 to 8 small, ` +
+	`concrete subtasks in the order they should be done. Answer only with JSON of the form ` +
+	`{"subtasks": [{"title": "...", "description": "..."}]}.`
+
+// breakdownTodo serves POST /todos/{id}/breakdown. It only suggests
+// subtasks; nothing is created.
+func (app *App) breakdownTodo(w http.ResponseWriter, r *http.Request) {
+	if !app.requireAssistant(w) {
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+
+	// Keyed by content, so editing the todo asks again
+	task := todo.Title
+	if todo.Description != "" {
+		task += "\n\n" + todo.Description
+	}
+	sum := sha256.Sum256([]byte(task))
+	var answer struct {
+		Subtasks []Subtask `json:"subtasks"`
+	}
+	if err := app.askCached(ctx, "ai:breakdown:"+hex.EncodeToString(sum[:16]), breakdownPrompt, task, &answer); err != nil {
+		log.Printf("Error breaking down todo %s: %v", objID.Hex(), err)
+		respondError(w, http.StatusBadGateway, "Suggestions are unavailable right now")
+		return
+	}
+	if answer.Subtasks == nil {
+		answer.Subtasks = []Subtask{}
+	}
+	respondJSON(w, http.StatusOK, BreakdownResponse{TodoID: objID.Hex(), Subtasks: answer.Subtasks})
+}
+
+type PrioritySuggestion struct {
+	TodoID            string `json:"todoId"`
+	Title             string `json:"title"`
+	CurrentPriority   string `json:"currentPriority,omitempty"`
+	SuggestedPriority string `json:"suggestedPriority"`
+	Reason            string `json:"reason,omitempty"`
+}
+
+type SuggestionsResponse struct {
+	Suggestions []PrioritySuggestion `json:"suggestions"`
+}
+
+const suggestionsPrompt = `You help people prioritize. For each task in the user's JSON list, ` +
+	`suggest a priority of low, medium or high with a one-sentence reason. Answer only with JSON ` +
+	`of the form {"suggestions": [{"id": "...", "priority": "...", "reason": "..."}]}.`
+
+// todoSuggestions serves GET /todos/suggestions: priorities proposed for
+// the newest open todos. Answers are cached per list version.
+func (app *App) todoSuggestions(w http.ResponseWriter, r *http.Request) {
+	if !app.requireAssistant(w) {
+		return
+	}
+	ctx := r.Context()
+	open := false
+	todos, err := app.Store.List(ctx, store.Query{
+		Fields:    []string{"title", "priority"},
+		Completed: &open,
+		Limit:     maxSuggestionTodos,
+	})
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	out := SuggestionsResponse{Suggestions: []PrioritySuggestion{}}
+	if len(todos) == 0 {
+		respondJSON(w, http.StatusOK, out)
+		return
+	}
+
+	type task struct {
+		ID       string `json:"id"`
+		Title    string `json:"title"`
+		Priority string `json:"priority,omitempty"`
+	}
+	tasks := make([]task, 0, len(todos))
+	byID := map[string]store.Todo{}
+	for _, todo := range todos {
+		tasks = append(tasks, task{ID: todo.ID.Hex(), Title: todo.Title, Priority: todo.Priority})
+		byID[todo.ID.Hex()] = todo
+	}
+	prompt, _ := json.Marshal(tasks)
+
+	version, _ := listVersion(ctx, app.RedisClient)
+	var answer struct {
+		Suggestions []struct {
+			ID       string `json:"id"`
+			Priority string `json:"priority"`
+			Reason   string `json:"reason"`
+		} `json:"suggestions"`
+	}
+	key := "ai:suggestions:" + strconv.FormatInt(version, 36)
+	if err := app.askCached(ctx, key, suggestionsPrompt, string(prompt), &answer); err != nil {
+		log.Printf("Error suggesting priorities: %v", err)
+		respondError(w, http.StatusBadGateway, "Suggestions are unavailable right now")
+		return
+	}
+
+	// Drop anything the model made up: unknown todos or invalid priorities
+	for _, s := range answer.Suggestions {
+		todo, ok := byID[s.ID]
+		if !ok || s.Priority == "" || !store.ValidPriority(s.Priority) {
+			continue
+		}
+		out.Suggestions = append(out.Suggestions, PrioritySuggestion{
+			TodoID:            s.ID,
+			Title:             todo.Title,
+			CurrentPriority:   todo.Priority,
+			SuggestedPriority: s.Priority,
+			Reason:            s.Reason,
+		})
+	}
+	respondJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mkgakishi/go-azure-todo/apitest"
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+func TestAPI(t *testing.T) {
+	apitest.Run(t, func(t *testing.T) http.Handler {
+		app, err := NewApp(store.NewMemory(), apitest.NewRedis(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return app.Router
+	})
+}
+
+// fixedTranscript transcribes every clip as itself.
+type fixedTranscript string
+
+func (f fixedTranscript) Transcribe(context.Context, io.Reader, string) (string, error) {
+	return string(f), nil
+}
+
+func TestVoiceTitles(t *testing.T) {
+	for _, tc := range []struct {
+		transcript string
+		status     int
+		title      string
+	}{
+		{"\u200bBuy milk #errands.", http.StatusCreated, "Buy milk"},
+		{"\u200b !high", http.StatusUnprocessableEntity, ""},
+		{strings.Repeat("a", MaxTitleLength+1), http.StatusBadRequest, ""},
+	} {
+		app, err := NewApp(store.NewMemory(), apitest.NewRedis(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.transcriber = fixedTranscript(tc.transcript)
+		h := apitest.New(t, app.Router)
+
+		resp := h.Do(http.MethodPost, "/todos/voice", strings.NewReader("RIFF"), http.Header{"Content-Type": {"audio/wav"}})
+		apitest.ExpectStatus(t, resp, tc.status)
+		if tc.status != http.StatusCreated {
+			continue
+		}
+		var created VoiceResponse
+		resp.Decode(t, &created)
+		if created.Todo.Title != tc.title {
+			t.Errorf("title of %q = %q, want %q", tc.transcript, created.Todo.Title, tc.title)
+		}
+	}
+}
+
+func TestQueueMessages(t *testing.T) {
+	app, err := NewApp(store.NewMemory(), apitest.NewRedis(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, tc := range []struct {
+		body   string
+		poison bool
+	}{
+		{`{"title": "From the queue", "priority": "high"}`, false},
+		{`{not json`, true},
+		{`{"title": "\u200b"}`, true},
+		{`{"title": "Somewhere", "location": {"lat": 91, "lng": 0}}`, true},
+		{`{"title": "Blocked", "blockedBy": ["000000000000000000000000"]}`, true},
+		{`{"title": "Elsewhere", "tenant": "acme"}`, true},
+	} {
+		err := app.createFromQueue(ctx, []byte(tc.body))
+		if (err != nil) != tc.poison || tc.poison && !poisonous(err) {
+			t.Errorf("message %s failed with %v, want poisoned %v", tc.body, err, tc.poison)
+		}
+	}
+	todos, err := app.Store.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || todos[0].Title != "From the queue" || todos[0].Priority != "high" {
+		t.Errorf("todos created from the queue = %+v, want only the valid one", todos)
+	}
+	if poisonous(errors.New("store unavailable")) {
+		t.Errorf("a failing store poisons messages, want them tried again")
+	}
+}
@@ -0,0 +1,145 @@
+// Package apitest is an end-to-end harness for the todo HTTP API. It drives
+// any http.Handler through httptest, covering both the JSON API and the
+// HTML form flows, and provides in-memory backends to mount the app on:
+//
+//	func TestAPI(t *testing.T) {
+//		apitest.Run(t, func(t *testing.T) http.Handler {
+//			app, err := NewApp(store.NewMemory(), apitest.NewRedis(t))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return app.Router
+//		})
+//	}
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedis returns a client connected to an in-memory Redis server that is
+// shut down when t completes.
+func NewRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+// --- Harness ---
+
+// Harness serves a handler on a test server and issues requests against it.
+// Redirects are not followed so form flows can assert on them.
+type Harness struct {
+	t      *testing.T
+	srv    *httptest.Server
+	client *http.Client
+}
+
+func New(t *testing.T, h http.Handler) *Harness {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return &Harness{
+		t:   t,
+		srv: srv,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Response is a fully read HTTP response.
+type Response struct {
+	*http.Response
+	Body []byte
+}
+
+// Decode unmarshals the JSON body into v, failing the test on error.
+func (r *Response) Decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decoding %s %s response %q: %v", r.Request.Method, r.Request.URL.Path, r.Body, err)
+	}
+}
+
+// Do sends a request with the given headers and returns the read response.
+func (h *Harness) Do(method, path string, body io.Reader, header http.Header) *Response {
+	h.t.Helper()
+	req, err := http.NewRequest(method, h.srv.URL+path, body)
+	if err != nil {
+		h.t.Fatalf("building %s %s: %v", method, path, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("reading %s %s: %v", method, path, err)
+	}
+	return &Response{Response: resp, Body: data}
+}
+
+// JSON sends v (when non-nil) as a JSON API request.
+func (h *Harness) JSON(method, path string, v interface{}) *Response {
+	h.t.Helper()
+	var body io.Reader
+	if v != nil {
+		data, err := json.Marshal(v)
+		if err != nil {
+			h.t.Fatalf("encoding request body: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	return h.Do(method, path, body, http.Header{
+		"Content-Type":     {"application/json"},
+		"Accept":           {"application/json"},
+		"X-Requested-With": {"XMLHttpRequest"},
+	})
+}
+
+// Form posts values the way the HTML UI does.
+func (h *Harness) Form(path string, values url.Values) *Response {
+	h.t.Helper()
+	return h.Do(http.MethodPost, path, strings.NewReader(values.Encode()), http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Accept":       {"text/html"},
+	})
+}
+
+// ExpectStatus fails the test unless resp has the wanted status code.
+func ExpectStatus(t *testing.T, resp *Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		t.Fatalf("%s %s = %d, want %d (body %q)",
+			resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, resp.Body)
+	}
+}
+
+// ExpectRedirect fails the test unless resp is a 303 to location.
+func ExpectRedirect(t *testing.T, resp *Response, location string) {
+	t.Helper()
+	ExpectStatus(t, resp, http.StatusSeeOther)
+	if got := resp.Header.Get("Location"); got != location {
+		t.Fatalf("%s %s redirected to %q, want %q",
+			resp.Request.Method, resp.Request.URL.Path, got, location)
+	}
+}
@@ -0,0 +1,1516 @@
+package apitest
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// todo mirrors the JSON representation returned by the API.
+type todo struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Priority    string   `json:"priority"`
+	BlockedBy   []string `json:"blockedBy"`
+	Status      string   `json:"status"`
+	Completed   bool     `json:"completed"`
+	CreatedAt   string   `json:"createdAt"`
+}
+
+// Run exercises every endpoint against a fresh handler per case.
+func Run(t *testing.T, newHandler func(t *testing.T) http.Handler) {
+	t.Helper()
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, New(t, newHandler(t)))
+		})
+	}
+}
+
+var cases = []struct {
+	name string
+	run  func(t *testing.T, h *Harness)
+}{
+	{"Health", testHealth},
+	{"Readyz", testReadyz},
+	{"StatusPage", testStatusPage},
+	{"Version", testVersion},
+	{"HomeEmpty", testHomeEmpty},
+	{"HomeLoadMore", testHomeLoadMore},
+	{"HomeTabs", testHomeTabs},
+	{"CreateJSON", testCreateJSON},
+	{"CreateJSONInvalid", testCreateJSONInvalid},
+	{"CreateDetails", testCreateDetails},
+	{"CreateForm", testCreateForm},
+	{"CreateFormMissingTitle", testCreateFormMissingTitle},
+	{"UnicodeTitles", testUnicodeTitles},
+	{"ListNewestFirst", testListNewestFirst},
+	{"ListPage", testListPage},
+	{"ListCounts", testListCounts},
+	{"HALEnvelope", testHALEnvelope},
+	{"JSONAPI", testJSONAPI},
+	{"BatchGet", testBatchGet},
+	{"Deduplicate", testDeduplicate},
+	{"Bulk", testBulk},
+	{"BulkFormDelete", testBulkFormDelete},
+	{"Templates", testTemplates},
+	{"Dependencies", testDependencies},
+	{"StatusBoard", testStatusBoard},
+	{"PrintList", testPrintList},
+	{"Pomodoro", testPomodoro},
+	{"Nearby", testNearby},
+	{"Suggest", testSuggest},
+	{"AIDisabled", testAIDisabled},
+	{"DueDates", testDueDates},
+	{"DueHighlighting", testDueHighlighting},
+	{"Progress", testProgress},
+	{"Snooze", testSnooze},
+	{"MyDay", testMyDay},
+	{"Usage", testUsage},
+	{"Reports", testReports},
+	{"GetTodo", testGetTodo},
+	{"GetTodoMissing", testGetTodoMissing},
+	{"GetTodoInvalidID", testGetTodoInvalidID},
+	{"UpdateTodo", testUpdateTodo},
+	{"UpdateTodoInvalidID", testUpdateTodoInvalidID},
+	{"DeleteJSON", testDeleteJSON},
+	{"DeleteForm", testDeleteForm},
+	{"DeleteConfirmUndo", testDeleteConfirmUndo},
+	{"UpdateForm", testUpdateForm},
+	{"DeleteInvalidID", testDeleteInvalidID},
+	{"ConditionalGet", testConditionalGet},
+	{"AuthLockout", testAuthLockout},
+}
+
+// --- Helpers ---
+
+func createTodo(t *testing.T, h *Harness, title string) todo {
+	t.Helper()
+	resp := h.JSON(http.MethodPost, "/todos", map[string]string{"title": title})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var created todo
+	resp.Decode(t, &created)
+	return created
+}
+
+func listTodos(t *testing.T, h *Harness) []todo {
+	t.Helper()
+	resp := h.JSON(http.MethodGet, "/todos", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var todos []todo
+	resp.Decode(t, &todos)
+	return todos
+}
+
+// flashOf returns the flash message resp left, as a Cookie header value.
+func flashOf(t *testing.T, resp *Response) string {
+	t.Helper()
+	for _, c := range resp.Cookies() {
+		if c.Name == "flash" && c.Value != "" {
+			return c.Name + "=" + c.Value
+		}
+	}
+	t.Fatalf("%s %s left no flash message", resp.Request.Method, resp.Request.URL.Path)
+	return ""
+}
+
+// --- Cases ---
+
+func testHealth(t *testing.T, h *Harness) {
+	resp := h.Do(http.MethodGet, "/health", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	if string(resp.Body) != "OK" {
+		t.Errorf("health body = %q, want OK", resp.Body)
+	}
+}
+
+func testReadyz(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodGet, "/readyz", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+}
+
+func testStatusPage(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodGet, "/status", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var status struct {
+		Status       string `json:"status"`
+		Version      string `json:"version"`
+		Dependencies []struct {
+			Name    string `json:"name"`
+			Current struct {
+				OK bool `json:"ok"`
+			} `json:"current"`
+		} `json:"dependencies"`
+	}
+	resp.Decode(t, &status)
+	if status.Status != "ok" || status.Version == "" || len(status.Dependencies) == 0 || !status.Dependencies[0].Current.OK {
+		t.Fatalf("status = %+v", status)
+	}
+
+	resp = h.Do(http.MethodGet, "/status", nil, http.Header{"Accept": {"text/html"}})
+	ExpectStatus(t, resp, http.StatusOK)
+	if !strings.Contains(string(resp.Body), "All systems operational") {
+		t.Errorf("status page = %s", resp.Body)
+	}
+}
+
+func testVersion(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodGet, "/version", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var v struct {
+		Version   string `json:"version"`
+		Commit    string `json:"commit"`
+		BuildTime string `json:"buildTime"`
+	}
+	resp.Decode(t, &v)
+	if v.Version == "" || v.Commit == "" || v.BuildTime == "" {
+		t.Fatalf("version = %+v", v)
+	}
+	if got := resp.Header.Get("X-App-Version"); got != v.Version {
+		t.Errorf("X-App-Version = %q, want %q", got, v.Version)
+	}
+}
+
+func testHomeEmpty(t *testing.T, h *Harness) {
+	resp := h.Do(http.MethodGet, "/", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	if !strings.Contains(string(resp.Body), "No tasks yet") {
+		t.Errorf("home page missing empty state")
+	}
+}
+
+func testHomeTabs(t *testing.T, h *Harness) {
+	createTodo(t, h, "Still open")
+	done := createTodo(t, h, "Already done")
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+done.ID, map[string]bool{"completed": true}), http.StatusOK)
+
+	for path, want := range map[string][]string{
+		"/":                 {"Still open", "Already done"},
+		"/?state=open":      {"Still open"},
+		"/?state=completed": {"Already done"},
+	} {
+		resp := h.Do(http.MethodGet, path, nil, nil)
+		ExpectStatus(t, resp, http.StatusOK)
+		body := string(resp.Body)
+		if n := strings.Count(body, `class="todo-item"`); n != len(want) {
+			t.Errorf("GET %s lists %d todos, want %v", path, n, want)
+		}
+		for _, title := range want {
+			if !strings.Contains(body, title) {
+				t.Errorf("GET %s doesn't list %q", path, title)
+			}
+		}
+		// Every tab shows the counts of all of them
+		for _, tab := range []string{`All <span class="badge bg-secondary">2</span>`, `Active <span class="badge bg-secondary">1</span>`, `Completed <span class="badge bg-secondary">1</span>`} {
+			if !strings.Contains(body, tab) {
+				t.Errorf("GET %s lacks the tab %s", path, tab)
+			}
+		}
+	}
+	ExpectStatus(t, h.Do(http.MethodGet, "/?state=blocked", nil, nil), http.StatusBadRequest)
+}
+
+func testHomeLoadMore(t *testing.T, h *Harness) {
+	for _, title := range []string{"First", "Second", "Third"} {
+		createTodo(t, h, title)
+	}
+	rows := func(body []byte) int { return strings.Count(string(body), `class="todo-item"`) }
+
+	resp := h.Do(http.MethodGet, "/?limit=2", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	if n := rows(resp.Body); n != 2 || !strings.Contains(string(resp.Body), `hx-get="/?offset=2&limit=2"`) {
+		t.Fatalf("first page has %d rows, want 2 and a load more button: %s", n, resp.Body)
+	}
+
+	// htmx asks for the next page's rows only
+	resp = h.Do(http.MethodGet, "/?offset=2&limit=2", nil, http.Header{"HX-Request": {"true"}})
+	ExpectStatus(t, resp, http.StatusOK)
+	body := string(resp.Body)
+	if rows(resp.Body) != 1 || !strings.Contains(body, "First") || strings.Contains(body, "<html") || strings.Contains(body, "hx-get") {
+		t.Errorf("last page partial = %s", body)
+	}
+
+	// A page ending with the list has nothing more to load
+	resp = h.Do(http.MethodGet, "/?limit=3", nil, nil)
+	if n := rows(resp.Body); n != 3 || strings.Contains(string(resp.Body), "hx-get=\"/?offset=") {
+		t.Errorf("full page has %d rows, want 3 and no load more button", n)
+	}
+
+	ExpectStatus(t, h.Do(http.MethodGet, "/?limit=abc", nil, nil), http.StatusBadRequest)
+}
+
+func testCreateJSON(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Write integration tests")
+	if created.ID == "" || created.Title != "Write integration tests" || created.Completed {
+		t.Fatalf("created todo = %+v", created)
+	}
+	if _, err := time.Parse(time.RFC3339, created.CreatedAt); err != nil {
+		t.Errorf("createdAt %q is not RFC 3339: %v", created.CreatedAt, err)
+	}
+
+	todos := listTodos(t, h)
+	if len(todos) != 1 || todos[0].ID != created.ID {
+		t.Fatalf("list after create = %+v", todos)
+	}
+}
+
+func testCreateJSONInvalid(t *testing.T, h *Harness) {
+	resp := h.Do(http.MethodPost, "/todos", strings.NewReader("{not json"), http.Header{
+		"Content-Type": {"application/json"},
+	})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+
+	resp = h.JSON(http.MethodPost, "/todos", map[string]string{"title": ""})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	resp.Decode(t, &body)
+	if body.Error == "" || body.Code == "" {
+		t.Errorf("error response %q has no error message or code", resp.Body)
+	}
+}
+
+func testCreateDetails(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+		"title":       "Plan release",
+		"description": "Cut the branch and tag it",
+		"tags":        []string{"release", "ops"},
+		"priority":    "high",
+	})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var created todo
+	resp.Decode(t, &created)
+
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var got todo
+	resp.Decode(t, &got)
+	if got.Description != "Cut the branch and tag it" || got.Priority != "high" || strings.Join(got.Tags, ",") != "release,ops" {
+		t.Errorf("GET after create = %+v", got)
+	}
+
+	resp = h.JSON(http.MethodPut, "/todos/"+created.ID, map[string]string{"priority": "urgent"})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+	resp = h.JSON(http.MethodPost, "/todos", map[string]string{"title": "x", "priority": "urgent"})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+}
+
+func testCreateForm(t *testing.T, h *Harness) {
+	resp := h.Form("/todos", url.Values{"title": {"Buy milk"}})
+	ExpectRedirect(t, resp, "/")
+
+	home := h.Do(http.MethodGet, "/", nil, http.Header{"Cookie": {flashOf(t, resp)}})
+	ExpectStatus(t, home, http.StatusOK)
+	if !strings.Contains(string(home.Body), "Buy milk") {
+		t.Errorf("home page does not list the created todo")
+	}
+	if !strings.Contains(string(home.Body), "alert-success") {
+		t.Errorf("home page after a create lacks the confirmation banner")
+	}
+}
+
+func testCreateFormMissingTitle(t *testing.T, h *Harness) {
+	// Browsers are sent back with the error in a banner
+	resp := h.Form("/todos", url.Values{})
+	ExpectRedirect(t, resp, "/")
+	home := h.Do(http.MethodGet, "/", nil, http.Header{"Cookie": {flashOf(t, resp)}})
+	if !strings.Contains(string(home.Body), "alert-danger") || !strings.Contains(string(home.Body), "Title is required") {
+		t.Errorf("home page after a failed create lacks the error banner: %s", home.Body)
+	}
+	if todos := listTodos(t, h); len(todos) != 0 {
+		t.Fatalf("created without a title: %+v", todos)
+	}
+
+	// Other clients posting forms still get the error
+	resp = h.Do(http.MethodPost, "/todos", strings.NewReader(""), http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+}
+
+func testUnicodeTitles(t *testing.T, h *Harness) {
+	const family = "\U0001F468\u200D\U0001F469\u200D\U0001F467" // one grapheme of 5 code points
+
+	// Titles are stored in NFC without invisible padding; emoji sequences
+	// keep their joiners
+	for give, want := range map[string]string{
+		"Cafe\u0301 run":               "Caf\u00e9 run",
+		"\u200B\uFEFF Party " + family: "Party " + family,
+		"שלום עולם":                    "שלום עולם",
+	} {
+		if got := createTodo(t, h, give).Title; got != want {
+			t.Errorf("title %q stored as %q, want %q", give, got, want)
+		}
+	}
+
+	// Invisible characters alone don't make a title
+	for _, blank := range []string{"\u200B", " \u2060 ", "\uFEFF"} {
+		ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]string{"title": blank}), http.StatusBadRequest)
+	}
+
+	// Length counts what users see, not bytes
+	createTodo(t, h, strings.Repeat(family, 500))
+	resp := h.JSON(http.MethodPost, "/todos", map[string]string{"title": strings.Repeat(family, 501)})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+	var body struct {
+		Fields map[string]string `json:"fields"`
+	}
+	resp.Decode(t, &body)
+	if body.Fields["title"] == "" {
+		t.Errorf("too long title rejected without naming the field: %s", resp.Body)
+	}
+
+	// Searches match whichever way the accent was typed
+	for _, q := range []string{"caf\u00e9", "cafe\u0301", "\u05E9\u05DC\u05D5\u05DD"} {
+		resp := h.JSON(http.MethodGet, "/todos/suggest?q="+url.QueryEscape(q), nil)
+		ExpectStatus(t, resp, http.StatusOK)
+		var matches []todo
+		resp.Decode(t, &matches)
+		if len(matches) != 1 {
+			t.Errorf("suggestions for %q = %+v", q, matches)
+		}
+	}
+
+	// Pages render them escaped, with right-to-left titles isolated
+	home := h.Do(http.MethodGet, "/", nil, nil)
+	ExpectStatus(t, home, http.StatusOK)
+	for _, want := range []string{"Party " + family, `dir="auto">שלום עולם</a>`} {
+		if !strings.Contains(string(home.Body), want) {
+			t.Errorf("home page lacks %q", want)
+		}
+	}
+}
+
+func testListNewestFirst(t *testing.T, h *Harness) {
+	first := createTodo(t, h, "first")
+	second := createTodo(t, h, "second")
+
+	todos := listTodos(t, h)
+	if len(todos) != 2 {
+		t.Fatalf("list returned %d todos, want 2", len(todos))
+	}
+	if todos[0].ID != second.ID || todos[1].ID != first.ID {
+		t.Errorf("list order = [%s %s], want newest first", todos[0].Title, todos[1].Title)
+	}
+}
+
+func testListPage(t *testing.T, h *Harness) {
+	for _, title := range []string{"a", "b", "c"} {
+		createTodo(t, h, title)
+	}
+
+	resp := h.JSON(http.MethodGet, "/todos?offset=1&limit=1", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var page []todo
+	resp.Decode(t, &page)
+	if len(page) != 1 || page[0].Title != "b" {
+		t.Fatalf("page 2 = %+v, want [b]", page)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos?limit=-1", nil), http.StatusBadRequest)
+}
+
+func testListCounts(t *testing.T, h *Harness) {
+	for _, tags := range [][]string{{"work"}, {"work", "home"}, nil} {
+		resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": "t", "tags": tags})
+		ExpectStatus(t, resp, http.StatusCreated)
+	}
+	listTodos(t, h) // cache the counts
+	done := createTodo(t, h, "done")
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+done.ID, map[string]bool{"completed": true}), http.StatusOK)
+
+	resp := h.JSON(http.MethodGet, "/todos", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	for header, want := range map[string]string{
+		"X-Total-Count":     "4",
+		"X-Open-Count":      "3",
+		"X-Completed-Count": "1",
+		"X-Tag-Counts":      "home=1&work=2",
+	} {
+		if got := resp.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func testHALEnvelope(t *testing.T, h *Harness) {
+	for _, title := range []string{"a", "b", "c"} {
+		createTodo(t, h, title)
+	}
+
+	resp := h.Do(http.MethodGet, "/todos?offset=1&limit=1", nil, http.Header{"Accept": {"application/hal+json"}})
+	ExpectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/hal+json" {
+		t.Errorf("Content-Type = %q, want application/hal+json", ct)
+	}
+	type link struct {
+		Href   string `json:"href"`
+		Method string `json:"method"`
+	}
+	var list struct {
+		Links    map[string]link `json:"_links"`
+		Embedded struct {
+			Todos []struct {
+				todo
+				Links map[string]link `json:"_links"`
+			} `json:"todos"`
+		} `json:"_embedded"`
+	}
+	resp.Decode(t, &list)
+	if len(list.Embedded.Todos) != 1 || list.Embedded.Todos[0].Title != "b" {
+		t.Fatalf("embedded todos = %+v, want [b]", list.Embedded.Todos)
+	}
+	item := list.Embedded.Todos[0]
+	if item.Links["self"].Href != "/todos/"+item.ID || item.Links["delete"].Method != http.MethodDelete {
+		t.Errorf("item links = %+v", item.Links)
+	}
+	if list.Links["next"].Href == "" || list.Links["prev"].Href == "" {
+		t.Errorf("list links = %+v, want next and prev", list.Links)
+	}
+
+	// The next link leads to the last page, which has no next
+	resp = h.Do(http.MethodGet, list.Links["next"].Href, nil, http.Header{"Accept": {"application/hal+json"}})
+	ExpectStatus(t, resp, http.StatusOK)
+	list.Links = nil
+	resp.Decode(t, &list)
+	if len(list.Embedded.Todos) != 1 || list.Embedded.Todos[0].Title != "a" {
+		t.Fatalf("next page = %+v, want [a]", list.Embedded.Todos)
+	}
+	if _, ok := list.Links["next"]; ok {
+		t.Errorf("last page has a next link")
+	}
+
+	// ?envelope=true works for single todos too
+	resp = h.JSON(http.MethodGet, "/todos/"+item.ID+"?envelope=true", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var single struct {
+		todo
+		Links map[string]link `json:"_links"`
+	}
+	resp.Decode(t, &single)
+	if single.ID != item.ID || single.Links["update"].Method != http.MethodPut {
+		t.Errorf("enveloped todo = %+v", single)
+	}
+}
+
+func testJSONAPI(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Render me")
+	accept := http.Header{"Accept": {"application/vnd.api+json"}}
+
+	resp := h.Do(http.MethodGet, "/todos?fields[todos]=title", nil, accept)
+	ExpectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/vnd.api+json" {
+		t.Errorf("Content-Type = %q, want application/vnd.api+json", ct)
+	}
+	var list struct {
+		Data []struct {
+			Type       string                 `json:"type"`
+			ID         string                 `json:"id"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"data"`
+	}
+	resp.Decode(t, &list)
+	if len(list.Data) != 1 || list.Data[0].Type != "todos" || list.Data[0].ID != created.ID {
+		t.Fatalf("data = %+v", list.Data)
+	}
+	if attrs := list.Data[0].Attributes; attrs["title"] != "Render me" || len(attrs) != 1 {
+		t.Errorf("sparse fieldset attributes = %v, want only title", attrs)
+	}
+
+	resp = h.Do(http.MethodGet, "/todos/not-an-id", nil, accept)
+	ExpectStatus(t, resp, http.StatusBadRequest)
+	var errs struct {
+		Errors []struct {
+			Status string `json:"status"`
+			Title  string `json:"title"`
+		} `json:"errors"`
+	}
+	resp.Decode(t, &errs)
+	if len(errs.Errors) != 1 || errs.Errors[0].Status != "400" || errs.Errors[0].Title == "" {
+		t.Errorf("error document = %+v", errs)
+	}
+}
+
+func testBatchGet(t *testing.T, h *Harness) {
+	a := createTodo(t, h, "a")
+	b := createTodo(t, h, "b")
+	createTodo(t, h, "c")
+	h.JSON(http.MethodGet, "/todos/"+b.ID, nil) // warm one item cache entry
+	missing := "000000000000000000000000"
+
+	ids := []string{b.ID, missing, a.ID}
+	for _, resp := range []*Response{
+		h.JSON(http.MethodGet, "/todos?ids="+strings.Join(ids, ","), nil),
+		h.JSON(http.MethodPost, "/todos/batch-get", map[string][]string{"ids": ids}),
+	} {
+		ExpectStatus(t, resp, http.StatusOK)
+		var got []todo
+		resp.Decode(t, &got)
+		if len(got) != 2 || got[0].ID != b.ID || got[1].ID != a.ID {
+			t.Errorf("%s %s = %+v, want [b a]", resp.Request.Method, resp.Request.URL.Path, got)
+		}
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos?ids="+a.ID+",nope", nil), http.StatusBadRequest)
+}
+
+func testDeduplicate(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": "Buy milk", "tags": []string{"home"}})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var original todo
+	resp.Decode(t, &original)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+		"title":       "buy  MILK ",
+		"description": "Oat, not dairy",
+		"tags":        []string{"errands", "home"},
+		"priority":    "high",
+	}), http.StatusCreated)
+	createTodo(t, h, "Something else")
+
+	var result struct {
+		Groups []struct {
+			Kept    string   `json:"kept"`
+			Removed []string `json:"removed"`
+			Merged  []string `json:"merged"`
+		} `json:"groups"`
+		Removed int `json:"removed"`
+	}
+	resp = h.JSON(http.MethodPost, "/todos/deduplicate?dryRun=true", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	resp.Decode(t, &result)
+	if result.Removed != 1 || len(result.Groups) != 1 || result.Groups[0].Kept != original.ID {
+		t.Fatalf("dry run = %+v, want one duplicate of %s", result, original.ID)
+	}
+	if merged := strings.Join(result.Groups[0].Merged, ","); merged != "description,priority,tags" {
+		t.Errorf("dry run merges %q, want description,priority,tags", merged)
+	}
+	if todos := listTodos(t, h); len(todos) != 3 {
+		t.Fatalf("dry run removed todos: %+v", todos)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/deduplicate", nil), http.StatusOK)
+	todos := listTodos(t, h)
+	if len(todos) != 2 {
+		t.Fatalf("list after deduplicate = %+v", todos)
+	}
+	for _, todo := range todos {
+		if todo.Title == "buy  MILK" {
+			t.Errorf("newer duplicate survived: %+v", todos)
+		}
+	}
+	resp = h.JSON(http.MethodGet, "/todos/"+original.ID, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var kept todo
+	resp.Decode(t, &kept)
+	if kept.Description != "Oat, not dairy" || kept.Priority != "high" || strings.Join(kept.Tags, ",") != "home,errands" {
+		t.Errorf("kept todo after merging = %+v", kept)
+	}
+}
+
+func testBulk(t *testing.T, h *Harness) {
+	milk := createTodo(t, h, "Buy milk")
+	dog := createTodo(t, h, "Walk the dog")
+	keep := createTodo(t, h, "Keep me")
+	missing := "000000000000000000000000"
+
+	var result struct {
+		Done    []string          `json:"done"`
+		Skipped map[string]string `json:"skipped"`
+	}
+	resp := h.JSON(http.MethodPost, "/todos/bulk", map[string]interface{}{
+		"action": "complete", "ids": []string{milk.ID, dog.ID, missing},
+	})
+	ExpectStatus(t, resp, http.StatusOK)
+	resp.Decode(t, &result)
+	if len(result.Done) != 2 || result.Skipped[missing] == "" {
+		t.Fatalf("bulk complete = %+v, want two done and %s skipped", result, missing)
+	}
+	for _, todo := range listTodos(t, h) {
+		if todo.Completed != (todo.ID != keep.ID) {
+			t.Errorf("after bulk complete: %+v", todo)
+		}
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/bulk", map[string]interface{}{
+		"action": "color", "ids": []string{milk.ID}, "color": "Red",
+	}), http.StatusOK)
+	resp = h.JSON(http.MethodGet, "/todos/"+milk.ID, nil)
+	var labeled struct {
+		Color string `json:"color"`
+	}
+	resp.Decode(t, &labeled)
+	if labeled.Color != "red" {
+		t.Errorf("color after bulk label = %q, want red", labeled.Color)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/bulk", map[string]interface{}{
+		"action": "delete", "ids": []string{milk.ID, dog.ID},
+	}), http.StatusOK)
+	if todos := listTodos(t, h); len(todos) != 1 || todos[0].ID != keep.ID {
+		t.Fatalf("list after bulk delete = %+v", todos)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/bulk", map[string]interface{}{"action": "archive", "ids": []string{keep.ID}}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/bulk", map[string]interface{}{"action": "delete"}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/bulk", map[string]interface{}{"action": "color", "ids": []string{keep.ID}, "color": "mauve"}), http.StatusBadRequest)
+}
+
+func testBulkFormDelete(t *testing.T, h *Harness) {
+	milk := createTodo(t, h, "Buy milk")
+	createTodo(t, h, "Keep me")
+
+	// Deleting asks for confirmation first
+	form := url.Values{"action": {"delete"}, "id": {milk.ID}}
+	resp := h.Form("/todos/bulk/form", form)
+	ExpectStatus(t, resp, http.StatusOK)
+	if !strings.Contains(string(resp.Body), "Buy milk") || !strings.Contains(string(resp.Body), `name="confirm"`) {
+		t.Fatalf("confirmation page = %s", resp.Body)
+	}
+	if todos := listTodos(t, h); len(todos) != 2 {
+		t.Fatalf("deleted before confirming: %+v", todos)
+	}
+
+	form.Set("confirm", "true")
+	ExpectRedirect(t, h.Form("/todos/bulk/form", form), "/")
+	if todos := listTodos(t, h); len(todos) != 1 || todos[0].Title != "Keep me" {
+		t.Fatalf("list after confirmed delete = %+v", todos)
+	}
+}
+
+func testTemplates(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodPost, "/templates", map[string]interface{}{
+		"name":     "Release checklist",
+		"title":    "Release v-next",
+		"tags":     []string{"release"},
+		"priority": "high",
+	})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var tpl struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	resp.Decode(t, &tpl)
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/templates", map[string]string{"title": "no name"}), http.StatusBadRequest)
+
+	resp = h.JSON(http.MethodGet, "/templates", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var templates []struct {
+		ID string `json:"id"`
+	}
+	resp.Decode(t, &templates)
+	if len(templates) != 1 || templates[0].ID != tpl.ID {
+		t.Fatalf("templates = %+v", templates)
+	}
+
+	resp = h.JSON(http.MethodPost, "/todos/from-template/"+tpl.ID, nil)
+	ExpectStatus(t, resp, http.StatusCreated)
+	var created todo
+	resp.Decode(t, &created)
+	if created.Title != "Release v-next" || created.Priority != "high" || len(created.Tags) != 1 {
+		t.Errorf("todo from template = %+v", created)
+	}
+
+	// The home page offers the template and its form redirects back home
+	home := h.Do(http.MethodGet, "/", nil, nil)
+	if !strings.Contains(string(home.Body), "/todos/from-template/"+tpl.ID) {
+		t.Errorf("home page does not offer the template")
+	}
+	ExpectRedirect(t, h.Form("/todos/from-template/"+tpl.ID, url.Values{}), "/")
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/from-template/000000000000000000000000", nil), http.StatusNotFound)
+	ExpectStatus(t, h.JSON(http.MethodDelete, "/templates/"+tpl.ID, nil), http.StatusOK)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/templates/"+tpl.ID, nil), http.StatusNotFound)
+}
+
+func testDependencies(t *testing.T, h *Harness) {
+	blocker := createTodo(t, h, "Pour foundation")
+	resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+		"title":     "Build walls",
+		"blockedBy": []string{blocker.ID},
+	})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var blocked todo
+	resp.Decode(t, &blocked)
+	if len(blocked.BlockedBy) != 1 || blocked.BlockedBy[0] != blocker.ID {
+		t.Fatalf("created = %+v, want blocked by %s", blocked, blocker.ID)
+	}
+
+	resp = h.JSON(http.MethodGet, "/todos?state=blocked", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var list []todo
+	resp.Decode(t, &list)
+	if len(list) != 1 || list[0].ID != blocked.ID {
+		t.Fatalf("blocked todos = %+v, want only %s", list, blocked.ID)
+	}
+
+	// Unknown blockers, self-references and cycles are refused
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+		"title":     "Orphan",
+		"blockedBy": []string{"000000000000000000000000"},
+	}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+blocked.ID, map[string]interface{}{
+		"blockedBy": []string{blocked.ID},
+	}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+blocker.ID, map[string]interface{}{
+		"blockedBy": []string{blocked.ID},
+	}), http.StatusConflict)
+
+	// A blocked todo can't be completed until its blocker is
+	done := map[string]bool{"completed": true}
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+blocked.ID, done), http.StatusConflict)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+blocker.ID, done), http.StatusOK)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+blocked.ID, done), http.StatusOK)
+
+	resp = h.JSON(http.MethodGet, "/todos?state=blocked", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	list = nil
+	resp.Decode(t, &list)
+	if len(list) != 0 {
+		t.Errorf("blocked todos after completing = %+v, want none", list)
+	}
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos?state=stuck", nil), http.StatusBadRequest)
+}
+
+func testStatusBoard(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Write report")
+	if created.Status != "todo" {
+		t.Fatalf("new todo status = %q, want todo", created.Status)
+	}
+
+	move := func(status string) *Response {
+		return h.JSON(http.MethodPost, "/todos/"+created.ID+"/status", map[string]string{"status": status})
+	}
+	resp := move("in-progress")
+	ExpectStatus(t, resp, http.StatusOK)
+	var got todo
+	resp.Decode(t, &got)
+	if got.Status != "in-progress" || got.Completed {
+		t.Fatalf("after move = %+v, want in-progress and still open", got)
+	}
+	ExpectStatus(t, move("done"), http.StatusOK)
+	ExpectStatus(t, move("done"), http.StatusOK) // no-op
+	ExpectStatus(t, move("todo"), http.StatusConflict)
+	ExpectStatus(t, move("someday"), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/000000000000000000000000/status", map[string]string{"status": "done"}), http.StatusNotFound)
+
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	resp.Decode(t, &got)
+	if got.Status != "done" {
+		t.Errorf("status after moves = %q, want done", got.Status)
+	}
+
+	board := h.Do(http.MethodGet, "/board", nil, nil)
+	ExpectStatus(t, board, http.StatusOK)
+	if !strings.Contains(string(board.Body), `data-id="`+created.ID+`"`) {
+		t.Errorf("board does not show the todo")
+	}
+}
+
+func testPrintList(t *testing.T, h *Harness) {
+	for _, body := range []map[string]interface{}{
+		{"title": "Someday", "priority": "low"},
+		{"title": "Urgent", "priority": "high", "color": "red"},
+		{"title": "Unsorted"},
+	} {
+		ExpectStatus(t, h.JSON(http.MethodPost, "/todos", body), http.StatusCreated)
+	}
+	done := createTodo(t, h, "Already done")
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+done.ID, map[string]interface{}{"completed": true}), http.StatusOK)
+
+	resp := h.Do(http.MethodGet, "/lists/all/print", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	body := string(resp.Body)
+	urgent, someday, unsorted := strings.Index(body, "Urgent"), strings.Index(body, "Someday"), strings.Index(body, "Unsorted")
+	if urgent < 0 || someday < urgent || unsorted < someday || strings.Contains(body, "Already done") {
+		t.Fatalf("print view not open todos by priority: %s", body)
+	}
+
+	// Pages carry on in the same order
+	resp = h.Do(http.MethodGet, "/lists/all/print?offset=1&limit=1", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	if body := string(resp.Body); !strings.Contains(body, "Someday") || strings.Contains(body, "Urgent") {
+		t.Errorf("second page = %s", body)
+	}
+
+	resp = h.Do(http.MethodGet, "/lists/red/print", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	if body := string(resp.Body); !strings.Contains(body, "Urgent") || strings.Contains(body, "Someday") {
+		t.Errorf("red list = %s", body)
+	}
+
+	ExpectStatus(t, h.Do(http.MethodGet, "/lists/chores/print", nil, nil), http.StatusNotFound)
+	ExpectStatus(t, h.Do(http.MethodGet, "/lists/all/print?group=tags", nil, nil), http.StatusBadRequest)
+}
+
+func testPomodoro(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Deep work")
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos/"+created.ID+"/pomodoro/events", nil), http.StatusNotFound)
+
+	resp := h.JSON(http.MethodPost, "/todos/"+created.ID+"/pomodoro", nil)
+	ExpectStatus(t, resp, http.StatusCreated)
+	var session struct {
+		TodoID           string `json:"todoId"`
+		RemainingSeconds int    `json:"remainingSeconds"`
+	}
+	resp.Decode(t, &session)
+	if session.TodoID != created.ID || session.RemainingSeconds <= 0 {
+		t.Fatalf("session = %+v", session)
+	}
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/pomodoro", nil), http.StatusConflict)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/000000000000000000000000/pomodoro", nil), http.StatusNotFound)
+
+	resp = h.JSON(http.MethodGet, "/stats", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var stats struct {
+		Todos     int `json:"todos"`
+		Open      int `json:"open"`
+		Pomodoros int `json:"pomodoros"`
+	}
+	resp.Decode(t, &stats)
+	if stats.Todos != 1 || stats.Open != 1 || stats.Pomodoros != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func testNearby(t *testing.T, h *Harness) {
+	at := func(title string, lat, lng float64) todo {
+		resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+			"title":    title,
+			"location": map[string]interface{}{"lat": lat, "lng": lng, "label": title},
+		})
+		ExpectStatus(t, resp, http.StatusCreated)
+		var created todo
+		resp.Decode(t, &created)
+		return created
+	}
+	milk := at("Buy milk", 47.6101, -122.2015)
+	at("Pick up parcel", 47.6150, -122.2000)
+	at("Visit museum", 48.8606, 2.3376)
+	createTodo(t, h, "Anywhere")
+
+	resp := h.JSON(http.MethodGet, "/todos/nearby?lat=47.6100&lng=-122.2010&radius=2000", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var nearby []struct {
+		ID             string  `json:"id"`
+		DistanceMeters float64 `json:"distanceMeters"`
+		Location       struct {
+			Label string `json:"label"`
+		} `json:"location"`
+	}
+	resp.Decode(t, &nearby)
+	if len(nearby) != 2 || nearby[0].ID != milk.ID || nearby[0].Location.Label != "Buy milk" {
+		t.Fatalf("nearby = %+v, want Buy milk first of two", nearby)
+	}
+	if nearby[0].DistanceMeters > nearby[1].DistanceMeters {
+		t.Errorf("nearby not ordered by distance: %+v", nearby)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos/nearby?lat=91&lng=0", nil), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+		"title":    "Nowhere",
+		"location": map[string]interface{}{"lat": 10},
+	}), http.StatusBadRequest)
+
+	// An empty location removes it
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+milk.ID, map[string]interface{}{"location": map[string]interface{}{}}), http.StatusOK)
+	resp = h.JSON(http.MethodGet, "/todos/nearby?lat=47.6100&lng=-122.2010&radius=2000", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	nearby = nil
+	resp.Decode(t, &nearby)
+	if len(nearby) != 1 {
+		t.Errorf("nearby after removing a location = %+v", nearby)
+	}
+}
+
+func testSuggest(t *testing.T, h *Harness) {
+	cow := createTodo(t, h, "Milk the cow")
+	// Newer, so listed first, but only a later word matches
+	createTodo(t, h, "Buy milk")
+	createTodo(t, h, "Walk the dog")
+
+	resp := h.JSON(http.MethodGet, "/todos/suggest?q=MIL", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var matches []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	resp.Decode(t, &matches)
+	if len(matches) != 2 || matches[0].ID != cow.ID {
+		t.Fatalf("suggestions = %+v, want Milk the cow then Buy milk", matches)
+	}
+
+	resp = h.JSON(http.MethodGet, "/todos/suggest?q=mil&limit=1", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	matches = nil
+	resp.Decode(t, &matches)
+	if len(matches) != 1 || matches[0].ID != cow.ID {
+		t.Errorf("suggestions with limit=1 = %+v, want Milk the cow", matches)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos/suggest?q=mil&limit=x", nil), http.StatusBadRequest)
+}
+
+func testAIDisabled(t *testing.T, h *Harness) {
+	// Handlers under test run without Azure AI services; CRUD must not care
+	created := createTodo(t, h, "Plan the offsite")
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/breakdown", nil), http.StatusNotFound)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos/suggestions", nil), http.StatusNotFound)
+	ExpectStatus(t, h.Do(http.MethodPost, "/todos/voice", strings.NewReader("RIFF"), http.Header{"Content-Type": {"audio/wav"}}), http.StatusNotFound)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos/"+created.ID, nil), http.StatusOK)
+}
+
+func testDueDates(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{
+		"title":           "File taxes",
+		"dueAt":           "2030-04-15T17:00:00+02:00",
+		"estimateMinutes": 90,
+	})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var created struct {
+		ID       string `json:"id"`
+		DueAt    string `json:"dueAt"`
+		Estimate int    `json:"estimateMinutes"`
+	}
+	resp.Decode(t, &created)
+	if created.DueAt != "2030-04-15T15:00:00Z" || created.Estimate != 90 {
+		t.Fatalf("created = %+v, want due 2030-04-15T15:00:00Z taking 90 minutes", created)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": "Bad", "dueAt": "tomorrow"}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+created.ID, map[string]interface{}{"estimateMinutes": -5}), http.StatusBadRequest)
+
+	// An empty dueAt removes it
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+created.ID, map[string]interface{}{"dueAt": ""}), http.StatusOK)
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	created.DueAt = ""
+	resp.Decode(t, &created)
+	if created.DueAt != "" || created.Estimate != 90 {
+		t.Errorf("after removing the due date = %+v", created)
+	}
+
+	// Scheduling needs Microsoft Graph, which handlers under test lack
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/schedule", nil), http.StatusNotFound)
+}
+
+func testProgress(t *testing.T, h *Harness) {
+	create := func(title string, estimate, progress int) string {
+		resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": title, "estimateMinutes": estimate, "progressPercent": progress})
+		ExpectStatus(t, resp, http.StatusCreated)
+		var created todo
+		resp.Decode(t, &created)
+		return created.ID
+	}
+	quick := create("Quick", 15, 80)
+	long := create("Long", 240, 10)
+	create("Unestimated", 0, 50)
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": "Bad", "progressPercent": 101}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+long, map[string]interface{}{"progressPercent": -1}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos?sort=title", nil), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos?minProgress=200", nil), http.StatusBadRequest)
+
+	for query, want := range map[string][]string{
+		"sort=estimateMinutes":                {"Quick", "Long", "Unestimated"},
+		"sort=-estimateMinutes":               {"Long", "Quick", "Unestimated"},
+		"sort=-progressPercent":               {"Quick", "Unestimated", "Long"},
+		"maxEstimate=60":                      {"Quick"},
+		"minProgress=50&sort=progressPercent": {"Unestimated", "Quick"},
+	} {
+		resp := h.JSON(http.MethodGet, "/todos?"+query, nil)
+		ExpectStatus(t, resp, http.StatusOK)
+		var todos []todo
+		resp.Decode(t, &todos)
+		var got []string
+		for _, todo := range todos {
+			got = append(got, todo.Title)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("GET /todos?%s = %v, want %v", query, got, want)
+		}
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+quick, map[string]interface{}{"progressPercent": 100}), http.StatusOK)
+	resp := h.JSON(http.MethodGet, "/todos/"+quick, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var got struct {
+		Progress int `json:"progressPercent"`
+	}
+	resp.Decode(t, &got)
+	if got.Progress != 100 {
+		t.Errorf("progressPercent = %d, want 100", got.Progress)
+	}
+
+	page := h.Do(http.MethodGet, "/todos/"+quick+"/view", nil, nil)
+	ExpectStatus(t, page, http.StatusOK)
+	if !strings.Contains(string(page.Body), `aria-valuenow="100"`) {
+		t.Errorf("detail page lacks the progress bar")
+	}
+}
+
+func testDueHighlighting(t *testing.T, h *Harness) {
+	now := time.Now()
+	create := func(title string, due time.Time) todo {
+		resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": title, "dueAt": due.Format(time.RFC3339)})
+		ExpectStatus(t, resp, http.StatusCreated)
+		var created todo
+		resp.Decode(t, &created)
+		return created
+	}
+	late := create("Late", now.Add(-3*time.Hour-time.Minute))
+	create("Later", now.Add(72*time.Hour+time.Minute))
+	done := create("Done late", now.Add(-time.Hour))
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+done.ID, map[string]bool{"completed": true}), http.StatusOK)
+
+	resp := h.Do(http.MethodGet, "/", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	body := string(resp.Body)
+	if !strings.Contains(body, `<span class="badge bg-danger" title="Due `) || !strings.Contains(body, ">overdue by 3 hours<") {
+		t.Errorf("home page doesn't show the overdue todo in red: %s", body)
+	}
+	if !strings.Contains(body, ">due in 3 days<") {
+		t.Errorf("home page doesn't say when the later todo is due")
+	}
+	if strings.Count(body, "overdue by") != 1 {
+		t.Errorf("completed todos are highlighted as overdue")
+	}
+
+	// Dates are shown and entered in the browser's zone
+	tokyo := http.Header{"Cookie": {"tz=Asia/Tokyo"}}
+	resp = h.Do(http.MethodGet, "/todos/"+late.ID+"/view", nil, tokyo)
+	ExpectStatus(t, resp, http.StatusOK)
+	if !strings.Contains(string(resp.Body), " JST ") {
+		t.Errorf("detail page doesn't show the due date in the client's zone: %s", resp.Body)
+	}
+	form := url.Values{"dueDate": {"2030-04-15"}}
+	tokyo.Set("Content-Type", "application/x-www-form-urlencoded")
+	ExpectStatus(t, h.Do(http.MethodPost, "/todos/"+late.ID+"/update", strings.NewReader(form.Encode()), tokyo), http.StatusSeeOther)
+	resp = h.JSON(http.MethodGet, "/todos/"+late.ID, nil)
+	var got struct {
+		DueAt string `json:"dueAt"`
+	}
+	resp.Decode(t, &got)
+	if got.DueAt != "2030-04-14T15:00:00Z" {
+		t.Errorf("due date entered in Tokyo = %s, want 2030-04-14T15:00:00Z", got.DueAt)
+	}
+}
+
+func testSnooze(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodPost, "/todos", map[string]string{"title": "Call back"})
+	ExpectStatus(t, resp, http.StatusCreated)
+	var created todo
+	resp.Decode(t, &created)
+
+	before := time.Now()
+	resp = h.JSON(http.MethodPost, "/todos/"+created.ID+"/snooze", map[string]string{"until": "1h"})
+	ExpectStatus(t, resp, http.StatusOK)
+	var snoozed struct {
+		DueAt   string `json:"dueAt"`
+		Snoozes int    `json:"snoozes"`
+	}
+	resp.Decode(t, &snoozed)
+	due, err := time.Parse(time.RFC3339, snoozed.DueAt)
+	if err != nil || due.Before(before.Add(time.Hour-time.Second)) || due.After(time.Now().Add(time.Hour)) || snoozed.Snoozes != 1 {
+		t.Errorf("snoozed for an hour = %+v", snoozed)
+	}
+
+	// Other days start in the morning of the client's zone
+	form := strings.NewReader(url.Values{"until": {"next-week"}}.Encode())
+	resp = h.Do(http.MethodPost, "/todos/"+created.ID+"/snooze", form, http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Cookie":       {"tz=Asia/Tokyo"},
+	})
+	ExpectStatus(t, resp, http.StatusSeeOther)
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	resp.Decode(t, &snoozed)
+	due, _ = time.Parse(time.RFC3339, snoozed.DueAt)
+	if due = due.In(time.FixedZone("JST", 9*60*60)); due.Weekday() != time.Monday || due.Hour() != 9 || snoozed.Snoozes != 2 {
+		t.Errorf("snoozed to next week = %s (%s), %d snoozes", due, due.Weekday(), snoozed.Snoozes)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/snooze", map[string]string{"until": "forever"}), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/000000000000000000000000/snooze", map[string]string{"until": "1h"}), http.StatusNotFound)
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+created.ID, map[string]bool{"completed": true}), http.StatusOK)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/snooze", map[string]string{"until": "1h"}), http.StatusConflict)
+
+	resp = h.JSON(http.MethodGet, "/stats", nil)
+	var stats struct {
+		Snoozes int `json:"snoozes"`
+	}
+	resp.Decode(t, &stats)
+	if stats.Snoozes != 2 {
+		t.Errorf("stats snoozes = %d, want 2", stats.Snoozes)
+	}
+}
+
+func testMyDay(t *testing.T, h *Harness) {
+	create := func(body map[string]interface{}) string {
+		resp := h.JSON(http.MethodPost, "/todos", body)
+		ExpectStatus(t, resp, http.StatusCreated)
+		var created todo
+		resp.Decode(t, &created)
+		return created.ID
+	}
+	now := time.Now().UTC()
+	late := create(map[string]interface{}{"title": "Late", "dueAt": now.Add(-time.Hour).Format(time.RFC3339)})
+	done := create(map[string]interface{}{"title": "Done late", "dueAt": now.Add(-time.Hour).Format(time.RFC3339)})
+	ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+done, map[string]bool{"completed": true}), http.StatusOK)
+	planned := create(map[string]interface{}{"title": "Planned"})
+	create(map[string]interface{}{"title": "Someday"})
+	pinned := create(map[string]interface{}{"title": "Pinned"})
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+pinned+"/pin", nil), http.StatusOK)
+
+	resp := h.JSON(http.MethodPost, "/todos/"+planned+"/add-to-today", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var added struct {
+		MyDay bool `json:"myDay"`
+	}
+	resp.Decode(t, &added)
+	if !added.MyDay {
+		t.Errorf("add-to-today didn't set myDay")
+	}
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/000000000000000000000000/add-to-today", nil), http.StatusNotFound)
+
+	type day struct {
+		Date     string `json:"date"`
+		Overdue  []todo `json:"overdue"`
+		DueToday []todo `json:"dueToday"`
+		Planned  []todo `json:"planned"`
+	}
+	titles := func(todos []todo) []string {
+		out := []string{}
+		for _, t := range todos {
+			out = append(out, t.Title)
+		}
+		sort.Strings(out)
+		return out
+	}
+	resp = h.JSON(http.MethodGet, "/today", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var got day
+	resp.Decode(t, &got)
+	if got.Date != now.Format("2006-01-02") || len(got.Overdue) != 1 || got.Overdue[0].ID != late || len(got.DueToday) != 0 {
+		t.Errorf("today = %+v, want Late overdue", got)
+	}
+	if p := titles(got.Planned); len(p) != 2 || p[0] != "Pinned" || p[1] != "Planned" {
+		t.Errorf("planned = %v, want Pinned and Planned", p)
+	}
+
+	resp = h.Do(http.MethodGet, "/today", nil, http.Header{"Accept": {"text/html"}})
+	ExpectStatus(t, resp, http.StatusOK)
+	if body := string(resp.Body); !strings.Contains(body, ">Overdue<") || !strings.Contains(body, "Planned</bdi>") {
+		t.Errorf("My Day page misses its sections: %s", body)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+planned+"/add-to-today", map[string]bool{"myDay": false}), http.StatusOK)
+	resp = h.JSON(http.MethodGet, "/today", nil)
+	resp.Decode(t, &got)
+	if p := titles(got.Planned); len(p) != 1 || p[0] != "Pinned" {
+		t.Errorf("planned after removing = %v, want Pinned", p)
+	}
+}
+
+func testUsage(t *testing.T, h *Harness) {
+	for _, title := range []string{"a", "b"} {
+		ExpectStatus(t, h.JSON(http.MethodPost, "/todos", map[string]string{"title": title}), http.StatusCreated)
+	}
+	resp := h.JSON(http.MethodGet, "/usage", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var usage struct {
+		Todos       struct{ Used int } `json:"todos"`
+		TodosPerDay struct{ Used int } `json:"todosPerDay"`
+	}
+	resp.Decode(t, &usage)
+	if usage.Todos.Used != 2 {
+		t.Errorf("todos used = %d, want 2", usage.Todos.Used)
+	}
+}
+
+func testReports(t *testing.T, h *Harness) {
+	for _, tags := range [][]string{{"work"}, {"work", "home"}} {
+		resp := h.JSON(http.MethodPost, "/todos", map[string]interface{}{"title": "t", "tags": tags})
+		ExpectStatus(t, resp, http.StatusCreated)
+		var created todo
+		resp.Decode(t, &created)
+		ExpectStatus(t, h.JSON(http.MethodPut, "/todos/"+created.ID, map[string]bool{"completed": true}), http.StatusOK)
+	}
+
+	resp := h.JSON(http.MethodGet, "/reports/completions?group=week", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var completions struct {
+		Total   int `json:"total"`
+		Periods []struct {
+			Period    string `json:"period"`
+			Completed int    `json:"completed"`
+		} `json:"periods"`
+	}
+	resp.Decode(t, &completions)
+	if n := len(completions.Periods); completions.Total != 2 || n == 0 || completions.Periods[n-1].Completed != 2 {
+		t.Errorf("completions = %+v, want 2 this week", completions)
+	}
+
+	resp = h.JSON(http.MethodGet, "/reports/by-tag", nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var byTag struct {
+		Tags []struct {
+			Tag       string `json:"tag"`
+			Total     int    `json:"total"`
+			Completed int    `json:"completed"`
+			Open      int    `json:"open"`
+		} `json:"tags"`
+	}
+	resp.Decode(t, &byTag)
+	if len(byTag.Tags) != 2 || byTag.Tags[0].Tag != "work" || byTag.Tags[0].Completed != 2 || byTag.Tags[0].Open != 0 {
+		t.Errorf("by-tag = %+v, want work first with 2 completed", byTag.Tags)
+	}
+
+	ExpectStatus(t, h.JSON(http.MethodGet, "/reports/completions?group=month", nil), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/reports/completions?from=2026-02-01&to=2026-01-01", nil), http.StatusBadRequest)
+	ExpectStatus(t, h.JSON(http.MethodGet, "/reports/completions?from=2020-01-01&to=2026-01-01", nil), http.StatusBadRequest)
+}
+
+func testGetTodo(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Fetch me")
+
+	// The second read is served from the item cache and must match.
+	for i := 0; i < 2; i++ {
+		resp := h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+		ExpectStatus(t, resp, http.StatusOK)
+		var got todo
+		resp.Decode(t, &got)
+		if got.ID != created.ID || got.Title != created.Title {
+			t.Fatalf("get #%d = %+v, want %+v", i+1, got, created)
+		}
+	}
+}
+
+func testGetTodoMissing(t *testing.T, h *Harness) {
+	// The second request is answered from the cached miss
+	for i := 0; i < 2; i++ {
+		resp := h.JSON(http.MethodGet, "/todos/000000000000000000000000", nil)
+		ExpectStatus(t, resp, http.StatusNotFound)
+		var body struct {
+			Code string `json:"code"`
+		}
+		resp.Decode(t, &body)
+		if body.Code != "not_found" {
+			t.Errorf("code = %q, want not_found", body.Code)
+		}
+	}
+}
+
+func testGetTodoInvalidID(t *testing.T, h *Harness) {
+	for _, id := range []string{"not-an-id", "..%2F..%2Fhealth", "0000000000000000000000001"} {
+		resp := h.JSON(http.MethodGet, "/todos/"+id, nil)
+		ExpectStatus(t, resp, http.StatusBadRequest)
+		if resp.Header.Get("ETag") != "" {
+			t.Errorf("GET /todos/%s set an ETag for an invalid ID", id)
+		}
+	}
+}
+
+func testUpdateTodo(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Draft")
+	h.JSON(http.MethodGet, "/todos/"+created.ID, nil) // warm the item cache
+
+	resp := h.JSON(http.MethodPut, "/todos/"+created.ID, map[string]interface{}{
+		"title":     "Final",
+		"completed": true,
+	})
+	ExpectStatus(t, resp, http.StatusOK)
+
+	get := h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	ExpectStatus(t, get, http.StatusOK)
+	var got todo
+	get.Decode(t, &got)
+	if got.Title != "Final" || !got.Completed {
+		t.Errorf("after update = %+v, want completed \"Final\"", got)
+	}
+
+	// Invalid fields are refused by name and change nothing
+	for field, body := range map[string]map[string]interface{}{
+		"title":           {"title": "\u200b"},
+		"priority":        {"priority": "urgent"},
+		"location.lat":    {"location": map[string]float64{"lat": 91, "lng": 0}},
+		"progressPercent": {"progressPercent": 101},
+	} {
+		resp := h.JSON(http.MethodPut, "/todos/"+created.ID, body)
+		ExpectStatus(t, resp, http.StatusBadRequest)
+		var invalid struct {
+			Fields map[string]string `json:"fields"`
+		}
+		resp.Decode(t, &invalid)
+		if invalid.Fields[field] == "" {
+			t.Errorf("update of %s answered %s, want it named", field, resp.Body)
+		}
+	}
+	get = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	get.Decode(t, &got)
+	if got.Title != "Final" {
+		t.Errorf("after invalid updates = %+v, want it unchanged", got)
+	}
+}
+
+func testUpdateTodoInvalidID(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodPut, "/todos/not-an-id", map[string]string{"title": "x"})
+	ExpectStatus(t, resp, http.StatusBadRequest)
+}
+
+func testDeleteJSON(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Delete via API")
+
+	resp := h.JSON(http.MethodDelete, "/todos/"+created.ID, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+
+	if todos := listTodos(t, h); len(todos) != 0 {
+		t.Fatalf("list after delete = %+v", todos)
+	}
+	ExpectStatus(t, h.JSON(http.MethodGet, "/todos/"+created.ID, nil), http.StatusNotFound)
+}
+
+func testDeleteForm(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Delete via form")
+
+	resp := h.Form("/todos/"+created.ID+"/delete", url.Values{})
+	ExpectRedirect(t, resp, "/")
+
+	if todos := listTodos(t, h); len(todos) != 0 {
+		t.Fatalf("list after form delete = %+v", todos)
+	}
+}
+
+func testDeleteConfirmUndo(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Delete then undo")
+
+	// The list's delete buttons lead to a page that asks first
+	resp := h.Do(http.MethodGet, "/todos/"+created.ID+"/delete", nil, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	if !strings.Contains(string(resp.Body), "Delete then undo") || !strings.Contains(string(resp.Body), `action="/todos/`+created.ID+`/delete"`) {
+		t.Fatalf("confirmation page = %s", resp.Body)
+	}
+
+	resp = h.Form("/todos/"+created.ID+"/delete", url.Values{})
+	ExpectRedirect(t, resp, "/")
+	home := h.Do(http.MethodGet, "/", nil, http.Header{"Cookie": {flashOf(t, resp)}})
+	if !strings.Contains(string(home.Body), `action="/todos/`+created.ID+`/restore"`) {
+		t.Fatalf("home page after delete offers no undo: %s", home.Body)
+	}
+	if todos := listTodos(t, h); len(todos) != 0 {
+		t.Fatalf("list after delete = %+v", todos)
+	}
+
+	ExpectRedirect(t, h.Form("/todos/"+created.ID+"/restore", url.Values{}), "/")
+	todos := listTodos(t, h)
+	if len(todos) != 1 || todos[0].ID != created.ID || todos[0].Title != "Delete then undo" {
+		t.Fatalf("list after undo = %+v", todos)
+	}
+
+	// A deletion is undone once
+	ExpectStatus(t, h.JSON(http.MethodDelete, "/todos/"+created.ID, nil), http.StatusOK)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/restore", nil), http.StatusCreated)
+	ExpectStatus(t, h.JSON(http.MethodPost, "/todos/"+created.ID+"/restore", nil), http.StatusGone)
+}
+
+func testUpdateForm(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Edit via form")
+
+	// Back to the page the form was on, with a flash message for it
+	detail := "/todos/" + created.ID + "/view"
+	form := url.Values{"title": {" Edited "}, "completed": {"false", "true"}, "dueDate": {"2030-04-15"}}
+	resp := h.Do(http.MethodPost, "/todos/"+created.ID+"/update", strings.NewReader(form.Encode()), http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Referer":      {h.srv.URL + detail},
+	})
+	ExpectRedirect(t, resp, detail)
+	page := h.Do(http.MethodGet, detail, nil, http.Header{"Cookie": {flashOf(t, resp)}})
+	ExpectStatus(t, page, http.StatusOK)
+	if !strings.Contains(string(page.Body), "Todo updated") {
+		t.Errorf("detail page after update lacks the flash message: %s", page.Body)
+	}
+
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	ExpectStatus(t, resp, http.StatusOK)
+	var got struct {
+		todo
+		DueAt string `json:"dueAt"`
+	}
+	resp.Decode(t, &got)
+	if got.Title != "Edited" || !got.Completed || got.DueAt != "2030-04-15T00:00:00Z" {
+		t.Fatalf("after form update = %+v", got)
+	}
+
+	// Invalid fields change nothing; the client is told why
+	for _, bad := range []url.Values{{"title": {" "}}, {"dueDate": {"soon"}}, {"completed": {"maybe"}}} {
+		ExpectRedirect(t, h.Form("/todos/"+created.ID+"/update", bad), "/")
+	}
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	resp.Decode(t, &got)
+	if got.Title != "Edited" || !got.Completed || got.DueAt != "2030-04-15T00:00:00Z" {
+		t.Fatalf("invalid form updates changed the todo: %+v", got)
+	}
+
+	// An empty date clears it
+	ExpectRedirect(t, h.Form("/todos/"+created.ID+"/update", url.Values{"dueDate": {""}, "completed": {"false"}}), "/")
+	resp = h.JSON(http.MethodGet, "/todos/"+created.ID, nil)
+	got.DueAt = ""
+	resp.Decode(t, &got)
+	if got.Completed || got.DueAt != "" {
+		t.Fatalf("after reopening and clearing the date = %+v", got)
+	}
+}
+
+func testDeleteInvalidID(t *testing.T, h *Harness) {
+	resp := h.JSON(http.MethodDelete, "/todos/not-an-id", nil)
+	ExpectStatus(t, resp, http.StatusBadRequest)
+}
+
+func testConditionalGet(t *testing.T, h *Harness) {
+	created := createTodo(t, h, "Poll me")
+
+	for _, path := range []string{"/todos", "/todos/" + created.ID, "/todos?fields=title,completed", "/todos?limit=10"} {
+		first := h.JSON(http.MethodGet, path, nil)
+		ExpectStatus(t, first, http.StatusOK)
+		etag := first.Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("GET %s returned no ETag", path)
+		}
+
+		again := h.Do(http.MethodGet, path, nil, http.Header{"If-None-Match": {etag}})
+		ExpectStatus(t, again, http.StatusNotModified)
+	}
+
+	// Filtered, sorted and paged lists revalidate too
+	filtered := "/todos?maxEstimate=60&minProgress=10&sort=-progressPercent&offset=0&limit=5&fields=title,progressPercent"
+	first := h.JSON(http.MethodGet, filtered, nil)
+	ExpectStatus(t, first, http.StatusOK)
+	again := h.Do(http.MethodGet, filtered, nil, http.Header{"If-None-Match": {first.Header.Get("ETag")}})
+	ExpectStatus(t, again, http.StatusNotModified)
+
+	// Any write must invalidate previously issued ETags
+	list := h.JSON(http.MethodGet, "/todos", nil)
+	createTodo(t, h, "Change the list")
+	after := h.Do(http.MethodGet, "/todos", nil, http.Header{"If-None-Match": {list.Header.Get("ETag")}})
+	ExpectStatus(t, after, http.StatusOK)
+}
+
+func testAuthLockout(t *testing.T, h *Harness) {
+	// Wrong refresh tokens count against the IP like wrong keys; the
+	// default AUTH_MAX_FAILURES is 10
+	for i := 0; i < 10; i++ {
+		resp := h.JSON(http.MethodPost, "/auth/refresh", map[string]string{"refreshToken": "guess"})
+		ExpectStatus(t, resp, http.StatusUnauthorized)
+	}
+	resp := h.JSON(http.MethodPost, "/auth/refresh", map[string]string{"refreshToken": "guess"})
+	ExpectStatus(t, resp, http.StatusTooManyRequests)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("lockout sent no Retry-After")
+	}
+}
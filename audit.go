@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Audit Log ---
+
+const (
+	// AuditColName holds the record of admin actions
+	AuditColName = "audit_log"
+	// DefaultAuditLimit is how many entries GET /admin/audit returns
+	// without ?limit=
+	DefaultAuditLimit = 100
+)
+
+// audit records an admin action the caller has taken on target. The
+// action already happened, so failing to record it is only logged.
+func (app *App) audit(r *http.Request, action, target string) {
+	e := store.AuditEntry{
+		ID:     primitive.NewObjectID(),
+		Actor:  callerSubject(r),
+		Action: action,
+		Target: target,
+		At:     time.Now().UTC(),
+	}
+	if err := app.Audit.RecordAudit(r.Context(), e); err != nil {
+		log.Printf("Error auditing %s on %s by %s: %v", action, target, e.Actor, err)
+	}
+}
+
+type AuditEntryResponse struct {
+	ID     string `json:"id"`
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+	Count  int    `json:"count,omitempty"`
+	At     string `json:"at"`
+}
+
+// listAudit serves GET /admin/audit?limit=: the most recent admin
+// actions, newest first.
+func (app *App) listAudit(w http.ResponseWriter, r *http.Request) {
+	_, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 {
+		limit = DefaultAuditLimit
+	}
+	entries, err := app.Audit.RecentAudit(r.Context(), limit)
+	if err != nil {
+		serverError(w, r, "Failed to load audit log", err)
+		return
+	}
+	out := make([]AuditEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, AuditEntryResponse{
+			ID:     e.ID.Hex(),
+			Actor:  e.Actor,
+			Action: e.Action,
+			Target: e.Target,
+			Count:  e.Count,
+			At:     formatTime(e.At),
+		})
+	}
+	respondJSON(w, http.StatusOK, out)
+}
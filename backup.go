@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Backup & Restore ---
+
+const (
+	// DefaultBackupSchedule takes the nightly backups at 02:00 UTC
+	DefaultBackupSchedule = "0 2 * * *"
+	// backupTimeout bounds a backup or restore started from the admin API
+	backupTimeout = 10 * time.Minute
+	// blobAPIVersion is the Blob service REST API version spoken
+	blobAPIVersion = "2021-12-02"
+	// backupDefaultPrefix is the default tenant's folder in the container
+	backupDefaultPrefix = "default"
+)
+
+// blobContainer talks to an Azure Blob Storage container through its REST
+// API, authorized by the SAS token in its URL.
+type blobContainer struct {
+	base   *url.URL // https://<account>.blob.core.windows.net/<container>?<sas>
+	client *http.Client
+}
+
+// newBackupContainer reads AZURE_STORAGE_BACKUP_URL, a container URL
+// carrying a SAS token allowed to read, write and list blobs. It returns
+// nil when backups are off.
+func newBackupContainer() (*blobContainer, error) {
+	raw := os.Getenv("AZURE_STORAGE_BACKUP_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	base, err := url.Parse(raw)
+	if err != nil || base.Scheme != "https" || strings.Trim(base.Path, "/") == "" || base.RawQuery == "" {
+		return nil, fmt.Errorf("AZURE_STORAGE_BACKUP_URL must be an https container URL with a SAS token")
+	}
+	return &blobContainer{base: base, client: &http.Client{Timeout: backupTimeout}}, nil
+}
+
+// do sends a request for the blob name (the container itself when empty)
+// with extra query parameters.
+func (c *blobContainer) do(ctx context.Context, method, name string, params url.Values, header http.Header, body []byte) (*http.Response, error) {
+	target := *c.base
+	if name != "" {
+		target.Path = strings.TrimSuffix(c.base.Path, "/") + "/" + name
+	}
+	query := target.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	target.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("x-ms-version", blobAPIVersion)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, store.ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s responded %s", c.base.Host, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *blobContainer) put(ctx context.Context, name, contentType string, data []byte) error {
+	header := http.Header{}
+	header.Set("x-ms-blob-type", "BlockBlob")
+	header.Set("Content-Type", contentType)
+	resp, err := c.do(ctx, http.MethodPut, name, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// get opens a blob; it fails with store.ErrNotFound when there's none.
+func (c *blobContainer) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, name, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// blobItem is a listed blob.
+type blobItem struct {
+	Name       string `xml:"Name"`
+	Properties struct {
+		LastModified  string `xml:"Last-Modified"`
+		ContentLength int64  `xml:"Content-Length"`
+	} `xml:"Properties"`
+}
+
+// list returns the blobs whose name starts with prefix, following the
+// listing's continuation markers.
+func (c *blobContainer) list(ctx context.Context, prefix string) ([]blobItem, error) {
+	var items []blobItem
+	marker := ""
+	for {
+		params := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			params.Set("marker", marker)
+		}
+		resp, err := c.do(ctx, http.MethodGet, "", params, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Blobs      []blobItem `xml:"Blobs>Blob"`
+			NextMarker string     `xml:"NextMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Blobs...)
+		if page.NextMarker == "" {
+			return items, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// BackupSnapshot describes a backup blob: the tenant's todos as gzipped
+// NDJSON, one todo per line.
+type BackupSnapshot struct {
+	Name      string `json:"name"`
+	Todos     int    `json:"todos,omitempty"` // only known right after the backup
+	Bytes     int64  `json:"bytes"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// RestoreRequest is the body of POST /admin/restore.
+type RestoreRequest struct {
+	Snapshot string `json:"snapshot"`
+	DryRun   bool   `json:"dryRun,omitempty"`
+}
+
+// RestoreResponse reports what a restore did, or would do on a dry run.
+// Todos that still exist are left as they are.
+type RestoreResponse struct {
+	Snapshot string `json:"snapshot"`
+	DryRun   bool   `json:"dryRun"`
+	Total    int    `json:"total"`
+	Restored int    `json:"restored"`
+	Existing int    `json:"existing"`
+	Failed   int    `json:"failed"`
+}
+
+// backupPrefix is the folder of the context tenant's snapshots.
+func backupPrefix(ctx context.Context) string {
+	if tenant := store.TenantFrom(ctx); tenant != "" {
+		return tenant + "/"
+	}
+	return backupDefaultPrefix + "/"
+}
+
+// backupTodos writes every todo of the context tenant to a new snapshot.
+func (app *App) backupTodos(ctx context.Context) (BackupSnapshot, error) {
+	todos, err := app.Store.List(ctx, store.Query{})
+	if err != nil {
+		return BackupSnapshot{}, err
+	}
+	return app.writeSnapshot(ctx, "todos", todos)
+}
+
+// writeSnapshot stores todos as a snapshot of the context tenant whose
+// name starts with kind, so it is listed and restored like any backup.
+func (app *App) writeSnapshot(ctx context.Context, kind string, todos []store.Todo) (BackupSnapshot, error) {
+	now := time.Now().UTC()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, todo := range todos {
+		if err := enc.Encode(todo); err != nil {
+			return BackupSnapshot{}, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return BackupSnapshot{}, err
+	}
+	name := backupPrefix(ctx) + kind + "-" + now.Format("20060102T150405Z") + ".ndjson.gz"
+	if err := app.backups.put(ctx, name, "application/gzip", buf.Bytes()); err != nil {
+		return BackupSnapshot{}, err
+	}
+	return BackupSnapshot{Name: name, Todos: len(todos), Bytes: int64(buf.Len()), CreatedAt: formatTime(now)}, nil
+}
+
+// backupAll is the scheduled job: one snapshot per tenant.
+func (app *App) backupAll(ctx context.Context) error {
+	for _, tenant := range app.tenantIDs() {
+		snap, err := app.backupTodos(store.WithTenant(ctx, tenant))
+		if err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+		log.Printf("Backed up %d todo(s) to %s", snap.Todos, snap.Name)
+	}
+	return nil
+}
+
+// restoreTodos recreates the snapshot's todos that no longer exist, with
+// their original IDs. A dry run only counts them.
+func (app *App) restoreTodos(ctx context.Context, name string, dryRun bool) (RestoreResponse, error) {
+	res := RestoreResponse{Snapshot: name, DryRun: dryRun}
+	body, err := app.backups.get(ctx, name)
+	if err != nil {
+		return res, err
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return res, fmt.Errorf("reading snapshot: %w", err)
+	}
+	lines := bufio.NewScanner(gz)
+	lines.Buffer(make([]byte, 64<<10), 16<<20)
+	err = app.Todos.Batch(ctx, func(ctx context.Context) error {
+		for lines.Scan() {
+			var todo store.Todo
+			if err := json.Unmarshal(lines.Bytes(), &todo); err != nil {
+				return fmt.Errorf("reading snapshot: line %d: %w", res.Total+1, err)
+			}
+			res.Total++
+			_, err := app.Todos.Get(ctx, todo.ID)
+			switch {
+			case err == nil:
+				res.Existing++
+			case !errors.Is(err, store.ErrNotFound):
+				res.Failed++
+			case dryRun:
+				res.Restored++
+			case app.Todos.Insert(ctx, todo) != nil:
+				res.Failed++
+			default:
+				res.Restored++
+			}
+		}
+		if err := lines.Err(); err != nil {
+			return fmt.Errorf("reading snapshot: %w", err)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// requireBackups answers 404 when no backup container is configured.
+func (app *App) requireBackups(w http.ResponseWriter) bool {
+	if app.backups == nil {
+		respondError(w, http.StatusNotFound, "Backups are off")
+		return false
+	}
+	return true
+}
+
+// createBackup serves POST /admin/backup: it snapshots the tenant's todos
+// now rather than waiting for the schedule.
+func (app *App) createBackup(w http.ResponseWriter, r *http.Request) {
+	if !app.requireBackups(w) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), backupTimeout)
+	defer cancel()
+	snap, err := app.backupTodos(ctx)
+	if err != nil {
+		serverError(w, r, "Failed to back up todos", err)
+		return
+	}
+	app.audit(r, "backup.create", "backup:"+snap.Name)
+	respondJSON(w, http.StatusCreated, snap)
+}
+
+// listBackups serves GET /admin/backups: the tenant's snapshots, newest
+// first.
+func (app *App) listBackups(w http.ResponseWriter, r *http.Request) {
+	if !app.requireBackups(w) {
+		return
+	}
+	items, err := app.backups.list(r.Context(), backupPrefix(r.Context()))
+	if err != nil {
+		serverError(w, r, "Failed to list backups", err)
+		return
+	}
+	out := make([]BackupSnapshot, 0, len(items))
+	for _, item := range items {
+		snap := BackupSnapshot{Name: item.Name, Bytes: item.Properties.ContentLength}
+		if t, err := time.Parse(http.TimeFormat, item.Properties.LastModified); err == nil {
+			snap.CreatedAt = formatTime(t)
+		}
+		out = append(out, snap)
+	}
+	// Names embed the time, so they sort chronologically
+	sort.Slice(out, func(i, j int) bool { return out[i].Name > out[j].Name })
+	respondJSON(w, http.StatusOK, out)
+}
+
+// restoreBackup serves POST /admin/restore with a RestoreRequest.
+func (app *App) restoreBackup(w http.ResponseWriter, r *http.Request) {
+	if !app.requireBackups(w) {
+		return
+	}
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Snapshot == "" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"snapshot": "is required"},
+		})
+		return
+	}
+	// A tenant only restores its own snapshots
+	if !strings.HasPrefix(req.Snapshot, backupPrefix(r.Context())) || strings.Contains(req.Snapshot, "..") {
+		respondError(w, http.StatusNotFound, "Snapshot not found")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), backupTimeout)
+	defer cancel()
+	res, err := app.restoreTodos(ctx, req.Snapshot, req.DryRun)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "Snapshot not found")
+		return
+	}
+	if err != nil {
+		serverError(w, r, "Failed to restore backup", err)
+		return
+	}
+	if !req.DryRun {
+		app.audit(r, "backup.restore", "backup:"+req.Snapshot)
+	}
+	respondJSON(w, http.StatusOK, res)
+}
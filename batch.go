@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Batch Reads ---
+
+// MaxBatchIDs caps how many todos one batch read may ask for.
+const MaxBatchIDs = 100
+
+type BatchGetRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// parseIDs validates and dedupes todo IDs, keeping their order.
+func parseIDs(raw []string) ([]primitive.ObjectID, error) {
+	if len(raw) > MaxBatchIDs {
+		return nil, fmt.Errorf("At most %d ids per request", MaxBatchIDs)
+	}
+	ids := make([]primitive.ObjectID, 0, len(raw))
+	seen := map[primitive.ObjectID]bool{}
+	for _, s := range raw {
+		id, err := primitive.ObjectIDFromHex(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ID %q", s)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// batchGetTodos serves GET /todos?ids=a,b,c.
+func (app *App) batchGetTodos(w http.ResponseWriter, r *http.Request, raw string) {
+	ids, err := parseIDs(splitList(raw))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if checkNotModified(w, r, app.listETag(r.Context(), "ids="+raw)) {
+		return
+	}
+	app.respondBatch(w, r, ids)
+}
+
+// batchGetTodosPost serves POST /todos/batch-get for ID lists too long
+// for a URL.
+func (app *App) batchGetTodosPost(w http.ResponseWriter, r *http.Request) {
+	var req BatchGetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	ids, err := parseIDs(req.IDs)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	app.respondBatch(w, r, ids)
+}
+
+// respondBatch writes the existing todos among ids, in the order asked for.
+// Unknown IDs are left out.
+func (app *App) respondBatch(w http.ResponseWriter, r *http.Request, ids []primitive.ObjectID) {
+	found, err := app.getTodos(r.Context(), ids)
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	out := make([]TodoResponse, 0, len(found))
+	for _, id := range ids {
+		if resp, ok := found[id]; ok {
+			out = append(out, resp)
+		}
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// getTodos looks ids up in the item cache in one round trip and fetches
+// the misses with a single store query, caching what it finds (and, like
+// getTodo, what it doesn't).
+func (app *App) getTodos(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]TodoResponse, error) {
+	found := make(map[primitive.ObjectID]TodoResponse, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	misses := ids
+	if cachedReads(ctx) {
+		// Pipelined GETs rather than MGET, which Redis Cluster rejects when
+		// the keys span slots
+		pipe := app.RedisClient.Pipeline()
+		gets := make([]*redis.StringCmd, len(ids))
+		for i, id := range ids {
+			gets[i] = pipe.Get(ctx, todoCacheKey(ctx, id))
+		}
+		pipe.Exec(ctx)
+
+		misses = nil
+		for i, id := range ids {
+			cached, err := gets[i].Bytes()
+			if err != nil {
+				misses = append(misses, id)
+				continue
+			}
+			if string(cached) == notFoundSentinel {
+				continue
+			}
+			if resp, ok := decodeCachedTodo(cached, id.Hex()); ok {
+				found[id] = resp
+			} else {
+				misses = append(misses, id)
+			}
+		}
+	}
+	if len(misses) == 0 {
+		return found, nil
+	}
+
+	todos, err := app.Store.List(ctx, store.Query{IDs: misses})
+	if err != nil {
+		return nil, err
+	}
+	pipe := app.RedisClient.Pipeline()
+	for _, todo := range todos {
+		resp := newTodoResponse(todo)
+		found[todo.ID] = resp
+		data, _ := json.Marshal(resp)
+		pipe.Set(ctx, todoCacheKey(ctx, todo.ID), data, 5*time.Minute)
+	}
+	if app.notFoundTTL > 0 {
+		for _, id := range misses {
+			if _, ok := found[id]; !ok {
+				pipe.Set(ctx, todoCacheKey(ctx, id), notFoundSentinel, app.notFoundTTL)
+			}
+		}
+	}
+	pipe.Exec(ctx)
+	return found, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// --- Startup ---
+
+const (
+	// DefaultStartupRetryWindow is how long startup keeps retrying Mongo and
+	// Redis before giving up; containers often start before their backends.
+	DefaultStartupRetryWindow = 60 * time.Second
+
+	connectAttemptTimeout = 10 * time.Second
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 10 * time.Second
+)
+
+// retryConnect calls connect until it succeeds, ctx is cancelled or window
+// elapses, backing off exponentially (with jitter) between attempts. A zero
+// window means a single attempt.
+func retryConnect[T any](ctx context.Context, name string, window time.Duration,
+	connect func(context.Context) (T, error)) (T, error) {
+	deadline := time.Now().Add(window)
+	backoff := connectInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, connectAttemptTimeout)
+		client, err := connect(attemptCtx)
+		cancel()
+		if err == nil {
+			return client, nil
+		}
+
+		// Jitter keeps replicas from retrying in lockstep
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		if time.Now().Add(delay).After(deadline) {
+			return client, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Connecting to %s failed (attempt %d): %v; retrying in %s", name, attempt, err, delay.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return client, ctx.Err()
+		case <-time.After(delay):
+		}
+		if backoff *= 2; backoff > connectMaxBackoff {
+			backoff = connectMaxBackoff
+		}
+	}
+}
+
+// swapHandler lets the server start listening before the app exists and
+// switch to the real router once startup completes.
+type swapHandler struct {
+	current atomic.Value // handlerBox
+}
+
+type handlerBox struct{ http.Handler }
+
+func (s *swapHandler) Set(h http.Handler) {
+	s.current.Store(handlerBox{h})
+}
+
+func (s *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.current.Load().(handlerBox).ServeHTTP(w, r)
+}
+
+// startingHandler answers while dependencies are still connecting: /healthz
+// and /readyz report not ready and everything else asks the client to retry.
+func startingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+			return
+		}
+		respondError(w, http.StatusServiceUnavailable, "Service is starting")
+	})
+}
+
+// handleHealthz reports readiness: the app is up and Redis answers.
+func (app *App) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	status, code := map[string]string{"status": "ok"}, http.StatusOK
+	if err := app.RedisClient.Ping(ctx).Err(); err != nil {
+		status, code = map[string]string{"status": "unavailable", "redis": "unreachable"}, http.StatusServiceUnavailable
+	}
+	respondJSON(w, code, status)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Bulk Actions ---
+
+// Actions accepted by POST /todos/bulk. There are no lists to move todos
+// between; color labels are how todos are grouped, so BulkColor stands in.
+const (
+	BulkComplete = "complete"
+	BulkDelete   = "delete"
+	BulkColor    = "color"
+)
+
+type BulkRequest struct {
+	Action string   `json:"action"`
+	IDs    []string `json:"ids"`
+	Color  string   `json:"color,omitempty"` // for BulkColor; empty removes the label
+}
+
+type BulkResponse struct {
+	Action  string            `json:"action"`
+	Done    []string          `json:"done"`
+	Skipped map[string]string `json:"skipped,omitempty"` // ID to why it was left alone
+}
+
+// checkBulk validates a bulk request, returning the parsed IDs and the
+// normalized color, or the error to answer 400 with.
+func checkBulk(action string, raw []string, color string) ([]primitive.ObjectID, string, *ErrorResponse) {
+	switch action {
+	case BulkComplete, BulkDelete, BulkColor:
+	default:
+		return nil, "", &ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"action": "must be complete, delete or color"},
+		}
+	}
+	ids, err := parseIDs(raw)
+	if err != nil {
+		return nil, "", &ErrorResponse{Error: err.Error()}
+	}
+	if len(ids) == 0 {
+		return nil, "", &ErrorResponse{Error: "Validation failed", Fields: map[string]string{"ids": "is required"}}
+	}
+	color = normalizeColor(color)
+	if action == BulkColor && !store.ValidColor(color) {
+		return nil, "", &invalidColor
+	}
+	return ids, color, nil
+}
+
+// applyBulk applies action to the todos among ids, in one transaction
+// when transactions are on. Unknown todos are skipped, as are completions
+// of todos blocked by open todos outside the selection when blocked
+// completion is rejected.
+func (app *App) applyBulk(ctx context.Context, action string, ids []primitive.ObjectID, color string) (BulkResponse, error) {
+	out := BulkResponse{Action: action, Done: []string{}}
+	g, err := app.dependencyGraph(ctx)
+	if err != nil {
+		return out, err
+	}
+	selected := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+	skip := func(id primitive.ObjectID, reason string) {
+		if out.Skipped == nil {
+			out.Skipped = map[string]string{}
+		}
+		out.Skipped[id.Hex()] = reason
+	}
+
+	now := time.Now()
+	err = app.Todos.InTransaction(ctx, func(ctx context.Context) error {
+		// A retried transaction starts over
+		out.Done, out.Skipped = []string{}, nil
+		for _, id := range ids {
+			todo, ok := g[id]
+			if !ok {
+				skip(id, "not found")
+				continue
+			}
+			var err error
+			switch action {
+			case BulkComplete:
+				if todo.Completed {
+					out.Done = append(out.Done, id.Hex())
+					continue
+				}
+				var open []primitive.ObjectID
+				for _, blocker := range g.openBlockers(todo.BlockedBy) {
+					if !selected[blocker] {
+						open = append(open, blocker)
+					}
+				}
+				if len(open) > 0 && app.blocked == BlockedReject {
+					skip(id, fmt.Sprintf("blocked by %d open todo(s)", len(open)))
+					continue
+				}
+				completed := true
+				err = app.Todos.Update(ctx, id, &store.Update{Completed: &completed, CompletedAt: &now})
+			case BulkDelete:
+				err = app.Todos.Delete(ctx, id)
+			case BulkColor:
+				err = app.Todos.Update(ctx, id, &store.Update{Color: &color})
+			}
+			if err != nil {
+				return err
+			}
+			out.Done = append(out.Done, id.Hex())
+		}
+		return nil
+	})
+	return out, err
+}
+
+// bulkTodos serves POST /todos/bulk: complete, delete or label up to
+// MaxBatchIDs todos at once.
+func (app *App) bulkTodos(w http.ResponseWriter, r *http.Request) {
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	ids, color, errResp := checkBulk(req.Action, req.IDs, req.Color)
+	if errResp != nil {
+		writeError(w, http.StatusBadRequest, *errResp)
+		return
+	}
+	out, err := app.applyBulk(r.Context(), req.Action, ids, color)
+	if err != nil {
+		serverError(w, r, "Failed to apply bulk action", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// bulkConfirmPage is the data rendered by bulkConfirmTemplate.
+type bulkConfirmPage struct {
+	Action string
+	Todos  []store.Todo
+}
+
+// bulkTodosForm serves POST /todos/bulk/form for the list's selection
+// toolbar. Deleting first renders a confirmation page, which posts back
+// with confirm=true; everything else redirects to the list.
+func (app *App) bulkTodosForm(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	raw := r.PostForm["id"]
+	if len(raw) == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	action := r.PostForm.Get("action")
+	ids, color, errResp := checkBulk(action, raw, r.PostForm.Get("color"))
+	if errResp != nil {
+		writeError(w, http.StatusBadRequest, *errResp)
+		return
+	}
+
+	ctx := r.Context()
+	if action == BulkDelete && r.PostForm.Get("confirm") != "true" {
+		todos, err := app.Store.List(ctx, store.Query{IDs: ids, Fields: []string{"title", "completed", "createdAt"}})
+		if err != nil {
+			serverError(w, r, "Failed to fetch todos", err)
+			return
+		}
+		if len(todos) == 0 {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		app.BulkConfirm.Execute(w, bulkConfirmPage{Action: action, Todos: todos})
+		return
+	}
+	out, err := app.applyBulk(ctx, action, ids, color)
+	if err != nil {
+		serverError(w, r, "Failed to apply bulk action", err)
+		return
+	}
+	setFlash(w, FlashSuccess, bulkSummary(out))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// bulkSummary describes the outcome of a bulk action for its flash.
+func bulkSummary(out BulkResponse) string {
+	verb := map[string]string{BulkComplete: "Completed", BulkDelete: "Deleted", BulkColor: "Labelled"}[out.Action]
+	msg := fmt.Sprintf("%s %d todo(s)", verb, len(out.Done))
+	if len(out.Skipped) > 0 {
+		msg += fmt.Sprintf(", skipped %d", len(out.Skipped))
+	}
+	return msg
+}
+
+const bulkConfirmTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Delete {{len .Todos}} todo{{if gt (len .Todos) 1}}s{{end}}? - Azure Go Todo</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 50px; }
+        .container { max-width: 600px; }
+        .completed { text-decoration: line-through; color: #888; }
+    </style>
+</head>
+<body>
+<main class="container">
+    <h1 class="h3">Delete {{len .Todos}} todo{{if gt (len .Todos) 1}}s{{end}}?</h1>
+    <p class="text-muted">This can't be undone.</p>
+    <ul class="list-group mb-4">
+        {{range .Todos}}<li class="list-group-item {{if .Completed}}completed{{end}}">{{.Title}}</li>{{end}}
+    </ul>
+    <form action="/todos/bulk/form" method="POST">
+        <input type="hidden" name="action" value="{{.Action}}">
+        <input type="hidden" name="confirm" value="true">
+        {{range .Todos}}<input type="hidden" name="id" value="{{.ID.Hex}}">{{end}}
+        <button type="submit" class="btn btn-danger" autofocus>Delete</button>
+        <a href="/" class="btn btn-outline-secondary">Cancel</a>
+    </form>
+</main>
+</body>
+</html>`
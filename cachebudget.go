@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// --- Cache Budget ---
+
+// Compressions of large cached lists, the values of CACHE_COMPRESSION.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+)
+
+const (
+	// DefaultCacheMaxListBytes keeps lists over 1 MiB out of the cache
+	DefaultCacheMaxListBytes = 1 << 20
+	// DefaultCacheCompressMinBytes is the size lists are compressed from
+	DefaultCacheCompressMinBytes = 16 << 10
+	// DefaultCacheWarnBytes is the size cached lists are logged from
+	DefaultCacheWarnBytes = 256 << 10
+	// listCacheTTL is how long a cached list lives
+	listCacheTTL = 10 * time.Minute
+)
+
+// Compressed cache entries start with a byte naming their compression.
+// Cached lists are JSON arrays, so neither can be mistaken for one.
+const (
+	gzipMarker   = 'g'
+	snappyMarker = 's'
+)
+
+// cacheBudget bounds what the list cache holds and counts what it stored
+// since this instance started.
+type cacheBudget struct {
+	maxListBytes     int    // lists larger than this aren't cached; 0 means no cap
+	compression      string // one of the Compression constants
+	compressMinBytes int
+	warnBytes        int // lists this large are logged; 0 means never
+
+	stored     atomic.Int64
+	compressed atomic.Int64
+	rawBytes   atomic.Int64 // of the stored lists, before compression
+	savedBytes atomic.Int64 // by compression
+	large      atomic.Int64 // stored despite reaching warnBytes
+	skipped    atomic.Int64 // not stored for exceeding maxListBytes
+}
+
+// newCacheBudget reads CACHE_MAX_LIST_BYTES, CACHE_COMPRESSION,
+// CACHE_COMPRESS_MIN_BYTES and CACHE_WARN_BYTES.
+func newCacheBudget() *cacheBudget {
+	b := &cacheBudget{
+		maxListBytes:     envInt("CACHE_MAX_LIST_BYTES", DefaultCacheMaxListBytes),
+		compression:      CompressionNone,
+		compressMinBytes: envInt("CACHE_COMPRESS_MIN_BYTES", DefaultCacheCompressMinBytes),
+		warnBytes:        envInt("CACHE_WARN_BYTES", DefaultCacheWarnBytes),
+	}
+	switch v := os.Getenv("CACHE_COMPRESSION"); v {
+	case "", CompressionNone:
+	case CompressionGzip, CompressionSnappy:
+		b.compression = v
+	default:
+		log.Printf("Invalid CACHE_COMPRESSION=%q, using default: %s", v, CompressionNone)
+	}
+	return b
+}
+
+// fits reports whether a list of n bytes may be cached.
+func (b *cacheBudget) fits(n int) bool {
+	return b.maxListBytes <= 0 || n <= b.maxListBytes
+}
+
+// oversized records that a list of at least n bytes wasn't cached.
+func (b *cacheBudget) oversized(key string, n int) {
+	b.skipped.Add(1)
+	log.Printf("Not caching %s: %d bytes exceed CACHE_MAX_LIST_BYTES=%d", key, n, b.maxListBytes)
+}
+
+// encode returns the entry to cache for the list data, compressed when
+// it's large enough, or false when it's too large to cache at all.
+func (b *cacheBudget) encode(key string, data []byte) ([]byte, bool) {
+	if !b.fits(len(data)) {
+		b.oversized(key, len(data))
+		return nil, false
+	}
+	if b.warnBytes > 0 && len(data) >= b.warnBytes {
+		b.large.Add(1)
+		log.Printf("Caching large list %s: %d bytes", key, len(data))
+	}
+	entry := data
+	if b.compression != CompressionNone && len(data) >= b.compressMinBytes {
+		if c, err := compressEntry(b.compression, data); err != nil {
+			log.Printf("Error compressing %s, caching it as is: %v", key, err)
+		} else if len(c) < len(data) {
+			entry = c
+			b.compressed.Add(1)
+			b.savedBytes.Add(int64(len(data) - len(c)))
+		}
+	}
+	b.stored.Add(1)
+	b.rawBytes.Add(int64(len(data)))
+	return entry, true
+}
+
+func compressEntry(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		buf.WriteByte(gzipMarker)
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionSnappy:
+		return append([]byte{snappyMarker}, snappy.Encode(nil, data)...), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", compression)
+}
+
+// decodeCacheEntry returns the list cached as entry, whichever compression
+// it was stored with, so changing CACHE_COMPRESSION doesn't strand entries.
+func decodeCacheEntry(entry []byte) ([]byte, error) {
+	if len(entry) == 0 {
+		return entry, nil
+	}
+	switch entry[0] {
+	case gzipMarker:
+		zr, err := gzip.NewReader(bytes.NewReader(entry[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	case snappyMarker:
+		return snappy.Decode(nil, entry[1:])
+	}
+	return entry, nil
+}
+
+// cacheList caches the encoded list under key, within the budget.
+func (app *App) cacheList(ctx context.Context, key string, data []byte) {
+	if entry, ok := app.cacheBudget.encode(key, data); ok {
+		app.RedisClient.Set(ctx, key, entry, listCacheTTL)
+	}
+}
+
+// cachedList returns the list cached under key.
+func (app *App) cachedList(ctx context.Context, key string) ([]byte, error) {
+	entry, err := app.RedisClient.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return decodeCacheEntry(entry)
+}
+
+// CacheBudgetResponse is what this instance stored in the list cache.
+type CacheBudgetResponse struct {
+	MaxListBytes     int    `json:"maxListBytes"`
+	Compression      string `json:"compression"`
+	CompressMinBytes int    `json:"compressMinBytes"`
+	WarnBytes        int    `json:"warnBytes"`
+	Stored           int64  `json:"stored"`
+	Compressed       int64  `json:"compressed"`
+	RawBytes         int64  `json:"rawBytes"`   // before compression
+	SavedBytes       int64  `json:"savedBytes"` // by compression
+	Large            int64  `json:"large"`      // stored at or over warnBytes
+	Skipped          int64  `json:"skipped"`    // over maxListBytes, not stored
+}
+
+func newCacheBudgetResponse(b *cacheBudget) CacheBudgetResponse {
+	return CacheBudgetResponse{
+		MaxListBytes:     b.maxListBytes,
+		Compression:      b.compression,
+		CompressMinBytes: b.compressMinBytes,
+		WarnBytes:        b.warnBytes,
+		Stored:           b.stored.Load(),
+		Compressed:       b.compressed.Load(),
+		RawBytes:         b.rawBytes.Load(),
+		SavedBytes:       b.savedBytes.Load(),
+		Large:            b.large.Load(),
+		Skipped:          b.skipped.Load(),
+	}
+}
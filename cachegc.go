@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Cache Cleanup ---
+
+const (
+	// DefaultCacheCleanupSchedule reclaims orphaned cache keys hourly
+	DefaultCacheCleanupSchedule = "15 * * * *"
+	// cacheCleanupBatch is how many keys are scanned, checked against the
+	// store and deleted at a time
+	cacheCleanupBatch = 500
+	// cacheCleanupKey is an instance-wide hash of the cleanup's totals and
+	// last run
+	cacheCleanupKey = "cache:cleanup"
+)
+
+// cacheCleanup counts what a cleanup run found.
+type cacheCleanup struct {
+	Scanned   int64 // todo:* keys looked at
+	Reclaimed int64 // of those, deleted because their todo is gone
+	Tenants   int64 // deleted because their tenant is gone
+}
+
+// cleanCacheKeys deletes the todo:* cache entries of todos no longer in
+// the store, and those of tenants no longer in the registry, and records
+// how many it reclaimed. Not-found markers go too; the next read of such
+// a todo puts one back.
+func (app *App) cleanCacheKeys(ctx context.Context) error {
+	var run cacheCleanup
+	for _, tenant := range app.tenantIDs() {
+		if err := app.cleanTenantCache(store.WithTenant(ctx, tenant), &run); err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	if app.tenancy != nil {
+		if err := app.cleanRemovedTenants(ctx, &run); err != nil {
+			return err
+		}
+	}
+	log.Printf("Cache cleanup: scanned %d keys, reclaimed %d of deleted todos and %d of removed tenants", run.Scanned, run.Reclaimed, run.Tenants)
+	return app.recordCacheCleanup(ctx, run)
+}
+
+// cleanTenantCache reclaims the cache entries of the context tenant's
+// deleted todos.
+func (app *App) cleanTenantCache(ctx context.Context, run *cacheCleanup) error {
+	prefix := redisKey(ctx, "todo:")
+	return app.scanKeys(ctx, prefix+"*", func(keys []string) error {
+		run.Scanned += int64(len(keys))
+		ids := make([]primitive.ObjectID, 0, len(keys))
+		var orphans []string
+		for _, key := range keys {
+			id, err := primitive.ObjectIDFromHex(strings.TrimPrefix(key, prefix))
+			if err != nil {
+				orphans = append(orphans, key) // not a key todoCacheKey makes
+				continue
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			todos, err := app.Store.List(ctx, store.Query{IDs: ids, Fields: []string{"createdAt"}})
+			if err != nil {
+				return err
+			}
+			exists := make(map[primitive.ObjectID]bool, len(todos))
+			for _, todo := range todos {
+				exists[todo.ID] = true
+			}
+			for _, id := range ids {
+				if !exists[id] {
+					orphans = append(orphans, todoCacheKey(ctx, id))
+				}
+			}
+		}
+		n, err := app.deleteKeys(ctx, orphans)
+		run.Reclaimed += n
+		return err
+	})
+}
+
+// cleanRemovedTenants reclaims the todo cache entries of tenants no longer
+// in the registry.
+func (app *App) cleanRemovedTenants(ctx context.Context, run *cacheCleanup) error {
+	return app.scanKeys(ctx, "tenant:*:todo:*", func(keys []string) error {
+		var orphans []string
+		for _, key := range keys {
+			tenant := strings.TrimPrefix(key, "tenant:")
+			if i := strings.LastIndex(tenant, ":todo:"); i >= 0 {
+				tenant = tenant[:i]
+			}
+			if _, ok := app.tenancy.known[tenant]; !ok {
+				orphans = append(orphans, key)
+			}
+		}
+		run.Scanned += int64(len(orphans)) // the others were counted per tenant
+		n, err := app.deleteKeys(ctx, orphans)
+		run.Tenants += n
+		return err
+	})
+}
+
+// scanKeys calls fn with batches of the keys matching pattern, on every
+// primary of a cluster. A key may be passed more than once.
+func (app *App) scanKeys(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	scan := func(ctx context.Context, c redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := c.Scan(ctx, cursor, pattern, cacheCleanupBatch).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if err := fn(keys); err != nil {
+					return err
+				}
+			}
+			if cursor = next; cursor == 0 {
+				return nil
+			}
+		}
+	}
+	if cluster, ok := app.RedisClient.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return scan(ctx, c)
+		})
+	}
+	return scan(ctx, app.RedisClient)
+}
+
+// deleteKeys deletes keys one by one, as a cluster can't delete keys of
+// different slots in one command, and returns how many existed.
+func (app *App) deleteKeys(ctx context.Context, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	cmds, err := app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.(*redis.IntCmd).Val()
+	}
+	return n, err
+}
+
+// recordCacheCleanup adds run to the totals and keeps it as the last run.
+func (app *App) recordCacheCleanup(ctx context.Context, run cacheCleanup) error {
+	_, err := app.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, cacheCleanupKey, "runs", 1)
+		pipe.HIncrBy(ctx, cacheCleanupKey, "scanned", run.Scanned)
+		pipe.HIncrBy(ctx, cacheCleanupKey, "reclaimed", run.Reclaimed)
+		pipe.HIncrBy(ctx, cacheCleanupKey, "tenants", run.Tenants)
+		pipe.HSet(ctx, cacheCleanupKey,
+			"lastAt", formatTime(time.Now()),
+			"lastScanned", run.Scanned,
+			"lastReclaimed", run.Reclaimed,
+			"lastTenants", run.Tenants,
+		)
+		return nil
+	})
+	return err
+}
+
+// CacheCleanupResponse is what the cache cleanup reclaimed, in total and
+// in its last run.
+type CacheCleanupResponse struct {
+	Runs          int64  `json:"runs" redis:"runs"`
+	Scanned       int64  `json:"scanned" redis:"scanned"`
+	Reclaimed     int64  `json:"reclaimed" redis:"reclaimed"` // keys of deleted todos
+	Tenants       int64  `json:"tenants" redis:"tenants"`     // keys of removed tenants
+	LastAt        string `json:"lastAt,omitempty" redis:"lastAt"`
+	LastScanned   int64  `json:"lastScanned" redis:"lastScanned"`
+	LastReclaimed int64  `json:"lastReclaimed" redis:"lastReclaimed"`
+	LastTenants   int64  `json:"lastTenants" redis:"lastTenants"`
+}
+
+// cacheCleanupReport serves GET /admin/cache/cleanup.
+func (app *App) cacheCleanupReport(w http.ResponseWriter, r *http.Request) {
+	var resp CacheCleanupResponse
+	if err := app.RedisClient.HGetAll(r.Context(), cacheCleanupKey).Scan(&resp); err != nil {
+		serverError(w, r, "Failed to load cache cleanup", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
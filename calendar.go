@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Due Dates, Estimates & Progress ---
+
+// MaxEstimateMinutes caps estimateMinutes at one day.
+const MaxEstimateMinutes = 24 * 60
+
+// parseDueAt parses the optional dueAt (RFC 3339, "" to clear) of a
+// create or update request, returning per-field errors. A nil due means
+// dueAt wasn't given.
+func parseDueAt(dueAt *string) (due *time.Time, fields map[string]string) {
+	if dueAt == nil {
+		return nil, nil
+	}
+	var t time.Time
+	if *dueAt != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *dueAt); err != nil {
+			return nil, map[string]string{"dueAt": "must be an RFC 3339 timestamp"}
+		}
+	}
+	return &t, nil
+}
+
+// effortErrors checks the estimateMinutes and progressPercent being
+// written, returning per-field errors.
+func effortErrors(estimate, progress *int) map[string]string {
+	fields := map[string]string{}
+	if estimate != nil && (*estimate < 0 || *estimate > MaxEstimateMinutes) {
+		fields["estimateMinutes"] = fmt.Sprintf("must be between 0 and %d", MaxEstimateMinutes)
+	}
+	if progress != nil && (*progress < 0 || *progress > 100) {
+		fields["progressPercent"] = "must be between 0 and 100"
+	}
+	return fields
+}
+
+// parseEffort parses the maxEstimate and minProgress filters of a list
+// request; zero means unfiltered.
+func parseEffort(params url.Values) (maxEstimate, minProgress int, err error) {
+	for _, p := range []struct {
+		name string
+		dst  *int
+		max  int
+	}{
+		{"maxEstimate", &maxEstimate, MaxEstimateMinutes},
+		{"minProgress", &minProgress, 100},
+	} {
+		raw := params.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > p.max {
+			return 0, 0, fmt.Errorf("Invalid %s %q", p.name, raw)
+		}
+		*p.dst = n
+	}
+	return maxEstimate, minProgress, nil
+}
+
+// --- Outlook Calendar ---
+
+// DefaultBlockMinutes is the length of a calendar block for todos without
+// an estimate.
+const DefaultBlockMinutes = 30
+
+// calendarEvent is a time block to put on the calendar.
+type calendarEvent struct {
+	Subject string
+	Body    string
+	Start   time.Time
+	End     time.Time
+}
+
+// calendar creates events, returning their ID.
+type calendar interface {
+	CreateEvent(ctx context.Context, event calendarEvent) (string, error)
+}
+
+// newCalendar returns the Microsoft Graph calendar when GRAPH_TENANT_ID,
+// GRAPH_CLIENT_ID, GRAPH_CLIENT_SECRET and GRAPH_CALENDAR_USER are set, or
+// nil to disable scheduling.
+func newCalendar() calendar {
+	tenant, clientID := os.Getenv("GRAPH_TENANT_ID"), os.Getenv("GRAPH_CLIENT_ID")
+	secret, user := os.Getenv("GRAPH_CLIENT_SECRET"), os.Getenv("GRAPH_CALENDAR_USER")
+	if tenant == "" || clientID == "" || secret == "" || user == "" {
+		return nil
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &graphCalendar{
+		tokens:    newClientCredentials(tenant, clientID, secret, "https://graph.microsoft.com/.default", client),
+		eventsURL: "https://graph.microsoft.com/v1.0/users/" + url.PathEscape(user) + "/events",
+		client:    client,
+	}
+}
+
+// graphCalendar writes to a user's Outlook calendar through Microsoft
+// Graph, authenticating as the app with the client credentials flow. The
+// app registration needs the Calendars.ReadWrite application permission.
+type graphCalendar struct {
+	tokens    *clientCredentials
+	eventsURL string
+	client    *http.Client
+}
+
+// graphTime is Graph's dateTimeTimeZone resource.
+type graphTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+func newGraphTime(t time.Time) graphTime {
+	return graphTime{DateTime: t.UTC().Format("2006-01-02T15:04:05"), TimeZone: "UTC"}
+}
+
+func (g *graphCalendar) CreateEvent(ctx context.Context, event calendarEvent) (string, error) {
+	token, err := g.tokens.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("graph token: %w", err)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"subject": event.Subject,
+		"body":    map[string]string{"contentType": "text", "content": event.Body},
+		"start":   newGraphTime(event.Start),
+		"end":     newGraphTime(event.End),
+		"showAs":  "busy",
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.eventsURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+type ScheduleResponse struct {
+	TodoID  string `json:"todoId"`
+	EventID string `json:"eventId"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// scheduleTodo serves POST /todos/{id}/schedule: it blocks the todo's
+// estimated time on the calendar, ending at its due date, and links the
+// event to the todo. A todo is only scheduled once.
+func (app *App) scheduleTodo(w http.ResponseWriter, r *http.Request) {
+	if app.calendar == nil {
+		respondError(w, http.StatusNotFound, "Calendar scheduling is disabled")
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+
+	if todo.CalendarID != "" {
+		writeError(w, http.StatusConflict, ErrorResponse{
+			Error:  "Todo is already scheduled",
+			Fields: map[string]string{"calendarEventId": todo.CalendarID},
+		})
+		return
+	}
+	if todo.DueAt == nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Todo can't be scheduled",
+			Fields: map[string]string{"dueAt": "is required to schedule"},
+		})
+		return
+	}
+	if !todo.DueAt.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Todo can't be scheduled",
+			Fields: map[string]string{"dueAt": "is in the past"},
+		})
+		return
+	}
+
+	minutes := todo.Estimate
+	if minutes == 0 {
+		minutes = DefaultBlockMinutes
+	}
+	event := calendarEvent{
+		Subject: todo.Title,
+		Body:    todo.Description,
+		Start:   todo.DueAt.Add(-time.Duration(minutes) * time.Minute),
+		End:     *todo.DueAt,
+	}
+	eventID, err := app.calendar.CreateEvent(ctx, event)
+	if err != nil {
+		log.Printf("Error creating calendar event for %s: %v", objID.Hex(), err)
+		respondError(w, http.StatusBadGateway, "Failed to create calendar event")
+		return
+	}
+
+	if err := app.Todos.Update(ctx, objID, &store.Update{CalendarID: &eventID}); err != nil {
+		// The event exists but isn't linked; say so rather than hide it
+		serverError(w, r, "Calendar event created but not linked to the todo", fmt.Errorf("event %s: %w", eventID, err))
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, ScheduleResponse{
+		TodoID:  objID.Hex(),
+		EventID: eventID,
+		Start:   formatTime(event.Start),
+		End:     formatTime(event.End),
+	})
+}
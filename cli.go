@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/client"
+)
+
+// --- CLI Client ---
+
+// cliConfig is persisted by `todo login` so later invocations know which
+// server to talk to and which token to present. TODO_SERVER and TODO_TOKEN
+// override the stored values.
+type cliConfig struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+}
+
+func cliConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-azure-todo", "config.json"), nil
+}
+
+func loadCLIConfig() cliConfig {
+	cfg := cliConfig{Server: "http://localhost:" + DefaultPort}
+	if path, err := cliConfigPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &cfg)
+		}
+	}
+	if server := os.Getenv("TODO_SERVER"); server != "" {
+		cfg.Server = server
+	}
+	if token := os.Getenv("TODO_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	return cfg
+}
+
+func saveCLIConfig(cfg cliConfig) (string, error) {
+	path, err := cliConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// The token is a credential, keep the file private
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+func newCLIClient() *client.Client {
+	cfg := loadCLIConfig()
+	return client.New(cfg.Server, cfg.Token)
+}
+
+// todoCommands are the subcommands of `todo`.
+var todoCommands = []command{
+	{"add", "Create a todo: todo add <title...>", cmdTodoAdd},
+	{"list", "List todos: todo list [-all]", cmdTodoList},
+	{"done", "Mark todos completed: todo done <id>...", cmdTodoDone},
+	{"rm", "Delete todos: todo rm <id>...", cmdTodoRm},
+	{"login", "Store server URL and token: todo login -server URL [-token TOKEN]", cmdTodoLogin},
+}
+
+func cmdTodo(args []string) error {
+	if len(args) > 0 {
+		for _, cmd := range todoCommands {
+			if cmd.Name == args[0] {
+				return cmd.Run(args[1:])
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: %s todo <command> [args]\n\nCommands:\n", os.Args[0])
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range todoCommands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.Name, cmd.Summary)
+	}
+	w.Flush()
+	if len(args) == 0 {
+		return fmt.Errorf("missing command")
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func cliContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+func cmdTodoAdd(args []string) error {
+	title := strings.TrimSpace(strings.Join(args, " "))
+	if title == "" {
+		return fmt.Errorf("usage: todo add <title...>")
+	}
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	todo, err := newCLIClient().Create(ctx, title)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s  %s\n", todo.ID.Hex(), todo.Title)
+	return nil
+}
+
+func cmdTodoList(args []string) error {
+	fs := flag.NewFlagSet("todo list", flag.ExitOnError)
+	all := fs.Bool("all", false, "include completed todos")
+	fs.Parse(args)
+
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	todos, err := newCLIClient().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	shown := 0
+	for _, todo := range todos {
+		if todo.Completed && !*all {
+			continue
+		}
+		mark := " "
+		if todo.Completed {
+			mark = "x"
+		}
+		fmt.Fprintf(w, "[%s]\t%s\t%s\t%s\n", mark, todo.ID.Hex(), todo.CreatedAt.Local().Format("Jan 02 15:04"), todo.Title)
+		shown++
+	}
+	w.Flush()
+	if shown == 0 {
+		fmt.Println("No todos.")
+	}
+	return nil
+}
+
+func cmdTodoDone(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: todo done <id>...")
+	}
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	c := newCLIClient()
+	done := true
+	for _, id := range args {
+		if err := c.Update(ctx, id, client.Update{Completed: &done}); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Printf("Completed %s\n", id)
+	}
+	return nil
+}
+
+func cmdTodoRm(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: todo rm <id>...")
+	}
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	c := newCLIClient()
+	for _, id := range args {
+		if err := c.Delete(ctx, id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Printf("Deleted %s\n", id)
+	}
+	return nil
+}
+
+func cmdTodoLogin(args []string) error {
+	fs := flag.NewFlagSet("todo login", flag.ExitOnError)
+	server := fs.String("server", "", "server base URL, e.g. https://todo.example.com")
+	token := fs.String("token", "", "API token sent as a bearer token")
+	fs.Parse(args)
+
+	cfg := loadCLIConfig()
+	if *server != "" {
+		cfg.Server = *server
+	}
+	cfg.Token = *token
+
+	// Verify the server is reachable before saving
+	ctx, cancel := cliContext()
+	defer cancel()
+	if _, err := client.New(cfg.Server, cfg.Token).List(ctx); err != nil {
+		return fmt.Errorf("checking %s: %w", cfg.Server, err)
+	}
+
+	path, err := saveCLIConfig(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved credentials for %s to %s\n", cfg.Server, path)
+	return nil
+}
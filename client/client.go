@@ -0,0 +1,128 @@
+// Package client talks to a running todo server over its JSON API. It backs
+// the `todo` subcommands of the binary.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// Client is a JSON API client. The zero HTTP client is replaced by one with
+// a sensible timeout.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Update is a partial update; nil fields are left untouched.
+type Update struct {
+	Title     *string `json:"title,omitempty"`
+	Completed *bool   `json:"completed,omitempty"`
+}
+
+// --- Todos ---
+
+func (c *Client) List(ctx context.Context) ([]store.Todo, error) {
+	var todos []store.Todo
+	err := c.do(ctx, http.MethodGet, "/todos", nil, &todos)
+	return todos, err
+}
+
+func (c *Client) Get(ctx context.Context, id string) (store.Todo, error) {
+	var todo store.Todo
+	err := c.do(ctx, http.MethodGet, "/todos/"+url.PathEscape(id), nil, &todo)
+	return todo, err
+}
+
+func (c *Client) Create(ctx context.Context, title string) (store.Todo, error) {
+	var todo store.Todo
+	err := c.do(ctx, http.MethodPost, "/todos", map[string]string{"title": title}, &todo)
+	return todo, err
+}
+
+func (c *Client) Update(ctx context.Context, id string, u Update) error {
+	return c.do(ctx, http.MethodPut, "/todos/"+url.PathEscape(id), u, nil)
+}
+
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/todos/"+url.PathEscape(id), nil, nil)
+}
+
+// --- Transport ---
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	// Marks the request as an API call so the server answers with JSON
+	// instead of redirecting like it does for browser form posts
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+		// Error bodies are {"error": "..."}; fall back to the raw text
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(msg, &body) == nil && body.Error != "" {
+			apiErr.Message = body.Error
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Collections ---
+
+// collectionNames maps the logical collection names used in code
+// (ColName, EventColName, ...) to this environment's physical ones, from
+// MONGO_COLLECTIONS; names it doesn't list are used as is.
+var collectionNames = map[string]string{}
+
+// logicalCollections are the names MONGO_COLLECTIONS may rename.
+var logicalCollections = []string{
+	ColName, EventColName, OutboxColName, TemplateColName,
+	RefreshTokenColName, AuditColName, DataKeyColName,
+}
+
+// loadCollections reads MONGO_COLLECTIONS, a JSON object such as
+// {"todos":"todos_staging","todo_events":"events_staging"}, so
+// environments can share a database without sharing collections.
+func loadCollections() error {
+	v := os.Getenv("MONGO_COLLECTIONS")
+	if v == "" {
+		return nil
+	}
+	names := map[string]string{}
+	if err := json.Unmarshal([]byte(v), &names); err != nil {
+		return fmt.Errorf("MONGO_COLLECTIONS: %w", err)
+	}
+	for logical, physical := range names {
+		known := false
+		for _, name := range logicalCollections {
+			known = known || name == logical
+		}
+		if !known {
+			return fmt.Errorf("MONGO_COLLECTIONS: unknown collection %q", logical)
+		}
+		if physical == "" || strings.ContainsAny(physical, "$\x00") || strings.HasPrefix(physical, "system.") {
+			return fmt.Errorf("MONGO_COLLECTIONS: invalid name %q for %s", physical, logical)
+		}
+	}
+	collectionNames = names
+	return nil
+}
+
+// collectionName resolves a logical collection to the physical name of
+// the given tenant's copy; the default tenant keeps the plain name.
+func collectionName(logical, tenant string) string {
+	name := logical
+	if physical, ok := collectionNames[logical]; ok {
+		name = physical
+	}
+	if tenant == "" {
+		return name
+	}
+	return name + "_" + tenant
+}
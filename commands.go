@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Commands ---
+
+// command is a subcommand of the binary, e.g. `main serve` or
+// `main todo list`. Run receives the arguments following the name.
+type command struct {
+	Name    string
+	Summary string
+	Run     func(args []string) error
+}
+
+// commands lists the subcommands shown by `help`.
+var commands = []command{
+	{"serve", "Run the HTTP server (default)", cmdServe},
+	{"todo", "Manage todos on a running server: add, list, done, rm, login", cmdTodo},
+	{"tui", "Browse and edit todos on a running server interactively", cmdTUI},
+	{"anonymize-export", "Export todos with user content replaced by fake values", cmdAnonymizeExport},
+	{"seed", "Load fixture or generated todos into Mongo", cmdSeed},
+	{"rebuild-todos", "Project the todo event log into an empty collection", cmdRebuildTodos},
+	{"rotate-data-key", "Add or re-wrap the keys encrypting todo fields", cmdRotateDataKey},
+}
+
+func runCommand(name string, args []string) error {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd.Run(args)
+		}
+	}
+	printUsage()
+	return fmt.Errorf("unknown command")
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.Name, cmd.Summary)
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for command flags.\n", os.Args[0])
+}
+
+// openStore connects to Mongo the same way the server does and returns the
+// todo store plus a function releasing the connection.
+func openStore() (store.Store, *mongo.Database, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := connectMongo(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("connecting to Mongo: %w", err)
+	}
+	closeFn := func() {
+		if err := client.Disconnect(context.Background()); err != nil {
+			log.Printf("Error disconnecting Mongo: %v", err)
+		}
+	}
+
+	db := client.Database(databaseName())
+	return store.NewMongo(db.Collection(collectionName(ColName, ""))), db, closeFn, nil
+}
+
+// commandOutput opens path for writing, or returns stdout for "" and "-".
+func commandOutput(path string) (*os.File, func() error, error) {
+	if path == "" || path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Read Consistency ---
+
+// readYourWritesCookie marks a client that wrote recently; its value is
+// when the window ends, in Unix seconds.
+const readYourWritesCookie = "ryw"
+
+// applyReadOptions sets the client's read preference and read concern
+// from MONGO_READ_PREFERENCE and MONGO_READ_CONCERN, keeping the driver's
+// (or the connection string's) when unset or invalid.
+func applyReadOptions(opts *options.ClientOptions) {
+	if v := os.Getenv("MONGO_READ_PREFERENCE"); v != "" {
+		mode, err := readpref.ModeFromString(v)
+		if err == nil {
+			var rp *readpref.ReadPref
+			if rp, err = readpref.New(mode); err == nil {
+				opts.SetReadPreference(rp)
+			}
+		}
+		if err != nil {
+			log.Printf("Invalid MONGO_READ_PREFERENCE=%q, using default: %v", v, err)
+		}
+	}
+	switch v := os.Getenv("MONGO_READ_CONCERN"); v {
+	case "":
+	case "local", "available", "majority", "linearizable":
+		opts.SetReadConcern(&readconcern.ReadConcern{Level: v})
+	default:
+		log.Printf("Invalid MONGO_READ_CONCERN=%q, using default", v)
+	}
+}
+
+// readYourWrites lets clients see their own changes right away: a write
+// starts a window of readYourWritesWindow in which the client's reads skip
+// the Redis caches and go to the Mongo primary, so neither a cache another
+// instance refilled nor a lagging secondary can serve the old state. It's
+// off when the window is zero.
+func (app *App) readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.readYourWritesWindow <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := time.Now()
+		if c, err := r.Cookie(readYourWritesCookie); err == nil {
+			if until, err := strconv.ParseInt(c.Value, 10, 64); err == nil && now.Unix() < until {
+				r = r.WithContext(store.WithFreshReads(r.Context()))
+			}
+		}
+		// Set before the handler writes its headers; marking a write that
+		// then fails only costs a few uncached reads
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			http.SetCookie(w, &http.Cookie{
+				Name:     readYourWritesCookie,
+				Value:    strconv.FormatInt(now.Add(app.readYourWritesWindow).Unix(), 10),
+				Path:     "/",
+				MaxAge:   int((app.readYourWritesWindow + time.Second - 1) / time.Second),
+				HttpOnly: true,
+				Secure:   os.Getenv("SESSION_COOKIE_INSECURE") != "true",
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cachedReads reports whether reads may be served from the Redis caches.
+func cachedReads(ctx context.Context) bool {
+	return !store.FreshReads(ctx)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Counts ---
+
+// versionedTTL bounds how long results cached under a list version live;
+// a write retires them sooner by bumping the version.
+const versionedTTL = 10 * time.Minute
+
+// versioned fills dst with the result cached in Redis as name under the
+// current list version, or else with load, caching what it loaded.
+func (app *App) versioned(ctx context.Context, name string, dst interface{}, load func() error) error {
+	v, err := listVersion(ctx, app.RedisClient)
+	cacheable := err == nil
+	key := redisKey(ctx, name+":"+strconv.FormatInt(v, 36))
+	if cacheable && cachedReads(ctx) {
+		if cached, err := app.RedisClient.Get(ctx, key).Bytes(); err == nil && json.Unmarshal(cached, dst) == nil {
+			return nil
+		}
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+	if cacheable {
+		data, _ := json.Marshal(dst)
+		app.RedisClient.Set(ctx, key, data, versionedTTL)
+	}
+	return nil
+}
+
+// todoCounts returns the tenant's todo counts, cached like the list.
+func (app *App) todoCounts(ctx context.Context) (store.Counts, error) {
+	var c store.Counts
+	err := app.versioned(ctx, "todos:counts", &c, func() (err error) {
+		c, err = store.CountTodos(ctx, app.Store)
+		return err
+	})
+	return c, err
+}
+
+// setCountHeaders reports the counts of the whole list on a GET /todos
+// response, so clients can render badges without asking again:
+// X-Total-Count, X-Open-Count, X-Completed-Count and X-Tag-Counts, the
+// latter form-encoded ("home=2&work=5"). The list is served without them
+// when counting fails.
+func (app *App) setCountHeaders(w http.ResponseWriter, r *http.Request) {
+	c, err := app.todoCounts(r.Context())
+	if err != nil {
+		log.Printf("Error counting todos: %v", err)
+		return
+	}
+	tags := url.Values{}
+	for tag, n := range c.Tags {
+		tags.Set(tag, strconv.Itoa(n))
+	}
+	h := w.Header()
+	h.Set("X-Total-Count", strconv.Itoa(c.Total))
+	h.Set("X-Open-Count", strconv.Itoa(c.Open()))
+	h.Set("X-Completed-Count", strconv.Itoa(c.Completed))
+	h.Set("X-Tag-Counts", tags.Encode())
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Scheduled Jobs ---
+
+const (
+	// DefaultCacheWarmSchedule re-fills the list caches every 5 minutes
+	DefaultCacheWarmSchedule = "*/5 * * * *"
+	// DefaultRetentionSchedule runs retention cleanup nightly at 03:00 UTC
+	DefaultRetentionSchedule = "0 3 * * *"
+	// cronRunTimeout bounds a single run
+	cronRunTimeout = 30 * time.Minute
+	// cronLockTTL keeps a run's lock long enough that replicas whose
+	// clocks are a little apart can't claim the same minute twice
+	cronLockTTL = time.Hour
+
+	// Schedules are instance-wide, so keys aren't tenant-scoped
+	cronLockPrefix = "cron:lock:" // one key per schedule and minute
+	cronRunPrefix  = "cron:runs:" // hash of each schedule's last run
+)
+
+// cronSpec is a parsed five-field cron expression (minute, hour, day of
+// month, month, day of week), evaluated in UTC. Each field is a bit set
+// of the values it allows.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	anyDOM, anyDOW                bool
+}
+
+// parseCron parses expressions like "*/15 * * * *" or "0 9 * * 1-5".
+// Fields take *, numbers, ranges (a-b), steps (*/n, a-b/n) and lists.
+func parseCron(expr string) (cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	var c cronSpec
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSpec{}, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSpec{}, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSpec{}, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSpec{}, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSpec{}, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	c.anyDOM, c.anyDOW = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+func parseCronField(field string, first, last int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in cron field %q", field)
+			}
+			rng, step = r, n
+		}
+		lo, hi := first, last
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid cron field %q", field)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid cron field %q", field)
+				}
+			} else if step > 1 {
+				hi = last // "5/15" means from 5 on
+			}
+		}
+		if lo < first || hi > last || lo > hi {
+			return 0, fmt.Errorf("cron field %q out of range %d-%d", field, first, last)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matches reports whether the spec fires in t's minute. As in cron, when
+// both days are restricted either one matching is enough.
+func (c cronSpec) matches(t time.Time) bool {
+	t = t.UTC()
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.anyDOM || c.anyDOW {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// next returns the first minute after t the spec fires in, or the zero
+// time if it doesn't within a year (e.g. "0 0 31 2 *").
+func (c cronSpec) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(1, 0, 0); t.Before(end); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// cronSchedule is a job run on a cron spec by exactly one instance.
+type cronSchedule struct {
+	Name string
+	Spec string
+	spec cronSpec
+	Run  func(ctx context.Context) error
+}
+
+// newSchedules returns the scheduled jobs. CRON_CACHE_WARM,
+// CRON_CACHE_CLEANUP, CRON_MY_DAY, CRON_RETENTION and CRON_BACKUP
+// override their specs; retention only runs
+// when some tenant's policy removes todos, backups when a backup container
+// is set.
+func (app *App) newSchedules() ([]cronSchedule, error) {
+	schedules := []cronSchedule{
+		{Name: "cache-warm", Spec: envString("CRON_CACHE_WARM", DefaultCacheWarmSchedule), Run: app.warmCaches},
+		{Name: "cache-cleanup", Spec: envString("CRON_CACHE_CLEANUP", DefaultCacheCleanupSchedule), Run: app.cleanCacheKeys},
+		{Name: "my-day", Spec: envString("CRON_MY_DAY", DefaultMyDaySchedule), Run: app.clearMyDay},
+	}
+	if _, active := app.retentionPolicies(); active {
+		if err := app.checkRetention(); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, cronSchedule{Name: "retention", Spec: envString("CRON_RETENTION", DefaultRetentionSchedule), Run: app.applyRetention})
+	}
+	if app.backups != nil {
+		schedules = append(schedules, cronSchedule{Name: "backup", Spec: envString("CRON_BACKUP", DefaultBackupSchedule), Run: app.backupAll})
+	}
+	for i := range schedules {
+		spec, err := parseCron(schedules[i].Spec)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", schedules[i].Name, err)
+		}
+		schedules[i].spec = spec
+	}
+	return schedules, nil
+}
+
+// runSchedules starts the due schedules at the top of every minute until
+// ctx is done, then waits for the runs in progress.
+func (app *App) runSchedules(ctx context.Context) {
+	var runs sync.WaitGroup
+	defer runs.Wait()
+	for {
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+		slot := time.Now().Truncate(time.Minute)
+		for _, s := range app.schedules {
+			if !s.spec.matches(slot) {
+				continue
+			}
+			runs.Add(1)
+			go func(s cronSchedule) {
+				defer runs.Done()
+				app.runSchedule(context.WithoutCancel(ctx), s, slot)
+			}(s)
+		}
+	}
+}
+
+// runSchedule runs s for the given minute unless another instance has
+// claimed it, and records the outcome.
+func (app *App) runSchedule(ctx context.Context, s cronSchedule, slot time.Time) {
+	lock := cronLockPrefix + s.Name + ":" + strconv.FormatInt(slot.Unix(), 10)
+	claimed, err := app.RedisClient.SetNX(ctx, lock, instanceName(), cronLockTTL).Result()
+	if err != nil {
+		log.Printf("Error claiming schedule %s: %v", s.Name, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, cronRunTimeout)
+	defer cancel()
+	start := time.Now()
+	err = s.Run(runCtx)
+	run := map[string]interface{}{
+		"at":         formatTime(start),
+		"durationMs": time.Since(start).Milliseconds(),
+		"instance":   instanceName(),
+		"error":      "",
+	}
+	if err != nil {
+		log.Printf("Scheduled job %s failed: %v", s.Name, err)
+		run["error"] = err.Error()
+	}
+	if err := app.RedisClient.HSet(ctx, cronRunPrefix+s.Name, run).Err(); err != nil {
+		log.Printf("Error recording run of %s: %v", s.Name, err)
+	}
+}
+
+// instanceName identifies this replica in locks and run records.
+func instanceName() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// tenantIDs lists the tenants scheduled jobs visit: those in the registry,
+// or just the default tenant.
+func (app *App) tenantIDs() []string {
+	if app.tenancy == nil {
+		return []string{""}
+	}
+	ids := make([]string, 0, len(app.tenancy.known))
+	for id := range app.tenancy.known {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// warmCaches fills the list cache of every tenant that has none, so the
+// first request after it expires doesn't wait on the store.
+func (app *App) warmCaches(ctx context.Context) error {
+	for _, tenant := range app.tenantIDs() {
+		if _, err := app.getAllTodos(store.WithTenant(ctx, tenant)); err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+type CronScheduleResponse struct {
+	Name    string   `json:"name"`
+	Spec    string   `json:"spec"`
+	NextRun string   `json:"nextRun,omitempty"`
+	LastRun *CronRun `json:"lastRun,omitempty"`
+}
+
+type CronRun struct {
+	At         string `json:"at" redis:"at"`
+	DurationMs int64  `json:"durationMs" redis:"durationMs"`
+	Instance   string `json:"instance" redis:"instance"`
+	Error      string `json:"error,omitempty" redis:"error"`
+}
+
+// listSchedules serves GET /admin/schedules: every schedule with its next
+// and last run.
+func (app *App) listSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	out := make([]CronScheduleResponse, 0, len(app.schedules))
+	now := time.Now()
+	for _, s := range app.schedules {
+		resp := CronScheduleResponse{Name: s.Name, Spec: s.Spec}
+		if next := s.spec.next(now); !next.IsZero() {
+			resp.NextRun = formatTime(next)
+		}
+		cmd := app.RedisClient.HGetAll(ctx, cronRunPrefix+s.Name)
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			serverError(w, r, "Failed to load schedules", err)
+			return
+		}
+		if len(cmd.Val()) > 0 {
+			var run CronRun
+			if err := cmd.Scan(&run); err == nil {
+				resp.LastRun = &run
+			}
+		}
+		out = append(out, resp)
+	}
+	respondJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// --- Custom Fields ---
+
+// Types of custom fields.
+const (
+	CustomText   = "text"
+	CustomNumber = "number"
+	CustomDate   = "date"   // YYYY-MM-DD
+	CustomSelect = "select" // one of Options
+)
+
+// MaxCustomTextLength caps the value of a text field, in characters.
+const MaxCustomTextLength = 500
+
+// customFieldName keeps names safe as Mongo field paths.
+var customFieldName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,31}$`)
+
+// CustomField defines a field the todos of a team carry beyond the built-in
+// ones. Values are stored per todo and validated on write.
+type CustomField struct {
+	Name    string   `json:"name"`
+	Label   string   `json:"label,omitempty"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"` // choices of a select
+}
+
+func (f CustomField) hasOption(s string) bool {
+	for _, o := range f.Options {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCustomFields validates definitions, which must have unique names.
+func checkCustomFields(defs []CustomField) error {
+	seen := map[string]bool{}
+	for _, f := range defs {
+		if !customFieldName.MatchString(f.Name) {
+			return fmt.Errorf("invalid custom field name %q", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate custom field %q", f.Name)
+		}
+		seen[f.Name] = true
+		switch f.Type {
+		case CustomText, CustomNumber, CustomDate:
+		case CustomSelect:
+			if len(f.Options) == 0 {
+				return fmt.Errorf("select field %q needs options", f.Name)
+			}
+		default:
+			return fmt.Errorf("custom field %q: invalid type %q: use text, number, date or select", f.Name, f.Type)
+		}
+	}
+	return nil
+}
+
+// defaultCustomFields reads the instance-wide definitions from
+// CUSTOM_FIELDS, a JSON list.
+func defaultCustomFields() ([]CustomField, error) {
+	v := os.Getenv("CUSTOM_FIELDS")
+	if v == "" {
+		return nil, nil
+	}
+	var defs []CustomField
+	if err := json.Unmarshal([]byte(v), &defs); err != nil {
+		return nil, fmt.Errorf("parsing CUSTOM_FIELDS: %w", err)
+	}
+	return defs, checkCustomFields(defs)
+}
+
+// customFieldsFor returns the custom fields of the request's tenant: its
+// own from TENANTS_FILE when it has any, else the instance-wide ones.
+func (app *App) customFieldsFor(ctx context.Context) []CustomField {
+	if own := tenantFrom(ctx).CustomFields; len(own) > 0 {
+		return own
+	}
+	return app.customFields
+}
+
+// validateCustom checks values against defs and returns them normalized:
+// text, dates and selects as strings, numbers as float64. Per-field
+// errors are keyed custom.<name>. In updates a null value removes the
+// field and is kept as nil.
+func validateCustom(defs []CustomField, values map[string]interface{}, update bool) (map[string]interface{}, map[string]string) {
+	byName := map[string]CustomField{}
+	for _, f := range defs {
+		byName[f.Name] = f
+	}
+	out := map[string]interface{}{}
+	fields := map[string]string{}
+	for name, v := range values {
+		key := "custom." + name
+		f, ok := byName[name]
+		if !ok {
+			fields[key] = "is not a custom field"
+			continue
+		}
+		if v == nil {
+			if update {
+				out[name] = nil
+			}
+			continue
+		}
+		switch f.Type {
+		case CustomNumber:
+			n, ok := v.(float64)
+			if !ok {
+				fields[key] = "must be a number"
+				continue
+			}
+			out[name] = n
+		case CustomDate:
+			s, _ := v.(string)
+			d, err := time.Parse(time.DateOnly, s)
+			if err != nil {
+				fields[key] = "must be a date as YYYY-MM-DD"
+				continue
+			}
+			out[name] = d.Format(time.DateOnly)
+		case CustomSelect:
+			s, _ := v.(string)
+			if !f.hasOption(s) {
+				fields[key] = "must be one of " + strings.Join(f.Options, ", ")
+				continue
+			}
+			out[name] = s
+		default:
+			s, ok := v.(string)
+			if !ok || utf8.RuneCountInString(s) > MaxCustomTextLength {
+				fields[key] = fmt.Sprintf("must be text of at most %d characters", MaxCustomTextLength)
+				continue
+			}
+			out[name] = s
+		}
+	}
+	if len(fields) > 0 {
+		return nil, fields
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// listCustomFields serves GET /custom-fields: the definitions todos of
+// the request's tenant are validated against.
+func (app *App) listCustomFields(w http.ResponseWriter, r *http.Request) {
+	defs := app.customFieldsFor(r.Context())
+	if defs == nil {
+		defs = []CustomField{}
+	}
+	respondJSON(w, http.StatusOK, defs)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Dead Letters ---
+
+// DefaultDeadLetterLimit is how many dead letters a listing returns
+// without ?limit=.
+const DefaultDeadLetterLimit = 100
+
+// OutboxDeadLetter is an outbox message the dispatcher gave up relaying:
+// the payload sinks would have received and why they refused it.
+type OutboxDeadLetter struct {
+	OutboxPayload
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError"`
+}
+
+// PurgeResponse reports how many dead letters were deleted.
+type PurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// requireOutbox answers 404 when no outbox sink is configured.
+func (app *App) requireOutbox(w http.ResponseWriter) bool {
+	if app.Outbox == nil {
+		respondError(w, http.StatusNotFound, "The outbox is off")
+		return false
+	}
+	return true
+}
+
+// listOutboxDead serves GET /admin/outbox/dead?offset=&limit=.
+func (app *App) listOutboxDead(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w) {
+		return
+	}
+	offset, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 {
+		limit = DefaultDeadLetterLimit
+	}
+	msgs, err := app.Outbox.DeadLetters(r.Context(), offset, limit)
+	if err != nil {
+		serverError(w, r, "Failed to load dead letters", err)
+		return
+	}
+	out := make([]OutboxDeadLetter, len(msgs))
+	for i, msg := range msgs {
+		out[i] = OutboxDeadLetter{OutboxPayload: newOutboxPayload(msg), Attempts: msg.Attempts, LastError: msg.LastError}
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// retryOutboxDead serves POST /admin/outbox/dead/{id}/retry, handing the
+// message back to the dispatcher with a fresh set of attempts.
+func (app *App) retryOutboxDead(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w) {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	err = app.Outbox.Revive(r.Context(), id, time.Now().UTC())
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "Dead letter not found")
+		return
+	}
+	if err != nil {
+		serverError(w, r, "Failed to retry dead letter", err)
+		return
+	}
+	app.audit(r, "outbox.retry", "outbox:"+id.Hex())
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// purgeOutboxDead serves DELETE /admin/outbox/dead, deleting every dead
+// message, and DELETE /admin/outbox/dead/{id}, deleting one.
+func (app *App) purgeOutboxDead(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w) {
+		return
+	}
+	var ids []primitive.ObjectID
+	if raw := chi.URLParam(r, "id"); raw != "" {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		ids = append(ids, id)
+	}
+	n, err := app.Outbox.PurgeDead(r.Context(), ids)
+	if err != nil {
+		serverError(w, r, "Failed to purge dead letters", err)
+		return
+	}
+	if len(ids) > 0 && n == 0 {
+		respondError(w, http.StatusNotFound, "Dead letter not found")
+		return
+	}
+	target := "outbox:dead"
+	if len(ids) > 0 {
+		target = "outbox:" + ids[0].Hex()
+	}
+	app.audit(r, "outbox.purge", target)
+	respondJSON(w, http.StatusOK, PurgeResponse{Purged: n})
+}
+
+// deadJobs returns the dead-lettered jobs with the raw list entries they
+// were read from, newest first.
+func (app *App) deadJobs(ctx context.Context) ([]Job, []string, error) {
+	raw, err := app.RedisClient.LRange(ctx, jobDeadKey, 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs := make([]Job, 0, len(raw))
+	entries := make([]string, 0, len(raw))
+	for _, item := range raw {
+		var job Job
+		if err := json.Unmarshal([]byte(item), &job); err == nil {
+			jobs = append(jobs, job)
+			entries = append(entries, item)
+		}
+	}
+	return jobs, entries, nil
+}
+
+// listJobsDead serves GET /admin/jobs/dead?offset=&limit=.
+func (app *App) listJobsDead(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 {
+		limit = DefaultDeadLetterLimit
+	}
+	jobs, _, err := app.deadJobs(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to load dead letters", err)
+		return
+	}
+	if offset > len(jobs) {
+		offset = len(jobs)
+	}
+	jobs = jobs[offset:]
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	respondJSON(w, http.StatusOK, jobs)
+}
+
+// retryJobsDead serves POST /admin/jobs/dead/{id}/retry, queueing the job
+// again with a fresh set of attempts.
+func (app *App) retryJobsDead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobs, entries, err := app.deadJobs(ctx)
+	if err != nil {
+		serverError(w, r, "Failed to load dead letters", err)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	for i, job := range jobs {
+		if job.ID != id {
+			continue
+		}
+		// Whoever removes the entry requeues it, so a double click runs it once
+		if n, err := app.RedisClient.LRem(ctx, jobDeadKey, 1, entries[i]).Result(); err != nil || n == 0 {
+			break
+		}
+		job.Attempts, job.LastError, job.FailedAt = 0, "", nil
+		if err := app.pushJob(ctx, job); err != nil {
+			serverError(w, r, "Failed to retry dead letter", err)
+			return
+		}
+		app.audit(r, "job.retry", "job:"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	respondError(w, http.StatusNotFound, "Dead letter not found")
+}
+
+// purgeJobsDead serves DELETE /admin/jobs/dead, deleting every dead job,
+// and DELETE /admin/jobs/dead/{id}, deleting one.
+func (app *App) purgeJobsDead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		n, err := app.RedisClient.LLen(ctx, jobDeadKey).Result()
+		if err == nil {
+			err = app.RedisClient.Del(ctx, jobDeadKey).Err()
+		}
+		if err != nil {
+			serverError(w, r, "Failed to purge dead letters", err)
+			return
+		}
+		app.audit(r, "job.purge", "jobs:dead")
+		respondJSON(w, http.StatusOK, PurgeResponse{Purged: n})
+		return
+	}
+
+	jobs, entries, err := app.deadJobs(ctx)
+	if err != nil {
+		serverError(w, r, "Failed to load dead letters", err)
+		return
+	}
+	for i, job := range jobs {
+		if job.ID != id {
+			continue
+		}
+		n, err := app.RedisClient.LRem(ctx, jobDeadKey, 1, entries[i]).Result()
+		if err != nil {
+			serverError(w, r, "Failed to purge dead letters", err)
+			return
+		}
+		if n > 0 {
+			app.audit(r, "job.purge", "job:"+job.ID)
+			respondJSON(w, http.StatusOK, PurgeResponse{Purged: n})
+			return
+		}
+	}
+	respondError(w, http.StatusNotFound, "Dead letter not found")
+}
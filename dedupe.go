@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Duplicate Titles ---
+
+// Policies for creating a todo whose title matches an open one, chosen
+// with DUPLICATE_TITLES.
+const (
+	DuplicatesAllow  = "allow"
+	DuplicatesWarn   = "warn"   // create it, naming the original in X-Duplicate-Of
+	DuplicatesReject = "reject" // refuse with 409
+)
+
+func duplicatePolicy() string {
+	switch v := os.Getenv("DUPLICATE_TITLES"); {
+	case v == "":
+		return DuplicatesAllow
+	case validDuplicatePolicy(v):
+		return v
+	default:
+		log.Printf("Invalid DUPLICATE_TITLES=%q, using default: %s", v, DuplicatesAllow)
+		return DuplicatesAllow
+	}
+}
+
+func validDuplicatePolicy(v string) bool {
+	return v == DuplicatesAllow || v == DuplicatesWarn || v == DuplicatesReject
+}
+
+// duplicatesFor returns the duplicate policy of the request's tenant.
+func (app *App) duplicatesFor(ctx context.Context) string {
+	if policy := tenantFrom(ctx).Duplicates; policy != "" {
+		return policy
+	}
+	return app.duplicates
+}
+
+// openTodos lists incomplete todos, newest first, with just enough fields
+// to compare titles.
+func (app *App) openTodos(ctx context.Context) ([]store.Todo, error) {
+	open := false
+	return app.Store.List(ctx, store.Query{
+		Fields:    []string{"title", "completed", "createdAt"},
+		Completed: &open,
+	})
+}
+
+// findDuplicate returns the newest open todo with the same store.TitleKey
+// as title.
+func (app *App) findDuplicate(ctx context.Context, title string) (store.Todo, bool, error) {
+	open := false
+	todos, err := app.Store.List(ctx, store.Query{
+		Fields:    []string{"title", "completed", "createdAt"},
+		Completed: &open,
+		Title:     title,
+		Limit:     1,
+	})
+	if err != nil || len(todos) == 0 {
+		return store.Todo{}, false, err
+	}
+	return todos[0], true, nil
+}
+
+// checkDuplicate applies the duplicate policy to a new title, noting the
+// original when the todo may still be created.
+func (app *App) checkDuplicate(ctx context.Context, title string) error {
+	policy := app.duplicatesFor(ctx)
+	if policy == DuplicatesAllow {
+		return nil
+	}
+	dup, found, err := app.findDuplicate(ctx, title)
+	if err != nil {
+		// Don't block creation on a failed check
+		log.Printf("Error checking for duplicate titles: %v", err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+	if policy == DuplicatesReject {
+		return &RuleError{Kind: ErrDuplicate, Fields: map[string]string{"title": "duplicates open todo " + dup.ID.Hex()}}
+	}
+	noticesFrom(ctx).DuplicateOf = dup.ID.Hex()
+	return nil
+}
+
+type DuplicateGroup struct {
+	Kept    string   `json:"kept"`
+	Removed []string `json:"removed"`
+	Merged  []string `json:"merged,omitempty"` // fields of the kept todo filled in from the removed ones
+}
+
+type DeduplicateResponse struct {
+	DryRun  bool             `json:"dryRun"`
+	Groups  []DuplicateGroup `json:"groups"`
+	Removed int              `json:"removed"`
+}
+
+// mergeDuplicate folds the details of dup into kept, setting them in u,
+// and adds the fields it changed to merged. Tags are unioned, descriptions
+// appended, the earlier due date and higher priority win, and fields kept
+// lacks are taken from dup.
+func mergeDuplicate(kept *store.Todo, dup store.Todo, u *store.Update, merged map[string]bool) {
+	for _, tag := range dup.Tags {
+		if !slices.Contains(kept.Tags, tag) {
+			kept.Tags = append(kept.Tags, tag)
+			u.Tags = &kept.Tags
+			merged["tags"] = true
+		}
+	}
+	if desc := strings.TrimSpace(dup.Description); desc != "" && !strings.Contains(kept.Description, desc) {
+		if kept.Description != "" {
+			kept.Description += "\n\n"
+		}
+		kept.Description += desc
+		u.Description = &kept.Description
+		merged["description"] = true
+	}
+	if dup.DueAt != nil && (kept.DueAt == nil || dup.DueAt.Before(*kept.DueAt)) {
+		kept.DueAt = dup.DueAt
+		u.DueAt = dup.DueAt
+		merged["dueAt"] = true
+	}
+	if slices.Index(store.Priorities, dup.Priority) > slices.Index(store.Priorities, kept.Priority) {
+		kept.Priority = dup.Priority
+		u.Priority = &kept.Priority
+		merged["priority"] = true
+	}
+	if kept.Color == "" && dup.Color != "" {
+		kept.Color = dup.Color
+		u.Color = &kept.Color
+		merged["color"] = true
+	}
+	if kept.Location == nil && dup.Location != nil {
+		kept.Location = dup.Location
+		u.Location = dup.Location
+		merged["location"] = true
+	}
+	if kept.Estimate == 0 && dup.Estimate != 0 {
+		kept.Estimate = dup.Estimate
+		u.Estimate = &kept.Estimate
+		merged["estimateMinutes"] = true
+	}
+	for name, value := range dup.Custom {
+		if _, ok := kept.Custom[name]; ok {
+			continue
+		}
+		if kept.Custom == nil {
+			kept.Custom = map[string]interface{}{}
+		}
+		if u.Custom == nil {
+			u.Custom = map[string]interface{}{}
+		}
+		kept.Custom[name], u.Custom[name] = value, value
+		merged["custom"] = true
+	}
+	if dup.Pinned && !kept.Pinned {
+		kept.Pinned = true
+		u.Pinned = &kept.Pinned
+		merged["pinned"] = true
+	}
+	if dup.MyDay && !kept.MyDay {
+		kept.MyDay = true
+		u.MyDay = &kept.MyDay
+		merged["myDay"] = true
+	}
+}
+
+// deduplicateTodos serves POST /todos/deduplicate: open todos with the
+// same store.TitleKey are merged into the oldest of them, which keeps its
+// ID and creation time and takes in the details of the others (see
+// mergeDuplicate) before they're deleted, in one transaction when
+// transactions are on. ?dryRun=true only reports what would be merged.
+func (app *App) deduplicateTodos(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	todos, err := app.openTodos(ctx)
+	if err != nil {
+		serverError(w, r, "Failed to list todos", err)
+		return
+	}
+
+	// Newest first, so the last todo seen per title is the oldest
+	groups := map[string][]primitive.ObjectID{}
+	var order []string
+	for _, todo := range todos {
+		key := store.TitleKey(todo.Title)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], todo.ID)
+	}
+	var ids []primitive.ObjectID
+	for _, group := range groups {
+		if len(group) > 1 {
+			ids = append(ids, group...)
+		}
+	}
+
+	var out DeduplicateResponse
+	err = app.Todos.InTransaction(ctx, func(ctx context.Context) error {
+		// A retried transaction starts over
+		out = DeduplicateResponse{DryRun: dryRun, Groups: []DuplicateGroup{}}
+		if len(ids) == 0 {
+			return nil
+		}
+		full, err := app.Store.List(ctx, store.Query{IDs: ids})
+		if err != nil {
+			return err
+		}
+		byID := make(map[primitive.ObjectID]store.Todo, len(full))
+		for _, todo := range full {
+			byID[todo.ID] = todo
+		}
+		for _, key := range order {
+			group := groups[key]
+			if len(group) < 2 {
+				continue
+			}
+			kept, ok := byID[group[len(group)-1]]
+			if !ok {
+				continue
+			}
+			result := DuplicateGroup{Kept: kept.ID.Hex()}
+			var u store.Update
+			var removed []primitive.ObjectID
+			merged := map[string]bool{}
+			for _, id := range group[:len(group)-1] {
+				dup, ok := byID[id]
+				if !ok {
+					continue // deleted since it was listed
+				}
+				mergeDuplicate(&kept, dup, &u, merged)
+				removed = append(removed, id)
+				result.Removed = append(result.Removed, id.Hex())
+			}
+			if len(removed) == 0 {
+				continue
+			}
+			for field := range merged {
+				result.Merged = append(result.Merged, field)
+			}
+			sort.Strings(result.Merged)
+			if !dryRun {
+				if len(merged) > 0 {
+					if err := app.Todos.Update(ctx, kept.ID, &u); err != nil {
+						return fmt.Errorf("merging into %s: %w", kept.ID.Hex(), err)
+					}
+				}
+				for _, id := range removed {
+					if err := app.Todos.Delete(ctx, id); err != nil {
+						return fmt.Errorf("deleting duplicate %s: %w", id.Hex(), err)
+					}
+				}
+			}
+			out.Removed += len(result.Removed)
+			out.Groups = append(out.Groups, result)
+		}
+		return nil
+	})
+	if err != nil {
+		serverError(w, r, "Failed to merge duplicates", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, out)
+}
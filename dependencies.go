@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Dependencies ---
+
+// Policies for completing a todo whose blockers are still open, chosen with
+// BLOCKED_COMPLETION.
+const (
+	BlockedReject = "reject" // refuse with 409
+	BlockedWarn   = "warn"   // complete it, listing open blockers in X-Blocked-By
+)
+
+func blockedPolicy() string {
+	switch v := os.Getenv("BLOCKED_COMPLETION"); v {
+	case "", BlockedReject:
+		return BlockedReject
+	case BlockedWarn:
+		return v
+	default:
+		log.Printf("Invalid BLOCKED_COMPLETION=%q, using default: %s", v, BlockedReject)
+		return BlockedReject
+	}
+}
+
+func hexIDs(ids []primitive.ObjectID) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, id.Hex())
+	}
+	return out
+}
+
+// dependencyGraph maps every todo to its blockers and completion state.
+type dependencyGraph map[primitive.ObjectID]store.Todo
+
+func (app *App) dependencyGraph(ctx context.Context) (dependencyGraph, error) {
+	todos, err := app.Store.List(ctx, store.Query{Fields: []string{"blockedBy", "completed"}})
+	if err != nil {
+		return nil, err
+	}
+	g := make(dependencyGraph, len(todos))
+	for _, todo := range todos {
+		g[todo.ID] = todo
+	}
+	return g, nil
+}
+
+// reaches reports whether target is reachable from any of from by
+// following blockedBy edges.
+func (g dependencyGraph) reaches(from []primitive.ObjectID, target primitive.ObjectID) bool {
+	seen := map[primitive.ObjectID]bool{}
+	stack := append([]primitive.ObjectID(nil), from...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == target {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		stack = append(stack, g[id].BlockedBy...)
+	}
+	return false
+}
+
+// openBlockers returns those of blockers that exist and aren't completed.
+// Blockers that were deleted no longer block.
+func (g dependencyGraph) openBlockers(blockers []primitive.ObjectID) []primitive.ObjectID {
+	var open []primitive.ObjectID
+	for _, id := range blockers {
+		if todo, ok := g[id]; ok && !todo.Completed {
+			open = append(open, id)
+		}
+	}
+	return open
+}
+
+// isBlocked reports whether todo waits on an open blocker.
+func (g dependencyGraph) isBlocked(todo store.Todo) bool {
+	return len(g.openBlockers(todo.BlockedBy)) > 0
+}
+
+// parseBlockers parses the raw blockedBy IDs of a request, failing with
+// a *store.ValidationError. An empty list clears the todo's blockers.
+func parseBlockers(raw []string) ([]primitive.ObjectID, error) {
+	blockers, err := parseIDs(raw)
+	if err != nil {
+		return nil, &store.ValidationError{Fields: map[string]string{"blockedBy": err.Error()}}
+	}
+	return blockers, nil
+}
+
+// checkBlockers validates the blockers of todo id on write: they must
+// exist, not include the todo itself and not close a cycle.
+func checkBlockers(g dependencyGraph, id primitive.ObjectID, blockers []primitive.ObjectID) error {
+	for _, b := range blockers {
+		if b == id {
+			return &store.ValidationError{Fields: map[string]string{"blockedBy": "a todo can't block itself"}}
+		}
+		if _, ok := g[b]; !ok {
+			return &store.ValidationError{Fields: map[string]string{"blockedBy": fmt.Sprintf("unknown todo %s", b.Hex())}}
+		}
+	}
+	if g.reaches(blockers, id) {
+		return &RuleError{Kind: ErrDependencyCycle, Fields: map[string]string{"blockedBy": "would create a dependency cycle"}}
+	}
+	return nil
+}
+
+// checkCompletable applies the blocked-completion policy to completing a
+// todo with the given blockers, noting the open ones when it's let
+// through.
+func (app *App) checkCompletable(ctx context.Context, g dependencyGraph, blockers []primitive.ObjectID) error {
+	open := g.openBlockers(blockers)
+	if len(open) == 0 {
+		return nil
+	}
+	if app.blocked == BlockedWarn {
+		noticesFrom(ctx).BlockedBy = open
+		return nil
+	}
+	return &RuleError{
+		Kind:   ErrBlocked,
+		Fields: map[string]string{"blockedBy": fmt.Sprintf("%d open blocker(s): %v", len(open), hexIDs(open))},
+	}
+}
+
+// blockedIDs returns the open todos waiting on at least one open blocker,
+// for GET /todos?state=blocked.
+func (app *App) blockedIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	g, err := app.dependencyGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var blocked []primitive.ObjectID
+	for id, todo := range g {
+		if !todo.Completed && g.isBlocked(todo) {
+			blocked = append(blocked, id)
+		}
+	}
+	if len(blocked) == 0 {
+		// An empty Query.IDs means all todos; the nil ID matches none
+		blocked = []primitive.ObjectID{primitive.NilObjectID}
+	}
+	return blocked, nil
+}
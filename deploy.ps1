@@ -17,7 +17,9 @@ if ($LASTEXITCODE -ne 0) {
 
 # Step 2: Build Docker image
 Write-Host "`n[2/4] Building Docker image..." -ForegroundColor Green
-docker build -t ${ACR_LOGIN_SERVER}/${IMAGE_NAME}:${IMAGE_TAG} .
+$COMMIT = git rev-parse --short HEAD
+$BUILD_TIME = (Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
+docker build --build-arg VERSION=$IMAGE_TAG --build-arg COMMIT=$COMMIT --build-arg BUILD_TIME=$BUILD_TIME -t ${ACR_LOGIN_SERVER}/${IMAGE_NAME}:${IMAGE_TAG} .
 
 if ($LASTEXITCODE -ne 0) {
     Write-Host "Failed to build Docker image" -ForegroundColor Red
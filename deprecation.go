@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// --- API Deprecation ---
+
+// Deprecation schedules a route for removal. Routes opt in where they are
+// declared in setupRoutes:
+//
+//	r.With(app.deprecated(Deprecation{
+//		Route:  "POST /todos/{id}/delete",
+//		Since:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+//		Sunset: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+//		Link:   "https://example.com/docs/migrating-deletes",
+//	})).Post("/delete", app.deleteTodoForm)
+type Deprecation struct {
+	Route  string    `json:"route"` // "METHOD /pattern", as reported to admins
+	Since  time.Time `json:"since"`
+	Sunset time.Time `json:"sunset"`
+	Link   string    `json:"link,omitempty"` // migration guide or successor
+}
+
+// Redis hashes, per deprecated route, keyed by apiClientID.
+const (
+	deprecationCallsKey  = "deprecations:calls:"
+	deprecationSeenKey   = "deprecations:seen:"
+	deprecationAgentsKey = "deprecations:agents:"
+)
+
+// deprecated registers d and returns middleware that advertises it via the
+// Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers and counts
+// calls per client.
+func (app *App) deprecated(d Deprecation) func(http.Handler) http.Handler {
+	app.deprecations = append(app.deprecations, d)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+			if !d.Sunset.IsZero() {
+				h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+			}
+
+			client := apiClientID(r)
+			pipe := app.RedisClient.Pipeline()
+			pipe.HIncrBy(r.Context(), deprecationCallsKey+d.Route, client, 1)
+			pipe.HSet(r.Context(), deprecationSeenKey+d.Route, client, time.Now().UTC().Format(time.RFC3339))
+			pipe.HSet(r.Context(), deprecationAgentsKey+d.Route, client, r.UserAgent())
+			if _, err := pipe.Exec(r.Context()); err != nil {
+				log.Printf("Error recording deprecated call to %s: %v", d.Route, err)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type deprecationClient struct {
+	Client    string `json:"client"`
+	Calls     int64  `json:"calls"`
+	LastSeen  string `json:"lastSeen,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+type deprecationReportEntry struct {
+	Deprecation
+	Calls   int64               `json:"calls"`
+	Clients []deprecationClient `json:"clients"`
+}
+
+// deprecationReport lists every deprecated route with the clients still
+// calling it, busiest first.
+func (app *App) deprecationReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	report := make([]deprecationReportEntry, 0, len(app.deprecations))
+
+	for _, d := range app.deprecations {
+		calls, err := app.RedisClient.HGetAll(ctx, deprecationCallsKey+d.Route).Result()
+		if err != nil {
+			serverError(w, r, "Failed to load deprecation usage", err)
+			return
+		}
+		seen, _ := app.RedisClient.HGetAll(ctx, deprecationSeenKey+d.Route).Result()
+		agents, _ := app.RedisClient.HGetAll(ctx, deprecationAgentsKey+d.Route).Result()
+
+		entry := deprecationReportEntry{Deprecation: d, Clients: []deprecationClient{}}
+		for client, count := range calls {
+			n, _ := strconv.ParseInt(count, 10, 64)
+			entry.Calls += n
+			entry.Clients = append(entry.Clients, deprecationClient{
+				Client:    client,
+				Calls:     n,
+				LastSeen:  seen[client],
+				UserAgent: agents[client],
+			})
+		}
+		sort.Slice(entry.Clients, func(i, j int) bool {
+			return entry.Clients[i].Calls > entry.Clients[j].Calls
+		})
+		report = append(report, entry)
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Detail Page ---
+
+// detailPage is the data rendered by detailTemplate.
+type detailPage struct {
+	Todo       store.Todo
+	BlockedBy  []store.Todo  // todos to finish first
+	Blocking   []store.Todo  // todos waiting on this one
+	History    []store.Event // nil without event sourcing
+	Priorities []string
+	Colors     []string
+	Error      string   // why the last edit was rejected
+	Flash      *Flash   // the outcome of the last form action
+	Due        *dueView // nil without a due date or once completed
+	DueDate    string   // the due date in the client's zone, for the date input
+}
+
+// viewTodo serves GET /todos/{id}/view, the HTML detail page of a todo.
+func (app *App) viewTodo(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todo, err := app.Store.Get(r.Context(), objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+	app.renderDetail(w, r, todo, http.StatusOK, "")
+}
+
+// renderDetail renders the detail page of todo with its dependencies and,
+// when event sourcing is on, its history.
+func (app *App) renderDetail(w http.ResponseWriter, r *http.Request, todo store.Todo, status int, msg string) {
+	ctx := r.Context()
+	others, err := app.Store.List(ctx, store.Query{Fields: []string{"title", "blockedBy", "completed"}})
+	if err != nil {
+		serverError(w, r, "Failed to load todo", err)
+		return
+	}
+	page := detailPage{Todo: todo, Priorities: store.Priorities, Colors: store.Colors, Error: msg, Flash: takeFlash(w, r)}
+	loc := app.userLocation(r)
+	page.Due = describeDue(todo, time.Now(), loc)
+	if todo.DueAt != nil {
+		page.DueDate = todo.DueAt.In(loc).Format(DueDateLayout)
+	}
+	blockers := map[primitive.ObjectID]bool{}
+	for _, id := range todo.BlockedBy {
+		blockers[id] = true
+	}
+	for _, other := range others {
+		if blockers[other.ID] {
+			page.BlockedBy = append(page.BlockedBy, other)
+		}
+		for _, id := range other.BlockedBy {
+			if id == todo.ID {
+				page.Blocking = append(page.Blocking, other)
+			}
+		}
+	}
+	if app.Events != nil {
+		if page.History, err = app.Events.TodoEvents(ctx, todo.ID); err != nil {
+			log.Printf("Error loading history of todo %s: %v", todo.ID.Hex(), err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	app.Detail.Execute(w, page)
+}
+
+// editTodoForm serves POST /todos/{id}/edit for the detail page's edit
+// form and the list's inline one, updating the fields the form has. It
+// redirects back to the detail page, or the list with next=list, and
+// renders the detail page with the error when the edit is invalid.
+func (app *App) editTodoForm(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+
+	// Fields the form doesn't have are left untouched
+	field := func(name string) *string {
+		if _, ok := r.PostForm[name]; !ok {
+			return nil
+		}
+		v := r.PostForm.Get(name)
+		return &v
+	}
+	update := store.Update{Title: field("title"), Description: field("description"), Priority: field("priority"), Color: field("color")}
+	if tags := field("tags"); tags != nil {
+		list := splitList(*tags)
+		update.Tags = &list
+	}
+	ctx, notices := withNotices(ctx)
+	if err := app.Todos.Update(ctx, objID, &update); err != nil {
+		if status, body, ok := errorResponse(err); ok {
+			app.renderDetail(w, r, todo, status, formMessage(body))
+			return
+		}
+		serverError(w, r, "Failed to update", err)
+		return
+	}
+	app.passNotices(w, r, "todo:"+objID.Hex(), notices)
+	setFlash(w, FlashSuccess, "Todo saved")
+	if r.PostForm.Get("next") == "list" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/todos/"+objID.Hex()+"/view", http.StatusSeeOther)
+}
+
+// DueDateLayout is the format of the date inputs of the HTML forms.
+const DueDateLayout = "2006-01-02"
+
+// updateTodoForm serves POST /todos/{id}/update, the no-JavaScript way to
+// change a todo's title, completion and due date (a date in the client's
+// zone, "" to clear)
+// from the HTML pages. The fields the form has are updated, and the
+// client goes back to the page it came from with a flash message saying
+// how it went.
+func (app *App) updateTodoForm(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	fail := func(msg string) {
+		setFlash(w, FlashError, msg)
+		redirectBack(w, r, "/")
+	}
+
+	var update store.Update
+	if values, ok := r.PostForm["title"]; ok {
+		update.Title = &values[len(values)-1]
+	}
+	// A checkbox sends nothing when unchecked, so forms pair it with a
+	// hidden "false" before it; the last value wins
+	if values, ok := r.PostForm["completed"]; ok {
+		v := values[len(values)-1]
+		completed, err := strconv.ParseBool(v)
+		if v == "on" {
+			completed, err = true, nil
+		}
+		if err != nil {
+			fail("Completed must be true or false")
+			return
+		}
+		update.Completed = &completed
+	}
+	if values, ok := r.PostForm["dueDate"]; ok {
+		var due time.Time
+		if v := values[len(values)-1]; v != "" {
+			if due, err = time.ParseInLocation(DueDateLayout, v, app.userLocation(r)); err != nil {
+				fail("Due date must be a date like 2030-04-15")
+				return
+			}
+		}
+		update.DueAt = &due
+	}
+
+	ctx, notices := withNotices(r.Context())
+	if err := app.Todos.Update(ctx, objID, &update); err != nil {
+		_, body, ok := errorResponse(err)
+		if !ok {
+			serverError(w, r, "Failed to update", err)
+			return
+		}
+		if errors.Is(err, ErrBlocked) {
+			body.Fields = nil // the IDs mean nothing on the page
+			body.Error += ": finish the todos it depends on first"
+		}
+		fail(formMessage(body))
+		return
+	}
+	app.passNotices(w, r, "todo:"+objID.Hex(), notices)
+	setFlash(w, FlashSuccess, "Todo updated")
+	redirectBack(w, r, "/")
+}
+
+// editTodoPartial serves GET /todos/{id}/edit: the list's inline form for
+// a todo's title and description, which the list page swaps in for the
+// item.
+func (app *App) editTodoPartial(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todo, err := app.Store.Get(r.Context(), objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	app.Template.ExecuteTemplate(w, "edit-form", todo)
+}
+
+const detailTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Todo.Title}} - Azure Go Todo</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 50px; }
+        .container { max-width: 700px; }
+        .description { white-space: pre-wrap; }
+        .completed { text-decoration: line-through; color: #888; }
+    </style>
+</head>
+<body>
+<div class="container">
+    <p><a href="/">Back to list</a></p>
+    {{with .Flash}}<div class="alert {{.Class}}" role="{{if eq .Kind "error"}}alert{{else}}status{{end}}">{{.Message}}</div>{{end}}
+    {{with .Todo}}
+    <div class="card mb-4"{{if .Color}} style="border-left: 4px solid {{.Color}}"{{end}}>
+        <div class="card-body">
+            <h1 class="h3 {{if .Completed}}completed{{end}}">{{if .Pinned}}&#9733; {{end}}<bdi>{{.Title}}</bdi></h1>
+            <p>
+                <span class="badge bg-info text-dark">{{.CurrentStatus}}</span>
+                {{if .Priority}}<span class="badge bg-secondary">{{.Priority}}</span>{{end}}
+                {{range .Tags}}<span class="badge bg-light text-dark">{{.}}</span>{{end}}
+                {{range $name, $value := .Custom}}<span class="badge border text-dark">{{$name}}: {{$value}}</span>{{end}}
+            </p>
+            {{if .Description}}<p class="description">{{.Description}}</p>{{else}}<p class="text-muted">No description.</p>{{end}}
+            <dl class="row small mb-0">
+                <dt class="col-sm-4">Created</dt><dd class="col-sm-8">{{.CreatedAt.Format "Jan 02 2006, 15:04"}}</dd>
+                {{if .DueAt}}<dt class="col-sm-4">Due</dt><dd class="col-sm-8">{{with $.Due}}{{.At}} <span class="badge {{.Class}}">{{.Label}}</span>{{else}}{{.DueAt.Format "Jan 02 2006, 15:04"}}{{end}}</dd>{{end}}
+                {{if .Estimate}}<dt class="col-sm-4">Estimate</dt><dd class="col-sm-8">{{.Estimate}} min</dd>{{end}}
+                {{if .Progress}}<dt class="col-sm-4">Progress</dt><dd class="col-sm-8"><div class="progress" role="progressbar" aria-label="Progress" aria-valuenow="{{.Progress}}" aria-valuemin="0" aria-valuemax="100"><div class="progress-bar bg-success" style="width: {{.Progress}}%">{{.Progress}}%</div></div></dd>{{end}}
+                {{if .Pomodoros}}<dt class="col-sm-4">Pomodoros</dt><dd class="col-sm-8">{{.Pomodoros}}</dd>{{end}}
+                {{if .Snoozes}}<dt class="col-sm-4">Snoozed</dt><dd class="col-sm-8">{{.Snoozes}} time{{if gt .Snoozes 1}}s{{end}}</dd>{{end}}
+                {{if .Location}}<dt class="col-sm-4">Location</dt><dd class="col-sm-8">{{or .Location.Label "Pinned on the map"}}</dd>{{end}}
+                {{if .CompletedAt}}<dt class="col-sm-4">Completed</dt><dd class="col-sm-8">{{.CompletedAt.Format "Jan 02 2006, 15:04"}}</dd>{{end}}
+            </dl>
+        </div>
+    </div>
+    {{end}}
+
+    <form action="/todos/{{.Todo.ID.Hex}}/update" method="POST" class="card card-body mb-4">
+        <div class="row g-2 align-items-end">
+            <div class="col-auto form-check ms-2">
+                <input type="hidden" name="completed" value="false">
+                <input type="checkbox" id="completed" name="completed" value="true" class="form-check-input"{{if .Todo.Completed}} checked{{end}}>
+                <label class="form-check-label" for="completed">Completed</label>
+            </div>
+            <div class="col">
+                <label class="form-label" for="dueDate">Due date</label>
+                <input type="date" id="dueDate" name="dueDate" class="form-control" value="{{.DueDate}}">
+            </div>
+            <div class="col-auto"><button type="submit" class="btn btn-outline-primary">Update</button></div>
+        </div>
+    </form>
+
+    {{if not .Todo.Completed}}
+    <div class="d-flex flex-wrap gap-3 mb-4">
+        <form action="/todos/{{.Todo.ID.Hex}}/snooze" method="POST">
+            <span class="small text-muted me-2">Snooze</span>
+            <div class="btn-group btn-group-sm" role="group" aria-label="Snooze">
+                <button type="submit" name="until" value="1h" class="btn btn-outline-secondary">1 hour</button>
+                <button type="submit" name="until" value="tomorrow" class="btn btn-outline-secondary">Tomorrow</button>
+                <button type="submit" name="until" value="next-week" class="btn btn-outline-secondary">Next week</button>
+            </div>
+        </form>
+        <form action="/todos/{{.Todo.ID.Hex}}/add-to-today" method="POST">
+            {{if .Todo.MyDay}}
+            <input type="hidden" name="myDay" value="false">
+            <button type="submit" class="btn btn-sm btn-outline-secondary">Remove from My Day</button>
+            {{else}}
+            <button type="submit" class="btn btn-sm btn-outline-primary">Add to My Day</button>
+            {{end}}
+        </form>
+    </div>
+    {{end}}
+
+    {{if or .BlockedBy .Blocking}}
+    <h2 class="h5">Dependencies</h2>
+    <ul class="list-group mb-4">
+        {{range .BlockedBy}}<li class="list-group-item">Blocked by <a href="/todos/{{.ID.Hex}}/view" class="{{if .Completed}}completed{{end}}">{{.Title}}</a></li>{{end}}
+        {{range .Blocking}}<li class="list-group-item">Blocks <a href="/todos/{{.ID.Hex}}/view" class="{{if .Completed}}completed{{end}}">{{.Title}}</a></li>{{end}}
+    </ul>
+    {{end}}
+
+    {{if .History}}
+    <h2 class="h5">History</h2>
+    <ul class="list-group mb-4">
+        {{range .History}}<li class="list-group-item small"><span class="text-muted">{{.At.Format "Jan 02 2006, 15:04"}}</span> {{.Type}}</li>{{end}}
+    </ul>
+    {{end}}
+
+    <h2 class="h5">Edit</h2>
+    {{if .Error}}<div class="alert alert-danger">{{.Error}}</div>{{end}}
+    <form action="/todos/{{.Todo.ID.Hex}}/edit" method="POST" class="card card-body mb-5">
+        <div class="mb-2">
+            <label class="form-label" for="title">Title</label>
+            <input type="text" id="title" name="title" class="form-control" value="{{.Todo.Title}}" dir="auto" required>
+        </div>
+        <div class="mb-2">
+            <label class="form-label" for="description">Description</label>
+            <textarea id="description" name="description" class="form-control" rows="4">{{.Todo.Description}}</textarea>
+        </div>
+        <div class="row mb-2">
+            <div class="col">
+                <label class="form-label" for="priority">Priority</label>
+                <select id="priority" name="priority" class="form-select">
+                    <option value="">None</option>
+                    {{$priority := .Todo.Priority}}{{range .Priorities}}<option value="{{.}}"{{if eq . $priority}} selected{{end}}>{{.}}</option>{{end}}
+                </select>
+            </div>
+            <div class="col">
+                <label class="form-label" for="color">Color</label>
+                <input type="text" id="color" name="color" class="form-control" value="{{.Todo.Color}}" list="colors" placeholder="none">
+                <datalist id="colors">{{range .Colors}}<option value="{{.}}">{{end}}</datalist>
+            </div>
+        </div>
+        <div class="mb-3">
+            <label class="form-label" for="tags">Tags</label>
+            <input type="text" id="tags" name="tags" class="form-control" value="{{range $i, $t := .Todo.Tags}}{{if $i}}, {{end}}{{$t}}{{end}}" placeholder="comma, separated">
+        </div>
+        <div><button type="submit" class="btn btn-primary">Save</button></div>
+    </form>
+</div>
+<script src="/static/timezone.js" defer></script>
+</body>
+</html>
+`
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Due Dates ---
+
+// tzCookie holds the IANA time zone of the browser, set by timezone.js.
+const tzCookie = "tz"
+
+// defaultLocation reads DEFAULT_TIMEZONE, the zone for clients that
+// haven't said theirs; UTC when unset or unknown.
+func defaultLocation() *time.Location {
+	v := os.Getenv("DEFAULT_TIMEZONE")
+	if v == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(v)
+	if err != nil {
+		log.Printf("Invalid DEFAULT_TIMEZONE=%q, using UTC: %v", v, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// userLocation is the time zone of the client making r.
+func (app *App) userLocation(r *http.Request) *time.Location {
+	if c, err := r.Cookie(tzCookie); err == nil && c.Value != "" {
+		if loc, err := time.LoadLocation(c.Value); err == nil {
+			return loc
+		}
+	}
+	return app.timezone
+}
+
+// dueView is how a due date is shown: Class is the badge's Bootstrap
+// classes, red when overdue and amber when due later today, and Label is
+// relative ("due in 2 hours"). At is the date in the client's zone.
+type dueView struct {
+	Class string
+	Label string
+	At    string
+}
+
+// describeDue returns how the due date of todo looks at now in loc, or nil
+// when it has none or is completed.
+func describeDue(todo store.Todo, now time.Time, loc *time.Location) *dueView {
+	if todo.DueAt == nil || todo.Completed {
+		return nil
+	}
+	due := todo.DueAt.In(loc)
+	v := &dueView{Class: "bg-light text-dark border", At: due.Format("Mon Jan 02 2006, 15:04 MST")}
+	d := due.Sub(now)
+	switch {
+	case d < 0:
+		v.Class, v.Label = "bg-danger", "overdue by "+humanizeDuration(-d)
+	case sameDay(due, now.In(loc)):
+		v.Class, v.Label = "bg-warning text-dark", "due in "+humanizeDuration(d)
+	default:
+		v.Label = "due in " + humanizeDuration(d)
+	}
+	return v
+}
+
+// dueViews describes the due dates of todos for the list's rows.
+func dueViews(todos []store.Todo, now time.Time, loc *time.Location) map[primitive.ObjectID]*dueView {
+	views := make(map[primitive.ObjectID]*dueView)
+	for _, todo := range todos {
+		if v := describeDue(todo, now, loc); v != nil {
+			views[todo.ID] = v
+		}
+	}
+	return views
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// humanizeDuration spells d in its largest whole unit, as in "3 days".
+func humanizeDuration(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
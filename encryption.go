@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Field Encryption ---
+
+const (
+	// DataKeyColName holds the wrapped data keys encrypting todo fields
+	DataKeyColName = "data_keys"
+	// keyVaultAPIVersion is the Key Vault REST API version used
+	keyVaultAPIVersion = "7.4"
+)
+
+// keyVault wraps and unwraps data keys with a Key Vault key (the key
+// encryption key) through the REST API, so data keys are only ever stored
+// wrapped. The app registration needs the wrapKey and unwrapKey permissions.
+type keyVault struct {
+	keyURL string // https://<vault>.vault.azure.net/keys/<name>
+	tokens *clientCredentials
+	client *http.Client
+}
+
+// newKeyVault reads KEYVAULT_KEY_URL, KEYVAULT_TENANT_ID, KEYVAULT_CLIENT_ID
+// and KEYVAULT_CLIENT_SECRET.
+func newKeyVault() (*keyVault, error) {
+	keyURL, tenant := os.Getenv("KEYVAULT_KEY_URL"), os.Getenv("KEYVAULT_TENANT_ID")
+	clientID, secret := os.Getenv("KEYVAULT_CLIENT_ID"), os.Getenv("KEYVAULT_CLIENT_SECRET")
+	if keyURL == "" || tenant == "" || clientID == "" || secret == "" {
+		return nil, errors.New("KEYVAULT_KEY_URL, KEYVAULT_TENANT_ID, KEYVAULT_CLIENT_ID and KEYVAULT_CLIENT_SECRET must be set")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &keyVault{
+		keyURL: strings.TrimSuffix(keyURL, "/"),
+		tokens: newClientCredentials(tenant, clientID, secret, "https://vault.azure.net/.default", client),
+		client: client,
+	}, nil
+}
+
+// keyOperation posts value to the key's wrapkey or unwrapkey operation,
+// returning the versioned key ID used and the result.
+func (kv *keyVault) keyOperation(ctx context.Context, keyURL, op string, value []byte) (string, []byte, error) {
+	token, err := kv.tokens.Token(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("key vault token: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{
+		"alg":   "RSA-OAEP-256",
+		"value": base64.RawURLEncoding.EncodeToString(value),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, keyURL+"/"+op+"?api-version="+keyVaultAPIVersion, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := kv.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", nil, fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	var out struct {
+		Kid   string `json:"kid"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", nil, err
+	}
+	result, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(out.Value, "="))
+	return out.Kid, result, err
+}
+
+// wrap encrypts key with the latest version of the Key Vault key.
+func (kv *keyVault) wrap(ctx context.Context, key []byte) (kid string, wrapped []byte, err error) {
+	return kv.keyOperation(ctx, kv.keyURL, "wrapkey", key)
+}
+
+// unwrap decrypts a key wrapped with the Key Vault key version kid.
+func (kv *keyVault) unwrap(ctx context.Context, kid string, wrapped []byte) ([]byte, error) {
+	_, key, err := kv.keyOperation(ctx, kid, "unwrapkey", wrapped)
+	return key, err
+}
+
+// dataKey is a data key as stored in data_keys, wrapped by the key
+// encryption key version KEK.
+type dataKey struct {
+	ID        string    `bson:"_id"`
+	KEK       string    `bson:"kek"`
+	Wrapped   []byte    `bson:"wrapped"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// newDataKey generates a data key, stores it wrapped and returns it.
+func newDataKey(ctx context.Context, coll *mongo.Collection, kv *keyVault) (dataKey, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return dataKey{}, err
+	}
+	kid, wrapped, err := kv.wrap(ctx, key)
+	if err != nil {
+		return dataKey{}, fmt.Errorf("wrapping data key: %w", err)
+	}
+	dk := dataKey{ID: primitive.NewObjectID().Hex(), KEK: kid, Wrapped: wrapped, CreatedAt: time.Now().UTC()}
+	_, err = coll.InsertOne(ctx, dk)
+	return dk, err
+}
+
+// loadDataKeys returns the stored data keys, oldest first.
+func loadDataKeys(ctx context.Context, coll *mongo.Collection) ([]dataKey, error) {
+	cur, err := coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var keys []dataKey
+	err = cur.All(ctx, &keys)
+	return keys, err
+}
+
+// loadKeyring returns the cipher for todo titles and descriptions when
+// FIELD_ENCRYPTION=true, or nil, creating the first data key when there is
+// none.
+func loadKeyring(ctx context.Context, db *mongo.Database) (*store.Keyring, error) {
+	if os.Getenv("FIELD_ENCRYPTION") != "true" {
+		return nil, nil
+	}
+	kv, err := newKeyVault()
+	if err != nil {
+		return nil, err
+	}
+	coll := db.Collection(collectionName(DataKeyColName, ""))
+	if n, err := coll.CountDocuments(ctx, bson.M{}); err != nil {
+		return nil, err
+	} else if n == 0 {
+		dk, err := newDataKey(ctx, coll, kv)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Created data key %s", dk.ID)
+	}
+	return unwrapKeyring(ctx, coll, kv)
+}
+
+// unwrapKeyring unwraps every data key once, encrypting with the newest.
+func unwrapKeyring(ctx context.Context, coll *mongo.Collection, kv *keyVault) (*store.Keyring, error) {
+	keys, err := loadDataKeys(ctx, coll)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no data keys")
+	}
+	plain := map[string][]byte{}
+	for _, dk := range keys {
+		if plain[dk.ID], err = kv.unwrap(ctx, dk.KEK, dk.Wrapped); err != nil {
+			return nil, fmt.Errorf("unwrapping data key %s: %w", dk.ID, err)
+		}
+	}
+	return store.NewKeyring(plain, keys[len(keys)-1].ID)
+}
+
+// cmdRotateDataKey rotates the keys protecting todo fields:
+//
+//	rotate-data-key              new data key for new writes
+//	rotate-data-key -rewrap      re-wrap data keys with the KEK's latest version
+//	rotate-data-key -reencrypt   also re-encrypt every todo with the newest key
+//
+// Servers pick up a new data key on restart. Old data keys are kept, since
+// the event log and outbox may still hold values they encrypted.
+func cmdRotateDataKey(args []string) error {
+	fs := flag.NewFlagSet("rotate-data-key", flag.ExitOnError)
+	rewrap := fs.Bool("rewrap", false, "re-wrap existing data keys instead of adding one, e.g. after rotating the Key Vault key")
+	reencrypt := fs.Bool("reencrypt", false, "re-encrypt todos not yet encrypted with the newest data key")
+	fs.Parse(args)
+
+	kv, err := newKeyVault()
+	if err != nil {
+		return err
+	}
+	_, db, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	coll := db.Collection(collectionName(DataKeyColName, ""))
+	if *rewrap {
+		keys, err := loadDataKeys(ctx, coll)
+		if err != nil {
+			return err
+		}
+		for _, dk := range keys {
+			key, err := kv.unwrap(ctx, dk.KEK, dk.Wrapped)
+			if err != nil {
+				return fmt.Errorf("unwrapping data key %s: %w", dk.ID, err)
+			}
+			kid, wrapped, err := kv.wrap(ctx, key)
+			if err != nil {
+				return fmt.Errorf("wrapping data key %s: %w", dk.ID, err)
+			}
+			if _, err := coll.UpdateByID(ctx, dk.ID, bson.M{"$set": bson.M{"kek": kid, "wrapped": wrapped}}); err != nil {
+				return err
+			}
+		}
+		log.Printf("Re-wrapped %d data key(s)", len(keys))
+	} else {
+		dk, err := newDataKey(ctx, coll, kv)
+		if err != nil {
+			return err
+		}
+		log.Printf("Created data key %s; restart servers to encrypt with it", dk.ID)
+	}
+	if !*reencrypt {
+		return nil
+	}
+
+	keyring, err := unwrapKeyring(ctx, coll, kv)
+	if err != nil {
+		return err
+	}
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(collectionName(ColName, "")) + "(_|$)"}})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		n, err := reencryptTodos(ctx, store.NewMongo(db.Collection(name)), keyring)
+		if err != nil {
+			return fmt.Errorf("%s: after %d todos: %w", name, n, err)
+		}
+		log.Printf("Re-encrypted %d todo(s) in %s", n, name)
+	}
+	return nil
+}
+
+// reencryptTodos rewrites the titles and descriptions of raw's todos that
+// are plaintext or encrypted with an older key, returning how many it did.
+func reencryptTodos(ctx context.Context, raw store.Store, keyring *store.Keyring) (int, error) {
+	var stale []primitive.ObjectID
+	todos, err := raw.List(ctx, store.Query{Fields: []string{"title", "description"}})
+	if err != nil {
+		return 0, err
+	}
+	for _, todo := range todos {
+		for _, v := range []string{todo.Title, todo.Description} {
+			if id, ok := store.IsEncrypted(v); v != "" && (!ok || id != keyring.Current()) {
+				stale = append(stale, todo.ID)
+				break
+			}
+		}
+	}
+
+	encrypted := store.WithEncryption(raw, keyring)
+	for i, id := range stale {
+		todo, err := encrypted.Get(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return i, err
+		}
+		if err := encrypted.Update(ctx, id, store.Update{Title: &todo.Title, Description: &todo.Description}); err != nil {
+			return i, err
+		}
+	}
+	return len(stale), nil
+}
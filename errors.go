@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Errors ---
+
+// ErrQuotaExceeded is matched by every *QuotaError.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrNothingToUndo is returned by TodoService.Restore past the undo window.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Kinds of *RuleError, the changes TodoService refuses under the policies
+// in effect.
+var (
+	ErrDuplicate       = errors.New("duplicate of an open todo")
+	ErrDependencyCycle = errors.New("dependency cycle")
+	ErrBlocked         = errors.New("todo is blocked")
+	ErrContentRejected = errors.New("content rejected by moderation")
+)
+
+// RuleError is a change a policy refused, naming the offending fields. It
+// matches its Kind.
+type RuleError struct {
+	Kind   error
+	Fields map[string]string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Fields)
+}
+
+func (e *RuleError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Codes of ErrorResponse.Code for the error kinds. Other errors get one
+// from errorCode.
+const (
+	CodeNotFound      = "not_found"
+	CodeValidation    = "validation_failed"
+	CodeConflict      = "conflict"
+	CodeQuotaExceeded = "quota_exceeded"
+	CodeNothingToUndo = "nothing_to_undo"
+
+	// CodeContentRejected is the code of text moderation refused
+	CodeContentRejected = "content_rejected"
+)
+
+// errorKinds maps the errors the store and the app return to responses.
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+	msg    string
+}{
+	{store.ErrNotFound, http.StatusNotFound, CodeNotFound, "Todo not found"},
+	{store.ErrValidation, http.StatusBadRequest, CodeValidation, "Validation failed"},
+	{store.ErrConflict, http.StatusConflict, CodeConflict, "Todo already exists"},
+	{ErrQuotaExceeded, http.StatusForbidden, CodeQuotaExceeded, "Quota exceeded"},
+	{ErrNothingToUndo, http.StatusGone, CodeNothingToUndo, "Too late to undo"},
+	{ErrDuplicate, http.StatusConflict, CodeConflict, "Duplicate of an open todo"},
+	{ErrDependencyCycle, http.StatusConflict, CodeConflict, "Dependency cycle"},
+	{ErrBlocked, http.StatusConflict, CodeConflict, "Todo is blocked"},
+	{ErrContentRejected, http.StatusUnprocessableEntity, CodeContentRejected, "Content rejected by moderation"},
+}
+
+// respondErr answers err according to its kind, with the offending fields
+// when it has some. Errors of no kind are 500s, reported with msg.
+func respondErr(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	status, body, ok := errorResponse(err)
+	if !ok {
+		serverError(w, r, msg, err)
+		return
+	}
+	var qerr *QuotaError
+	if errors.As(err, &qerr) && qerr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(qerr.RetryAfter.Seconds())+1))
+	}
+	writeError(w, status, body)
+}
+
+// errorResponse is the status and body respondErr answers err with, or
+// false for errors of no kind.
+func errorResponse(err error) (int, ErrorResponse, bool) {
+	for _, k := range errorKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+		status, body := k.status, ErrorResponse{Error: k.msg, Code: k.code}
+		var verr *store.ValidationError
+		var rerr *RuleError
+		var qerr *QuotaError
+		switch {
+		case errors.As(err, &verr):
+			body.Fields = verr.Fields
+		case errors.As(err, &rerr):
+			body.Fields = rerr.Fields
+		case errors.As(err, &qerr):
+			body.Fields = qerr.Fields
+			if qerr.RetryAfter > 0 {
+				status = http.StatusTooManyRequests
+			}
+		}
+		return status, body, true
+	}
+	return 0, ErrorResponse{}, false
+}
+
+// errorCode is the code of errors written without one: a 400 naming
+// fields is a validation failure, anything else gets its status text in
+// snake case.
+func errorCode(status int, body ErrorResponse) string {
+	if status == http.StatusBadRequest && len(body.Fields) > 0 {
+		return CodeValidation
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
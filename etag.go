@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- HTTP Caching ---
+
+// listVersionKey holds a counter bumped on every todo mutation. ETags are
+// derived from it, so any write changes the ETag of the list and its items.
+const listVersionKey = "todos:version"
+
+// listVersion returns the current list version, seeding the counter with
+// the clock when it's missing so a flushed Redis can't reissue old ETags.
+func listVersion(ctx context.Context, rdb redis.UniversalClient) (int64, error) {
+	key := redisKey(ctx, listVersionKey)
+	v, err := rdb.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		rdb.SetNX(ctx, key, time.Now().UnixNano(), 0)
+		v, err = rdb.Get(ctx, key).Int64()
+	}
+	return v, err
+}
+
+// bumpListVersion invalidates every ETag handed out so far.
+func bumpListVersion(ctx context.Context, rdb redis.UniversalClient) {
+	if _, err := listVersion(ctx, rdb); err == nil {
+		rdb.Incr(ctx, redisKey(ctx, listVersionKey))
+	}
+}
+
+// listETag returns the weak ETag for GET /todos, or "" if the version is
+// unavailable. variant distinguishes representations of the same version,
+// such as different field selections. itemETag scopes it to a single todo.
+func (app *App) listETag(ctx context.Context, variant string) string {
+	v, err := listVersion(ctx, app.RedisClient)
+	if err != nil {
+		return ""
+	}
+	if variant != "" {
+		return `W/"` + strconv.FormatInt(v, 36) + ";" + variant + `"`
+	}
+	return `W/"` + strconv.FormatInt(v, 36) + `"`
+}
+
+func (app *App) itemETag(ctx context.Context, id, variant string) string {
+	v, err := listVersion(ctx, app.RedisClient)
+	if err != nil {
+		return ""
+	}
+	return versionedItemETag(v, id, variant)
+}
+
+func versionedItemETag(v int64, id, variant string) string {
+	if variant != "" {
+		return `W/"` + strconv.FormatInt(v, 36) + "-" + id + ";" + variant + `"`
+	}
+	return `W/"` + strconv.FormatInt(v, 36) + "-" + id + `"`
+}
+
+// readItem returns the todo's ETag and, when cached reads are allowed, its
+// item cache entry (nil on a miss), reading both in one round trip.
+func (app *App) readItem(ctx context.Context, id primitive.ObjectID, variant string) (etag string, cached []byte) {
+	var version, item *redis.StringCmd
+	app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		version = pipe.Get(ctx, redisKey(ctx, listVersionKey))
+		if cachedReads(ctx) {
+			item = pipe.Get(ctx, todoCacheKey(ctx, id))
+		}
+		return nil
+	})
+	switch v, err := version.Int64(); {
+	case err == nil:
+		etag = versionedItemETag(v, id.Hex(), variant)
+	case err == redis.Nil:
+		etag = app.itemETag(ctx, id.Hex(), variant) // seeds the version
+	}
+	if item != nil {
+		cached, _ = item.Bytes()
+	}
+	return etag, cached
+}
+
+// checkNotModified sets the ETag on the response and, when the request's
+// If-None-Match already matches it, answers 304 and reports true.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches implements the weak comparison If-None-Match requires.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- List Export ---
+
+// ExportManifest is manifest.json of a list export: what was exported and
+// when. The todos are in todos.json.
+type ExportManifest struct {
+	List       string `json:"list"`
+	ExportedAt string `json:"exportedAt"`
+	Todos      int    `json:"todos"`
+}
+
+// ExportResponse answers an export stored in Blob Storage.
+type ExportResponse struct {
+	Name  string `json:"name"`
+	Todos int    `json:"todos"`
+	Bytes int64  `json:"bytes"`
+}
+
+// exportList zips a snapshot of the todos tagged list, which is how
+// imported projects and boards are kept (see projectTag).
+func (app *App) exportList(ctx context.Context, list string, now time.Time) ([]byte, int, error) {
+	all, err := app.Store.List(ctx, store.Query{})
+	if err != nil {
+		return nil, 0, err
+	}
+	todos := []store.Todo{}
+	for _, todo := range all {
+		for _, tag := range todo.Tags {
+			if tag == list {
+				todos = append(todos, todo)
+				break
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"manifest.json", ExportManifest{List: list, ExportedAt: formatTime(now), Todos: len(todos)}},
+		{"todos.json", todos},
+	}
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, 0, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(todos), nil
+}
+
+// exportListHandler serves GET /admin/exports/{list}, downloading the
+// list's zip, and POST /admin/exports/{list}, storing it in the backup
+// container's exports/ folder instead.
+func (app *App) exportListHandler(w http.ResponseWriter, r *http.Request) {
+	toBlob := r.Method == http.MethodPost
+	if toBlob && !app.requireBackups(w) {
+		return
+	}
+	list := projectTag(chi.URLParam(r, "list"))
+	if list == "" {
+		respondError(w, http.StatusBadRequest, "Invalid list")
+		return
+	}
+	now := time.Now().UTC()
+	data, n, err := app.exportList(r.Context(), list, now)
+	if err != nil {
+		serverError(w, r, "Failed to export list", err)
+		return
+	}
+	name := "export-" + list + "-" + now.Format("20060102T150405Z") + ".zip"
+
+	if !toBlob {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+	name = "exports/" + backupPrefix(r.Context()) + name
+	if err := app.backups.put(r.Context(), name, "application/zip", data); err != nil {
+		serverError(w, r, "Failed to store export", err)
+		return
+	}
+	app.audit(r, "list.export", "list:"+list)
+	respondJSON(w, http.StatusCreated, ExportResponse{Name: name, Todos: n, Bytes: int64(len(data))})
+}
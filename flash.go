@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Flash Messages ---
+
+// flashCookie carries a Flash from a form action to the page it redirects
+// to, which shows it once.
+const flashCookie = "flash"
+
+// Flash kinds, which pick the banner's color.
+const (
+	FlashSuccess = "success"
+	FlashError   = "error"
+)
+
+// Flash is a one-time message about the last form action.
+type Flash struct {
+	Kind    string
+	Message string
+	Undo    string // ID of a deleted todo the banner offers to restore
+}
+
+// Class is the Bootstrap alert class of f's banner.
+func (f Flash) Class() string {
+	if f.Kind == FlashError {
+		return "alert-danger"
+	}
+	return "alert-success"
+}
+
+// setFlash leaves a message for the next page the client loads.
+func setFlash(w http.ResponseWriter, kind, msg string) {
+	writeFlashCookie(w, url.Values{"kind": {kind}, "msg": {msg}}.Encode(), 0)
+}
+
+// setUndoFlash leaves a success message with an undo button for the
+// todo id, which was just deleted.
+func setUndoFlash(w http.ResponseWriter, msg string, id primitive.ObjectID) {
+	writeFlashCookie(w, url.Values{"kind": {FlashSuccess}, "msg": {msg}, "undo": {id.Hex()}}.Encode(), 0)
+}
+
+// takeFlash returns the message left by setFlash, if any, and clears it.
+func takeFlash(w http.ResponseWriter, r *http.Request) *Flash {
+	c, err := r.Cookie(flashCookie)
+	if err != nil {
+		return nil
+	}
+	writeFlashCookie(w, "", -1)
+	v, err := url.ParseQuery(c.Value)
+	if err != nil || v.Get("msg") == "" {
+		return nil
+	}
+	f := &Flash{Kind: v.Get("kind"), Message: v.Get("msg")}
+	if id, err := primitive.ObjectIDFromHex(v.Get("undo")); err == nil {
+		f.Undo = id.Hex()
+	}
+	return f
+}
+
+func writeFlashCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   os.Getenv("SESSION_COOKIE_INSECURE") != "true",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectBack sends the client back to the page the form was on, when
+// it's one of ours, or to fallback.
+func redirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	to := fallback
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		to = ref.RequestURI()
+	}
+	http.Redirect(w, r, to, http.StatusSeeOther)
+}
+
+// formErrors sends HTML form posts that fail with a 4xx back where they
+// came from with an error flash, where writeError's JSON would leave the
+// browser on a bare error document. Like jsonAPIErrors, it must be
+// innermost; the two never wrap the same request.
+func formErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHTMLForm(r) {
+			w = &formWriter{ResponseWriter: w, r: r}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isHTMLForm reports whether r is a browser's form submission.
+func isHTMLForm(r *http.Request) bool {
+	return r.Method == http.MethodPost &&
+		strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// formWriter marks a response to an HTML form post.
+type formWriter struct {
+	http.ResponseWriter
+	r *http.Request
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *formWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *formWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// flashError redirects back with body as an error flash, naming the
+// offending fields.
+func (w *formWriter) flashError(body ErrorResponse) {
+	setFlash(w, FlashError, formMessage(body))
+	redirectBack(w, w.r, "/")
+}
+
+// formMessage is body as a line for people to read: the offending fields
+// as sentences, such as "Title is required", after what went wrong unless
+// that was only validation.
+func formMessage(body ErrorResponse) string {
+	if len(body.Fields) == 0 {
+		return body.Error
+	}
+	names := make([]string, 0, len(body.Fields))
+	for name := range body.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = strings.ToUpper(name[:1]) + name[1:] + " " + body.Fields[name]
+	}
+	if body.Code == CodeValidation {
+		return strings.Join(names, "; ")
+	}
+	return body.Error + ": " + strings.Join(names, "; ")
+}
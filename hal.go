@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Hypermedia (HAL) ---
+
+// halContentType is negotiated via Accept; ?envelope=true gets the same
+// body as plain application/json for clients that can't set headers.
+const halContentType = "application/hal+json"
+
+// wantsHAL reports whether the client asked for the hypermedia envelope.
+func wantsHAL(r *http.Request) bool {
+	if v, err := strconv.ParseBool(r.URL.Query().Get("envelope")); err == nil {
+		return v
+	}
+	return strings.Contains(r.Header.Get("Accept"), halContentType)
+}
+
+type halLink struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"` // when not GET
+	Title  string `json:"title,omitempty"`
+}
+
+type halLinks map[string]halLink
+
+// halTodo is a todo with the actions available on it.
+type halTodo struct {
+	TodoResponse
+	Links halLinks `json:"_links"`
+}
+
+type halTodoList struct {
+	Links    halLinks `json:"_links"`
+	Count    int      `json:"count"`
+	Offset   int      `json:"offset"`
+	Embedded struct {
+		Todos []interface{} `json:"todos"`
+	} `json:"_embedded"`
+}
+
+func todoLinks(id string) halLinks {
+	self := "/todos/" + id
+	return halLinks{
+		"self":   {Href: self},
+		"update": {Href: self, Method: http.MethodPut},
+		"delete": {Href: self, Method: http.MethodDelete},
+		"toggle": {Href: self, Method: http.MethodPut, Title: `Send {"completed": true|false}`},
+		"status": {Href: self + "/status", Method: http.MethodPost, Title: `Send {"status": "todo"|"in-progress"|"done"}`},
+	}
+}
+
+// respondHAL writes v with the HAL media type when the client asked for it
+// by Accept header, or as plain JSON for ?envelope=true.
+func respondHAL(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if strings.Contains(r.Header.Get("Accept"), halContentType) {
+		w.Header().Set("Content-Type", halContentType)
+	}
+	respondJSON(w, status, v)
+}
+
+// listTodosHAL serves GET /todos wrapped in a HAL envelope, with item
+// actions and, for paged requests, next/prev links.
+func (app *App) listTodosHAL(w http.ResponseWriter, r *http.Request, q store.Query, projected bool) {
+	page, err := app.fetchPage(r, q)
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	todos := page.Todos
+
+	out := halTodoList{
+		Links: halLinks{
+			"self":   {Href: r.URL.RequestURI()},
+			"create": {Href: "/todos", Method: http.MethodPost},
+		},
+		Count:  len(todos),
+		Offset: q.Offset,
+	}
+	if page.Next != "" {
+		out.Links["next"] = halLink{Href: page.Next}
+	}
+	if page.Prev != "" {
+		out.Links["prev"] = halLink{Href: page.Prev}
+	}
+
+	view := todoView(q, projected)
+	out.Embedded.Todos = make([]interface{}, 0, len(todos))
+	for _, todo := range todos {
+		links := todoLinks(todo.ID.Hex())
+		if !projected {
+			out.Embedded.Todos = append(out.Embedded.Todos, halTodo{TodoResponse: newTodoResponse(todo), Links: links})
+			continue
+		}
+		item := view(todo).(map[string]interface{})
+		item["_links"] = links
+		out.Embedded.Todos = append(out.Embedded.Todos, item)
+	}
+	respondHAL(w, r, http.StatusOK, out)
+}
+
+// respondTodo writes a single todo in the format the client negotiated.
+// cached holds its already-encoded plain JSON, if available.
+func respondTodo(w http.ResponseWriter, r *http.Request, resp TodoResponse, cached []byte) {
+	if wantsJSONAPI(r) {
+		respondJSONAPI(w, http.StatusOK, jsonAPIDocument{Data: todoResource(resp)})
+		return
+	}
+	if wantsHAL(r) {
+		respondHAL(w, r, http.StatusOK, halTodo{TodoResponse: resp, Links: todoLinks(resp.ID)})
+		return
+	}
+	if cached == nil {
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(cached)
+}
+
+// decodeCachedTodo parses an item cache entry, reporting false when it
+// isn't a todo with the given ID.
+func decodeCachedTodo(data []byte, id string) (TodoResponse, bool) {
+	var resp TodoResponse
+	if json.Unmarshal(data, &resp) != nil || resp.ID != id {
+		return TodoResponse{}, false
+	}
+	return resp, true
+}
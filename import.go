@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Importers ---
+
+const (
+	// MaxImportUpload caps uploaded board exports
+	MaxImportUpload = 20 << 20
+	// importTTL is how long an import's progress stays queryable
+	importTTL = 24 * time.Hour
+	// importTimeout bounds a whole import running in the background
+	importTimeout = 30 * time.Minute
+)
+
+// importSource fetches the todos of another app. There are no lists here,
+// so projects and boards become a tag on each todo.
+type importSource interface {
+	Fetch(ctx context.Context) ([]store.Todo, error)
+}
+
+// projectTag turns a project or board name into a tag.
+func projectTag(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+// todoistAPI is the Todoist REST API base URL.
+var todoistAPI = "https://api.todoist.com/rest/v2"
+
+// todoistSource imports the active tasks of a Todoist account.
+type todoistSource struct {
+	token  string
+	client *http.Client
+}
+
+func (s *todoistSource) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, todoistAPI+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("todoist responded %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// todoistPriorities maps Todoist's 1 (normal) to 4 (urgent).
+var todoistPriorities = map[int]string{2: "low", 3: "medium", 4: "high"}
+
+func (s *todoistSource) Fetch(ctx context.Context) ([]store.Todo, error) {
+	var projects []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := s.get(ctx, "/projects", &projects); err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, p := range projects {
+		names[p.ID] = p.Name
+	}
+
+	var tasks []struct {
+		Content     string   `json:"content"`
+		Description string   `json:"description"`
+		ProjectID   string   `json:"project_id"`
+		Labels      []string `json:"labels"`
+		Priority    int      `json:"priority"`
+		IsCompleted bool     `json:"is_completed"`
+		CreatedAt   string   `json:"created_at"`
+		Due         *struct {
+			Date     string `json:"date"`
+			Datetime string `json:"datetime"`
+		} `json:"due"`
+	}
+	if err := s.get(ctx, "/tasks", &tasks); err != nil {
+		return nil, err
+	}
+
+	todos := make([]store.Todo, 0, len(tasks))
+	for _, t := range tasks {
+		todo := store.Todo{
+			ID:          primitive.NewObjectID(),
+			Title:       cleanTitle(t.Content),
+			Description: t.Description,
+			Priority:    todoistPriorities[t.Priority],
+			Completed:   t.IsCompleted,
+			CreatedAt:   time.Now(),
+		}
+		if name := names[t.ProjectID]; name != "" {
+			todo.Tags = append(todo.Tags, projectTag(name))
+		}
+		todo.Tags = append(todo.Tags, t.Labels...)
+		if created, err := time.Parse(time.RFC3339, t.CreatedAt); err == nil {
+			todo.CreatedAt = created
+		}
+		if t.Due != nil {
+			if due, err := time.Parse(time.RFC3339, t.Due.Datetime); err == nil {
+				todo.DueAt = &due
+			} else if due, err := time.Parse("2006-01-02", t.Due.Date); err == nil {
+				todo.DueAt = &due
+			}
+		}
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+// trelloExport is the part of a Trello board's JSON export we read.
+type trelloExport struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		Name        string     `json:"name"`
+		Desc        string     `json:"desc"`
+		IDList      string     `json:"idList"`
+		Closed      bool       `json:"closed"`
+		Due         *time.Time `json:"due"`
+		DueComplete bool       `json:"dueComplete"`
+		Labels      []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"cards"`
+}
+
+// trelloSource imports the open cards of an exported board. Cards in a
+// list named "Done" or with a completed due date count as completed.
+type trelloSource struct {
+	board trelloExport
+}
+
+func (s *trelloSource) Fetch(ctx context.Context) ([]store.Todo, error) {
+	lists := map[string]string{}
+	for _, l := range s.board.Lists {
+		if !l.Closed {
+			lists[l.ID] = l.Name
+		}
+	}
+
+	now := time.Now()
+	todos := make([]store.Todo, 0, len(s.board.Cards))
+	for i, c := range s.board.Cards {
+		list, ok := lists[c.IDList]
+		if c.Closed || !ok {
+			continue
+		}
+		done := c.DueComplete || strings.EqualFold(list, "done")
+		todo := store.Todo{
+			ID:          primitive.NewObjectID(),
+			Title:       cleanTitle(c.Name),
+			Description: c.Desc,
+			Tags:        []string{projectTag(s.board.Name)},
+			DueAt:       c.Due,
+			Completed:   done,
+			// Keep the board's card order, first card newest
+			CreatedAt: now.Add(-time.Duration(i) * time.Millisecond),
+		}
+		if done {
+			todo.Status = store.StatusDone
+		}
+		for _, l := range c.Labels {
+			if l.Name != "" {
+				todo.Tags = append(todo.Tags, projectTag(l.Name))
+			}
+		}
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+// ImportProgress is the state of an import, polled via
+// GET /admin/imports/{id}.
+type ImportProgress struct {
+	ID       string `json:"id"`
+	Source   string `json:"source"`
+	State    string `json:"state"` // fetching, importing, done or failed
+	Total    int    `json:"total"`
+	Imported int    `json:"imported"`
+	Skipped  int    `json:"skipped"` // invalid, or an open todo with the same title exists
+	Failed   int    `json:"failed"`
+	Error    string `json:"error,omitempty"`
+}
+
+func importKey(ctx context.Context, id string) string {
+	return redisKey(ctx, "import:"+id)
+}
+
+// saveProgress publishes p in Redis so any instance can report it.
+func (app *App) saveProgress(ctx context.Context, p ImportProgress) {
+	data, _ := json.Marshal(p)
+	app.RedisClient.Set(ctx, importKey(ctx, p.ID), data, importTTL)
+}
+
+// runImport fetches from src and creates its todos, publishing progress
+// every few todos. Todos whose title matches an open todo are skipped,
+// so re-running an import doesn't duplicate it.
+func (app *App) runImport(ctx context.Context, src importSource, p ImportProgress) {
+	fail := func(err error) {
+		log.Printf("Import %s failed: %v", p.ID, err)
+		p.State, p.Error = "failed", err.Error()
+		app.saveProgress(ctx, p)
+	}
+
+	todos, err := src.Fetch(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	open, err := app.openTodos(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	existing := map[string]bool{}
+	for _, todo := range open {
+		existing[store.TitleKey(todo.Title)] = true
+	}
+
+	p.State, p.Total = "importing", len(todos)
+	app.saveProgress(ctx, p)
+	app.Todos.Batch(ctx, func(ctx context.Context) error {
+		for i, todo := range todos {
+			dup := existing[store.TitleKey(todo.Title)]
+			var err error
+			if !dup {
+				err = app.Todos.Insert(ctx, todo)
+			}
+			switch {
+			case dup || errors.Is(err, store.ErrValidation):
+				p.Skipped++
+			case err != nil:
+				p.Failed++
+			default:
+				p.Imported++
+				existing[store.TitleKey(todo.Title)] = true
+			}
+			if i%25 == 0 {
+				app.saveProgress(ctx, p)
+			}
+		}
+		return nil
+	})
+	p.State = "done"
+	app.saveProgress(ctx, p)
+}
+
+// startImport publishes the initial progress, runs the import in the
+// background and answers 202 pointing at its progress.
+func (app *App) startImport(w http.ResponseWriter, r *http.Request, name string, src importSource) {
+	p := ImportProgress{ID: primitive.NewObjectID().Hex(), Source: name, State: "fetching"}
+	app.saveProgress(r.Context(), p)
+
+	go func() {
+		// Keep the request's tenant, not its cancellation
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), importTimeout)
+		defer cancel()
+		app.runImport(ctx, src, p)
+	}()
+
+	w.Header().Set("Location", "/admin/imports/"+p.ID)
+	respondJSON(w, http.StatusAccepted, p)
+}
+
+// importTodoist serves POST /admin/imports/todoist with {"token": "..."}.
+func (app *App) importTodoist(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"token": "is required"},
+		})
+		return
+	}
+	app.startImport(w, r, "todoist", &todoistSource{token: req.Token, client: &http.Client{Timeout: 30 * time.Second}})
+}
+
+// importTrello serves POST /admin/imports/trello with a board's JSON
+// export as the body.
+func (app *App) importTrello(w http.ResponseWriter, r *http.Request) {
+	var board trelloExport
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxImportUpload)).Decode(&board); err != nil {
+		respondError(w, http.StatusBadRequest, "Body must be a Trello board export")
+		return
+	}
+	app.startImport(w, r, "trello", &trelloSource{board: board})
+}
+
+// importProgress serves GET /admin/imports/{id}.
+func (app *App) importProgress(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	data, err := app.RedisClient.Get(r.Context(), importKey(r.Context(), id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		respondError(w, http.StatusNotFound, "Import not found")
+		return
+	}
+	if err != nil {
+		serverError(w, r, "Failed to load import", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
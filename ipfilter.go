@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// --- IP Filtering ---
+
+// ipFilter admits requests by client address and works out that address
+// behind trusted reverse proxies such as Azure Front Door.
+type ipFilter struct {
+	allow   []netip.Prefix // empty admits everyone not denied
+	deny    []netip.Prefix
+	proxies []netip.Prefix // whose X-Forwarded-For is believed
+	// frontDoorID, when set, must match the X-Azure-FDID header for a
+	// proxy's X-Forwarded-For to be believed, so other Front Door
+	// instances can't spoof client addresses
+	frontDoorID string
+}
+
+// newIPFilter reads IP_ALLOWLIST, IP_DENYLIST and TRUSTED_PROXIES (comma-
+// separated CIDRs or addresses) and AZURE_FRONT_DOOR_ID. It returns nil
+// when none is set.
+func newIPFilter() (*ipFilter, error) {
+	f := &ipFilter{frontDoorID: os.Getenv("AZURE_FRONT_DOOR_ID")}
+	for _, list := range []struct {
+		name string
+		dst  *[]netip.Prefix
+	}{{"IP_ALLOWLIST", &f.allow}, {"IP_DENYLIST", &f.deny}, {"TRUSTED_PROXIES", &f.proxies}} {
+		for _, item := range splitList(os.Getenv(list.name)) {
+			prefix, err := parsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", list.name, err)
+			}
+			*list.dst = append(*list.dst, prefix)
+		}
+	}
+	if len(f.allow) == 0 && len(f.deny) == 0 && len(f.proxies) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// parsePrefix parses a CIDR, or a single address as a one-address prefix.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address of the client behind any trusted proxies:
+// X-Forwarded-For is read right to left, skipping proxies, up to the first
+// address not trusted.
+func (f *ipFilter) clientAddr(r *http.Request) netip.Addr {
+	remote := remoteAddr(r)
+	if !containsAddr(f.proxies, remote) {
+		return remote
+	}
+	if f.frontDoorID != "" && r.Header.Get("X-Azure-FDID") != f.frontDoorID {
+		return remote
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !containsAddr(f.proxies, client) {
+			break
+		}
+	}
+	return client
+}
+
+// allowed reports whether addr may reach the app; denials win.
+func (f *ipFilter) allowed(addr netip.Addr) bool {
+	if containsAddr(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || containsAddr(f.allow, addr)
+}
+
+// remoteAddr returns the address of the connection's peer.
+func remoteAddr(r *http.Request) netip.Addr {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, _ := netip.ParseAddr(host)
+	return addr.Unmap()
+}
+
+type clientIPContextKey struct{}
+
+// clientIP returns the address the request came from, behind trusted
+// proxies.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok {
+		return ip
+	}
+	if addr := remoteAddr(r); addr.IsValid() {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// filterIPs refuses requests from addresses the filter doesn't admit with
+// 403, before routing. The exempt paths, e.g. health probes, are always
+// admitted.
+func (app *App) filterIPs(exempt ...string) func(http.Handler) http.Handler {
+	skip := map[string]bool{}
+	for _, path := range exempt {
+		skip[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		if app.ipFilter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := app.ipFilter.clientAddr(r)
+			if !skip[r.URL.Path] && !app.ipFilter.allowed(addr) {
+				respondError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			if addr.IsValid() {
+				r = r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, addr.String()))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
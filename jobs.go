@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Background Jobs ---
+
+const (
+	// DefaultJobConcurrency is how many jobs each instance runs at once
+	// unless JOB_CONCURRENCY says otherwise
+	DefaultJobConcurrency = 4
+	// MaxJobAttempts is how often a job is tried before it's dead-lettered
+	MaxJobAttempts = 5
+	// jobTimeout bounds a single attempt
+	jobTimeout = 5 * time.Minute
+	// jobClaimIdle is how long a job may sit with a worker before another
+	// instance takes it back, e.g. after the first one crashed mid-job
+	jobClaimIdle = 10 * time.Minute
+	// jobDeadLetterSize caps the dead-letter list, dropping the oldest
+	jobDeadLetterSize = 1000
+
+	// Jobs are instance-wide and carry their tenant, so keys aren't
+	// tenant-scoped. Each is touched on its own, which Redis Cluster needs.
+	jobStreamKey    = "jobs:stream"    // stream of jobs ready to run
+	jobGroup        = "workers"        // consumer group shared by every instance
+	jobScheduledKey = "jobs:scheduled" // sorted set of jobs by when they run
+	jobDeadKey      = "jobs:dead"      // list of dead-lettered jobs, newest first
+	jobStatsPrefix  = "jobs:stats:"    // hash of counters per kind
+)
+
+// Job is a unit of background work, queued as JSON.
+type Job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Tenant     string          `json:"tenant,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"` // failed attempts so far
+	LastError  string          `json:"lastError,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	FailedAt   *time.Time      `json:"failedAt,omitempty"` // set once dead-lettered
+}
+
+// jobHandler runs one kind of job. The context carries the job's tenant.
+// A returned error retries the job with backoff.
+type jobHandler func(ctx context.Context, payload json.RawMessage) error
+
+// registerJobs sets up the handler of every job kind.
+func (app *App) registerJobs() {
+	app.jobHandlers = map[string]jobHandler{
+		jobFinishPomodoro: app.finishPomodoroJob,
+	}
+}
+
+// jobBackoff is the delay before retry n (1-based): 10s doubling up to
+// half an hour.
+func jobBackoff(n int) time.Duration {
+	d := 10 * time.Second << (n - 1)
+	if n > 8 || d > 30*time.Minute {
+		return 30 * time.Minute
+	}
+	return d
+}
+
+func newJob(ctx context.Context, kind string, payload interface{}) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	return Job{
+		ID:         primitive.NewObjectID().Hex(),
+		Kind:       kind,
+		Tenant:     store.TenantFrom(ctx),
+		Payload:    data,
+		EnqueuedAt: time.Now().UTC(),
+	}, nil
+}
+
+// enqueueJob queues a job of the given kind to run as soon as a worker is
+// free, in ctx's tenant.
+func (app *App) enqueueJob(ctx context.Context, kind string, payload interface{}) error {
+	job, err := newJob(ctx, kind, payload)
+	if err != nil {
+		return err
+	}
+	return app.pushJob(ctx, job)
+}
+
+// enqueueJobAt queues a job to run once at has passed.
+func (app *App) enqueueJobAt(ctx context.Context, kind string, payload interface{}, at time.Time) error {
+	job, err := newJob(ctx, kind, payload)
+	if err != nil {
+		return err
+	}
+	return app.scheduleJob(ctx, job, at)
+}
+
+func (app *App) pushJob(ctx context.Context, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return app.RedisClient.XAdd(ctx, &redis.XAddArgs{Stream: jobStreamKey, Values: []interface{}{"job", data}}).Err()
+}
+
+func (app *App) scheduleJob(ctx context.Context, job Job, at time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return app.RedisClient.ZAdd(ctx, jobScheduledKey, redis.Z{Score: float64(at.UnixMilli()), Member: data}).Err()
+}
+
+// runJobs works the queue with the given number of workers until ctx is
+// done, then waits for the jobs in hand to finish.
+func (app *App) runJobs(ctx context.Context, concurrency int) {
+	err := app.RedisClient.XGroupCreateMkStream(ctx, jobStreamKey, jobGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		log.Printf("Error creating job consumer group: %v", err)
+		return
+	}
+	consumer := instanceName()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.jobWorker(ctx, consumer)
+		}()
+	}
+	app.promoteJobs(ctx)
+	wg.Wait()
+}
+
+// jobWorker runs jobs from the stream one at a time until ctx is done.
+func (app *App) jobWorker(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		streams, err := app.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    jobGroup,
+			Consumer: consumer,
+			Streams:  []string{jobStreamKey, ">"},
+			Count:    1,
+			Block:    2 * time.Second,
+		}).Result()
+		if err == redis.Nil || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error reading jobs: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				app.handleJobMessage(ctx, msg)
+			}
+		}
+	}
+}
+
+// promoteJobs moves scheduled jobs whose time has come onto the stream
+// and requeues jobs left with a worker for too long, every second until
+// ctx is done.
+func (app *App) promoteJobs(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := app.RedisClient.ZRangeByScore(ctx, jobScheduledKey, &redis.ZRangeBy{
+			Min: "-inf", Max: fmt.Sprint(time.Now().UnixMilli()), Count: 100,
+		}).Result()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error polling scheduled jobs: %v", err)
+		}
+		for _, member := range due {
+			// Whichever instance removes the job queues it
+			if n, err := app.RedisClient.ZRem(ctx, jobScheduledKey, member).Result(); err != nil || n == 0 {
+				continue
+			}
+			var job Job
+			if err := json.Unmarshal([]byte(member), &job); err != nil {
+				log.Printf("Dropping unreadable scheduled job: %v", err)
+				continue
+			}
+			if err := app.pushJob(ctx, job); err != nil {
+				log.Printf("Error queueing job %s: %v", job.ID, err)
+			}
+		}
+
+		stale, err := app.claimStaleJobs(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error reclaiming jobs: %v", err)
+		}
+		for _, msg := range stale {
+			if job, ok := decodeJobMessage(msg); ok {
+				log.Printf("Requeueing job %s (%s), abandoned by its worker", job.ID, job.Kind)
+				if err := app.pushJob(ctx, job); err != nil {
+					continue
+				}
+			}
+			app.ackJob(ctx, msg.ID)
+		}
+	}
+}
+
+// claimStaleJobs claims jobs left with a worker for longer than
+// jobClaimIdle. XAUTOCLAIM would do it in one command, but needs Redis 6.2.
+func (app *App) claimStaleJobs(ctx context.Context) ([]redis.XMessage, error) {
+	pending, err := app.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: jobStreamKey, Group: jobGroup, Start: "-", End: "+", Count: 100,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, p := range pending {
+		if p.Idle >= jobClaimIdle {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return app.RedisClient.XClaim(ctx, &redis.XClaimArgs{
+		Stream: jobStreamKey, Group: jobGroup, Consumer: "reclaimer", MinIdle: jobClaimIdle, Messages: ids,
+	}).Result()
+}
+
+func decodeJobMessage(msg redis.XMessage) (Job, bool) {
+	var job Job
+	data, _ := msg.Values["job"].(string)
+	err := json.Unmarshal([]byte(data), &job)
+	return job, err == nil
+}
+
+// ackJob takes a finished job off the stream.
+func (app *App) ackJob(ctx context.Context, id string) {
+	app.RedisClient.XAck(ctx, jobStreamKey, jobGroup, id)
+	app.RedisClient.XDel(ctx, jobStreamKey, id)
+}
+
+// handleJobMessage runs a job and settles it: done, retried later or
+// dead-lettered. The attempt isn't cut short by shutdown.
+func (app *App) handleJobMessage(ctx context.Context, msg redis.XMessage) {
+	ctx = context.WithoutCancel(ctx)
+	defer app.ackJob(ctx, msg.ID)
+	job, ok := decodeJobMessage(msg)
+	if !ok {
+		log.Printf("Dropping unreadable job %s", msg.ID)
+		return
+	}
+
+	err := app.runJob(ctx, job)
+	if err == nil {
+		app.countJob(ctx, job.Kind, "succeeded")
+		return
+	}
+	job.Attempts++
+	job.LastError = err.Error()
+	app.countJob(ctx, job.Kind, "failed")
+	if _, known := app.jobHandlers[job.Kind]; known && job.Attempts < MaxJobAttempts {
+		log.Printf("Job %s (%s) failed (attempt %d): %v", job.ID, job.Kind, job.Attempts, err)
+		if err := app.scheduleJob(ctx, job, time.Now().Add(jobBackoff(job.Attempts))); err != nil {
+			log.Printf("Error retrying job %s: %v", job.ID, err)
+		}
+		return
+	}
+	log.Printf("Job %s (%s) dead after %d attempt(s): %v", job.ID, job.Kind, job.Attempts, err)
+	if err := app.deadLetterJob(ctx, job); err != nil {
+		log.Printf("Error dead-lettering job %s: %v", job.ID, err)
+	}
+}
+
+// runJob calls the job's handler in its tenant, turning a panic into an
+// error so the worker carries on.
+func (app *App) runJob(ctx context.Context, job Job) (err error) {
+	handler, ok := app.jobHandlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	ctx, cancel := context.WithTimeout(store.WithTenant(ctx, job.Tenant), jobTimeout)
+	defer cancel()
+	if app.tenancy != nil && job.Tenant != "" {
+		if tenant, ok := app.tenancy.lookup(job.Tenant); ok {
+			ctx = context.WithValue(ctx, tenantContextKey{}, tenant)
+		}
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return handler(ctx, job.Payload)
+}
+
+func (app *App) deadLetterJob(ctx context.Context, job Job) error {
+	now := time.Now().UTC()
+	job.FailedAt = &now
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, jobDeadKey, data)
+		pipe.LTrim(ctx, jobDeadKey, 0, jobDeadLetterSize-1)
+		return nil
+	})
+	app.countJob(ctx, job.Kind, "dead")
+	return err
+}
+
+// countJob bumps one of a job kind's counters, shared by every instance.
+func (app *App) countJob(ctx context.Context, kind, outcome string) {
+	app.RedisClient.HIncrBy(ctx, jobStatsPrefix+kind, outcome, 1)
+}
+
+type JobKindStats struct {
+	Succeeded int64 `json:"succeeded" redis:"succeeded"`
+	Failed    int64 `json:"failed" redis:"failed"` // attempts, including those retried
+	Dead      int64 `json:"dead" redis:"dead"`
+}
+
+type JobStatsResponse struct {
+	Ready     int64                   `json:"ready"`     // waiting for a worker
+	Running   int64                   `json:"running"`   // with a worker
+	Scheduled int64                   `json:"scheduled"` // delayed or waiting to retry
+	Dead      int64                   `json:"dead"`
+	Kinds     map[string]JobKindStats `json:"kinds"`
+}
+
+// jobStats serves GET /admin/jobs: queue depths and per-kind counters.
+func (app *App) jobStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	out := JobStatsResponse{Kinds: map[string]JobKindStats{}}
+	length, err := app.RedisClient.XLen(ctx, jobStreamKey).Result()
+	if err != nil && err != redis.Nil {
+		serverError(w, r, "Failed to load job stats", err)
+		return
+	}
+	if pending, err := app.RedisClient.XPending(ctx, jobStreamKey, jobGroup).Result(); err == nil {
+		out.Running = pending.Count
+	}
+	out.Ready = length - out.Running
+	out.Scheduled, _ = app.RedisClient.ZCard(ctx, jobScheduledKey).Result()
+	out.Dead, _ = app.RedisClient.LLen(ctx, jobDeadKey).Result()
+	for kind := range app.jobHandlers {
+		var s JobKindStats
+		if err := app.RedisClient.HGetAll(ctx, jobStatsPrefix+kind).Scan(&s); err != nil {
+			serverError(w, r, "Failed to load job stats", err)
+			return
+		}
+		out.Kinds[kind] = s
+	}
+	respondJSON(w, http.StatusOK, out)
+}
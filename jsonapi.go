@@ -0,0 +1,201 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- JSON:API ---
+
+// jsonAPIContentType selects JSON:API (https://jsonapi.org) rendering via
+// the Accept header.
+const jsonAPIContentType = "application/vnd.api+json"
+
+func wantsJSONAPI(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), jsonAPIContentType)
+}
+
+type jsonAPIResource struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Attributes interface{}       `json:"attributes"`
+	Links      map[string]string `json:"links"`
+}
+
+type jsonAPIDocument struct {
+	Data  interface{}            `json:"data"`
+	Links map[string]string      `json:"links,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
+
+type jsonAPIErrorSource struct {
+	Pointer string `json:"pointer,omitempty"`
+}
+
+type jsonAPIError struct {
+	Status string              `json:"status"`
+	Code   string              `json:"code,omitempty"`
+	Title  string              `json:"title"`
+	Detail string              `json:"detail,omitempty"`
+	Source *jsonAPIErrorSource `json:"source,omitempty"`
+}
+
+// todoAttributes is TodoResponse without the ID, which JSON:API keeps at
+// the resource's top level.
+type todoAttributes struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Priority    string                 `json:"priority,omitempty"`
+	Color       string                 `json:"color,omitempty"`
+	BlockedBy   []string               `json:"blockedBy,omitempty"`
+	Status      string                 `json:"status"`
+	Pomodoros   int                    `json:"pomodoros,omitempty"`
+	Snoozes     int                    `json:"snoozes,omitempty"`
+	Location    *LocationResponse      `json:"location,omitempty"`
+	DueAt       string                 `json:"dueAt,omitempty"`
+	Estimate    int                    `json:"estimateMinutes,omitempty"`
+	Progress    int                    `json:"progressPercent,omitempty"`
+	CalendarID  string                 `json:"calendarEventId,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty"`
+	Pinned      bool                   `json:"pinned,omitempty"`
+	MyDay       bool                   `json:"myDay,omitempty"`
+	Completed   bool                   `json:"completed"`
+	CompletedAt string                 `json:"completedAt,omitempty"`
+	CreatedAt   string                 `json:"createdAt"`
+}
+
+func todoResource(resp TodoResponse) jsonAPIResource {
+	return jsonAPIResource{
+		Type: "todos",
+		ID:   resp.ID,
+		Attributes: todoAttributes{
+			Title:       resp.Title,
+			Description: resp.Description,
+			Tags:        resp.Tags,
+			Priority:    resp.Priority,
+			Color:       resp.Color,
+			BlockedBy:   resp.BlockedBy,
+			Status:      resp.Status,
+			Pomodoros:   resp.Pomodoros,
+			Snoozes:     resp.Snoozes,
+			Location:    resp.Location,
+			DueAt:       resp.DueAt,
+			Estimate:    resp.Estimate,
+			Progress:    resp.Progress,
+			CalendarID:  resp.CalendarID,
+			Custom:      resp.Custom,
+			Pinned:      resp.Pinned,
+			MyDay:       resp.MyDay,
+			Completed:   resp.Completed,
+			CompletedAt: resp.CompletedAt,
+			CreatedAt:   resp.CreatedAt,
+		},
+		Links: map[string]string{"self": "/todos/" + resp.ID},
+	}
+}
+
+// projectedResource renders a sparse fieldset: only the selected fields
+// appear as attributes.
+func projectedResource(todo store.Todo, fields []string) jsonAPIResource {
+	attrs := projectTodo(todo, fields)
+	delete(attrs, "id")
+	id := todo.ID.Hex()
+	return jsonAPIResource{
+		Type:       "todos",
+		ID:         id,
+		Attributes: attrs,
+		Links:      map[string]string{"self": "/todos/" + id},
+	}
+}
+
+func respondJSONAPI(w http.ResponseWriter, status int, doc interface{}) {
+	w.Header().Set("Content-Type", jsonAPIContentType)
+	respondJSON(w, status, doc)
+}
+
+// listTodosJSONAPI serves GET /todos as a JSON:API collection document.
+func (app *App) listTodosJSONAPI(w http.ResponseWriter, r *http.Request, q store.Query, projected bool) {
+	page, err := app.fetchPage(r, q)
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+
+	data := make([]jsonAPIResource, 0, len(page.Todos))
+	for _, todo := range page.Todos {
+		if projected {
+			data = append(data, projectedResource(todo, q.Fields))
+		} else {
+			data = append(data, todoResource(newTodoResponse(todo)))
+		}
+	}
+	doc := jsonAPIDocument{
+		Data:  data,
+		Links: map[string]string{"self": r.URL.RequestURI()},
+		Meta:  map[string]interface{}{"count": len(data), "offset": q.Offset},
+	}
+	if page.Next != "" {
+		doc.Links["next"] = page.Next
+	}
+	if page.Prev != "" {
+		doc.Links["prev"] = page.Prev
+	}
+	respondJSONAPI(w, http.StatusOK, doc)
+}
+
+// jsonAPIErrors makes respondError and writeValidationError emit JSON:API
+// error objects for clients that accept JSON:API. It must be the innermost
+// router middleware so handlers receive its writer unwrapped.
+func jsonAPIErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wantsJSONAPI(r) {
+			w = &jsonAPIWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jsonAPIWriter marks a response as negotiated to JSON:API.
+type jsonAPIWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *jsonAPIWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *jsonAPIWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// jsonAPIErrorDocument converts an error body to JSON:API error objects,
+// one per invalid field when there are any.
+func jsonAPIErrorDocument(status int, body ErrorResponse) map[string][]jsonAPIError {
+	code := strconv.Itoa(status)
+	if len(body.Fields) == 0 {
+		return map[string][]jsonAPIError{"errors": {{Status: code, Code: body.Code, Title: body.Error}}}
+	}
+
+	names := make([]string, 0, len(body.Fields))
+	for name := range body.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]jsonAPIError, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, jsonAPIError{
+			Status: code,
+			Code:   body.Code,
+			Title:  body.Error,
+			Detail: name + " " + body.Fields[name],
+			Source: &jsonAPIErrorSource{Pointer: "/data/attributes/" + name},
+		})
+	}
+	return map[string][]jsonAPIError{"errors": errs}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Locations ---
+
+const (
+	// DefaultNearbyRadius is used when GET /todos/nearby has no ?radius=
+	DefaultNearbyRadius = 1000.0 // meters
+	// MaxNearbyRadius caps ?radius= on GET /todos/nearby
+	MaxNearbyRadius = 100000.0 // meters
+)
+
+// LocationRequest sets a todo's location. On update, an object without
+// lat and lng removes it.
+type LocationRequest struct {
+	Lat   *float64 `json:"lat"`
+	Lng   *float64 `json:"lng"`
+	Label string   `json:"label,omitempty"`
+}
+
+type LocationResponse struct {
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+	Label string  `json:"label,omitempty"`
+}
+
+func newLocationResponse(l *store.Location) *LocationResponse {
+	if !l.Valid() {
+		return nil
+	}
+	return &LocationResponse{Lat: l.Lat(), Lng: l.Lng(), Label: l.Label}
+}
+
+// NearbyTodoResponse is a todo with its distance from the searched point.
+type NearbyTodoResponse struct {
+	TodoResponse
+	DistanceMeters float64 `json:"distanceMeters"`
+}
+
+// Rules of a location's coordinates.
+const (
+	latRule = "must be between -90 and 90"
+	lngRule = "must be between -180 and 180"
+)
+
+// location converts req to a store.Location, returning per-field errors
+// for a missing coordinate; TodoService checks their ranges. Without
+// coordinates it returns an empty Location, which clears the todo's
+// location on update.
+func (req *LocationRequest) location() (*store.Location, map[string]string) {
+	if req.Lat == nil && req.Lng == nil {
+		return &store.Location{}, nil
+	}
+	fields := map[string]string{}
+	if req.Lat == nil {
+		fields["location.lat"] = latRule
+	}
+	if req.Lng == nil {
+		fields["location.lng"] = lngRule
+	}
+	if len(fields) > 0 {
+		return nil, fields
+	}
+	return store.NewLocation(*req.Lat, *req.Lng, req.Label), nil
+}
+
+// locationErrors checks the coordinates of a location being written,
+// returning per-field errors. Empty locations have none.
+func locationErrors(l *store.Location) map[string]string {
+	if !l.Valid() {
+		return nil
+	}
+	fields := map[string]string{}
+	if lat := l.Lat(); lat < -90 || lat > 90 {
+		fields["location.lat"] = latRule
+	}
+	if lng := l.Lng(); lng < -180 || lng > 180 {
+		fields["location.lng"] = lngRule
+	}
+	return fields
+}
+
+// parseNear reads ?lat=&lng=&radius= for GET /todos/nearby.
+func parseNear(r *http.Request) (store.Circle, error) {
+	params := r.URL.Query()
+	lat, err := strconv.ParseFloat(params.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return store.Circle{}, fmt.Errorf("lat must be a number between -90 and 90")
+	}
+	lng, err := strconv.ParseFloat(params.Get("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return store.Circle{}, fmt.Errorf("lng must be a number between -180 and 180")
+	}
+	radius := DefaultNearbyRadius
+	if v := params.Get("radius"); v != "" {
+		radius, err = strconv.ParseFloat(v, 64)
+		if err != nil || radius <= 0 || radius > MaxNearbyRadius {
+			return store.Circle{}, fmt.Errorf("radius must be between 0 and %g meters", MaxNearbyRadius)
+		}
+	}
+	return store.Circle{Lat: lat, Lng: lng, RadiusMeters: radius}, nil
+}
+
+// nearbyTodos serves GET /todos/nearby?lat=&lng=&radius=, pinned todos first
+// and otherwise nearest first.
+func (app *App) nearbyTodos(w http.ResponseWriter, r *http.Request) {
+	near, err := parseNear(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todos, err := app.Store.List(r.Context(), store.Query{Fields: store.LeanFields, Near: &near})
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	out := make([]NearbyTodoResponse, 0, len(todos))
+	for _, todo := range todos {
+		out = append(out, NearbyTodoResponse{
+			TodoResponse:   newTodoResponse(todo),
+			DistanceMeters: near.Distance(todo.Location),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Pinned != out[j].Pinned {
+			return out[i].Pinned
+		}
+		return out[i].DistanceMeters < out[j].DistanceMeters
+	})
+	respondJSON(w, http.StatusOK, out)
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,10 +25,11 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/redis/go-redis/v9"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mkgakishi/go-azure-todo/store"
 )
 
 // --- Configuration & Constants ---
@@ -31,6 +38,23 @@ const (
 	DefaultPort   = "8080"
 	DefaultDBName = "TodoDB"
 	ColName       = "todos"
+
+	// DefaultMongoOpTimeout bounds each store call made by request handlers
+	DefaultMongoOpTimeout = 10 * time.Second
+
+	// Default per-route time budgets
+	DefaultReadTimeout  = 2 * time.Second
+	DefaultWriteTimeout = 5 * time.Second
+
+	// DefaultNotFoundTTL is how long a missing todo is remembered
+	DefaultNotFoundTTL = 30 * time.Second
+
+	// MaxPageLimit caps ?limit= on list endpoints
+	MaxPageLimit = 1000
+
+	// DefaultHomePageSize is how many todos the home page renders before
+	// "load more"
+	DefaultHomePageSize = 50
 )
 
 // --- HTML Template ---
@@ -48,93 +72,519 @@ const htmlTemplate = `
         .container { max-width: 600px; }
         .todo-item { background: white; border-radius: 5px; padding: 15px; margin-bottom: 10px; box-shadow: 0 2px 4px rgba(0,0,0,0.05); display: flex; justify-content: space-between; align-items: center; }
         .completed { text-decoration: line-through; color: #888; }
+        .todo-item:focus { outline: 2px solid #0d6efd; outline-offset: 2px; }
     </style>
 </head>
 <body>
-<div class="container">
-    <h1 class="text-center mb-4">Azure Todo App</h1>
-    
+<a href="#todo-list" class="visually-hidden-focusable position-absolute top-0 start-0 m-2 btn btn-light">Skip to todos</a>
+<main class="container">
+    <header class="mb-4">
+        <h1 class="text-center">Azure Todo App</h1>
+        <nav class="text-center mb-2" aria-label="Views"><a href="/today">My Day</a> &middot; <a href="/board">Board view</a> &middot; <a href="/lists/all/print">Print</a></nav>
+        <div class="position-relative" role="search">
+            <input type="search" id="todo-search" class="form-control" placeholder="Search todos (press /)" autocomplete="off"
+                   role="combobox" aria-label="Search todos" aria-keyshortcuts="/" aria-controls="todo-suggestions" aria-autocomplete="list" aria-expanded="false">
+            <div id="todo-suggestions" class="list-group position-absolute w-100 shadow-sm" style="z-index: 10;" role="listbox" aria-label="Suggestions" hidden></div>
+        </div>
+    </header>
+
+    {{with .Flash}}
+    <div class="alert {{.Class}} d-flex align-items-center" role="{{if eq .Kind "error"}}alert{{else}}status{{end}}">
+        <span class="me-auto">{{.Message}}</span>
+        {{if .Undo}}
+        <form action="/todos/{{.Undo}}/restore" method="POST" class="undo-form" data-expires-in="{{$.UndoMs}}">
+            <button type="submit" class="btn btn-sm btn-outline-dark">Undo</button>
+        </form>
+        {{end}}
+    </div>
+    {{end}}
+
     <!-- Create Form -->
-    <div class="card mb-4">
+    <section class="card mb-4" aria-label="New todo">
         <div class="card-body">
             <form action="/todos" method="POST">
                 <div class="input-group">
-                    <input type="text" name="title" class="form-control" placeholder="What needs to be done?" required>
+                    <input type="text" name="title" id="new-todo-title" class="form-control" placeholder="What needs to be done?" aria-label="New todo title" aria-keyshortcuts="n" required>
                     <button class="btn btn-primary" type="submit">Add Todo</button>
                 </div>
             </form>
+            {{if .Templates}}
+            <details class="mt-2">
+                <summary class="text-muted small">Add from template</summary>
+                <div class="list-group mt-2">
+                    {{range .Templates}}
+                    <form action="/todos/from-template/{{.ID.Hex}}" method="POST">
+                        <button type="submit" class="list-group-item list-group-item-action">{{.Name}} <small class="text-muted">{{.Title}}</small></button>
+                    </form>
+                    {{end}}
+                </div>
+            </details>
+            {{end}}
         </div>
-    </div>
+    </section>
+
+    <p id="shortcut-status" class="visually-hidden" role="status" aria-live="polite"></p>
+
+    <!-- Filter Tabs -->
+    <nav aria-label="Filter todos">
+        <ul class="nav nav-tabs mb-3">
+            <li class="nav-item"><a class="nav-link{{if eq .State ""}} active{{end}}"{{if eq .State ""}} aria-current="page"{{end}} href="/">All <span class="badge bg-secondary">{{.Counts.Total}}</span></a></li>
+            <li class="nav-item"><a class="nav-link{{if eq .State "open"}} active{{end}}"{{if eq .State "open"}} aria-current="page"{{end}} href="/?state=open">Active <span class="badge bg-secondary">{{.Counts.Open}}</span></a></li>
+            <li class="nav-item"><a class="nav-link{{if eq .State "completed"}} active{{end}}"{{if eq .State "completed"}} aria-current="page"{{end}} href="/?state=completed">Completed <span class="badge bg-secondary">{{.Counts.Completed}}</span></a></li>
+        </ul>
+    </nav>
+
+    <!-- Selection Toolbar -->
+    {{if .Todos}}
+    <form id="bulk-form" action="/todos/bulk/form" method="POST" class="d-flex flex-wrap gap-2 align-items-center mb-2" aria-label="Selected todos">
+        <input type="checkbox" id="bulk-all" class="form-check-input m-0" aria-label="Select all todos">
+        <span id="bulk-count" class="text-muted small me-auto" aria-live="polite">None selected</span>
+        <button type="submit" name="action" value="complete" class="btn btn-sm btn-outline-success bulk-action">Complete selected</button>
+        <button type="submit" name="action" value="delete" class="btn btn-sm btn-outline-danger bulk-action">Delete selected</button>
+        <div class="input-group input-group-sm w-auto">
+            <select name="color" class="form-select" aria-label="Label for selected todos">
+                <option value="">No label</option>
+                {{range .Colors}}<option value="{{.}}">{{.}}</option>{{end}}
+            </select>
+            <button type="submit" name="action" value="color" class="btn btn-outline-secondary bulk-action">Label selected</button>
+        </div>
+    </form>
+    {{end}}
 
     <!-- Todo List -->
-    <div id="todo-list">
-        {{range .}}
-        <div class="todo-item">
-            <div>
-                <h5 class="mb-1 {{if .Completed}}completed{{end}}">{{.Title}}</h5>
-                <small class="text-muted">{{.CreatedAt.Format "Jan 02, 15:04"}}</small>
-            </div>
-            <div>
-                <form action="/todos/{{.ID.Hex}}/delete" method="POST" style="display:inline;">
-                    <button type="submit" class="btn btn-sm btn-outline-danger">Delete</button>
-                </form>
-            </div>
-        </div>
-        {{else}}
-        <div class="text-center text-muted">
-            <p>No tasks yet. Add one above!</p>
+    <div id="todo-list" role="list" aria-label="Todos" tabindex="-1">
+        {{template "todo-rows" .}}
+        {{if not .Todos}}
+        <div class="text-center text-muted" role="listitem">
+            {{if eq .State "open"}}<p>Nothing left to do.</p>{{else if eq .State "completed"}}<p>No completed tasks yet.</p>{{else}}<p>No tasks yet. Add one above!</p>{{end}}
         </div>
         {{end}}
     </div>
-</div>
+
+    <details class="mt-4 text-muted small">
+        <summary>Keyboard shortcuts</summary>
+        <dl class="row mt-2 mb-0">
+            <dt class="col-2"><kbd>n</kbd></dt><dd class="col-10">New todo</dd>
+            <dt class="col-2"><kbd>/</kbd></dt><dd class="col-10">Search</dd>
+            <dt class="col-2"><kbd>j</kbd> <kbd>k</kbd></dt><dd class="col-10">Next / previous todo</dd>
+            <dt class="col-2"><kbd>e</kbd></dt><dd class="col-10">Edit the focused todo</dd>
+            <dt class="col-2"><kbd>x</kbd></dt><dd class="col-10">Complete the focused todo</dd>
+            <dt class="col-2"><kbd>Esc</kbd></dt><dd class="col-10">Leave search or cancel an edit</dd>
+        </dl>
+    </details>
+</main>
+<footer class="container text-center text-muted small py-4">
+    Azure Go Todo {{.Version}} &middot; <a href="/status" class="text-muted">Status</a>
+</footer>
+<script src="https://unpkg.com/htmx.org@1.9.12" defer></script>
+<script src="/static/typeahead.js" defer></script>
+<script src="/static/bulk.js" defer></script>
+<script src="/static/shortcuts.js" defer></script>
+<script src="/static/timezone.js" defer></script>
+<script>
+// The undo button goes away once the deleted todo can't be restored
+document.querySelectorAll('.undo-form').forEach(function (form) {
+    setTimeout(function () { form.remove(); }, Number(form.dataset.expiresIn));
+});
+
+// Listeners are delegated, so rows added by "load more" get them too
+
+// Inline editing: swap the item for its edit form; cancelling reloads
+document.addEventListener('click', function (e) {
+    var link = e.target.closest('.inline-edit');
+    if (!link) { return; }
+    e.preventDefault();
+    var item = link.closest('.todo-item');
+    fetch('/todos/' + link.dataset.id + '/edit').then(function (resp) {
+        if (!resp.ok) { location.href = link.href; return; }
+        return resp.text().then(function (html) {
+            item.innerHTML = html;
+            item.querySelector('input[name=title]').focus();
+            item.querySelector('.inline-cancel').addEventListener('click', function () { location.reload(); });
+        });
+    });
+});
+
+// Pomodoro: start a session, then follow its ticks until it's done
+document.addEventListener('click', function (e) {
+    var button = e.target.closest('.pomodoro-start');
+    if (!button) { return; }
+    var id = button.dataset.id;
+    fetch('/todos/' + id + '/pomodoro', {method: 'POST'}).then(function (resp) {
+        if (!resp.ok && resp.status !== 409) { return; }
+        var timer = document.getElementById('timer-' + id);
+        var events = new EventSource('/todos/' + id + '/pomodoro/events');
+        events.addEventListener('tick', function (e) {
+            var s = JSON.parse(e.data).remainingSeconds;
+            timer.textContent = Math.floor(s / 60) + ':' + String(s % 60).padStart(2, '0');
+        });
+        events.addEventListener('done', function () { events.close(); location.reload(); });
+    });
+});
+</script>
 </body>
 </html>
-`
+{{define "todo-rows"}}
+{{range .Todos}}
+<div class="todo-item" role="listitem" tabindex="0" data-id="{{.ID.Hex}}" aria-label="{{.Title}}{{if .Completed}} (completed){{end}}"{{if .Color}} style="border-left: 4px solid {{.Color}}"{{end}}>
+    <div class="d-flex align-items-start gap-2">
+        <input type="checkbox" name="id" value="{{.ID.Hex}}" form="bulk-form" class="form-check-input mt-1 bulk-select" aria-label="Select {{.Title}}">
+        <div>
+            <h5 class="mb-1 {{if .Completed}}completed{{end}}"><a href="/todos/{{.ID.Hex}}/view" class="text-reset text-decoration-none todo-title" dir="auto">{{.Title}}</a></h5>
+            {{if .Priority}}<span class="badge bg-secondary">{{.Priority}}</span>{{end}}
+            <span class="badge bg-info text-dark">{{.CurrentStatus}}</span>
+            {{with index $.Due .ID}}<span class="badge {{.Class}}" title="Due {{.At}}">{{.Label}}</span>{{end}}
+            {{if .Pomodoros}}<span class="badge bg-danger">{{.Pomodoros}} pomodoro{{if gt .Pomodoros 1}}s{{end}}</span>{{end}}
+            {{if .Estimate}}<span class="badge bg-light text-dark" title="Estimate">{{.Estimate}} min</span>{{end}}
+            {{range .Tags}}<span class="badge bg-light text-dark">{{.}}</span>{{end}}
+            {{range $name, $value := .Custom}}<span class="badge border text-dark">{{$name}}: {{$value}}</span>{{end}}
+            <small class="text-muted">{{.CreatedAt.Format "Jan 02, 15:04"}}</small>
+            {{if .Progress}}<div class="progress mt-1" role="progressbar" aria-label="Progress of {{.Title}}" aria-valuenow="{{.Progress}}" aria-valuemin="0" aria-valuemax="100" style="height: 4px; max-width: 200px;"><div class="progress-bar bg-success" style="width: {{.Progress}}%"></div></div>{{end}}
+        </div>
+    </div>
+    <div>
+        <form action="/todos/{{.ID.Hex}}/update" method="POST" style="display:inline;">
+            <input type="hidden" name="completed" value="{{not .Completed}}">
+            <button type="submit" class="btn btn-sm btn-outline-success" aria-label="{{if .Completed}}Reopen{{else}}Complete{{end}} {{.Title}}">{{if .Completed}}Reopen{{else}}Done{{end}}</button>
+        </form>
+        <form action="/todos/{{.ID.Hex}}/pin" method="POST" style="display:inline;">
+            <input type="hidden" name="pinned" value="{{not .Pinned}}">
+            <button type="submit" class="btn btn-sm {{if .Pinned}}btn-warning{{else}}btn-outline-warning{{end}}" title="{{if .Pinned}}Unpin{{else}}Pin to top{{end}}" aria-label="Pin {{.Title}}" aria-pressed="{{.Pinned}}">&#9733;</button>
+        </form>
+        <a href="/todos/{{.ID.Hex}}/view" class="btn btn-sm btn-outline-primary inline-edit" data-id="{{.ID.Hex}}" aria-label="Edit {{.Title}}" aria-keyshortcuts="e">Edit</a>
+        <span class="pomodoro-timer text-muted small" id="timer-{{.ID.Hex}}" role="timer" aria-live="off"></span>
+        <button type="button" class="btn btn-sm btn-outline-secondary pomodoro-start" data-id="{{.ID.Hex}}" aria-label="Start a focus session on {{.Title}}">Focus</button>
+        <a href="/todos/{{.ID.Hex}}/delete" class="btn btn-sm btn-outline-danger" aria-label="Delete {{.Title}}">Delete</a>
+    </div>
+</div>
+{{end}}
+{{if .NextOffset}}
+<div class="text-center my-3 load-more" role="listitem">
+    <button type="button" class="btn btn-sm btn-outline-secondary" hx-get="/?offset={{.NextOffset}}&limit={{.Limit}}{{with .State}}&state={{.}}{{end}}" hx-trigger="click, revealed" hx-target="closest .load-more" hx-swap="outerHTML">Load more</button>
+</div>
+{{end}}
+{{end}}
+{{define "edit-form"}}
+<form action="/todos/{{.ID.Hex}}/edit" method="POST" class="w-100">
+    <input type="hidden" name="next" value="list">
+    <input type="text" name="title" class="form-control mb-2" value="{{.Title}}" aria-label="Title" dir="auto" required>
+    <textarea name="description" class="form-control mb-2" rows="2" aria-label="Description" placeholder="Description">{{.Description}}</textarea>
+    <button type="submit" class="btn btn-sm btn-primary">Save</button>
+    <button type="button" class="btn btn-sm btn-outline-secondary inline-cancel">Cancel</button>
+</form>
+{{end}}`
 
 // --- Models ---
 
-type Todo struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Title     string             `json:"title" bson:"title"`
-	Completed bool               `json:"completed" bson:"completed"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
-}
-
 type CreateTodoRequest struct {
-	Title string `json:"title"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Priority    string                 `json:"priority,omitempty"`
+	Color       string                 `json:"color,omitempty"`     // a named color or #rrggbb
+	BlockedBy   []string               `json:"blockedBy,omitempty"` // IDs of todos to finish first
+	Location    *LocationRequest       `json:"location,omitempty"`
+	DueAt       string                 `json:"dueAt,omitempty"` // RFC 3339
+	Estimate    int                    `json:"estimateMinutes,omitempty"`
+	Progress    int                    `json:"progressPercent,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty"` // values of the tenant's custom fields
 }
 
 type UpdateTodoRequest struct {
-	Title     *string `json:"title,omitempty"`
-	Completed *bool   `json:"completed,omitempty"`
+	Title       *string                `json:"title,omitempty"`
+	Description *string                `json:"description,omitempty"`
+	Tags        *[]string              `json:"tags,omitempty"`
+	Priority    *string                `json:"priority,omitempty"`
+	Color       *string                `json:"color,omitempty"` // "" removes it
+	BlockedBy   *[]string              `json:"blockedBy,omitempty"`
+	Location    *LocationRequest       `json:"location,omitempty"`
+	DueAt       *string                `json:"dueAt,omitempty"` // RFC 3339, or "" to remove
+	Estimate    *int                   `json:"estimateMinutes,omitempty"`
+	Progress    *int                   `json:"progressPercent,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty"` // merged into the todo's; null removes a field
+	Pinned      *bool                  `json:"pinned,omitempty"`
+	Completed   *bool                  `json:"completed,omitempty"`
+}
+
+// invalidPriority is the error body for a priority outside store.Priorities.
+var invalidPriority = ErrorResponse{
+	Error:  "Validation failed",
+	Fields: map[string]string{"priority": "must be low, medium or high"},
+}
+
+// invalidColor is the error body for a color store.ValidColor rejects.
+var invalidColor = ErrorResponse{
+	Error:  "Validation failed",
+	Fields: map[string]string{"color": "must be " + strings.Join(store.Colors, ", ") + " or a hex color like #1e90ff"},
+}
+
+// normalizeColor lowercases a requested color so #1E90FF matches #1e90ff.
+func normalizeColor(c string) string {
+	return strings.ToLower(strings.TrimSpace(c))
 }
 
 // --- App Container ---
 
 type App struct {
-	Router      *chi.Mux
-	MongoClient *mongo.Client
-	RedisClient *redis.Client
-	Collection  *mongo.Collection
-	Template    *template.Template
+	Router        *chi.Mux
+	RedisClient   redis.UniversalClient
+	Store         store.Store
+	Template      *template.Template
+	Board         *template.Template
+	Detail        *template.Template
+	BulkConfirm   *template.Template
+	DeleteConfirm *template.Template
+	Print         *template.Template
+	StatusPage    *template.Template
+	Today         *template.Template
+
+	// Templates defaults to an in-memory store; main swaps in Mongo
+	Templates store.TemplateStore
+	// Events is the todo event log when EVENT_SOURCING is on, else nil
+	Events store.EventLog
+	// RefreshTokens defaults to an in-memory store; main swaps in Mongo
+	RefreshTokens store.RefreshTokenStore
+	// Audit records admin actions; it defaults to memory, main swaps in Mongo
+	Audit store.AuditLog
+	// Outbox holds messages waiting to be relayed; nil unless a sink is set
+	Outbox store.Outbox
+	// Transactions groups multi-todo writes; none unless main enables Mongo's
+	Transactions store.Transactions
+	// Todos is where handlers send changes to todos
+	Todos *TodoService
+
+	deprecations []Deprecation
+	notFoundTTL  time.Duration
+	duplicates   string    // DuplicatesAllow, DuplicatesWarn or DuplicatesReject
+	moderation   string    // ModerationOff, ModerationFlag or ModerationReject
+	moderator    moderator // nil unless Azure Content Safety is configured
+	blocked      string    // BlockedReject or BlockedWarn
+	pomodoro     time.Duration
+	undoWindow   time.Duration  // how long deleted todos can be restored; zero turns undo off
+	timezone     *time.Location // for clients that haven't said theirs
+	assistant    assistant      // nil unless AI_SUGGESTIONS is on
+	transcriber  transcriber    // nil unless Azure Speech is configured
+	calendar     calendar       // nil unless Microsoft Graph is configured
+	tenancy      *tenancy       // nil unless TENANT_MODE is set
+	quotas       Quotas         // defaults for tenants without their own
+	lockout      lockoutPolicy
+	ipFilter     *ipFilter // nil unless IP_ALLOWLIST, IP_DENYLIST or TRUSTED_PROXIES is set
+	sessionTTL   time.Duration
+	refreshTTL   time.Duration
+	customFields []CustomField // defaults for tenants without their own
+	homePageSize int
+	started      time.Time
+	probes       []healthProbe // dependencies shown on the status page
+	draining     atomic.Bool   // set on shutdown so /readyz fails
+	streamsDone  chan struct{} // closed on shutdown to end event streams
+	stopStreams  sync.Once
+	jobHandlers  map[string]jobHandler
+	schedules    []cronSchedule
+	backups      *blobContainer  // nil unless AZURE_STORAGE_BACKUP_URL is set
+	retention    retentionPolicy // defaults for tenants without their own
+	redisMetrics *redisMetrics
+	cacheBudget  *cacheBudget
+	// readYourWritesWindow is how long a client's reads bypass caches
+	// after it writes; zero turns read-your-writes off
+	readYourWritesWindow time.Duration
+	serverTimingOn       bool // SERVER_TIMING
+}
+
+// NewApp wires the routes onto the given backends. main passes the Mongo
+// store and Azure Redis client; tests can pass in-memory fakes instead.
+func NewApp(st store.Store, redisClient redis.UniversalClient) (*App, error) {
+	tpl, err := template.New("index").Parse(htmlTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	board, err := template.New("board").Parse(boardTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing board template: %w", err)
+	}
+
+	detail, err := template.New("detail").Parse(detailTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing detail template: %w", err)
+	}
+
+	bulkConfirm, err := template.New("bulk-confirm").Parse(bulkConfirmTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing bulk confirmation template: %w", err)
+	}
+
+	deleteConfirm, err := template.New("delete-confirm").Parse(deleteConfirmTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing delete confirmation template: %w", err)
+	}
+
+	printView, err := template.New("print").Parse(printTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing print template: %w", err)
+	}
+
+	statusPage, err := template.New("status").Parse(statusPageTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing status page template: %w", err)
+	}
+
+	today, err := template.New("today").Parse(todayTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing my day template: %w", err)
+	}
+
+	tenancy, err := newTenancy()
+	if err != nil {
+		return nil, fmt.Errorf("configuring tenants: %w", err)
+	}
+
+	customFields, err := defaultCustomFields()
+	if err != nil {
+		return nil, err
+	}
+
+	ipFilter, err := newIPFilter()
+	if err != nil {
+		return nil, fmt.Errorf("configuring IP filter: %w", err)
+	}
+
+	backups, err := newBackupContainer()
+	if err != nil {
+		return nil, fmt.Errorf("configuring backups: %w", err)
+	}
+
+	app := &App{
+		Router:        chi.NewRouter(),
+		RedisClient:   redisClient,
+		Store:         st,
+		Template:      tpl,
+		Board:         board,
+		Detail:        detail,
+		BulkConfirm:   bulkConfirm,
+		DeleteConfirm: deleteConfirm,
+		Print:         printView,
+		StatusPage:    statusPage,
+		Today:         today,
+		Templates:     store.NewMemoryTemplates(),
+		RefreshTokens: store.NewMemoryRefreshTokens(),
+		Audit:         store.NewMemoryAudit(),
+		Transactions:  store.NoTransactions,
+		notFoundTTL:   envDuration("NOT_FOUND_CACHE_TTL", DefaultNotFoundTTL),
+		duplicates:    duplicatePolicy(),
+		moderation:    moderationPolicy(),
+		moderator:     newModerator(),
+		blocked:       blockedPolicy(),
+		pomodoro:      envDuration("POMODORO_DURATION", DefaultPomodoroDuration),
+		undoWindow:    envDuration("UNDO_WINDOW", DefaultUndoWindow),
+		timezone:      defaultLocation(),
+		homePageSize:  envInt("HOME_PAGE_SIZE", DefaultHomePageSize),
+		started:       time.Now(),
+		probes:        []healthProbe{{Name: "redis", Check: func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }}},
+		streamsDone:   make(chan struct{}),
+		assistant:     newAssistant(),
+		transcriber:   newTranscriber(),
+		calendar:      newCalendar(),
+		tenancy:       tenancy,
+		quotas:        defaultQuotas(),
+		lockout:       newLockoutPolicy(),
+		ipFilter:      ipFilter,
+		sessionTTL:    envDuration("SESSION_TTL", DefaultSessionTTL),
+		refreshTTL:    envDuration("REFRESH_TOKEN_TTL", DefaultRefreshTokenTTL),
+		customFields:  customFields,
+		backups:       backups,
+		retention:     defaultRetention(),
+
+		readYourWritesWindow: envDuration("READ_YOUR_WRITES_WINDOW", 0),
+		redisMetrics:         &redisMetrics{},
+		cacheBudget:          newCacheBudget(),
+		serverTimingOn:       serverTimingEnabled(),
+	}
+	app.Todos = &TodoService{app: app}
+	redisClient.AddHook(app.redisMetrics)
+	app.registerJobs()
+	if app.schedules, err = app.newSchedules(); err != nil {
+		return nil, fmt.Errorf("configuring schedules: %w", err)
+	}
+	app.setupRoutes()
+	return app, nil
 }
 
 // --- Main Entry Point ---
 
 func main() {
+	log.SetOutput(redactingWriter{os.Stderr})
+
+	// Without a subcommand the binary runs the server, as in the container image
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	if err := loadCollections(); err != nil {
+		log.Fatal(err)
+	}
+	if err := runCommand(args[0], args[1:]); err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+}
+
+// cmdServe runs the HTTP server until SIGINT or SIGTERM.
+func cmdServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Parse(args)
+	log.Printf("Starting Azure Go Todo %s (commit %s, built %s)", version, commit, buildTime)
+
 	// 1. Initialize Configuration
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = DefaultPort
 	}
+	retryWindow := envDuration("STARTUP_RETRY_WINDOW", DefaultStartupRetryWindow)
+	earlyListen := os.Getenv("STARTUP_EARLY_LISTEN") == "true"
 
-	// 2. Database Connections
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// 2. Optionally listen right away so orchestrators can probe /healthz
+	// (reported as not ready) while dependencies come up
+	handler := &swapHandler{}
+	handler.Set(startingHandler())
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: handler,
+	}
+
+	// Optional mTLS listener for certificate-authenticated service callers
+	mtlsServer, err := newMTLSServer(handler)
+	if err != nil {
+		log.Fatalf("Invalid mTLS configuration: %v", err)
+	}
+
+	// Channel to listen for errors coming from the listeners.
+	serverErrors := make(chan error, 2)
+	listen := func() {
+		go func() {
+			log.Printf("Server listening on port %s", port)
+			serverErrors <- server.ListenAndServe()
+		}()
+		if mtlsServer != nil {
+			go func() {
+				log.Printf("mTLS server listening on %s", mtlsServer.Addr)
+				serverErrors <- mtlsServer.ListenAndServeTLS("", "")
+			}()
+		}
+	}
+	if earlyListen {
+		listen()
+	}
+
+	// 3. Database Connections (retried; an interrupt aborts startup)
+	bootCtx, stopBoot := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopBoot()
 
 	// Connect to Azure Cosmos DB (MongoDB API)
-	mongoClient, err := connectMongo(ctx)
+	mongoClient, err := retryConnect(bootCtx, "Mongo", retryWindow, connectMongo)
 	if err != nil {
+		if bootCtx.Err() != nil {
+			log.Println("Shutdown requested during startup")
+			return nil
+		}
 		log.Fatalf("Failed to connect to Mongo: %v", err)
 	}
 	defer func() {
@@ -144,50 +594,155 @@ func main() {
 	}()
 
 	// Connect to Azure Redis Cache
-	redisClient, err := connectRedis(ctx)
+	redisClient, err := retryConnect(bootCtx, "Redis", retryWindow, connectRedis)
 	if err != nil {
+		if bootCtx.Err() != nil {
+			log.Println("Shutdown requested during startup")
+			return nil
+		}
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 	defer redisClient.Close()
+	stopBoot()
 
-	// Parse Template
-	tpl, err := template.New("index").Parse(htmlTemplate)
+	// 4. Setup Application
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dbName := databaseName()
+
+	db := mongoClient.Database(dbName)
+
+	// Optionally encrypt todo titles and descriptions with Key Vault-wrapped keys
+	keyring, err := loadKeyring(ctx, db)
 	if err != nil {
-		log.Fatalf("Failed to parse template: %v", err)
+		log.Fatalf("Failed to load field encryption keys: %v", err)
+	}
+	var cipher store.FieldCipher
+	if keyring != nil {
+		cipher = keyring
+		log.Printf("Field encryption on (data key %s)", keyring.Current())
 	}
 
-	// 3. Setup Application
-	dbName := os.Getenv("MONGODB_DATABASE")
-	if dbName == "" {
-		dbName = DefaultDBName
-		log.Printf("MONGODB_DATABASE not set, using default: %s", dbName)
+	// Optionally commit the writes of each mutation, bulk action and merge together
+	transactions := store.NoTransactions
+	if os.Getenv("MONGO_TRANSACTIONS") == "true" {
+		transactions = store.MongoTransactions(mongoClient)
+	}
+
+	// Queue an outbox message with every mutation when a sink is configured
+	sinks, err := newOutboxSinks()
+	if err != nil {
+		log.Fatalf("Invalid outbox configuration: %v", err)
+	}
+	var outbox store.Outbox
+	if len(sinks) > 0 {
+		mongoOutbox := store.NewMongoOutbox(db.Collection(collectionName(OutboxColName, "")), os.Getenv("MONGO_TRANSACTIONS") == "true")
+		if err := mongoOutbox.EnsureIndexes(ctx); err != nil {
+			log.Printf("Could not create indexes on %s: %v", OutboxColName, err)
+		}
+		outbox = mongoOutbox
+		if cipher != nil {
+			outbox = store.EncryptOutbox(outbox, cipher)
+		}
+	}
+
+	// Every tenant has its own collections, set up on first use; without
+	// tenancy only the default tenant's are used
+	opts := tenantStoreOptions{
+		strictSchema:  os.Getenv("MONGO_SCHEMA_STRICT") == "true",
+		eventSourcing: os.Getenv("EVENT_SOURCING") == "true",
+		outbox:        outbox,
+		cipher:        cipher,
+		transactions:  transactions,
+	}
+	var st store.Store
+	if os.Getenv("TENANT_MODE") == TenantModeOff {
+		if st, err = openTenantStore(ctx, db, "", opts); err != nil {
+			log.Printf("Could not set up the todo store: %v", err)
+		}
 	} else {
-		log.Printf("Using MongoDB database: %s", dbName)
+		// A tenant whose store couldn't be set up is retried on its next request
+		st = store.ByTenant(func(tenant string) (store.Store, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return openTenantStore(ctx, db, tenant, opts)
+		})
 	}
 
-	app := &App{
-		Router:      chi.NewRouter(),
-		MongoClient: mongoClient,
-		RedisClient: redisClient,
-		Collection:  mongoClient.Database(dbName).Collection(ColName),
-		Template:    tpl,
+	opTimeout := envDuration("MONGO_OP_TIMEOUT", DefaultMongoOpTimeout)
+	app, err := NewApp(store.WithTimeout(st, opTimeout), redisClient)
+	if err != nil {
+		log.Fatalf("Failed to initialize app: %v", err)
+	}
+	app.Templates = store.TemplatesByTenant(func(tenant string) (store.TemplateStore, error) {
+		return store.NewMongoTemplates(db.Collection(collectionName(TemplateColName, tenant))), nil
+	})
+	app.Outbox = outbox
+	app.Transactions = transactions
+	refreshTokens := store.NewMongoRefreshTokens(db.Collection(collectionName(RefreshTokenColName, "")))
+	if err := refreshTokens.EnsureIndexes(ctx); err != nil {
+		log.Printf("Could not create indexes on %s: %v", RefreshTokenColName, err)
+	}
+	app.RefreshTokens = refreshTokens
+	audit := store.NewMongoAudit(db.Collection(collectionName(AuditColName, "")))
+	if err := audit.EnsureIndexes(ctx); err != nil {
+		log.Printf("Could not create indexes on %s: %v", AuditColName, err)
+	}
+	app.Audit = audit
+	if opts.eventSourcing {
+		app.Events = store.EventsByTenant(func(tenant string) (store.EventLog, error) {
+			return encryptEvents(store.NewMongoEvents(db.Collection(collectionName(EventColName, tenant))), cipher), nil
+		})
 	}
 
-	app.setupRoutes()
+	// 5. Background workers run until shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
 
-	// 4. Start Server with Graceful Shutdown
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: app.Router,
+	// Probe dependencies for the status page
+	app.probes = append([]healthProbe{{Name: "mongo", Check: func(ctx context.Context) error { return mongoClient.Ping(ctx, nil) }}}, app.probes...)
+	probeInterval := envDuration("HEALTH_PROBE_INTERVAL", DefaultHealthProbeInterval)
+	startWorker(func(ctx context.Context) { app.probeHealth(ctx, probeInterval) })
+
+	// Run queued background jobs
+	jobConcurrency := envInt("JOB_CONCURRENCY", DefaultJobConcurrency)
+	startWorker(func(ctx context.Context) { app.runJobs(ctx, jobConcurrency) })
+
+	// Run scheduled jobs, each on one instance only
+	startWorker(app.runSchedules)
+
+	// Create todos sent to an Azure Storage queue
+	ingest, err := newStorageQueue()
+	if err != nil {
+		log.Fatalf("Invalid queue ingestion configuration: %v", err)
+	}
+	if ingest != nil {
+		pollInterval := envDuration("QUEUE_POLL_INTERVAL", DefaultQueuePollInterval)
+		startWorker(func(ctx context.Context) { app.ingestQueue(ctx, ingest, pollInterval) })
+		log.Printf("Ingesting todos from queue %s", ingest.base.Path)
 	}
 
-	// Channel to listen for errors coming from the listener.
-	serverErrors := make(chan error, 1)
+	// Relay outbox messages
+	if outbox != nil {
+		outboxInterval := envDuration("OUTBOX_POLL_INTERVAL", DefaultOutboxInterval)
+		startWorker(func(ctx context.Context) { dispatchOutbox(ctx, outbox, sinks, outboxInterval) })
+		log.Printf("Outbox dispatcher relaying to %d sink(s)", len(sinks))
+	}
 
-	go func() {
-		log.Printf("Server listening on port %s", port)
-		serverErrors <- server.ListenAndServe()
-	}()
+	// 6. Serve the app, with graceful shutdown
+	handler.Set(app.Router)
+	log.Println("Application ready")
+	if !earlyListen {
+		listen()
+	}
 
 	// Channel to listen for an interrupt or terminate signal from the OS.
 	shutdown := make(chan os.Signal, 1)
@@ -199,20 +754,93 @@ func main() {
 
 	case <-shutdown:
 		log.Println("Starting graceful shutdown...")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
 
-		if err := server.Shutdown(ctx); err != nil {
-			log.Printf("Could not stop server gracefully: %v", err)
-			if err := server.Close(); err != nil {
-				log.Printf("Could not stop http server: %v", err)
+		// Fail readiness first and give the load balancer time to stop
+		// routing here; a second signal skips the wait
+		app.beginDrain()
+		drain := envDuration("SHUTDOWN_DRAIN_PERIOD", DefaultDrainPeriod)
+		log.Printf("Draining for %s", drain)
+		select {
+		case <-time.After(drain):
+		case <-shutdown:
+			log.Println("Skipping the rest of the drain period")
+		}
+
+		// Then stop serving: in-flight requests finish and open event
+		// streams end as soon as the servers stop accepting
+		ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout))
+		defer cancel()
+		for _, srv := range []*http.Server{server, mtlsServer} {
+			if srv == nil {
+				continue
+			}
+			srv.RegisterOnShutdown(app.endStreams)
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("Could not stop server gracefully: %v", err)
+				if err := srv.Close(); err != nil {
+					log.Printf("Could not stop http server: %v", err)
+				}
 			}
 		}
+
+		// Last, stop the background workers once their current pass is done
+		stopWorkers()
+		workers.Wait()
+		log.Println("Shutdown complete")
 	}
+	return nil
 }
 
 // --- Connection Helpers ---
 
+func databaseName() string {
+	dbName := os.Getenv("MONGODB_DATABASE")
+	if dbName == "" {
+		dbName = DefaultDBName
+		log.Printf("MONGODB_DATABASE not set, using default: %s", dbName)
+	} else {
+		log.Printf("Using MongoDB database: %s", dbName)
+	}
+	return dbName
+}
+
+// envDuration reads a duration setting such as "5s" or "250ms", falling
+// back to def when unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default: %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// envInt reads an integer setting, falling back to def when unset or invalid.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default: %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// envString reads a string setting, falling back to def when unset.
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
 func connectMongo(ctx context.Context) (*mongo.Client, error) {
 	uri := os.Getenv("AZURE_COSMOS_CONNECTIONSTRING")
 	if uri == "" {
@@ -224,6 +852,21 @@ func connectMongo(ctx context.Context) (*mongo.Client, error) {
 	// Azure Cosmos DB requires TLS
 	clientOptions := options.Client().ApplyURI(uri)
 
+	// Pool sizing and server selection, left at driver defaults unless set
+	if n := envInt("MONGO_MAX_POOL_SIZE", 0); n > 0 {
+		clientOptions.SetMaxPoolSize(uint64(n))
+	}
+	if n := envInt("MONGO_MIN_POOL_SIZE", 0); n > 0 {
+		clientOptions.SetMinPoolSize(uint64(n))
+	}
+	if d := envDuration("MONGO_SERVER_SELECTION_TIMEOUT", 0); d > 0 {
+		clientOptions.SetServerSelectionTimeout(d)
+	}
+	applyReadOptions(clientOptions)
+	if serverTimingEnabled() {
+		clientOptions.SetMonitor(mongoTimingMonitor)
+	}
+
 	// Only set TLS for Azure (when using mongo.cosmos.azure.com)
 	if strings.Contains(uri, "cosmos.azure.com") || strings.Contains(uri, "ssl=true") {
 		if clientOptions.TLSConfig == nil {
@@ -238,6 +881,7 @@ func connectMongo(ctx context.Context) (*mongo.Client, error) {
 
 	// Ping to verify connection
 	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
@@ -245,12 +889,14 @@ func connectMongo(ctx context.Context) (*mongo.Client, error) {
 	return client, nil
 }
 
-func connectRedis(ctx context.Context) (*redis.Client, error) {
+func connectRedis(ctx context.Context) (redis.UniversalClient, error) {
 	host := os.Getenv("AZURE_REDIS_HOST")
 	port := os.Getenv("AZURE_REDIS_PORT")
+	username := os.Getenv("REDIS_USERNAME")
 	password := os.Getenv("AZURE_REDIS_PASSWORD")
 	ssl := os.Getenv("AZURE_REDIS_SSL")
 	dbStr := os.Getenv("AZURE_REDIS_DATABASE")
+	mode := os.Getenv("REDIS_MODE")
 
 	// Parse database number
 	db := 0
@@ -273,106 +919,385 @@ func connectRedis(ctx context.Context) (*redis.Client, error) {
 		addr = fmt.Sprintf("%s:%s", host, port)
 	}
 
-	redisOptions := &redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
+	// Enable TLS if specified or if using Azure Redis / Azure Redis Enterprise
+	var tlsConfig *tls.Config
+	if ssl == "true" || strings.Contains(host, "redis.cache.windows.net") ||
+		strings.Contains(host, "redisenterprise.cache.azure.net") {
+		tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: os.Getenv("REDIS_TLS_SERVER_NAME"),
+		}
 	}
 
-	// Enable TLS if specified or if using Azure Redis
-	if ssl == "true" || strings.Contains(host, "redis.cache.windows.net") {
-		redisOptions.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
-	}
+	var rdb redis.UniversalClient
+	switch mode {
+	case "", "single":
+		mode = "single"
+		rdb = redis.NewClient(&redis.Options{
+			Addr:      addr,
+			Username:  username,
+			Password:  password,
+			DB:        db,
+			TLSConfig: tlsConfig,
+		})
+
+	case "cluster":
+		// Seed nodes; the client discovers the rest of the cluster from them
+		addrs := splitList(os.Getenv("REDIS_CLUSTER_ADDRS"))
+		if len(addrs) == 0 {
+			addrs = []string{addr}
+		}
+		if db != 0 {
+			log.Printf("Ignoring AZURE_REDIS_DATABASE=%d: Redis Cluster only supports database 0", db)
+		}
+		rdb = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     addrs,
+			Username:  username,
+			Password:  password,
+			TLSConfig: tlsConfig,
+		})
+
+	case "sentinel":
+		master := os.Getenv("REDIS_SENTINEL_MASTER")
+		sentinels := splitList(os.Getenv("REDIS_SENTINEL_ADDRS"))
+		if master == "" || len(sentinels) == 0 {
+			return nil, fmt.Errorf("REDIS_MODE=sentinel requires REDIS_SENTINEL_MASTER and REDIS_SENTINEL_ADDRS")
+		}
+		rdb = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       master,
+			SentinelAddrs:    sentinels,
+			SentinelUsername: os.Getenv("REDIS_SENTINEL_USERNAME"),
+			SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+			Username:         username,
+			Password:         password,
+			DB:               db,
+			TLSConfig:        tlsConfig,
+		})
 
-	rdb := redis.NewClient(redisOptions)
+	default:
+		return nil, fmt.Errorf("unknown REDIS_MODE %q (want single, cluster or sentinel)", mode)
+	}
 
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
 		return nil, err
 	}
 
-	log.Println("Connected to Azure Redis Cache")
+	log.Printf("Connected to Redis (%s mode)", mode)
 	return rdb, nil
 }
 
+// splitList parses a comma-separated setting, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // --- Routes & Middleware ---
 
 func (app *App) setupRoutes() {
-	app.Router.Use(middleware.Logger)
-	app.Router.Use(middleware.Recoverer)
-	app.Router.Use(middleware.Timeout(60 * time.Second))
+	app.Router.Use(middleware.RequestID)
+	app.Router.Use(app.serverTiming)
+	app.Router.Use(versionHeader)
+	app.Router.Use(accessLog(newAccessLogPolicy(), log.Writer()))
+	app.Router.Use(app.filterIPs("/health", "/healthz", "/readyz"))
+	app.Router.Use(recoverPanics(newErrorReporter()))
+	app.Router.Use(shedLoad(envInt("MAX_IN_FLIGHT", DefaultMaxInFlight), "/health", "/healthz", "/readyz"))
+	app.Router.Use(compressResponses(envInt("COMPRESS_MIN_SIZE", DefaultCompressMinSize)))
+
+	// Per-route time budgets
+	reads := middleware.Timeout(envDuration("READ_TIMEOUT", DefaultReadTimeout))
+	writes := middleware.Timeout(envDuration("WRITE_TIMEOUT", DefaultWriteTimeout))
+	ai := middleware.Timeout(envDuration("AI_TIMEOUT", DefaultAITimeout))
+	suggest := middleware.Timeout(envDuration("SUGGEST_TIMEOUT", DefaultSuggestTimeout))
 
 	// CORS Setup
 	app.Router.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "If-None-Match", "X-API-Key", "X-CSRF-Token", "X-Tenant-ID"},
+		ExposedHeaders: []string{"Deprecation", "ETag", "Sunset", "Link", "X-Duplicate-Of", "X-Blocked-By", "X-Content-Flagged", "X-App-Version", "X-Total-Count", "X-Open-Count", "X-Completed-Count", "X-Tag-Counts", "Server-Timing"},
 	}))
+	app.Router.Use(jsonAPIErrors)
+	app.Router.Use(formErrors)
+	app.Router.Use(app.readYourWrites)
+	app.Router.Use(app.resolveTenant("/health", "/healthz", "/readyz", "/admin/deprecations", "/admin/blocks", "/admin/sessions", "/auth/refresh", "/auth/revoke", "/admin/audit", "/admin/tenants", "/admin/tenants/", "/admin/jobs", "/admin/jobs/", "/admin/outbox/", "/admin/schedules", "/admin/redis", "/admin/cache/", "/static/", "/status", "/version"))
 
 	app.Router.Get("/health", app.handleHealth)
+	app.Router.Get("/healthz", app.handleHealthz)
+	app.Router.Get("/readyz", app.handleReadyz)
+	app.Router.With(reads).Get("/status", app.statusPage)
+	app.Router.Get("/version", handleVersion)
 
 	// UI Route
-	app.Router.Get("/", app.handleHome)
+	app.Router.With(reads).Get("/", app.handleHome)
+	app.Router.With(reads).Get("/board", app.handleBoard)
+	app.Router.With(reads).Get("/today", app.handleToday)
+	app.Router.With(reads).Get("/stats", app.handleStats)
+	app.Router.With(reads).Get("/usage", app.handleUsage)
+	app.Router.With(reads).Get("/reports/completions", app.completionsReport)
+	app.Router.With(reads).Get("/reports/by-tag", app.tagReport)
+	app.Router.With(reads).Handle("/static/*", staticAssets())
 
 	app.Router.Route("/todos", func(r chi.Router) {
-		r.Get("/", app.listTodos)
-		r.Post("/", app.createTodo)
+		r.With(reads).Get("/", app.listTodos)
+		r.With(writes).Post("/", app.createTodo)
+		r.With(reads).Get("/nearby", app.nearbyTodos)
+		r.With(suggest).Get("/suggest", app.suggestTodos)
+		r.With(ai).Get("/suggestions", app.todoSuggestions)
+		r.With(ai).Post("/voice", app.createTodoFromVoice)
+		r.With(reads).Post("/batch-get", app.batchGetTodosPost)
+		r.With(writes).Post("/deduplicate", app.deduplicateTodos)
+		r.With(writes).Post("/bulk", app.bulkTodos)
+		r.With(writes).Post("/bulk/form", app.bulkTodosForm) // Helper for HTML forms
+		r.With(writes).Post("/from-template/{id}", app.createTodoFromTemplate)
 		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", app.getTodo)
-			r.Put("/", app.updateTodo)
-			r.Delete("/", app.deleteTodo)
-			r.Post("/delete", app.deleteTodoForm) // Helper for HTML forms
+			r.With(reads).Get("/", app.getTodo)
+			r.With(reads).Get("/view", app.viewTodo)
+			r.With(reads).Get("/edit", app.editTodoPartial)
+			r.With(writes).Post("/edit", app.editTodoForm)     // Helper for HTML forms
+			r.With(writes).Post("/update", app.updateTodoForm) // Helper for HTML forms
+			r.With(writes).Put("/", app.updateTodo)
+			r.With(writes).Delete("/", app.deleteTodo)
+			r.With(reads).Get("/delete", app.confirmDelete)
+			r.With(writes).Post("/delete", app.deleteTodoForm) // Helper for HTML forms
+			r.With(writes).Post("/restore", app.restoreTodo)
+			r.With(writes).Post("/status", app.setStatus)
+			r.With(writes).Post("/pin", app.pinTodo)
+			r.With(writes).Post("/add-to-today", app.addToToday)
+			r.With(writes).Post("/snooze", app.snoozeTodo)
+			r.With(writes).Post("/pomodoro", app.startPomodoro)
+			r.Get("/pomodoro/events", app.pomodoroEvents) // long-lived, so no time budget
+			r.With(ai).Post("/breakdown", app.breakdownTodo)
+			r.With(writes).Post("/schedule", app.scheduleTodo)
+			r.With(reads).Get("/events", app.todoEvents)
 		})
 	})
+
+	app.Router.With(reads).Get("/custom-fields", app.listCustomFields)
+	app.Router.With(reads).Get("/lists/{id}/print", app.printList)
+
+	app.Router.Route("/templates", func(r chi.Router) {
+		r.With(reads).Get("/", app.listTemplates)
+		r.With(writes).Post("/", app.createTemplate)
+		r.With(reads).Get("/{id}", app.getTemplate)
+		r.With(writes).Delete("/{id}", app.deleteTemplate)
+	})
+
+	// Refresh tokens are their own credential, so no key is required
+	app.Router.Route("/auth", func(r chi.Router) {
+		r.With(writes).Post("/refresh", app.refreshSession)
+		r.With(writes).Post("/revoke", app.revokeRefreshToken)
+	})
+
+	// Polling triggers for Zapier, IFTTT and the like (requires INTEGRATIONS_API_KEY)
+	app.Router.Route("/integrations/triggers", func(r chi.Router) {
+		r.Use(app.requireKey(os.Getenv("INTEGRATIONS_API_KEY"), "Integrations API"))
+		r.With(reads).Get("/new-todos", app.newTodosTrigger)
+		r.With(reads).Get("/completed-todos", app.completedTodosTrigger)
+	})
+
+	// Admin API (requires ADMIN_API_KEY)
+	app.Router.Route("/admin", func(r chi.Router) {
+		r.Use(app.requireAdmin(os.Getenv("ADMIN_API_KEY")))
+		r.With(reads).Get("/deprecations", app.deprecationReport)
+		r.With(reads).Get("/blocks", app.listAuthBlocks)
+		r.With(writes).Delete("/blocks", app.clearAuthBlock)
+		r.With(writes).Post("/sessions", app.createSession)
+		r.With(reads).Get("/sessions", app.listSessions)
+		r.With(writes).Delete("/sessions", app.deleteSessions)
+		r.With(reads).Get("/audit", app.listAudit)
+		r.With(reads).Get("/tenants", app.listTenants)
+		r.With(writes).Post("/tenants/{id}/disable", app.setTenantDisabled(true))
+		r.With(writes).Post("/tenants/{id}/enable", app.setTenantDisabled(false))
+		r.With(writes).Delete("/tenants/{id}/usage", app.resetTenantUsage)
+		r.With(writes).Post("/imports/todoist", app.importTodoist)
+		r.With(writes).Post("/imports/trello", app.importTrello)
+		r.With(reads).Get("/imports/{id}", app.importProgress)
+		r.With(reads).Get("/jobs", app.jobStats)
+		r.With(reads).Get("/jobs/dead", app.listJobsDead)
+		r.With(writes).Post("/jobs/dead/{id}/retry", app.retryJobsDead)
+		r.With(writes).Delete("/jobs/dead", app.purgeJobsDead)
+		r.With(writes).Delete("/jobs/dead/{id}", app.purgeJobsDead)
+		r.With(reads).Get("/outbox/dead", app.listOutboxDead)
+		r.With(writes).Post("/outbox/dead/{id}/retry", app.retryOutboxDead)
+		r.With(writes).Delete("/outbox/dead", app.purgeOutboxDead)
+		r.With(writes).Delete("/outbox/dead/{id}", app.purgeOutboxDead)
+		r.With(reads).Get("/schedules", app.listSchedules)
+		r.With(reads).Get("/redis", app.redisMetricsReport)
+		r.With(reads).Get("/cache/cleanup", app.cacheCleanupReport)
+		r.With(writes).Post("/backup", app.createBackup)
+		r.With(reads).Get("/backups", app.listBackups)
+		r.With(writes).Post("/restore", app.restoreBackup)
+		r.With(reads).Get("/exports/{list}", app.exportListHandler)
+		r.With(writes).Post("/exports/{list}", app.exportListHandler)
+	})
 }
 
 // --- Logic Helpers ---
 
-func (app *App) getAllTodos(ctx context.Context) ([]Todo, error) {
+func (app *App) getAllTodos(ctx context.Context) ([]store.Todo, error) {
 	// 1. Try to fetch from Redis
-	cached, err := app.RedisClient.Get(ctx, "todos:all").Result()
-	if err == nil {
-		var todos []Todo
-		if err := json.Unmarshal([]byte(cached), &todos); err == nil {
-			return todos, nil
+	cacheKey, cacheable := app.listCacheKey(ctx)
+	if cacheable && cachedReads(ctx) {
+		cached, err := app.cachedList(ctx, cacheKey)
+		if err == nil {
+			var todos []store.Todo
+			if err := json.Unmarshal(cached, &todos); err == nil {
+				return todos, nil
+			}
 		}
 	}
 
-	// 2. Fetch from MongoDB
-	// Note: Removed sort to avoid index issues with Cosmos DB serverless
-	// Cosmos DB serverless doesn't include default indexes on custom fields
-	cursor, err := app.Collection.Find(ctx, bson.M{})
+	// 2. Fetch from the store (lean projection, list views don't need more)
+	todos, err := app.Store.List(ctx, store.Query{Fields: store.LeanFields})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
-	var todos []Todo
-	if err = cursor.All(ctx, &todos); err != nil {
-		return nil, err
+	// 3. Cache the result in its wire format, so it can be served as-is
+	if cacheable {
+		data, _ := json.Marshal(newTodoResponses(todos))
+		app.cacheList(ctx, cacheKey, data)
 	}
 
-	// Sort in memory instead (works for reasonable dataset sizes)
-	// This avoids requiring index creation in Cosmos DB
-	if len(todos) > 0 {
-		// Sort by CreatedAt descending (newest first)
-		for i := 0; i < len(todos)-1; i++ {
-			for j := i + 1; j < len(todos); j++ {
-				if todos[i].CreatedAt.Before(todos[j].CreatedAt) {
-					todos[i], todos[j] = todos[j], todos[i]
-				}
-			}
+	return todos, nil
+}
+
+// filterTodos keeps the todos in state, "open" or "completed" as with
+// GET /todos?state=; "" keeps them all.
+func filterTodos(todos []store.Todo, state string) []store.Todo {
+	if state == "" {
+		return todos
+	}
+	out := make([]store.Todo, 0, len(todos))
+	for _, todo := range todos {
+		if todo.Completed == (state == "completed") {
+			out = append(out, todo)
 		}
 	}
+	return out
+}
 
-	// 3. Cache the result
-	// Handle empty slice serialization
-	if todos == nil {
-		todos = []Todo{}
+// parseFields validates a comma-separated ?fields= selection. It returns
+// nil when no selection was made.
+func parseFields(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
 	}
-	data, _ := json.Marshal(todos)
-	app.RedisClient.Set(ctx, "todos:all", data, 10*time.Minute)
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || f == "id" {
+			continue
+		}
+		if !store.ValidField(f) {
+			return nil, fmt.Errorf("Unknown field %q", f)
+		}
+		fields = append(fields, f)
+	}
+	if fields == nil {
+		fields = []string{} // only ids were requested
+	}
+	return fields, nil
+}
 
-	return todos, nil
+// parsePage validates ?offset= and ?limit=; zero means unset.
+func parsePage(params url.Values) (offset, limit int, err error) {
+	for _, p := range []struct {
+		name string
+		dst  *int
+		max  int
+	}{
+		{"offset", &offset, math.MaxInt32},
+		{"limit", &limit, MaxPageLimit},
+	} {
+		raw := params.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > p.max {
+			return 0, 0, fmt.Errorf("Invalid %s %q", p.name, raw)
+		}
+		*p.dst = n
+	}
+	return offset, limit, nil
+}
+
+// leanTodo renders a todo in full; used for lists fetched with the lean
+// projection, where bulky fields are already absent.
+func leanTodo(todo store.Todo) interface{} {
+	return newTodoResponse(todo)
+}
+
+// projectTodo renders only id and the selected fields of a todo.
+func projectTodo(todo store.Todo, fields []string) map[string]interface{} {
+	var full map[string]interface{}
+	data, _ := json.Marshal(newTodoResponse(todo))
+	json.Unmarshal(data, &full)
+
+	out := map[string]interface{}{"id": full["id"]}
+	for _, f := range fields {
+		if v, ok := full[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+// notFoundSentinel is cached under a todo's key when it doesn't exist. It
+// can't be mistaken for a cached todo, which is always a JSON object.
+const notFoundSentinel = "-"
+
+// todoCacheKey is the Redis key for a single todo. Keys are only ever
+// derived from a parsed ID, in canonical hex, so arbitrary path input can't
+// create or collide with cache entries.
+func todoCacheKey(ctx context.Context, id primitive.ObjectID) string {
+	return redisKey(ctx, "todo:"+id.Hex())
+}
+
+// listCacheKey is the Redis key of the full list cached at the current
+// list version. Reading the version before the store means a list read
+// just before a write is cached under the old version, which nobody asks
+// for once the write bumps it. ok is false when the version is unknown,
+// and then nothing should be cached.
+func (app *App) listCacheKey(ctx context.Context) (key string, ok bool) {
+	v, err := listVersion(ctx, app.RedisClient)
+	if err != nil {
+		return "", false
+	}
+	return redisKey(ctx, "todos:all:"+strconv.FormatInt(v, 36)), true
+}
+
+// invalidateTodos drops the given items and bumps the list version, which
+// retires the cached list and makes previously issued ETags stop matching.
+func (app *App) invalidateTodos(ctx context.Context, ids ...primitive.ObjectID) {
+	// One DEL per key: a multi-key DEL fails across Redis Cluster slots
+	if len(ids) > 0 {
+		pipe := app.RedisClient.Pipeline()
+		for _, id := range ids {
+			pipe.Del(ctx, todoCacheKey(ctx, id))
+		}
+		pipe.Exec(ctx)
+	}
+	bumpListVersion(ctx, app.RedisClient)
+}
+
+// writeValidationError responds with 400 and the offending fields when err
+// is a schema validation failure, reporting whether it handled the error.
+func writeValidationError(w http.ResponseWriter, err error) bool {
+	var verr *store.ValidationError
+	if !errors.As(err, &verr) {
+		return false
+	}
+	writeError(w, http.StatusBadRequest, ErrorResponse{Error: "Validation failed", Fields: verr.Fields})
+	return true
 }
 
 // --- Handlers ---
@@ -382,33 +1307,298 @@ func (app *App) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// handleHome serves GET /?offset=&limit=, rendering a page of todos
+// (HOME_PAGE_SIZE without ?limit=). htmx "load more" requests get just the
+// page's rows, followed by the button for the next page.
 func (app *App) handleHome(w http.ResponseWriter, r *http.Request) {
-	todos, err := app.getAllTodos(r.Context())
+	offset, limit, err := parsePage(r.URL.Query())
 	if err != nil {
-		log.Printf("Error loading todos: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to load todos: %v", err), http.StatusInternalServerError)
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if limit == 0 {
+		limit = app.homePageSize
+	}
+	state := r.URL.Query().Get("state")
+	if state != "" && state != "open" && state != "completed" {
+		respondError(w, http.StatusBadRequest, "state must be open or completed")
+		return
+	}
+	ctx := r.Context()
+	counts, err := app.todoCounts(ctx)
+	if err != nil {
+		serverError(w, r, "Failed to load todos", err)
+		return
+	}
+	page := homePage{Limit: limit, Colors: store.Colors, Version: version, State: state, Counts: counts}
+
+	if offset == 0 {
+		// The first page comes from the cached list, which the tabs filter
+		// rather than each caching their own
+		todos, err := app.getAllTodos(ctx)
+		if err != nil {
+			serverError(w, r, "Failed to load todos", err)
+			return
+		}
+		page.Todos = filterTodos(todos, state)
+	} else {
+		// "Load more" reads just its page from the store, one todo past it
+		// telling whether there's more
+		q := store.Query{Fields: store.LeanFields, Offset: offset}
+		if limit > 0 {
+			q.Limit = limit + 1
+		}
+		if state != "" {
+			completed := state == "completed"
+			q.Completed = &completed
+		}
+		if page.Todos, err = app.Store.List(ctx, q); err != nil {
+			serverError(w, r, "Failed to load todos", err)
+			return
+		}
+	}
+	if limit > 0 && len(page.Todos) > limit {
+		page.Todos = page.Todos[:limit]
+		page.NextOffset = offset + limit
+	}
+	page.Due = dueViews(page.Todos, time.Now(), app.userLocation(r))
+
 	w.Header().Set("Content-Type", "text/html")
-	app.Template.Execute(w, todos)
+	w.Header().Add("Vary", "HX-Request")
+	if r.Header.Get("HX-Request") == "true" {
+		app.Template.ExecuteTemplate(w, "todo-rows", page)
+		return
+	}
+	if page.Templates, err = app.Templates.ListTemplates(ctx); err != nil {
+		log.Printf("Error loading templates: %v", err)
+	}
+	page.Flash = takeFlash(w, r)
+	page.UndoMs = app.undoWindow.Milliseconds()
+	app.Template.Execute(w, page)
+}
+
+// homePage is the data rendered by htmlTemplate.
+type homePage struct {
+	Todos      []store.Todo
+	Templates  []store.Template
+	Colors     []string // for the selection toolbar's label picker
+	Limit      int
+	NextOffset int // where the next page starts; zero on the last page
+	Version    string
+	Flash      *Flash       // the outcome of the last form action
+	UndoMs     int64        // how long the flash's undo button works
+	State      string       // the tab shown: "", "open" or "completed"
+	Counts     store.Counts // of the whole list, for the tabs
+	Due        map[primitive.ObjectID]*dueView
 }
 
 func (app *App) listTodos(w http.ResponseWriter, r *http.Request) {
-	todos, err := app.getAllTodos(r.Context())
+	ctx := r.Context()
+	params := r.URL.Query()
+	if ids := params.Get("ids"); ids != "" {
+		app.batchGetTodos(w, r, ids)
+		return
+	}
+	rawFields := params.Get("fields")
+	if rawFields == "" {
+		rawFields = params.Get("fields[todos]") // JSON:API sparse fieldset
+	}
+	fields, err := parseFields(rawFields)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	offset, limit, err := parsePage(params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	state := params.Get("state")
+	switch state {
+	case "", "open", "completed", "blocked":
+	default:
+		respondError(w, http.StatusBadRequest, "state must be open, completed or blocked")
+		return
+	}
+	color := normalizeColor(params.Get("color"))
+	if !store.ValidColor(color) {
+		writeError(w, http.StatusBadRequest, invalidColor)
+		return
+	}
+	sortBy := params.Get("sort")
+	if !store.ValidSort(sortBy) {
+		respondError(w, http.StatusBadRequest, "sort must be one of "+strings.Join(store.Sorts, ", "))
+		return
+	}
+	maxEstimate, minProgress, err := parseEffort(params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hal, jsonAPI := wantsHAL(r), wantsJSONAPI(r)
+
+	var variant []string
+	if state != "" {
+		variant = append(variant, "state="+state)
+	}
+	if color != "" {
+		variant = append(variant, "color="+color)
+	}
+	if sortBy != "" {
+		variant = append(variant, "sort="+sortBy)
+	}
+	if maxEstimate > 0 || minProgress > 0 {
+		variant = append(variant, fmt.Sprintf("effort=%d+%d", maxEstimate, minProgress))
+	}
+	if fields != nil {
+		variant = append(variant, "fields="+strings.Join(fields, "+")) // commas would split the ETag in If-None-Match
+	}
+	if offset > 0 || limit > 0 {
+		variant = append(variant, fmt.Sprintf("page=%d+%d", offset, limit))
+	}
+	switch {
+	case jsonAPI:
+		variant = append(variant, "jsonapi")
+	case hal:
+		variant = append(variant, "hal")
+	}
+	w.Header().Add("Vary", "Accept")
+	if checkNotModified(w, r, app.listETag(ctx, strings.Join(variant, ";"))) {
+		return
+	}
+	app.setCountHeaders(w, r)
+
+	// Field selections, pages, states, colors, sorts and effort filters are
+	// queried in Mongo and not cached
+	q := store.Query{Fields: fields, Offset: offset, Limit: limit, Color: color, Sort: sortBy, MaxEstimate: maxEstimate, MinProgress: minProgress}
+	if q.Fields == nil {
+		q.Fields = store.LeanFields
+	}
+	switch state {
+	case "open", "completed":
+		completed := state == "completed"
+		q.Completed = &completed
+	case "blocked":
+		if q.IDs, err = app.blockedIDs(ctx); err != nil {
+			serverError(w, r, "Failed to fetch todos", err)
+			return
+		}
+	}
+	switch {
+	case jsonAPI:
+		app.listTodosJSONAPI(w, r, q, fields != nil)
+		return
+	case hal:
+		app.listTodosHAL(w, r, q, fields != nil)
+		return
+	}
+	if len(variant) > 0 {
+		app.listTodoQuery(w, r, q, fields != nil)
+		return
+	}
+
+	// 1. Serve the cached list as-is
+	cacheKey, _ := app.listCacheKey(ctx)
+	if cacheKey != "" && cachedReads(ctx) {
+		if cached, err := app.cachedList(ctx, cacheKey); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(cached)
+			return
+		}
+	}
+
+	// 2. Stream straight from the store's cursor when supported
+	if streamer, ok := app.Store.(store.Streamer); ok && app.streamTodos(w, r, streamer, q, leanTodo, cacheKey) {
+		return
+	}
+
+	// 3. Fall back to loading the whole list
+	todos, err := app.getAllTodos(ctx)
 	if err != nil {
-		log.Printf("Error fetching todos: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to fetch todos: %v", err), http.StatusInternalServerError)
+		serverError(w, r, "Failed to fetch todos", err)
 		return
 	}
 
-	// Check if this was a cache hit logic (optional, for debugging headers)
-	// We lost the exact hit/miss distinction in the helper, but functional result is same
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todos)
+	respondJSON(w, http.StatusOK, newTodoResponses(todos))
+}
+
+// todoPage is one page of GET /todos with the URLs of its neighbours.
+type todoPage struct {
+	Todos []store.Todo
+	Next  string // "" on the last page
+	Prev  string // "" on the first page
+}
+
+// fetchPage loads the todos q selects, probing one further to learn
+// whether a next page exists.
+func (app *App) fetchPage(r *http.Request, q store.Query) (todoPage, error) {
+	fetch := q
+	if q.Limit > 0 {
+		fetch.Limit = q.Limit + 1
+	}
+	todos, err := app.Store.List(r.Context(), fetch)
+	if err != nil {
+		return todoPage{}, err
+	}
+
+	page := todoPage{Todos: todos}
+	if q.Limit > 0 && len(todos) > q.Limit {
+		page.Todos = todos[:q.Limit]
+		page.Next = pageHref(r, q.Offset+q.Limit, q.Limit)
+	}
+	if q.Offset > 0 {
+		limit := q.Limit
+		if limit == 0 {
+			limit = q.Offset
+		}
+		page.Prev = pageHref(r, max(q.Offset-limit, 0), limit)
+	}
+	return page, nil
+}
+
+// pageHref is the current request URL moved to another page, keeping its
+// other query parameters.
+func pageHref(r *http.Request, offset, limit int) string {
+	params := r.URL.Query()
+	params.Del("offset")
+	if offset > 0 {
+		params.Set("offset", strconv.Itoa(offset))
+	}
+	params.Set("limit", strconv.Itoa(limit))
+	return r.URL.Path + "?" + params.Encode()
+}
+
+// todoView picks how list elements are rendered: only id and the selected
+// fields when projected, in full otherwise.
+func todoView(q store.Query, projected bool) func(store.Todo) interface{} {
+	if projected {
+		return func(todo store.Todo) interface{} { return projectTodo(todo, q.Fields) }
+	}
+	return leanTodo
+}
+
+// listTodoQuery serves GET /todos?fields=...&offset=...&limit=... straight
+// from the store, bypassing the list cache.
+func (app *App) listTodoQuery(w http.ResponseWriter, r *http.Request, q store.Query, projected bool) {
+	view := todoView(q, projected)
+	if streamer, ok := app.Store.(store.Streamer); ok && app.streamTodos(w, r, streamer, q, view, "") {
+		return
+	}
+
+	todos, err := app.Store.List(r.Context(), q)
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	out := make([]interface{}, 0, len(todos))
+	for _, todo := range todos {
+		out = append(out, view(todo))
+	}
+	respondJSON(w, http.StatusOK, out)
 }
 
 func (app *App) createTodo(w http.ResponseWriter, r *http.Request) {
-	var title string
+	var req CreateTodoRequest
 
 	// Handle both JSON and Form Data
 	contentType := r.Header.Get("Content-Type")
@@ -416,153 +1606,246 @@ func (app *App) createTodo(w http.ResponseWriter, r *http.Request) {
 
 	if isForm {
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, "Invalid form data")
 			return
 		}
-		title = r.FormValue("title")
+		req.Title = r.FormValue("title")
 	} else {
-		var req CreateTodoRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
-		title = req.Title
 	}
 
-	if title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+	newTodo, err := req.todo()
+	if err != nil {
+		respondErr(w, r, err, "Failed to create todo")
 		return
 	}
+	app.insertTodo(w, r, newTodo, isForm)
+}
 
-	newTodo := Todo{
-		ID:        primitive.NewObjectID(),
-		Title:     title,
-		Completed: false,
-		CreatedAt: time.Now(),
+// todo is the new todo req describes, for TodoService.Create to validate.
+// Fields that don't parse fail with a *store.ValidationError.
+func (req CreateTodoRequest) todo() (store.Todo, error) {
+	newTodo := store.Todo{
+		ID:          primitive.NewObjectID(),
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Priority:    req.Priority,
+		Color:       req.Color,
+		Estimate:    req.Estimate,
+		Progress:    req.Progress,
+		Custom:      req.Custom,
+		Completed:   false,
+		CreatedAt:   time.Now(),
+	}
+	if req.Location != nil {
+		loc, fields := req.Location.location()
+		if fields != nil {
+			return store.Todo{}, &store.ValidationError{Fields: fields}
+		}
+		newTodo.Location = loc
+	}
+	due, fields := parseDueAt(&req.DueAt)
+	if fields != nil {
+		return store.Todo{}, &store.ValidationError{Fields: fields}
+	}
+	if !due.IsZero() {
+		newTodo.DueAt = due
 	}
+	if len(req.BlockedBy) > 0 {
+		blockers, err := parseBlockers(req.BlockedBy)
+		if err != nil {
+			return store.Todo{}, err
+		}
+		newTodo.BlockedBy = blockers
+	}
+	return newTodo, nil
+}
 
-	ctx := r.Context()
-	_, err := app.Collection.InsertOne(ctx, newTodo)
-	if err != nil {
-		http.Error(w, "Failed to create todo", http.StatusInternalServerError)
+// insertTodo stores a new todo and answers with it, or with a redirect
+// home for HTML forms.
+func (app *App) insertTodo(w http.ResponseWriter, r *http.Request, newTodo store.Todo, isForm bool) {
+	if !app.saveTodo(w, r, &newTodo) {
 		return
 	}
 
-	// Invalidate list cache
-	app.RedisClient.Del(ctx, "todos:all")
-
 	// Response based on request type
 	if isForm {
+		setFlash(w, FlashSuccess, "Added \""+newTodo.Title+"\"")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	} else {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(newTodo)
+		if wantsJSONAPI(r) {
+			respondJSONAPI(w, http.StatusCreated, jsonAPIDocument{Data: todoResource(newTodoResponse(newTodo))})
+			return
+		}
+		respondJSON(w, http.StatusCreated, newTodoResponse(newTodo))
 	}
 }
 
-func (app *App) getTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
+// saveTodo creates a new todo, passing on the notices of the policies.
+// It answers errors itself and reports false when the todo wasn't stored.
+func (app *App) saveTodo(w http.ResponseWriter, r *http.Request, newTodo *store.Todo) bool {
+	ctx, notices := withNotices(r.Context())
+	if err := app.Todos.Create(ctx, newTodo); err != nil {
+		respondErr(w, r, err, "Failed to create todo")
+		return false
+	}
+	app.passNotices(w, r, "todo:"+newTodo.ID.Hex(), notices)
+	return true
+}
 
+func (app *App) getTodo(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
 	ctx := r.Context()
-
-	// 1. Check specific item cache
-	cacheKey := fmt.Sprintf("todo:%s", idStr)
-	cached, err := app.RedisClient.Get(ctx, cacheKey).Result()
-	if err == nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(cached))
+	variant := ""
+	switch {
+	case wantsJSONAPI(r):
+		variant = "jsonapi"
+	case wantsHAL(r):
+		variant = "hal"
+	}
+	w.Header().Add("Vary", "Accept")
+	etag, cached := app.readItem(ctx, objID, variant)
+	if checkNotModified(w, r, etag) {
 		return
 	}
 
-	// 2. Fetch from DB
-	objID, _ := primitive.ObjectIDFromHex(idStr)
-	var todo Todo
-	err = app.Collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&todo)
+	// 1. Check specific item cache, ignoring entries that aren't this todo
+	cacheKey := todoCacheKey(ctx, objID)
+	if cached != nil {
+		if string(cached) == notFoundSentinel {
+			w.Header().Del("ETag")
+			respondError(w, http.StatusNotFound, "Todo not found")
+			return
+		}
+		if resp, ok := decodeCachedTodo(cached, objID.Hex()); ok {
+			respondTodo(w, r, resp, cached)
+			return
+		}
+		app.RedisClient.Del(ctx, cacheKey)
+	}
+
+	// 2. Fetch from DB, briefly remembering misses so clients polling a
+	// deleted todo don't each reach Mongo
+	todo, err := app.Store.Get(ctx, objID)
+	if errors.Is(err, store.ErrNotFound) {
+		if app.notFoundTTL > 0 {
+			app.RedisClient.Set(ctx, cacheKey, notFoundSentinel, app.notFoundTTL)
+		}
+		w.Header().Del("ETag")
+		respondError(w, http.StatusNotFound, "Todo not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Todo not found", http.StatusNotFound)
+		w.Header().Del("ETag")
+		serverError(w, r, "Failed to fetch todo", err)
 		return
 	}
 
 	// 3. Cache item
-	data, _ := json.Marshal(todo)
+	resp := newTodoResponse(todo)
+	data, _ := json.Marshal(resp)
 	app.RedisClient.Set(ctx, cacheKey, data, 5*time.Minute)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todo)
+	respondTodo(w, r, resp, data)
 }
 
 func (app *App) updateTodo(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	objID, err := primitive.ObjectIDFromHex(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
 	var req UpdateTodoRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, "Invalid body")
 		return
 	}
 
-	update := bson.M{}
-	if req.Title != nil {
-		update["title"] = *req.Title
+	update := store.Update{
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Priority:    req.Priority,
+		Color:       req.Color,
+		Estimate:    req.Estimate,
+		Progress:    req.Progress,
+		Custom:      req.Custom,
+		Pinned:      req.Pinned,
+		Completed:   req.Completed,
 	}
-	if req.Completed != nil {
-		update["completed"] = *req.Completed
+	if req.Location != nil {
+		loc, fields := req.Location.location()
+		if fields != nil {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "Validation failed", Fields: fields})
+			return
+		}
+		update.Location = loc
 	}
-
-	ctx := r.Context()
-	_, err = app.Collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": update})
-	if err != nil {
-		http.Error(w, "Failed to update", http.StatusInternalServerError)
+	due, fields := parseDueAt(req.DueAt)
+	if fields != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "Validation failed", Fields: fields})
 		return
 	}
+	update.DueAt = due
+	if req.BlockedBy != nil {
+		blockers, err := parseBlockers(*req.BlockedBy)
+		if err != nil {
+			respondErr(w, r, err, "Failed to update")
+			return
+		}
+		update.BlockedBy = &blockers
+	}
+	ctx, notices := withNotices(r.Context())
+	if err := app.Todos.Update(ctx, objID, &update); err != nil {
+		respondErr(w, r, err, "Failed to update")
+		return
+	}
+	app.passNotices(w, r, "todo:"+objID.Hex(), notices)
 
-	// Invalidate caches
-	app.RedisClient.Del(ctx, "todos:all", fmt.Sprintf("todo:%s", idStr))
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"updated"}`))
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "updated"})
 }
 
-func (app *App) deleteTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	objID, err := primitive.ObjectIDFromHex(idStr)
+// removeTodo deletes the todo named in the URL, restorably for the undo
+// window, answering errors itself and reporting whether it was deleted.
+func (app *App) removeTodo(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return objID, false
 	}
-
-	ctx := r.Context()
-	_, err = app.Collection.DeleteOne(ctx, bson.M{"_id": objID})
-	if err != nil {
-		http.Error(w, "Failed to delete", http.StatusInternalServerError)
-		return
+	if err := app.Todos.DeleteUndoable(r.Context(), objID); err != nil {
+		respondErr(w, r, err, "Failed to delete")
+		return objID, false
 	}
+	return objID, true
+}
 
-	// Invalidate caches
-	app.RedisClient.Del(ctx, "todos:all", fmt.Sprintf("todo:%s", idStr))
-
-	// Check if this is a form submission or API call
-	contentType := r.Header.Get("Content-Type")
-	acceptHeader := r.Header.Get("Accept")
-	isFormOrBrowser := strings.Contains(contentType, "application/x-www-form-urlencoded") ||
-		strings.Contains(acceptHeader, "text/html") ||
-		r.Header.Get("X-Requested-With") == ""
-
-	if isFormOrBrowser {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-	} else {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"deleted"}`))
+func (app *App) deleteTodo(w http.ResponseWriter, r *http.Request) {
+	if _, ok := app.removeTodo(w, r); ok {
+		respondJSON(w, http.StatusOK, StatusResponse{Status: "deleted"})
 	}
 }
 
-// deleteTodoForm handles deletion via HTML form POST
+// deleteTodoForm handles deletion via HTML form POST, going back home.
 func (app *App) deleteTodoForm(w http.ResponseWriter, r *http.Request) {
-	// This route now just calls deleteTodo which handles the redirect
-	app.deleteTodo(w, r)
+	id, ok := app.removeTodo(w, r)
+	if !ok {
+		return
+	}
+	if app.undoWindow > 0 {
+		setUndoFlash(w, "Todo deleted", id)
+	} else {
+		setFlash(w, FlashSuccess, "Todo deleted")
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
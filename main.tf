@@ -143,6 +143,7 @@ resource "azurerm_redis_cache" "main" {
   capacity            = var.redis_capacity
   family              = var.redis_family
   sku_name            = var.redis_sku_name
+  redis_version       = "6"
   minimum_tls_version = "1.2"
 
   public_network_access_enabled = false
@@ -273,6 +274,13 @@ resource "azurerm_container_app" "main" {
         name  = "ENVIRONMENT"
         value = var.environment
       }
+
+      # Fails while the app starts up and while it drains on shutdown
+      readiness_probe {
+        transport = "HTTP"
+        path      = "/readyz"
+        port      = var.app_port
+      }
     }
 
     min_replicas = var.min_replicas
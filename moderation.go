@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Content Moderation ---
+
+// Policies for titles and descriptions the moderator flags, chosen with
+// CONTENT_MODERATION and per tenant in TENANTS_FILE.
+const (
+	ModerationOff    = "off"
+	ModerationFlag   = "flag"   // save it, naming the categories in X-Content-Flagged, and audit it
+	ModerationReject = "reject" // refuse with 422
+)
+
+const (
+	// DefaultContentSafetyAPIVersion is used unless
+	// AZURE_CONTENT_SAFETY_API_VERSION is set
+	DefaultContentSafetyAPIVersion = "2023-10-01"
+	// DefaultModerationSeverity is the Content Safety severity (0, 2, 4
+	// or 6) from which a category counts, unless MODERATION_SEVERITY is set
+	DefaultModerationSeverity = 4
+)
+
+// moderator judges user-written text.
+type moderator interface {
+	// Moderate returns the categories text falls in, none when it's fine.
+	Moderate(ctx context.Context, text string) ([]string, error)
+}
+
+// newModerator returns the Azure AI Content Safety moderator when
+// AZURE_CONTENT_SAFETY_ENDPOINT and AZURE_CONTENT_SAFETY_KEY are set, or
+// nil, which turns moderation off whatever the policy.
+func newModerator() moderator {
+	endpoint := strings.TrimRight(os.Getenv("AZURE_CONTENT_SAFETY_ENDPOINT"), "/")
+	key := os.Getenv("AZURE_CONTENT_SAFETY_KEY")
+	if endpoint == "" || key == "" {
+		return nil
+	}
+	version := os.Getenv("AZURE_CONTENT_SAFETY_API_VERSION")
+	if version == "" {
+		version = DefaultContentSafetyAPIVersion
+	}
+	return &azureContentSafety{
+		url:      fmt.Sprintf("%s/contentsafety/text:analyze?api-version=%s", endpoint, version),
+		key:      key,
+		severity: envInt("MODERATION_SEVERITY", DefaultModerationSeverity),
+		client:   &http.Client{Timeout: envDuration("AI_TIMEOUT", DefaultAITimeout)},
+	}
+}
+
+// azureContentSafety uses the text analysis API of Azure AI Content
+// Safety.
+type azureContentSafety struct {
+	url      string
+	key      string
+	severity int
+	client   *http.Client
+}
+
+func (a *azureContentSafety) Moderate(ctx context.Context, text string) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{"text": text, "outputType": "FourSeverityLevels"})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ocp-Apim-Subscription-Key", a.key)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	var out struct {
+		CategoriesAnalysis []struct {
+			Category string `json:"category"`
+			Severity int    `json:"severity"`
+		} `json:"categoriesAnalysis"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	var flagged []string
+	for _, c := range out.CategoriesAnalysis {
+		if c.Severity >= a.severity {
+			flagged = append(flagged, c.Category)
+		}
+	}
+	return flagged, nil
+}
+
+func moderationPolicy() string {
+	switch v := os.Getenv("CONTENT_MODERATION"); {
+	case v == "":
+		return ModerationOff
+	case validModerationPolicy(v):
+		return v
+	default:
+		log.Printf("Invalid CONTENT_MODERATION=%q, using default: %s", v, ModerationOff)
+		return ModerationOff
+	}
+}
+
+func validModerationPolicy(v string) bool {
+	return v == ModerationOff || v == ModerationFlag || v == ModerationReject
+}
+
+// moderationFor returns the moderation policy of the request's tenant.
+func (app *App) moderationFor(ctx context.Context) string {
+	if app.moderator == nil {
+		return ModerationOff
+	}
+	if policy := tenantFrom(ctx).Moderation; policy != "" {
+		return policy
+	}
+	return app.moderation
+}
+
+// updatedText is the user-written text u sets, for checkContent.
+func updatedText(u store.Update) map[string]string {
+	fields := map[string]string{}
+	if u.Title != nil {
+		fields["title"] = *u.Title
+	}
+	if u.Description != nil {
+		fields["description"] = *u.Description
+	}
+	return fields
+}
+
+// checkContent moderates the given fields (name to text) of the todo
+// target, noting the flagged categories when the policy lets them through.
+// A failed check doesn't block the write.
+func (app *App) checkContent(ctx context.Context, target string, fields map[string]string) error {
+	policy := app.moderationFor(ctx)
+	if policy == ModerationOff {
+		return nil
+	}
+	flagged := map[string]string{}
+	categories := map[string]bool{}
+	for name, text := range fields {
+		if text == "" {
+			continue
+		}
+		found, err := app.moderator.Moderate(ctx, text)
+		if err != nil {
+			log.Printf("Error moderating %s of %s: %v", name, target, err)
+			continue
+		}
+		if len(found) > 0 {
+			flagged[name] = "flagged as " + strings.Join(found, ", ")
+			for _, c := range found {
+				categories[c] = true
+			}
+		}
+	}
+	if len(flagged) == 0 {
+		return nil
+	}
+	if policy == ModerationReject {
+		return &RuleError{Kind: ErrContentRejected, Fields: flagged}
+	}
+	names := make([]string, 0, len(categories))
+	for c := range categories {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	noticesFrom(ctx).Flagged = names
+	return nil
+}
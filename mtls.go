@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// --- Mutual TLS ---
+
+// mtlsIdentities maps client certificate SANs (DNS names, URIs such as
+// SPIFFE IDs, or email addresses) to the caller names they identify.
+type mtlsIdentities map[string]string
+
+// parseMTLSIdentities parses MTLS_IDENTITIES: comma-separated san=name
+// pairs, e.g. billing.internal=billing,spiffe://corp/ns/jobs/sa/export=exporter.
+func parseMTLSIdentities(v string) (mtlsIdentities, error) {
+	ids := mtlsIdentities{}
+	for _, pair := range splitList(v) {
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid MTLS_IDENTITIES entry %q: want san=name", pair)
+		}
+		ids[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("MTLS_IDENTITIES must map at least one SAN")
+	}
+	return ids, nil
+}
+
+// identify returns the name mapped to the first SAN of cert that has one.
+func (ids mtlsIdentities) identify(cert *x509.Certificate) (string, bool) {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, san := range sans {
+		if name, ok := ids[san]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+type mtlsIdentityContextKey struct{}
+
+// mtlsIdentity returns the name of the certificate-authenticated caller,
+// or "" for requests not made over the mTLS listener.
+func mtlsIdentity(ctx context.Context) string {
+	name, _ := ctx.Value(mtlsIdentityContextKey{}).(string)
+	return name
+}
+
+// requireClientIdentity admits only requests whose verified client
+// certificate maps to an identity, answering 403 otherwise.
+func requireClientIdentity(ids mtlsIdentities, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			respondError(w, http.StatusForbidden, "Client certificate required")
+			return
+		}
+		name, ok := ids.identify(r.TLS.VerifiedChains[0][0])
+		if !ok {
+			respondError(w, http.StatusForbidden, "Unknown client certificate")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), mtlsIdentityContextKey{}, name)))
+	})
+}
+
+// newMTLSServer returns a server for service callers inside the network
+// that authenticate with client certificates, or nil unless MTLS_PORT is
+// set. Certificates must chain to MTLS_CLIENT_CA_FILE and carry a SAN
+// listed in MTLS_IDENTITIES; the server presents MTLS_CERT_FILE and
+// MTLS_KEY_FILE.
+func newMTLSServer(handler http.Handler) (*http.Server, error) {
+	port := os.Getenv("MTLS_PORT")
+	if port == "" {
+		return nil, nil
+	}
+	ids, err := parseMTLSIdentities(os.Getenv("MTLS_IDENTITIES"))
+	if err != nil {
+		return nil, err
+	}
+	caFile := os.Getenv("MTLS_CLIENT_CA_FILE")
+	if caFile == "" || os.Getenv("MTLS_CERT_FILE") == "" || os.Getenv("MTLS_KEY_FILE") == "" {
+		return nil, errors.New("MTLS_PORT needs MTLS_CLIENT_CA_FILE, MTLS_CERT_FILE and MTLS_KEY_FILE")
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cas := x509.NewCertPool()
+	if !cas.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s holds no PEM certificates", caFile)
+	}
+	cert, err := tls.LoadX509KeyPair(os.Getenv("MTLS_CERT_FILE"), os.Getenv("MTLS_KEY_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Server{
+		Addr:    ":" + port,
+		Handler: requireClientIdentity(ids, handler),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    cas,
+			MinVersion:   tls.VersionTLS12,
+		},
+	}, nil
+}
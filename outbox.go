@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Outbox Dispatcher ---
+
+const (
+	// OutboxColName holds messages waiting to be relayed
+	OutboxColName = "outbox"
+	// DefaultOutboxInterval is how often the dispatcher polls the outbox
+	DefaultOutboxInterval = 2 * time.Second
+	// MaxOutboxAttempts is how often a message is tried before it's marked dead
+	MaxOutboxAttempts = 10
+	// outboxBatch bounds the messages leased per poll
+	outboxBatch = 50
+	// outboxLease keeps a leased message from other dispatchers while it's sent
+	outboxLease = time.Minute
+)
+
+// outboxSink receives relayed events. Delivery is at least once, so sinks
+// pass the message ID on for consumers to deduplicate.
+type outboxSink interface {
+	Name() string
+	Send(ctx context.Context, msg store.OutboxMessage) error
+}
+
+// batchSink is an outboxSink taking many messages per request. SendBatch
+// returns an error for each message, nil for those accepted.
+type batchSink interface {
+	outboxSink
+	SendBatch(ctx context.Context, msgs []store.OutboxMessage) []error
+}
+
+// OutboxPayload is the body relayed for each message.
+type OutboxPayload struct {
+	ID      string        `json:"id"` // dedup key
+	Type    string        `json:"type"`
+	TodoID  string        `json:"todoId"`
+	Tenant  string        `json:"tenant,omitempty"`
+	Todo    *TodoResponse `json:"todo,omitempty"`
+	Changes *store.Update `json:"changes,omitempty"`
+	At      string        `json:"at"`
+}
+
+func newOutboxPayload(msg store.OutboxMessage) OutboxPayload {
+	p := OutboxPayload{
+		ID:      msg.ID.Hex(),
+		Type:    "todo." + msg.Event.Type,
+		TodoID:  msg.Event.TodoID.Hex(),
+		Tenant:  msg.Event.Tenant,
+		Changes: msg.Event.Changes,
+		At:      formatTime(msg.Event.At),
+	}
+	if msg.Event.Todo != nil {
+		todo := newTodoResponse(*msg.Event.Todo)
+		p.Todo = &todo
+	}
+	return p
+}
+
+// newOutboxSinks returns the sinks configured by OUTBOX_WEBHOOK_URLS
+// (comma-separated), AZURE_SERVICEBUS_CONNECTIONSTRING with
+// AZURE_SERVICEBUS_QUEUE and AZURE_EVENTHUB_CONNECTIONSTRING, or nil to
+// leave the outbox off.
+func newOutboxSinks() ([]outboxSink, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var sinks []outboxSink
+	for _, u := range strings.Split(os.Getenv("OUTBOX_WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			sinks = append(sinks, &webhookSink{url: u, client: client})
+		}
+	}
+	if conn := os.Getenv("AZURE_SERVICEBUS_CONNECTIONSTRING"); conn != "" {
+		sink, err := newServiceBusSink(conn, os.Getenv("AZURE_SERVICEBUS_QUEUE"), client)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if conn := os.Getenv("AZURE_EVENTHUB_CONNECTIONSTRING"); conn != "" {
+		sink, err := newEventHubSink(conn, os.Getenv("AZURE_EVENTHUB_NAME"), envInt("EVENTHUB_BATCH_SIZE", DefaultEventHubBatchSize), client)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// webhookSink POSTs the payload as JSON with the dedup key in an
+// Idempotency-Key header.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Name() string { return "webhook " + s.url }
+
+func (s *webhookSink) Send(ctx context.Context, msg store.OutboxMessage) error {
+	body, err := json.Marshal(newOutboxPayload(msg))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", msg.ID.Hex())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// serviceBusSink sends to an Azure Service Bus queue through its REST API.
+// The dedup key is the MessageId, so enabling duplicate detection on the
+// queue drops redeliveries.
+type serviceBusSink struct {
+	resource string // https://<namespace>.servicebus.windows.net/<queue>
+	keyName  string
+	key      string
+	client   *http.Client
+}
+
+// parseSASConnection splits a Service Bus or Event Hubs connection string
+// of the form Endpoint=sb://...;SharedAccessKeyName=...;SharedAccessKey=...
+// into its parts; EntityPath is optional.
+func parseSASConnection(conn string) (map[string]string, bool) {
+	parts := map[string]string{}
+	for _, kv := range strings.Split(conn, ";") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			parts[k] = v
+		}
+	}
+	ok := parts["Endpoint"] != "" && parts["SharedAccessKeyName"] != "" && parts["SharedAccessKey"] != ""
+	parts["Host"] = strings.TrimSuffix(strings.TrimPrefix(parts["Endpoint"], "sb://"), "/")
+	return parts, ok
+}
+
+// sasToken returns a shared access signature for resource valid for an
+// hour.
+func sasToken(resource, keyName, key string) string {
+	uri := url.QueryEscape(strings.ToLower(resource))
+	expiry := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(uri + "\n" + expiry))
+	sig := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s&skn=%s", uri, sig, expiry, url.QueryEscape(keyName))
+}
+
+// newServiceBusSink parses a Service Bus connection string.
+func newServiceBusSink(conn, queue string, client *http.Client) (*serviceBusSink, error) {
+	parts, ok := parseSASConnection(conn)
+	if !ok || queue == "" {
+		return nil, fmt.Errorf("AZURE_SERVICEBUS_CONNECTIONSTRING needs Endpoint, SharedAccessKeyName and SharedAccessKey, and AZURE_SERVICEBUS_QUEUE must be set")
+	}
+	return &serviceBusSink{
+		resource: "https://" + parts["Host"] + "/" + url.PathEscape(queue),
+		keyName:  parts["SharedAccessKeyName"],
+		key:      parts["SharedAccessKey"],
+		client:   client,
+	}, nil
+}
+
+func (s *serviceBusSink) Name() string { return "service bus " + s.resource }
+
+func (s *serviceBusSink) token() string { return sasToken(s.resource, s.keyName, s.key) }
+
+func (s *serviceBusSink) Send(ctx context.Context, msg store.OutboxMessage) error {
+	payload := newOutboxPayload(msg)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	props, _ := json.Marshal(map[string]string{"MessageId": payload.ID, "Label": payload.Type})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.resource+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", s.token())
+	req.Header.Set("BrokerProperties", string(props))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// eventHubSink streams every message into an Azure Event Hub through its
+// REST API, as change data capture for analytics. Messages are sent in
+// batches of up to batchSize per partition key, which is the tenant: there
+// are no users, and a tenant's events stay in order within a partition.
+type eventHubSink struct {
+	resource  string // https://<namespace>.servicebus.windows.net/<hub>
+	keyName   string
+	key       string
+	batchSize int
+	client    *http.Client
+}
+
+const (
+	// DefaultEventHubBatchSize is how many events go in one request unless
+	// EVENTHUB_BATCH_SIZE says otherwise
+	DefaultEventHubBatchSize = 100
+	// eventHubMaxBatchBytes keeps requests under Event Hubs' 1 MB limit
+	eventHubMaxBatchBytes = 900 << 10
+	// eventHubDefaultPartitionKey is the default tenant's partition key
+	eventHubDefaultPartitionKey = "default"
+)
+
+// newEventHubSink parses an Event Hubs connection string; the hub is its
+// EntityPath, or hub when the string is the namespace's.
+func newEventHubSink(conn, hub string, batchSize int, client *http.Client) (*eventHubSink, error) {
+	parts, ok := parseSASConnection(conn)
+	if parts["EntityPath"] != "" {
+		hub = parts["EntityPath"]
+	}
+	if !ok || hub == "" {
+		return nil, fmt.Errorf("AZURE_EVENTHUB_CONNECTIONSTRING needs Endpoint, SharedAccessKeyName and SharedAccessKey, and EntityPath or AZURE_EVENTHUB_NAME must be set")
+	}
+	if batchSize < 1 {
+		batchSize = DefaultEventHubBatchSize
+	}
+	return &eventHubSink{
+		resource:  "https://" + parts["Host"] + "/" + url.PathEscape(hub),
+		keyName:   parts["SharedAccessKeyName"],
+		key:       parts["SharedAccessKey"],
+		batchSize: batchSize,
+		client:    client,
+	}, nil
+}
+
+func (s *eventHubSink) Name() string { return "event hub " + s.resource }
+
+func (s *eventHubSink) Send(ctx context.Context, msg store.OutboxMessage) error {
+	return s.SendBatch(ctx, []store.OutboxMessage{msg})[0]
+}
+
+// eventHubEvent is one event of a batch request.
+type eventHubEvent struct {
+	Body           string            `json:"Body"`
+	UserProperties map[string]string `json:"UserProperties"`
+}
+
+func eventHubPartitionKey(msg store.OutboxMessage) string {
+	if msg.Event.Tenant == "" {
+		return eventHubDefaultPartitionKey
+	}
+	return msg.Event.Tenant
+}
+
+func (s *eventHubSink) SendBatch(ctx context.Context, msgs []store.OutboxMessage) []error {
+	errs := make([]error, len(msgs))
+
+	// Group by partition key, keeping each group in outbox order
+	var keys []string
+	groups := map[string][]int{}
+	for i, msg := range msgs {
+		key := eventHubPartitionKey(msg)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range keys {
+		var batch []eventHubEvent
+		var members []int
+		size := 0
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			err := s.post(ctx, key, batch)
+			for _, i := range members {
+				errs[i] = err
+			}
+			batch, members, size = nil, nil, 0
+		}
+		for _, i := range groups[key] {
+			payload := newOutboxPayload(msgs[i])
+			body, err := json.Marshal(payload)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			if len(batch) == s.batchSize || (size > 0 && size+len(body) > eventHubMaxBatchBytes) {
+				flush()
+			}
+			batch = append(batch, eventHubEvent{
+				Body:           string(body),
+				UserProperties: map[string]string{"id": payload.ID, "type": payload.Type},
+			})
+			members = append(members, i)
+			size += len(body)
+		}
+		flush()
+	}
+	return errs
+}
+
+func (s *eventHubSink) post(ctx context.Context, partitionKey string, batch []eventHubEvent) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	props, _ := json.Marshal(map[string]string{"PartitionKey": partitionKey})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.resource+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.microsoft.servicebus.json")
+	req.Header.Set("Authorization", sasToken(s.resource, s.keyName, s.key))
+	req.Header.Set("BrokerProperties", string(props))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// outboxBackoff is the delay before retry n (1-based): 5s doubling up to
+// an hour.
+func outboxBackoff(n int) time.Duration {
+	d := 5 * time.Second << (n - 1)
+	if n > 10 || d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
+
+// dispatchOutbox relays due messages to every sink until ctx is done. A
+// message is marked sent once all sinks accepted it; otherwise it's
+// retried for all of them with backoff, which consumers absorb by
+// deduplicating.
+func dispatchOutbox(ctx context.Context, outbox store.Outbox, sinks []outboxSink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		msgs, err := outbox.Due(ctx, time.Now().UTC(), outboxLease, outboxBatch)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error polling outbox: %v", err)
+		}
+		if len(msgs) > 0 {
+			relayMessages(ctx, outbox, sinks, msgs)
+		}
+	}
+}
+
+// relayMessages sends msgs to every sink, batch sinks all at once, and
+// settles each message by how its sinks took it.
+func relayMessages(ctx context.Context, outbox store.Outbox, sinks []outboxSink, msgs []store.OutboxMessage) {
+	failures := make([][]string, len(msgs))
+	for _, sink := range sinks {
+		if b, ok := sink.(batchSink); ok {
+			for i, err := range b.SendBatch(ctx, msgs) {
+				if err != nil {
+					failures[i] = append(failures[i], sink.Name()+": "+err.Error())
+				}
+			}
+			continue
+		}
+		for i, msg := range msgs {
+			if err := sink.Send(ctx, msg); err != nil {
+				failures[i] = append(failures[i], sink.Name()+": "+err.Error())
+			}
+		}
+	}
+	for i, msg := range msgs {
+		settleMessage(ctx, outbox, msg, failures[i])
+	}
+}
+
+// settleMessage marks msg sent when no sink failed it, and otherwise
+// schedules a retry or gives up on it.
+func settleMessage(ctx context.Context, outbox store.Outbox, msg store.OutboxMessage, failures []string) {
+	if len(failures) == 0 {
+		if err := outbox.MarkSent(ctx, msg.ID); err != nil {
+			log.Printf("Error marking outbox message %s sent: %v", msg.ID.Hex(), err)
+		}
+		return
+	}
+
+	reason := strings.Join(failures, "; ")
+	var retryAt time.Time
+	if attempt := msg.Attempts + 1; attempt < MaxOutboxAttempts {
+		retryAt = time.Now().UTC().Add(outboxBackoff(attempt))
+		log.Printf("Outbox message %s failed (attempt %d): %s", msg.ID.Hex(), attempt, reason)
+	} else {
+		log.Printf("Outbox message %s dead after %d attempts: %s", msg.ID.Hex(), attempt, reason)
+	}
+	if err := outbox.MarkFailed(ctx, msg.ID, retryAt, reason); err != nil {
+		log.Printf("Error marking outbox message %s failed: %v", msg.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// --- Panic Reporting ---
+
+// ServiceName identifies this service in error reports.
+const ServiceName = "go-azure-todo"
+
+// panicReportTimeout bounds delivery of a single report.
+const panicReportTimeout = 5 * time.Second
+
+// ErrorEvent is a recovered panic together with the request that caused it.
+type ErrorEvent struct {
+	ID        string // 32 hex digits, usable as a Sentry event ID
+	Time      time.Time
+	Message   string
+	Type      string // Go type of the panic value
+	Stack     string // debug.Stack output
+	Frames    []runtime.Frame
+	Method    string
+	URL       string
+	Route     string // chi route pattern, e.g. "/todos/{id}"
+	RequestID string
+	Remote    string
+	UserAgent string
+	User      string // apiClientID of the caller
+}
+
+// errorReporter forwards error events to an external tracker.
+type errorReporter interface {
+	Report(ctx context.Context, ev ErrorEvent) error
+}
+
+// newErrorReporter builds the reporters configured via SENTRY_DSN and
+// APPLICATIONINSIGHTS_CONNECTION_STRING. It returns nil when neither is set.
+func newErrorReporter() errorReporter {
+	var reporters multiReporter
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		rep, err := newSentryReporter(dsn)
+		if err != nil {
+			log.Printf("Ignoring SENTRY_DSN: %v", err)
+		} else {
+			reporters = append(reporters, rep)
+		}
+	}
+	if conn := os.Getenv("APPLICATIONINSIGHTS_CONNECTION_STRING"); conn != "" {
+		rep, err := newAppInsightsReporter(conn)
+		if err != nil {
+			log.Printf("Ignoring APPLICATIONINSIGHTS_CONNECTION_STRING: %v", err)
+		} else {
+			reporters = append(reporters, rep)
+		}
+	}
+	if len(reporters) == 0 {
+		return nil
+	}
+	return reporters
+}
+
+// recoverPanics replaces middleware.Recoverer: it turns a panic into a 500,
+// logs the stack with request details and hands a structured event to
+// reporter (if any) in the background.
+func recoverPanics(reporter errorReporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if rvr == http.ErrAbortHandler {
+					// Deliberate abort; let net/http handle it
+					panic(rvr)
+				}
+
+				ev := newErrorEvent(r, rvr)
+				log.Printf("panic %s: %s %s (route %s, request %s, client %s)\n%s",
+					ev.ID, ev.Method, ev.URL, ev.Route, ev.RequestID, ev.User, ev.Stack)
+				if reporter != nil {
+					go func() {
+						ctx, cancel := context.WithTimeout(context.Background(), panicReportTimeout)
+						defer cancel()
+						if err := reporter.Report(ctx, ev); err != nil {
+							log.Printf("Error reporting panic %s: %v", ev.ID, err)
+						}
+					}()
+				}
+
+				if r.Header.Get("Connection") != "Upgrade" {
+					writeError(w, http.StatusInternalServerError, ErrorResponse{Error: "Internal Server Error", RequestID: ev.RequestID})
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newErrorEvent captures rvr and the request. It must be called from the
+// deferred recover so the panicking frames are still on the stack.
+func newErrorEvent(r *http.Request, rvr interface{}) ErrorEvent {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	ev := ErrorEvent{
+		ID:        hex.EncodeToString(id),
+		Time:      time.Now().UTC(),
+		Message:   redactSecrets(fmt.Sprint(rvr)),
+		Type:      fmt.Sprintf("%T", rvr),
+		Stack:     string(debug.Stack()),
+		Method:    r.Method,
+		URL:       redactSecrets(r.URL.String()),
+		RequestID: middleware.GetReqID(r.Context()),
+		Remote:    clientIP(r),
+		UserAgent: r.UserAgent(),
+		User:      apiClientID(r),
+	}
+	if err, ok := rvr.(error); ok {
+		ev.Message = redactSecrets(err.Error())
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		ev.Route = rctx.RoutePattern()
+	}
+
+	// Skip runtime.Callers, newErrorEvent and the deferred closure
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		ev.Frames = append(ev.Frames, frame)
+		if !more {
+			break
+		}
+	}
+	return ev
+}
+
+type multiReporter []errorReporter
+
+func (m multiReporter) Report(ctx context.Context, ev ErrorEvent) error {
+	var errs []string
+	for _, rep := range m {
+		if err := rep.Report(ctx, ev); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// postJSON sends body to endpoint and treats any non-2xx status as an error.
+func postJSON(ctx context.Context, endpoint string, header http.Header, body interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// --- Sentry ---
+
+// sentryReporter posts events to Sentry's store endpoint.
+type sentryReporter struct {
+	endpoint string
+	auth     string
+}
+
+// newSentryReporter parses a DSN of the form https://KEY@HOST/PROJECT.
+func newSentryReporter(dsn string) (*sentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	project := strings.Trim(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || project == "" {
+		return nil, fmt.Errorf("expected https://KEY@HOST/PROJECT")
+	}
+	return &sentryReporter{
+		endpoint: fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s/1.0, sentry_key=%s", ServiceName, u.User.Username()),
+	}, nil
+}
+
+func (s *sentryReporter) Report(ctx context.Context, ev ErrorEvent) error {
+	// Sentry expects frames ordered from outermost to innermost call
+	frames := make([]map[string]interface{}, 0, len(ev.Frames))
+	for i := len(ev.Frames) - 1; i >= 0; i-- {
+		f := ev.Frames[i]
+		frames = append(frames, map[string]interface{}{
+			"function": f.Function,
+			"abs_path": f.File,
+			"lineno":   f.Line,
+			"in_app":   strings.HasPrefix(f.Function, "github.com/mkgakishi/go-azure-todo"),
+		})
+	}
+
+	event := map[string]interface{}{
+		"event_id":    ev.ID,
+		"timestamp":   ev.Time.Format(time.RFC3339Nano),
+		"level":       "fatal",
+		"platform":    "go",
+		"logger":      ServiceName,
+		"server_name": hostname(),
+		"transaction": ev.Method + " " + ev.Route,
+		"exception": map[string]interface{}{
+			"values": []map[string]interface{}{{
+				"type":       ev.Type,
+				"value":      ev.Message,
+				"mechanism":  map[string]interface{}{"type": "recover", "handled": false},
+				"stacktrace": map[string]interface{}{"frames": frames},
+			}},
+		},
+		"request": map[string]interface{}{
+			"method":  ev.Method,
+			"url":     ev.URL,
+			"headers": map[string]string{"User-Agent": ev.UserAgent},
+			"env":     map[string]string{"REMOTE_ADDR": ev.Remote},
+		},
+		"user": map[string]string{"id": ev.User},
+		"tags": map[string]string{"request_id": ev.RequestID, "route": ev.Route},
+	}
+	return postJSON(ctx, s.endpoint, http.Header{"X-Sentry-Auth": {s.auth}}, event)
+}
+
+// --- Application Insights ---
+
+// DefaultAppInsightsEndpoint is used when the connection string has no
+// IngestionEndpoint.
+const DefaultAppInsightsEndpoint = "https://dc.services.visualstudio.com"
+
+// appInsightsReporter sends exception telemetry to the Application
+// Insights ingestion API.
+type appInsightsReporter struct {
+	endpoint string
+	iKey     string
+}
+
+// newAppInsightsReporter parses an Azure connection string such as
+// "InstrumentationKey=...;IngestionEndpoint=https://...".
+func newAppInsightsReporter(conn string) (*appInsightsReporter, error) {
+	rep := &appInsightsReporter{endpoint: DefaultAppInsightsEndpoint}
+	for _, part := range strings.Split(conn, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(k) {
+		case "instrumentationkey":
+			rep.iKey = v
+		case "ingestionendpoint":
+			rep.endpoint = v
+		}
+	}
+	if rep.iKey == "" {
+		return nil, fmt.Errorf("missing InstrumentationKey")
+	}
+	rep.endpoint = strings.TrimSuffix(rep.endpoint, "/") + "/v2/track"
+	return rep, nil
+}
+
+func (a *appInsightsReporter) Report(ctx context.Context, ev ErrorEvent) error {
+	parsed := make([]map[string]interface{}, 0, len(ev.Frames))
+	for i, f := range ev.Frames {
+		parsed = append(parsed, map[string]interface{}{
+			"level":    i,
+			"method":   f.Function,
+			"fileName": f.File,
+			"line":     f.Line,
+		})
+	}
+
+	envelope := map[string]interface{}{
+		"name": "Microsoft.ApplicationInsights.Exception",
+		"time": ev.Time.Format(time.RFC3339Nano),
+		"iKey": a.iKey,
+		"tags": map[string]string{
+			"ai.cloud.role":         ServiceName,
+			"ai.cloud.roleInstance": hostname(),
+			"ai.operation.id":       ev.RequestID,
+			"ai.operation.name":     ev.Method + " " + ev.Route,
+			"ai.user.authUserId":    ev.User,
+			"ai.location.ip":        ev.Remote,
+		},
+		"data": map[string]interface{}{
+			"baseType": "ExceptionData",
+			"baseData": map[string]interface{}{
+				"ver":           2,
+				"severityLevel": 4, // Critical
+				"exceptions": []map[string]interface{}{{
+					"typeName":     ev.Type,
+					"message":      ev.Message,
+					"hasFullStack": true,
+					"stack":        ev.Stack,
+					"parsedStack":  parsed,
+				}},
+				"properties": map[string]string{
+					"eventId":   ev.ID,
+					"url":       ev.URL,
+					"userAgent": ev.UserAgent,
+				},
+			},
+		},
+	}
+	return postJSON(ctx, a.endpoint, nil, []interface{}{envelope})
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Pinning ---
+
+type PinRequest struct {
+	Pinned *bool `json:"pinned,omitempty"` // false unpins; defaults to true
+}
+
+// pinTodo serves POST /todos/{id}/pin, pinning a todo to the top of the
+// list or, with pinned=false, unpinning it. HTML forms are redirected home.
+func (app *App) pinTodo(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	pinned := true
+	isForm := strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	if isForm {
+		pinned = r.FormValue("pinned") != "false"
+	} else if r.ContentLength != 0 {
+		var req PinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.Pinned != nil {
+			pinned = *req.Pinned
+		}
+	}
+
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+	if todo.Pinned != pinned {
+		if err := app.Todos.Update(ctx, objID, &store.Update{Pinned: &pinned}); err != nil {
+			respondErr(w, r, err, "Failed to update")
+			return
+		}
+		todo.Pinned = pinned
+	}
+	if isForm {
+		if pinned {
+			setFlash(w, FlashSuccess, "Pinned \""+todo.Title+"\"")
+		} else {
+			setFlash(w, FlashSuccess, "Unpinned \""+todo.Title+"\"")
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	respondJSON(w, http.StatusOK, newTodoResponse(todo))
+}
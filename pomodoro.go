@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Pomodoro Sessions ---
+
+// DefaultPomodoroDuration is the length of a focus session unless
+// POMODORO_DURATION says otherwise.
+const DefaultPomodoroDuration = 25 * time.Minute
+
+// pomodoroGrace keeps a finished session in Redis long enough to be
+// counted even if the instance that started it has gone away.
+const pomodoroGrace = time.Hour
+
+// pomodoroSession is the running session of a todo, stored as JSON under
+// pomodoroKey.
+type pomodoroSession struct {
+	StartedAt time.Time `json:"startedAt"`
+	EndsAt    time.Time `json:"endsAt"`
+}
+
+type PomodoroResponse struct {
+	TodoID           string `json:"todoId"`
+	StartedAt        string `json:"startedAt"`
+	EndsAt           string `json:"endsAt"`
+	RemainingSeconds int    `json:"remainingSeconds"`
+}
+
+func newPomodoroResponse(id primitive.ObjectID, s pomodoroSession) PomodoroResponse {
+	return PomodoroResponse{
+		TodoID:           id.Hex(),
+		StartedAt:        formatTime(s.StartedAt),
+		EndsAt:           formatTime(s.EndsAt),
+		RemainingSeconds: int((max(time.Until(s.EndsAt), 0) + time.Second - 1) / time.Second), // rounded up
+	}
+}
+
+func pomodoroKey(ctx context.Context, id primitive.ObjectID) string {
+	return redisKey(ctx, "pomodoro:"+id.Hex())
+}
+
+// pomodoroSession returns the todo's session, reporting false when none
+// is running or waiting to be counted.
+func (app *App) pomodoroSession(ctx context.Context, id primitive.ObjectID) (pomodoroSession, bool, error) {
+	data, err := app.RedisClient.Get(ctx, pomodoroKey(ctx, id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return pomodoroSession{}, false, nil
+	}
+	if err != nil {
+		return pomodoroSession{}, false, err
+	}
+	var s pomodoroSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return pomodoroSession{}, false, err
+	}
+	return s, true, nil
+}
+
+// finishPomodoro counts the todo's session on the todo once it has ended.
+// Every instance may try; taking the key hands the session to exactly one
+// of them.
+func (app *App) finishPomodoro(ctx context.Context, id primitive.ObjectID) {
+	s, ok, err := app.pomodoroSession(ctx, id)
+	if err != nil || !ok || time.Now().Before(s.EndsAt) {
+		return
+	}
+	data, err := app.takeKey(ctx, pomodoroKey(ctx, id))
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &s); err == nil && time.Now().Before(s.EndsAt) {
+		// A new session started after ours was counted elsewhere; put it back
+		app.RedisClient.SetNX(ctx, pomodoroKey(ctx, id), data, time.Until(s.EndsAt)+pomodoroGrace)
+		return
+	}
+
+	if err := app.Todos.Update(ctx, id, &store.Update{AddPomodoros: 1}); err != nil {
+		log.Printf("Error recording pomodoro for %s: %v", id.Hex(), err)
+	}
+}
+
+// jobFinishPomodoro counts a session once it has ended.
+const jobFinishPomodoro = "pomodoro.finish"
+
+type pomodoroJob struct {
+	TodoID string `json:"todoId"`
+}
+
+// finishPomodoroJob runs jobFinishPomodoro. Whether it counted the session
+// or someone else did, there's nothing to retry.
+func (app *App) finishPomodoroJob(ctx context.Context, payload json.RawMessage) error {
+	var job pomodoroJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	id, err := primitive.ObjectIDFromHex(job.TodoID)
+	if err != nil {
+		return err
+	}
+	app.finishPomodoro(ctx, id)
+	return nil
+}
+
+// startPomodoro serves POST /todos/{id}/pomodoro.
+func (app *App) startPomodoro(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	ctx := r.Context()
+	if _, err := app.Store.Get(ctx, objID); err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+
+	// Count a finished session nobody has recorded yet before replacing it
+	app.finishPomodoro(ctx, objID)
+
+	now := time.Now()
+	s := pomodoroSession{StartedAt: now, EndsAt: now.Add(app.pomodoro)}
+	data, _ := json.Marshal(s)
+	started, err := app.RedisClient.SetNX(ctx, pomodoroKey(ctx, objID), data, app.pomodoro+pomodoroGrace).Result()
+	if err != nil {
+		serverError(w, r, "Failed to start pomodoro", err)
+		return
+	}
+	if !started {
+		respondError(w, http.StatusConflict, "A pomodoro is already running for this todo")
+		return
+	}
+
+	// Count the session when it ends, even if this instance is gone by then
+	if err := app.enqueueJobAt(ctx, jobFinishPomodoro, pomodoroJob{TodoID: objID.Hex()}, s.EndsAt); err != nil {
+		log.Printf("Error scheduling end of pomodoro for %s: %v", objID.Hex(), err)
+	}
+	respondJSON(w, http.StatusCreated, newPomodoroResponse(objID, s))
+}
+
+// pomodoroEvents serves GET /todos/{id}/pomodoro/events, a Server-Sent
+// Events stream with a "tick" every second and a final "done".
+func (app *App) pomodoroEvents(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	ctx := r.Context()
+	s, ok, err := app.pomodoroSession(ctx, objID)
+	if err != nil {
+		serverError(w, r, "Failed to load pomodoro", err)
+		return
+	}
+	if !ok {
+		respondError(w, http.StatusNotFound, "No pomodoro running")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		resp := newPomodoroResponse(objID, s)
+		event := "tick"
+		if resp.RemainingSeconds == 0 {
+			event = "done"
+			app.finishPomodoro(ctx, objID)
+		}
+		data, _ := json.Marshal(resp)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+		if event == "done" {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.streamsDone:
+			return // shutting down; the client reconnects to another replica
+		case <-ticker.C:
+		}
+	}
+}
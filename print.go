@@ -0,0 +1,224 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Print View ---
+
+const (
+	// AllTodosList names the list of every todo; the other lists are the
+	// color labels, which are how todos are grouped
+	AllTodosList = "all"
+	// DefaultPrintPageSize is how many todos GET /lists/{id}/print renders
+	// without ?limit=
+	DefaultPrintPageSize = 100
+)
+
+// printGroup is a heading of the print view and its todos.
+type printGroup struct {
+	Name  string
+	Todos []store.Todo
+}
+
+// printPage is the data rendered by printTemplate.
+type printPage struct {
+	List    string
+	GroupBy string // "priority" or "due"
+	Groups  []printGroup
+	Printed time.Time
+	Limit   int
+	HasPrev bool
+	Prev    int // offset of the previous page
+	Next    int // offset of the next page; zero on the last
+}
+
+// printPriorities are the priority groups, highest first.
+var printPriorities = []struct{ priority, name string }{
+	{"high", "High priority"},
+	{"medium", "Medium priority"},
+	{"low", "Low priority"},
+	{"", "No priority"},
+}
+
+// dueGroups are the due date groups, soonest first.
+var dueGroups = []string{"Overdue", "Today", "This week", "Later", "No due date"}
+
+// dueGroup returns the index in dueGroups of todo's due date relative to now.
+func dueGroup(todo store.Todo, now time.Time) int {
+	if todo.DueAt == nil {
+		return len(dueGroups) - 1
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch due := *todo.DueAt; {
+	case due.Before(now):
+		return 0
+	case due.Before(today.AddDate(0, 0, 1)):
+		return 1
+	case due.Before(today.AddDate(0, 0, 7)):
+		return 2
+	default:
+		return 3
+	}
+}
+
+// printOrder sorts todos by priority (highest first) or due date group
+// (soonest first), then by due date, and returns each todo's group name.
+func printOrder(todos []store.Todo, by string, now time.Time) []string {
+	group := func(todo store.Todo) int { return dueGroup(todo, now) }
+	if by != "due" {
+		group = func(todo store.Todo) int {
+			for i, p := range printPriorities {
+				if p.priority == todo.Priority {
+					return i
+				}
+			}
+			return len(printPriorities) - 1
+		}
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		if gi, gj := group(todos[i]), group(todos[j]); gi != gj {
+			return gi < gj
+		}
+		a, b := todos[i].DueAt, todos[j].DueAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	names := make([]string, len(todos))
+	for i, todo := range todos {
+		if by == "due" {
+			names[i] = dueGroups[group(todo)]
+		} else {
+			names[i] = printPriorities[group(todo)].name
+		}
+	}
+	return names
+}
+
+// printList serves GET /lists/{id}/print?group=&offset=&limit=: a plain,
+// printable checklist of the open todos of a list, grouped by priority or,
+// with group=due, by due date. The list is "all" or a color label. Todos
+// are ordered before paging, so each page carries on where the last ended.
+func (app *App) printList(w http.ResponseWriter, r *http.Request) {
+	list := normalizeColor(chi.URLParam(r, "id"))
+	if list != AllTodosList && (list == "" || !store.ValidColor(list)) {
+		respondError(w, http.StatusNotFound, "List not found")
+		return
+	}
+	params := r.URL.Query()
+	groupBy := params.Get("group")
+	if groupBy == "" {
+		groupBy = "priority"
+	}
+	if groupBy != "priority" && groupBy != "due" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"group": "must be priority or due"},
+		})
+		return
+	}
+	offset, limit, err := parsePage(params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 {
+		limit = DefaultPrintPageSize
+	}
+
+	open := false
+	q := store.Query{Fields: []string{"title", "priority", "dueAt", "tags", "createdAt"}, Completed: &open}
+	if list != AllTodosList {
+		q.Color = list
+	}
+	todos, err := app.Store.List(r.Context(), q)
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+
+	now := time.Now()
+	names := printOrder(todos, groupBy, now)
+	page := printPage{List: list, GroupBy: groupBy, Printed: now, Limit: limit, HasPrev: offset > 0}
+	if offset > len(todos) {
+		offset = len(todos)
+	}
+	end := len(todos)
+	if offset+limit < end {
+		end = offset + limit
+		page.Next = end
+	}
+	if page.Prev = offset - limit; page.Prev < 0 {
+		page.Prev = 0
+	}
+	for i := offset; i < end; i++ {
+		if n := len(page.Groups); n == 0 || page.Groups[n-1].Name != names[i] {
+			page.Groups = append(page.Groups, printGroup{Name: names[i]})
+		}
+		last := &page.Groups[len(page.Groups)-1]
+		last.Todos = append(last.Todos, todos[i])
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	app.Print.Execute(w, page)
+}
+
+const printTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{if eq .List "all"}}Todos{{else}}{{.List}} todos{{end}} - Azure Go Todo</title>
+    <style>
+        body { font: 12pt/1.4 Georgia, serif; color: #000; background: #fff; max-width: 42em; margin: 2em auto; padding: 0 1em; }
+        h1 { font-size: 18pt; margin: 0; }
+        h2 { font-size: 13pt; border-bottom: 1px solid #000; margin: 1.2em 0 0.4em; break-after: avoid; }
+        ul { list-style: none; padding: 0; margin: 0; }
+        li { padding: 0.25em 0; break-inside: avoid; }
+        li::before { content: "\2610"; margin-right: 0.5em; }
+        .meta, .due, .tags { color: #555; font-size: 10pt; }
+        nav { margin: 1em 0; font-family: sans-serif; font-size: 10pt; }
+        @page { margin: 1.5cm; }
+        @media print {
+            body { margin: 0; max-width: none; }
+            nav { display: none; }
+            a { color: inherit; text-decoration: none; }
+        }
+    </style>
+</head>
+<body>
+<header>
+    <h1>{{if eq .List "all"}}Todos{{else}}{{.List}} todos{{end}}</h1>
+    <p class="meta">Open todos by {{if eq .GroupBy "due"}}due date{{else}}priority{{end}}, printed {{.Printed.Format "Jan 02 2006, 15:04"}}</p>
+</header>
+<nav aria-label="Print options">
+    <a href="/">Back to list</a> &middot;
+    {{if eq .GroupBy "due"}}<a href="?group=priority">Group by priority</a>{{else}}<a href="?group=due">Group by due date</a>{{end}} &middot;
+    <a href="#" onclick="window.print(); return false;">Print</a>
+</nav>
+<main>
+    {{range .Groups}}
+    <section>
+        <h2>{{.Name}}</h2>
+        <ul>
+            {{range .Todos}}<li>{{.Title}}{{if .DueAt}} <span class="due">due {{.DueAt.Format "Jan 02"}}</span>{{end}}{{if .Tags}} <span class="tags">{{range .Tags}}#{{.}} {{end}}</span>{{end}}</li>{{end}}
+        </ul>
+    </section>
+    {{else}}
+    <p>Nothing left to do.</p>
+    {{end}}
+</main>
+{{if or .HasPrev .Next}}
+<nav aria-label="Pages">
+    {{if .HasPrev}}<a href="?group={{.GroupBy}}&offset={{.Prev}}&limit={{.Limit}}">&larr; Previous page</a>{{end}}
+    {{if .Next}}<a href="?group={{.GroupBy}}&offset={{.Next}}&limit={{.Limit}}">Next page &rarr;</a>{{end}}
+</nav>
+{{end}}
+</body>
+</html>`
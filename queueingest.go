@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Queue Ingestion ---
+
+const (
+	// DefaultQueuePollInterval is how often an empty queue is polled again
+	DefaultQueuePollInterval = 5 * time.Second
+	// MaxQueueDequeues is how often a message is tried before it's moved
+	// to the poison queue
+	MaxQueueDequeues = 5
+	// queueBatch bounds the messages received per poll (Azure's maximum)
+	queueBatch = 32
+	// queueVisibility hides a received message from other instances while
+	// it's processed; one that isn't deleted by then is tried again
+	queueVisibility = 5 * time.Minute
+	// queueAPIVersion is the Queue service REST API version spoken
+	queueAPIVersion = "2021-12-02"
+)
+
+// QueueTodoMessage is the JSON other systems send to create a todo: the
+// body of POST /todos, plus the tenant to create it in when tenancy is on.
+type QueueTodoMessage struct {
+	Tenant string `json:"tenant,omitempty"`
+	CreateTodoRequest
+}
+
+// storageQueue talks to an Azure Storage queue through its REST API,
+// authorized by the SAS token in its URL.
+type storageQueue struct {
+	base   *url.URL // https://<account>.queue.core.windows.net/<queue>?<sas>
+	poison *url.URL // the same account's <queue>-poison
+	client *http.Client
+}
+
+// newStorageQueue reads AZURE_STORAGE_QUEUE_URL, a queue URL carrying a
+// SAS token allowed to read, process and add messages and create queues.
+// It returns nil when ingestion is off.
+func newStorageQueue() (*storageQueue, error) {
+	raw := os.Getenv("AZURE_STORAGE_QUEUE_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	base, err := url.Parse(raw)
+	if err != nil || base.Scheme != "https" || strings.Trim(base.Path, "/") == "" || base.RawQuery == "" {
+		return nil, fmt.Errorf("AZURE_STORAGE_QUEUE_URL must be an https queue URL with a SAS token")
+	}
+	poison := *base
+	poison.Path = strings.TrimSuffix(base.Path, "/") + "-poison"
+	return &storageQueue{base: base, poison: &poison, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// queueMessage is a received message; MessageText is usually base64, as
+// the Azure SDKs and Functions write it.
+type queueMessage struct {
+	MessageID    string `xml:"MessageId"`
+	PopReceipt   string `xml:"PopReceipt"`
+	DequeueCount int    `xml:"DequeueCount"`
+	MessageText  string `xml:"MessageText"`
+}
+
+// body returns the message's text, decoding base64 when it is.
+func (m queueMessage) body() []byte {
+	if data, err := base64.StdEncoding.DecodeString(m.MessageText); err == nil {
+		return data
+	}
+	return []byte(m.MessageText)
+}
+
+// do sends a request to u with path appended and extra query parameters.
+func (q *storageQueue) do(ctx context.Context, method string, u *url.URL, path string, params url.Values, body []byte) (*http.Response, error) {
+	target := *u
+	target.Path = strings.TrimSuffix(u.Path, "/") + path
+	query := target.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	target.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", queueAPIVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s responded %s", u.Host, resp.Status)
+	}
+	return resp, nil
+}
+
+func (q *storageQueue) receive(ctx context.Context) ([]queueMessage, error) {
+	resp, err := q.do(ctx, http.MethodGet, q.base, "/messages", url.Values{
+		"numofmessages":     {fmt.Sprint(queueBatch)},
+		"visibilitytimeout": {fmt.Sprint(int(queueVisibility.Seconds()))},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Messages []queueMessage `xml:"QueueMessage"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return list.Messages, nil
+}
+
+func (q *storageQueue) delete(ctx context.Context, m queueMessage) error {
+	resp, err := q.do(ctx, http.MethodDelete, q.base, "/messages/"+url.PathEscape(m.MessageID), url.Values{"popreceipt": {m.PopReceipt}}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// quarantine moves a message to the poison queue as it was received, so
+// it can be fixed and sent again.
+func (q *storageQueue) quarantine(ctx context.Context, m queueMessage) error {
+	var body bytes.Buffer
+	body.WriteString("<QueueMessage><MessageText>")
+	xml.EscapeText(&body, []byte(m.MessageText))
+	body.WriteString("</MessageText></QueueMessage>")
+	resp, err := q.do(ctx, http.MethodPost, q.poison, "/messages", url.Values{"messagettl": {"-1"}}, body.Bytes())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return q.delete(ctx, m)
+}
+
+// ensurePoisonQueue creates the poison queue unless it exists.
+func (q *storageQueue) ensurePoisonQueue(ctx context.Context) error {
+	resp, err := q.do(ctx, http.MethodPut, q.poison, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ingestQueue creates todos from the queue's messages until ctx is done.
+// Messages that can never succeed (unreadable, invalid, rejected) and
+// those that failed MaxQueueDequeues times go to the poison queue; others
+// that fail reappear after queueVisibility and are tried again.
+func (app *App) ingestQueue(ctx context.Context, q *storageQueue, interval time.Duration) {
+	if err := q.ensurePoisonQueue(ctx); err != nil {
+		log.Printf("Could not create poison queue %s: %v", q.poison.Path, err)
+	}
+	for {
+		msgs, err := q.receive(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error receiving from queue %s: %v", q.base.Path, err)
+		}
+		for _, m := range msgs {
+			app.ingestMessage(context.WithoutCancel(ctx), q, m)
+		}
+		if len(msgs) == queueBatch {
+			continue // more may be waiting
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (app *App) ingestMessage(ctx context.Context, q *storageQueue, m queueMessage) {
+	err := app.createFromQueue(ctx, m.body())
+	switch {
+	case err == nil:
+		if err := q.delete(ctx, m); err != nil {
+			log.Printf("Error deleting queue message %s: %v", m.MessageID, err)
+		}
+		return
+	case poisonous(err):
+		log.Printf("Queue message %s rejected: %v", m.MessageID, err)
+	case m.DequeueCount >= MaxQueueDequeues:
+		log.Printf("Queue message %s failed %d times: %v", m.MessageID, m.DequeueCount, err)
+	default:
+		log.Printf("Queue message %s failed (attempt %d), retrying: %v", m.MessageID, m.DequeueCount, err)
+		return
+	}
+	if err := q.quarantine(ctx, m); err != nil {
+		log.Printf("Error moving queue message %s to the poison queue: %v", m.MessageID, err)
+	}
+}
+
+// errInvalidMessage is wrapped by the errors of messages that can't be
+// read or don't name a usable tenant.
+var errInvalidMessage = errors.New("invalid message")
+
+// createFromQueue creates the todo a message describes through
+// TodoService.Create, so it gets the same validation, policies and quotas
+// as POST /todos.
+func (app *App) createFromQueue(ctx context.Context, body []byte) error {
+	var msg QueueTodoMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	if msg.Tenant != "" {
+		if app.tenancy == nil {
+			return fmt.Errorf("%w: tenant given but tenancy is off", errInvalidMessage)
+		}
+		tenant, ok := app.tenancy.lookup(msg.Tenant)
+		if !ok {
+			return fmt.Errorf("%w: unknown tenant %s", errInvalidMessage, msg.Tenant)
+		}
+		if app.tenantDisabled(ctx, tenant.ID) {
+			return fmt.Errorf("%w: tenant %s is disabled", errInvalidMessage, tenant.ID)
+		}
+		ctx = context.WithValue(store.WithTenant(ctx, tenant.ID), tenantContextKey{}, tenant)
+	} else if app.tenancy != nil {
+		return fmt.Errorf("%w: tenant is required", errInvalidMessage)
+	}
+
+	todo, err := msg.todo()
+	if err != nil {
+		return err
+	}
+	return app.Todos.Create(ctx, &todo)
+}
+
+// poisonous reports whether a message that failed with err can never
+// succeed: it's invalid, or its todo is refused the way POST /todos
+// answers with a 4xx. Quotas that free up in time are tried again.
+func poisonous(err error) bool {
+	if errors.Is(err, errInvalidMessage) {
+		return true
+	}
+	status, _, ok := errorResponse(err)
+	return ok && status/100 == 4 && status != http.StatusTooManyRequests
+}
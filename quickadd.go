@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Quick Add ---
+
+// quickPriorities maps the !shorthands accepted by parseQuickAdd.
+var quickPriorities = map[string]string{
+	"low": "low", "medium": "medium", "high": "high",
+	"1": "high", "2": "medium", "3": "low",
+}
+
+// parseQuickAdd turns free text such as "Buy milk #errands !high" into a
+// new todo: #words become tags, !low/!medium/!high (or !1 to !3) sets the
+// priority and the remaining words form the title, cleaned as every title
+// is. Trailing sentence punctuation, as added by dictation, is dropped.
+func parseQuickAdd(text string) store.Todo {
+	var words, tags []string
+	priority := ""
+	for _, word := range strings.Fields(text) {
+		bare := strings.ToLower(strings.TrimRight(word, ".,!?"))
+		switch {
+		case strings.HasPrefix(bare, "#") && len(bare) > 1:
+			tags = append(tags, bare[1:])
+		case strings.HasPrefix(bare, "!") && quickPriorities[bare[1:]] != "":
+			priority = quickPriorities[bare[1:]]
+		default:
+			words = append(words, word)
+		}
+	}
+	return store.Todo{
+		ID:        primitive.NewObjectID(),
+		Title:     cleanTitle(strings.TrimRight(strings.Join(words, " "), ".!?")),
+		Tags:      tags,
+		Priority:  priority,
+		CreatedAt: time.Now(),
+	}
+}
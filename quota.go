@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Quotas ---
+
+// todoCountTTL bounds how long the todo counter may drift from the store,
+// e.g. after writes that bypass the API such as the seed command.
+const todoCountTTL = time.Hour
+
+// Quotas limit what a tenant may store; zero means no limit. Instance-wide
+// defaults come from QUOTA_MAX_TODOS and QUOTA_MAX_TODOS_PER_DAY, and a
+// tenant's own values in TENANTS_FILE take precedence.
+type Quotas struct {
+	MaxTodos       int `json:"maxTodos,omitempty"`       // stored todos, else 403
+	MaxTodosPerDay int `json:"maxTodosPerDay,omitempty"` // created per UTC day, else 429
+}
+
+func defaultQuotas() Quotas {
+	return Quotas{
+		MaxTodos:       envInt("QUOTA_MAX_TODOS", 0),
+		MaxTodosPerDay: envInt("QUOTA_MAX_TODOS_PER_DAY", 0),
+	}
+}
+
+// quotasFor returns the quotas of the request's tenant.
+func (app *App) quotasFor(ctx context.Context) Quotas {
+	q, own := app.quotas, tenantFrom(ctx).Quotas
+	if own.MaxTodos > 0 {
+		q.MaxTodos = own.MaxTodos
+	}
+	if own.MaxTodosPerDay > 0 {
+		q.MaxTodosPerDay = own.MaxTodosPerDay
+	}
+	return q
+}
+
+func todoCountKey(ctx context.Context) string {
+	return redisKey(ctx, "quota:todos")
+}
+
+func dailyTodosKey(ctx context.Context, day time.Time) string {
+	return redisKey(ctx, "quota:created:"+day.Format("2006-01-02"))
+}
+
+// incrIfExists bumps a counter only while it exists, so a dropped counter
+// is recounted rather than restarted from zero.
+var incrIfExists = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("INCRBY", KEYS[1], ARGV[1])
+end
+return false
+`)
+
+// todoCount returns the tenant's number of todos, counting them in the
+// store when the Redis counter is missing.
+func (app *App) todoCount(ctx context.Context) (int, error) {
+	key := todoCountKey(ctx)
+	n, err := app.RedisClient.Get(ctx, key).Int()
+	if !errors.Is(err, redis.Nil) {
+		return n, err
+	}
+	todos, err := app.Store.List(ctx, store.Query{Fields: []string{"createdAt"}})
+	if err != nil {
+		return 0, err
+	}
+	app.RedisClient.SetNX(ctx, key, len(todos), todoCountTTL)
+	return len(todos), nil
+}
+
+// forgetTodoCount drops the counter after deletes and bulk writes, whose
+// effect on the count isn't known exactly; the next check recounts.
+func (app *App) forgetTodoCount(ctx context.Context) {
+	app.RedisClient.Del(ctx, todoCountKey(ctx))
+}
+
+// QuotaError rejects a todo over one of the tenant's quotas. RetryAfter
+// is set when the quota frees up by itself.
+type QuotaError struct {
+	Fields     map[string]string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded: %v", e.Fields)
+}
+
+// Is makes every QuotaError match ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// reserve checks the quotas before a todo is created, failing with a
+// *QuotaError when it must not be. A reserved todo counts against the
+// daily quota; release returns it if creating it fails.
+func (app *App) reserve(ctx context.Context) (release func(), err error) {
+	q := app.quotasFor(ctx)
+	release = func() {}
+
+	if q.MaxTodos > 0 {
+		n, err := app.todoCount(ctx)
+		if err != nil {
+			return release, err
+		}
+		if n >= q.MaxTodos {
+			return release, &QuotaError{Fields: map[string]string{"todos": "limit of " + strconv.Itoa(q.MaxTodos) + " reached"}}
+		}
+	}
+
+	if q.MaxTodosPerDay > 0 {
+		now := time.Now().UTC()
+		key := dailyTodosKey(ctx, now)
+		var incr *redis.IntCmd
+		app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			incr = pipe.Incr(ctx, key)
+			pipe.Expire(ctx, key, 25*time.Hour)
+			return nil
+		})
+		n, err := incr.Result()
+		if err != nil {
+			return release, err
+		}
+		release = func() { app.RedisClient.Decr(ctx, key) }
+		if n > int64(q.MaxTodosPerDay) {
+			release()
+			tomorrow := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+			return func() {}, &QuotaError{
+				Fields:     map[string]string{"todosPerDay": "limit of " + strconv.Itoa(q.MaxTodosPerDay) + " reached"},
+				RetryAfter: time.Until(tomorrow),
+			}
+		}
+	}
+	return release, nil
+}
+
+// countCreatedTodo adds a new todo to the counter.
+func (app *App) countCreatedTodo(ctx context.Context) {
+	incrIfExists.Run(ctx, app.RedisClient, []string{todoCountKey(ctx)}, 1)
+}
+
+// QuotaUsage is current usage against a limit; a missing limit means none.
+type QuotaUsage struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit,omitempty"`
+}
+
+type UsageResponse struct {
+	Tenant      string     `json:"tenant,omitempty"`
+	Todos       QuotaUsage `json:"todos"`
+	TodosPerDay QuotaUsage `json:"todosPerDay"`
+	ResetsAt    string     `json:"resetsAt"` // when todosPerDay starts over
+}
+
+// handleUsage serves GET /usage for the request's tenant.
+func (app *App) handleUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := app.quotasFor(ctx)
+	now := time.Now().UTC()
+	var count, daily *redis.StringCmd
+	app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.Get(ctx, todoCountKey(ctx))
+		daily = pipe.Get(ctx, dailyTodosKey(ctx, now))
+		return nil
+	})
+	n, err := count.Int()
+	if errors.Is(err, redis.Nil) {
+		n, err = app.todoCount(ctx)
+	}
+	if err != nil {
+		serverError(w, r, "Failed to load usage", err)
+		return
+	}
+	today, err := daily.Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		serverError(w, r, "Failed to load usage", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, UsageResponse{
+		Tenant:      store.TenantFrom(ctx),
+		Todos:       QuotaUsage{Used: n, Limit: q.MaxTodos},
+		TodosPerDay: QuotaUsage{Used: today, Limit: q.MaxTodosPerDay},
+		ResetsAt:    formatTime(now.Truncate(24 * time.Hour).Add(24 * time.Hour)),
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Redis Metrics ---
+
+// redisLatencyBuckets are the upper bounds of the latency histogram.
+var redisLatencyBuckets = [...]time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+}
+
+// latencyStats accumulates round trips, safe for concurrent use.
+type latencyStats struct {
+	count   atomic.Int64
+	cmds    atomic.Int64 // commands sent in those round trips
+	errors  atomic.Int64
+	total   atomic.Int64 // nanoseconds
+	max     atomic.Int64 // nanoseconds
+	buckets [len(redisLatencyBuckets) + 1]atomic.Int64
+}
+
+func (s *latencyStats) observe(d time.Duration, cmds int, err error) {
+	s.count.Add(1)
+	s.cmds.Add(int64(cmds))
+	if err != nil && err != redis.Nil {
+		s.errors.Add(1)
+	}
+	s.total.Add(int64(d))
+	for {
+		prev := s.max.Load()
+		if int64(d) <= prev || s.max.CompareAndSwap(prev, int64(d)) {
+			break
+		}
+	}
+	i := 0
+	for i < len(redisLatencyBuckets) && d > redisLatencyBuckets[i] {
+		i++
+	}
+	s.buckets[i].Add(1)
+}
+
+// redisMetrics is a go-redis hook timing this instance's commands and
+// pipelines since it started.
+type redisMetrics struct {
+	commands  latencyStats
+	pipelines latencyStats
+}
+
+func (m *redisMetrics) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (m *redisMetrics) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		d := time.Since(start)
+		m.commands.observe(d, 1, err)
+		addCacheTime(ctx, d)
+		return err
+	}
+}
+
+func (m *redisMetrics) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		d := time.Since(start)
+		m.pipelines.observe(d, len(cmds), err)
+		addCacheTime(ctx, d)
+		return err
+	}
+}
+
+// LatencyResponse summarizes round trips to Redis. Buckets counts them by
+// upper bound in milliseconds, "+Inf" for the slower ones.
+type LatencyResponse struct {
+	Count    int64            `json:"count"`
+	Commands int64            `json:"commands"`
+	Errors   int64            `json:"errors"`
+	AvgMs    float64          `json:"avgMs"`
+	MaxMs    float64          `json:"maxMs"`
+	Buckets  map[string]int64 `json:"buckets"`
+}
+
+type RedisMetricsResponse struct {
+	Instance  string              `json:"instance"`
+	Since     string              `json:"since"`
+	Commands  LatencyResponse     `json:"commands"`  // one command per round trip
+	Pipelines LatencyResponse     `json:"pipelines"` // many commands per round trip
+	Cache     CacheBudgetResponse `json:"cache"`     // lists stored in the list cache
+}
+
+func newLatencyResponse(s *latencyStats) LatencyResponse {
+	out := LatencyResponse{
+		Count:    s.count.Load(),
+		Commands: s.cmds.Load(),
+		Errors:   s.errors.Load(),
+		MaxMs:    float64(s.max.Load()) / float64(time.Millisecond),
+		Buckets:  map[string]int64{},
+	}
+	if out.Count > 0 {
+		out.AvgMs = float64(s.total.Load()) / float64(out.Count) / float64(time.Millisecond)
+	}
+	for i := range s.buckets {
+		le := "+Inf"
+		if i < len(redisLatencyBuckets) {
+			le = strconv.FormatInt(redisLatencyBuckets[i].Milliseconds(), 10)
+		}
+		out.Buckets[le] = s.buckets[i].Load()
+	}
+	return out
+}
+
+// redisMetricsReport serves GET /admin/redis: this instance's Redis round
+// trips by kind, with their latencies.
+func (app *App) redisMetricsReport(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, RedisMetricsResponse{
+		Instance:  instanceName(),
+		Since:     formatTime(app.started),
+		Commands:  newLatencyResponse(&app.redisMetrics.commands),
+		Pipelines: newLatencyResponse(&app.redisMetrics.pipelines),
+		Cache:     newCacheBudgetResponse(app.cacheBudget),
+	})
+}
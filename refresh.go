@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Refresh Tokens ---
+
+const (
+	// RefreshTokenColName holds hashed refresh tokens
+	RefreshTokenColName = "refresh_tokens"
+	// DefaultRefreshTokenTTL is how long a "remember me" sign-in lasts
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+type SessionCreatedResponse struct {
+	SessionResponse
+	RefreshToken string `json:"refreshToken,omitempty"` // shown once; only its hash is kept
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// issueRefreshToken stores a refresh token for subject in family, or in a
+// family of its own for "", and returns the token and its family.
+func (app *App) issueRefreshToken(ctx context.Context, subject, family string) (string, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", "", err
+	}
+	id := hashToken(token)
+	if family == "" {
+		family = id
+	}
+	now := time.Now().UTC()
+	err = app.RefreshTokens.CreateRefreshToken(ctx, store.RefreshToken{
+		ID:        id,
+		Family:    family,
+		Subject:   subject,
+		CreatedAt: now,
+		ExpiresAt: now.Add(app.refreshTTL),
+	})
+	return token, family, err
+}
+
+// revokeFamily revokes a refresh token family and ends the sessions it
+// started.
+func (app *App) revokeFamily(ctx context.Context, subject, family string) error {
+	if err := app.RefreshTokens.RevokeRefreshFamily(ctx, family); err != nil {
+		return err
+	}
+	index := subjectSessionsKey + subject
+	ids, err := app.RedisClient.SMembers(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if s, err := app.loadSession(ctx, id); err == nil && s.Family == family {
+			app.RedisClient.Del(ctx, sessionPrefix+id)
+			app.RedisClient.SRem(ctx, index, id)
+		}
+	}
+	return nil
+}
+
+// decodeRefreshRequest reads the refresh token of a request, answering
+// 400 when there is none.
+func decodeRefreshRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return "", false
+	}
+	if req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"refreshToken": "is required"},
+		})
+		return "", false
+	}
+	return req.RefreshToken, true
+}
+
+// refreshSession serves POST /auth/refresh: it trades a refresh token for
+// a new session and the next token of its family. Presenting a token
+// that was already traded means it leaked, so the family is revoked.
+func (app *App) refreshSession(w http.ResponseWriter, r *http.Request) {
+	ctx, ip := r.Context(), clientIP(r)
+	if until := app.lockedOutUntil(ctx, ip); !until.IsZero() {
+		respondLockedOut(w, until)
+		return
+	}
+	token, ok := decodeRefreshRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tok, err := app.RefreshTokens.UseRefreshToken(ctx, hashToken(token), time.Now().UTC())
+	if errors.Is(err, store.ErrRefreshTokenNotFound) {
+		app.recordAuthFailure(ctx, ip)
+		respondError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		serverError(w, r, "Failed to refresh session", err)
+		return
+	}
+	// Sign-ins end with the admin key they were made with
+	if tok.Revoked || tok.Subject != keyFingerprint(os.Getenv("ADMIN_API_KEY")) {
+		respondError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if tok.UsedAt != nil {
+		log.Printf("Refresh token reused from %s; revoking family %s", ip, tok.Family)
+		if err := app.revokeFamily(ctx, tok.Subject, tok.Family); err != nil {
+			serverError(w, r, "Failed to revoke refresh tokens", err)
+			return
+		}
+		respondError(w, http.StatusUnauthorized, "Refresh token reuse detected")
+		return
+	}
+
+	next, _, err := app.issueRefreshToken(ctx, tok.Subject, tok.Family)
+	if err != nil {
+		serverError(w, r, "Failed to refresh session", err)
+		return
+	}
+	s, err := app.startSession(w, r, tok.Subject, tok.Family)
+	if err != nil {
+		serverError(w, r, "Failed to refresh session", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, SessionCreatedResponse{SessionResponse: newSessionResponse(s, true), RefreshToken: next})
+}
+
+// revokeRefreshToken serves POST /auth/revoke: it revokes the token's
+// family and ends its sessions. Like RFC 7009 it answers the same for
+// unknown tokens, so the response reveals nothing.
+func (app *App) revokeRefreshToken(w http.ResponseWriter, r *http.Request) {
+	token, ok := decodeRefreshRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	tok, err := app.RefreshTokens.GetRefreshToken(ctx, hashToken(token), time.Now().UTC())
+	if err != nil && !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		serverError(w, r, "Failed to revoke refresh token", err)
+		return
+	}
+	if err == nil {
+		if err := app.revokeFamily(ctx, tok.Subject, tok.Family); err != nil {
+			serverError(w, r, "Failed to revoke refresh token", err)
+			return
+		}
+		app.setSessionCookie(w, "")
+	}
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "revoked"})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Reports ---
+
+// maxReportPeriods bounds the periods one completions report spans.
+const maxReportPeriods = 366
+
+// PeriodCount is how many todos were completed in one period.
+type PeriodCount struct {
+	Period    string `json:"period"`
+	Completed int    `json:"completed"`
+}
+
+// CompletionsResponse lists every period of [from, to), including those
+// without completions, oldest first.
+type CompletionsResponse struct {
+	Group   string        `json:"group"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Total   int           `json:"total"`
+	Periods []PeriodCount `json:"periods"`
+}
+
+type TagReport struct {
+	store.TagCount
+	Open int `json:"open"`
+}
+
+type TagReportResponse struct {
+	Tags []TagReport `json:"tags"`
+}
+
+// parseReportTime accepts a date (UTC midnight) or an RFC 3339 time.
+func parseReportTime(params url.Values, name string) (time.Time, bool, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s must be a date or an RFC 3339 time", name)
+	}
+	return t.UTC(), true, nil
+}
+
+// periodStart is the start of the period t falls in.
+func periodStart(group string, t time.Time) time.Time {
+	t = t.UTC().Truncate(24 * time.Hour)
+	if group == store.GroupWeek {
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7) // back to Monday
+	}
+	return t
+}
+
+func periodStep(group string, t time.Time) time.Time {
+	if group == store.GroupWeek {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// completionsReport serves GET /reports/completions: todos completed per
+// ?group=day (default) or week between ?from= and ?to=, by default the
+// last 30 days or 12 weeks up to the end of today.
+func (app *App) completionsReport(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	group := params.Get("group")
+	switch group {
+	case "":
+		group = store.GroupDay
+	case store.GroupDay, store.GroupWeek:
+	default:
+		respondError(w, http.StatusBadRequest, "group must be day or week")
+		return
+	}
+	from, hasFrom, err := parseReportTime(params, "from")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, hasTo, err := parseReportTime(params, "to")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !hasTo {
+		// Up to the end of today, which keeps the default report cacheable
+		to = periodStart(store.GroupDay, time.Now()).AddDate(0, 0, 1)
+	}
+	if !hasFrom {
+		from = to.AddDate(0, 0, -30)
+		if group == store.GroupWeek {
+			from = to.AddDate(0, 0, -12*7)
+		}
+	}
+	if !from.Before(to) {
+		respondError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	var starts []time.Time
+	for t := periodStart(group, from); t.Before(to); t = periodStep(group, t) {
+		if len(starts) == maxReportPeriods {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("A report spans at most %d periods", maxReportPeriods))
+			return
+		}
+		starts = append(starts, t)
+	}
+
+	ctx := r.Context()
+	var counts map[string]int
+	name := fmt.Sprintf("reports:completions:%s:%d:%d", group, from.Unix(), to.Unix())
+	err = app.versioned(ctx, name, &counts, func() (err error) {
+		counts, err = store.ReportCompletions(ctx, app.Store, group, from, to)
+		return err
+	})
+	if err != nil {
+		serverError(w, r, "Failed to build report", err)
+		return
+	}
+
+	resp := CompletionsResponse{Group: group, From: formatTime(from), To: formatTime(to), Periods: []PeriodCount{}}
+	for _, t := range starts {
+		period := store.Period(group, t)
+		resp.Periods = append(resp.Periods, PeriodCount{Period: period, Completed: counts[period]})
+		resp.Total += counts[period]
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// tagReport serves GET /reports/by-tag: the todos of each tag, most used
+// first.
+func (app *App) tagReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var counts []store.TagCount
+	err := app.versioned(ctx, "reports:by-tag", &counts, func() (err error) {
+		counts, err = store.ReportTagCounts(ctx, app.Store)
+		return err
+	})
+	if err != nil {
+		serverError(w, r, "Failed to build report", err)
+		return
+	}
+
+	resp := TagReportResponse{Tags: make([]TagReport, 0, len(counts))}
+	for _, c := range counts {
+		resp.Tags = append(resp.Tags, TagReport{TagCount: c, Open: c.Total - c.Completed})
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
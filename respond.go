@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- JSON Responses ---
+
+// TodoResponse is the wire representation of a todo. It is decoupled from
+// store.Todo so storage-only fields never leak and the API shape stays
+// stable: fields are encoded in declaration order, the ID as a hex string
+// and times as RFC 3339 in UTC.
+type TodoResponse struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Priority    string                 `json:"priority,omitempty"`
+	Color       string                 `json:"color,omitempty"`
+	BlockedBy   []string               `json:"blockedBy,omitempty"`
+	Status      string                 `json:"status"`
+	Pomodoros   int                    `json:"pomodoros,omitempty"`
+	Snoozes     int                    `json:"snoozes,omitempty"`
+	Location    *LocationResponse      `json:"location,omitempty"`
+	DueAt       string                 `json:"dueAt,omitempty"`
+	Estimate    int                    `json:"estimateMinutes,omitempty"`
+	Progress    int                    `json:"progressPercent,omitempty"`
+	CalendarID  string                 `json:"calendarEventId,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty"`
+	Pinned      bool                   `json:"pinned,omitempty"`
+	MyDay       bool                   `json:"myDay,omitempty"`
+	Completed   bool                   `json:"completed"`
+	CompletedAt string                 `json:"completedAt,omitempty"`
+	CreatedAt   string                 `json:"createdAt"`
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error     string            `json:"error"`
+	Code      string            `json:"code"`                // machine-readable, e.g. "not_found"
+	Fields    map[string]string `json:"fields,omitempty"`    // per-field validation errors
+	RequestID string            `json:"requestId,omitempty"` // quoted in the server log, on 500s
+}
+
+// StatusResponse acknowledges a mutation that has no resource to return.
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+func newTodoResponse(todo store.Todo) TodoResponse {
+	return TodoResponse{
+		ID:          todo.ID.Hex(),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Tags:        todo.Tags,
+		Priority:    todo.Priority,
+		Color:       todo.Color,
+		BlockedBy:   hexIDs(todo.BlockedBy),
+		Status:      todo.CurrentStatus(),
+		Pomodoros:   todo.Pomodoros,
+		Snoozes:     todo.Snoozes,
+		Location:    newLocationResponse(todo.Location),
+		DueAt:       formatOptionalTime(todo.DueAt),
+		Estimate:    todo.Estimate,
+		Progress:    todo.Progress,
+		CalendarID:  todo.CalendarID,
+		Custom:      todo.Custom,
+		Pinned:      todo.Pinned,
+		MyDay:       todo.MyDay,
+		Completed:   todo.Completed,
+		CompletedAt: formatOptionalTime(todo.CompletedAt),
+		CreatedAt:   formatTime(todo.CreatedAt),
+	}
+}
+
+func newTodoResponses(todos []store.Todo) []TodoResponse {
+	out := make([]TodoResponse, 0, len(todos))
+	for _, todo := range todos {
+		out = append(out, newTodoResponse(todo))
+	}
+	return out
+}
+
+// formatTime renders t as RFC 3339 in UTC, or "" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatOptionalTime is formatTime for times that may be unset.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+// respondJSON writes v as the JSON body with the given status. A more
+// specific JSON media type set by the caller is kept.
+func respondJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// respondError writes {"error": msg} with the given status.
+func respondError(w http.ResponseWriter, status int, msg string) {
+	writeError(w, status, ErrorResponse{Error: msg})
+}
+
+// serverError answers 500 with msg, never err: driver and client errors
+// can carry hosts and credentials. The full error is logged under the
+// request ID returned to the caller.
+func serverError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	id := middleware.GetReqID(r.Context())
+	log.Printf("%s: %v (%s %s, request %s)", msg, err, r.Method, r.URL.Path, id)
+	writeError(w, http.StatusInternalServerError, ErrorResponse{Error: msg, RequestID: id})
+}
+
+// writeError writes body in the error format negotiated for the response.
+func writeError(w http.ResponseWriter, status int, body ErrorResponse) {
+	if body.Code == "" {
+		body.Code = errorCode(status, body)
+	}
+	if _, ok := w.(*jsonAPIWriter); ok {
+		respondJSONAPI(w, status, jsonAPIErrorDocument(status, body))
+		return
+	}
+	if fw, ok := w.(*formWriter); ok && status < http.StatusInternalServerError {
+		fw.flashError(body)
+		return
+	}
+	respondJSON(w, status, body)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Retention ---
+
+// retentionActor is the audit actor of the todos retention removes.
+const retentionActor = "retention"
+
+// Retention rules a tenant may set in TENANTS_FILE, in days; zero falls
+// back to the instance-wide COMPLETED_RETENTION and COMPLETED_ARCHIVE_AFTER.
+type Retention struct {
+	PurgeCompletedDays   int `json:"purgeCompletedDays,omitempty"`   // delete completed todos this old
+	ArchiveCompletedDays int `json:"archiveCompletedDays,omitempty"` // move them to Blob Storage first
+}
+
+// retentionPolicy is the rules in effect for a tenant; zero keeps forever.
+type retentionPolicy struct {
+	purgeAfter   time.Duration
+	archiveAfter time.Duration
+}
+
+func defaultRetention() retentionPolicy {
+	return retentionPolicy{
+		purgeAfter:   envDuration("COMPLETED_RETENTION", 0),
+		archiveAfter: envDuration("COMPLETED_ARCHIVE_AFTER", 0),
+	}
+}
+
+// retentionFor returns the policy of the given tenant.
+func (app *App) retentionFor(tenant string) retentionPolicy {
+	p := app.retention
+	if app.tenancy == nil {
+		return p
+	}
+	own := app.tenancy.known[tenant].Retention
+	if own.PurgeCompletedDays > 0 {
+		p.purgeAfter = time.Duration(own.PurgeCompletedDays) * 24 * time.Hour
+	}
+	if own.ArchiveCompletedDays > 0 {
+		p.archiveAfter = time.Duration(own.ArchiveCompletedDays) * 24 * time.Hour
+	}
+	return p
+}
+
+// retentionPolicies returns the policy of every tenant, and whether any of
+// them removes anything.
+func (app *App) retentionPolicies() (map[string]retentionPolicy, bool) {
+	policies := map[string]retentionPolicy{}
+	active := false
+	for _, tenant := range app.tenantIDs() {
+		p := app.retentionFor(tenant)
+		policies[tenant] = p
+		active = active || p.purgeAfter > 0 || p.archiveAfter > 0
+	}
+	return policies, active
+}
+
+// checkRetention fails when a policy archives but there's nowhere to
+// archive to.
+func (app *App) checkRetention() error {
+	policies, _ := app.retentionPolicies()
+	for tenant, p := range policies {
+		if p.archiveAfter > 0 && app.backups == nil {
+			if tenant == "" {
+				return fmt.Errorf("COMPLETED_ARCHIVE_AFTER needs AZURE_STORAGE_BACKUP_URL")
+			}
+			return fmt.Errorf("tenant %s archives completed todos but AZURE_STORAGE_BACKUP_URL isn't set", tenant)
+		}
+	}
+	return nil
+}
+
+// applyRetention is the scheduled job: it archives and purges each
+// tenant's old completed todos, auditing how many it removed. A todo old
+// enough for both rules is archived.
+func (app *App) applyRetention(ctx context.Context) error {
+	policies, _ := app.retentionPolicies()
+	now := time.Now()
+	for _, tenant := range app.tenantIDs() {
+		if err := app.retainTenant(store.WithTenant(ctx, tenant), policies[tenant], now); err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+func (app *App) retainTenant(ctx context.Context, p retentionPolicy, now time.Time) error {
+	if p.purgeAfter <= 0 && p.archiveAfter <= 0 {
+		return nil
+	}
+	done := true
+	todos, err := app.Store.List(ctx, store.Query{Completed: &done})
+	if err != nil {
+		return err
+	}
+	var archive, purge []store.Todo
+	for _, todo := range todos {
+		if todo.CompletedAt == nil {
+			continue
+		}
+		age := now.Sub(*todo.CompletedAt)
+		switch {
+		case p.archiveAfter > 0 && age >= p.archiveAfter:
+			archive = append(archive, todo)
+		case p.purgeAfter > 0 && age >= p.purgeAfter:
+			purge = append(purge, todo)
+		}
+	}
+
+	if len(archive) > 0 {
+		// Only delete what's safely stored
+		snap, err := app.writeSnapshot(ctx, "archive", archive)
+		if err != nil {
+			return fmt.Errorf("archiving: %w", err)
+		}
+		n, err := app.removeTodos(ctx, archive)
+		app.auditRetention(ctx, "retention.archive", n)
+		if err != nil {
+			return err
+		}
+		log.Printf("Retention archived %d completed todo(s) of tenant %q to %s", n, store.TenantFrom(ctx), snap.Name)
+	}
+	if len(purge) > 0 {
+		n, err := app.removeTodos(ctx, purge)
+		app.auditRetention(ctx, "retention.purge", n)
+		if err != nil {
+			return err
+		}
+		log.Printf("Retention purged %d completed todo(s) of tenant %q", n, store.TenantFrom(ctx))
+	}
+	return nil
+}
+
+// removeTodos deletes todos, returning how many it deleted.
+func (app *App) removeTodos(ctx context.Context, todos []store.Todo) (int, error) {
+	removed := 0
+	err := app.Todos.Batch(ctx, func(ctx context.Context) error {
+		for _, todo := range todos {
+			if err := app.Todos.Delete(ctx, todo.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// auditRetention records how many todos a retention rule removed from
+// the context tenant.
+func (app *App) auditRetention(ctx context.Context, action string, n int) {
+	if n == 0 {
+		return
+	}
+	tenant := store.TenantFrom(ctx)
+	if tenant == "" {
+		tenant = backupDefaultPrefix
+	}
+	e := store.AuditEntry{
+		ID:     primitive.NewObjectID(),
+		Actor:  retentionActor,
+		Action: action,
+		Target: "tenant:" + tenant,
+		Count:  n,
+		At:     time.Now().UTC(),
+	}
+	if err := app.Audit.RecordAudit(ctx, e); err != nil {
+		log.Printf("Error auditing %s of %d todo(s) on %s: %v", action, n, e.Target, err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Seed Data ---
+
+// seedBatchSize bounds the documents sent per InsertMany call.
+const seedBatchSize = 500
+
+// cmdSeed loads fixtures and/or generated todos into Mongo.
+//
+//	main seed [-file fixtures.yaml] [-wipe] [-count N]
+//
+// Fixture files are JSON or YAML (by extension) holding either a list of
+// todos, as written by anonymize-export, or an object with a "todos" key.
+func cmdSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := fs.String("file", "", "fixture file (.json, .yaml or .yml)")
+	wipe := fs.Bool("wipe", false, "delete all existing todos first")
+	count := fs.Int("count", 0, "number of random todos to generate")
+	fs.Parse(args)
+
+	if *file == "" && *count == 0 && !*wipe {
+		return fmt.Errorf("nothing to do: pass -file, -count or -wipe")
+	}
+
+	var todos []store.Todo
+	if *file != "" {
+		fixtures, err := loadFixtures(*file)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", *file, err)
+		}
+		todos = append(todos, fixtures...)
+	}
+	todos = append(todos, randomTodos(*count)...)
+
+	_, db, closeStore, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	coll := db.Collection(collectionName(ColName, ""))
+	if *wipe {
+		res, err := coll.DeleteMany(ctx, bson.M{})
+		if err != nil {
+			return fmt.Errorf("wiping %s: %w", coll.Name(), err)
+		}
+		log.Printf("Deleted %d existing todos", res.DeletedCount)
+	}
+
+	if err := insertBatches(ctx, coll, todos); err != nil {
+		return err
+	}
+	log.Printf("Seeded %d todos", len(todos))
+	if err := store.NewMongo(coll).BackfillTitleKeys(ctx); err != nil {
+		return fmt.Errorf("storing title keys: %w", err)
+	}
+
+	// Retire the cached list so running servers pick up the new data
+	redisCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if rdb, err := connectRedis(redisCtx); err != nil {
+		log.Printf("Could not invalidate cache: %v", err)
+	} else {
+		bumpListVersion(redisCtx, rdb)
+		rdb.Close()
+	}
+	return nil
+}
+
+func insertBatches(ctx context.Context, coll *mongo.Collection, todos []store.Todo) error {
+	for start := 0; start < len(todos); start += seedBatchSize {
+		end := start + seedBatchSize
+		if end > len(todos) {
+			end = len(todos)
+		}
+		docs := make([]interface{}, 0, end-start)
+		for _, todo := range todos[start:end] {
+			docs = append(docs, todo)
+		}
+		if _, err := coll.InsertMany(ctx, docs); err != nil {
+			return fmt.Errorf("inserting todos %d-%d: %w", start, end, err)
+		}
+	}
+	return nil
+}
+
+// loadFixtures reads todos from a JSON or YAML file, filling in missing IDs
+// and creation times.
+func loadFixtures(path string) ([]store.Todo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// Normalize YAML through JSON so both formats share the API field names
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	case ".json":
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q", filepath.Ext(path))
+	}
+
+	var todos []store.Todo
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		if err := json.Unmarshal(data, &todos); err != nil {
+			return nil, err
+		}
+	} else {
+		var sections map[string]json.RawMessage
+		if err := json.Unmarshal(data, &sections); err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(sections))
+		for key := range sections {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if key != "todos" {
+				log.Printf("Ignoring %q fixtures: only todos can be seeded", key)
+				continue
+			}
+			if err := json.Unmarshal(sections[key], &todos); err != nil {
+				return nil, fmt.Errorf("todos: %w", err)
+			}
+		}
+	}
+
+	now := time.Now()
+	for i := range todos {
+		if todos[i].Title == "" {
+			return nil, fmt.Errorf("todo #%d has no title", i+1)
+		}
+		if todos[i].ID.IsZero() {
+			todos[i].ID = primitive.NewObjectID()
+		}
+		if todos[i].CreatedAt.IsZero() {
+			todos[i].CreatedAt = now
+		}
+	}
+	return todos, nil
+}
+
+// randomTodos generates n plausible todos spread over the last 30 days,
+// roughly a third of them completed.
+func randomTodos(n int) []store.Todo {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	now := time.Now()
+	todos := make([]store.Todo, 0, n)
+	for i := 0; i < n; i++ {
+		title := fmt.Sprintf("%s %s %s",
+			fakeVerbs[r.Intn(len(fakeVerbs))],
+			fakeAdjectives[r.Intn(len(fakeAdjectives))],
+			fakeNouns[r.Intn(len(fakeNouns))])
+		todos = append(todos, store.Todo{
+			ID:        primitive.NewObjectID(),
+			Title:     title,
+			Completed: r.Intn(3) == 0,
+			CreatedAt: now.Add(-time.Duration(r.Int63n(int64(30 * 24 * time.Hour)))),
+		})
+	}
+	return todos
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// --- Server Timing ---
+
+type timingsKey struct{}
+
+// requestTimings accumulates the time a request spent waiting on each
+// backend, in nanoseconds.
+type requestTimings struct {
+	cache atomic.Int64 // Redis
+	db    atomic.Int64 // Mongo
+}
+
+func timingsFrom(ctx context.Context) *requestTimings {
+	t, _ := ctx.Value(timingsKey{}).(*requestTimings)
+	return t
+}
+
+// addCacheTime and addDBTime charge d to the request timed by ctx, if any.
+func addCacheTime(ctx context.Context, d time.Duration) {
+	if t := timingsFrom(ctx); t != nil {
+		t.cache.Add(int64(d))
+	}
+}
+
+func addDBTime(ctx context.Context, d time.Duration) {
+	if t := timingsFrom(ctx); t != nil {
+		t.db.Add(int64(d))
+	}
+}
+
+// serverTimingEnabled reports whether SERVER_TIMING is on.
+func serverTimingEnabled() bool {
+	return os.Getenv("SERVER_TIMING") == "true"
+}
+
+// mongoTimingMonitor charges each Mongo command to its request.
+var mongoTimingMonitor = &event.CommandMonitor{
+	Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) { addDBTime(ctx, e.Duration) },
+	Failed:    func(ctx context.Context, e *event.CommandFailedEvent) { addDBTime(ctx, e.Duration) },
+}
+
+// serverTiming adds a Server-Timing header splitting the time until the
+// response starts into cache (Redis), db (Mongo) and render (the rest),
+// for browsers' developer tools. It's off unless SERVER_TIMING is set, as
+// it tells clients about the backends.
+func (app *App) serverTiming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.serverTimingOn {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t := &requestTimings{}
+		tw := &timingWriter{ResponseWriter: w, timings: t, start: time.Now()}
+		next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), timingsKey{}, t)))
+	})
+}
+
+// timingWriter sets the Server-Timing header when the response starts.
+type timingWriter struct {
+	http.ResponseWriter
+	timings *requestTimings
+	start   time.Time
+	written bool
+}
+
+func (w *timingWriter) WriteHeader(status int) {
+	if !w.written {
+		w.written = true
+		total := time.Since(w.start)
+		cache := time.Duration(w.timings.cache.Load())
+		db := time.Duration(w.timings.db.Load())
+		render := max(total-cache-db, 0) // backends called concurrently overlap
+		w.Header().Set("Server-Timing", fmt.Sprintf(`cache;dur=%.1f;desc="Redis", db;dur=%.1f;desc="Mongo", render;dur=%.1f, total;dur=%.1f`,
+			ms(cache), ms(db), ms(render), ms(total)))
+		w.Header().Set("Timing-Allow-Origin", "*")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timingWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *timingWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Sessions ---
+
+const (
+	SessionCookieName = "todo_session"
+	// DefaultSessionTTL is how long a session lasts without being used
+	DefaultSessionTTL = 24 * time.Hour
+	// sessionTouchInterval limits how often use slides a session's expiry
+	sessionTouchInterval = time.Minute
+
+	// Sessions are keyed by a hash of their token, never the token itself,
+	// and indexed by subject for device listing. Like lockouts they're
+	// instance-wide, so keys aren't tenant-scoped.
+	sessionPrefix      = "session:"
+	subjectSessionsKey = "sessions:"
+)
+
+// Session is a browser signed in with an API key. Subject is the key's
+// fingerprint, so rotating the key ends its sessions.
+type Session struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Family    string    `json:"family,omitempty"` // refresh token family it came from
+}
+
+// hashToken derives the stored ID of a session or refresh token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+type sessionContextKey struct{}
+
+// sessionFrom returns the session that authenticated the request, if any.
+func sessionFrom(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(sessionContextKey{}).(Session)
+	return s, ok
+}
+
+func (app *App) saveSession(ctx context.Context, s Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionPrefix+s.ID, data, app.sessionTTL)
+		pipe.SAdd(ctx, subjectSessionsKey+s.Subject, s.ID)
+		pipe.Expire(ctx, subjectSessionsKey+s.Subject, app.sessionTTL)
+		return nil
+	})
+	return err
+}
+
+func (app *App) loadSession(ctx context.Context, id string) (Session, error) {
+	var s Session
+	data, err := app.RedisClient.Get(ctx, sessionPrefix+id).Bytes()
+	if err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal(data, &s)
+}
+
+// session returns the live session named by the request's cookie that
+// belongs to key, sliding its expiry.
+func (app *App) session(r *http.Request, key string) (Session, bool) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return Session{}, false
+	}
+	ctx := r.Context()
+	s, err := app.loadSession(ctx, hashToken(cookie.Value))
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error loading session: %v", err)
+		}
+		return Session{}, false
+	}
+	if s.Subject != keyFingerprint(key) {
+		return Session{}, false
+	}
+	if now := time.Now().UTC(); now.Sub(s.LastSeen) >= sessionTouchInterval {
+		s.LastSeen, s.IP, s.UserAgent = now, clientIP(r), r.UserAgent()
+		if err := app.saveSession(ctx, s); err != nil {
+			log.Printf("Error refreshing session %s: %v", s.ID, err)
+		}
+	}
+	return s, true
+}
+
+// setSessionCookie sets or, for an empty token, clears the cookie. It's
+// Secure unless SESSION_COOKIE_INSECURE=true, for local HTTP.
+func (app *App) setSessionCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   os.Getenv("SESSION_COOKIE_INSECURE") != "true",
+		SameSite: http.SameSiteStrictMode,
+	}
+	if token == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+type SessionResponse struct {
+	ID        string `json:"id"`
+	UserAgent string `json:"userAgent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	LastSeen  string `json:"lastSeen"`
+	Current   bool   `json:"current,omitempty"` // the session making the request
+}
+
+func newSessionResponse(s Session, current bool) SessionResponse {
+	return SessionResponse{
+		ID:        s.ID,
+		UserAgent: s.UserAgent,
+		IP:        s.IP,
+		CreatedAt: formatTime(s.CreatedAt),
+		LastSeen:  formatTime(s.LastSeen),
+		Current:   current,
+	}
+}
+
+// callerSubject returns the subject of the admin making the request.
+func callerSubject(r *http.Request) string {
+	if s, ok := sessionFrom(r.Context()); ok {
+		return s.Subject
+	}
+	return keyFingerprint(requestAPIKey(r))
+}
+
+// newToken returns a random 256-bit token.
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// startSession signs r's client in as subject and sets the cookie.
+func (app *App) startSession(w http.ResponseWriter, r *http.Request, subject, family string) (Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return Session{}, err
+	}
+	now := time.Now().UTC()
+	s := Session{
+		ID:        hashToken(token),
+		Subject:   subject,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		CreatedAt: now,
+		LastSeen:  now,
+		Family:    family,
+	}
+	if err := app.saveSession(r.Context(), s); err != nil {
+		return Session{}, err
+	}
+	app.setSessionCookie(w, token)
+	return s, nil
+}
+
+type CreateSessionRequest struct {
+	Remember bool `json:"remember,omitempty"` // also issue a refresh token
+}
+
+// createSession serves POST /admin/sessions: it exchanges the presented
+// admin key for a session cookie, so browsers needn't hold the key. With
+// remember, the response carries a refresh token for POST /auth/refresh.
+func (app *App) createSession(w http.ResponseWriter, r *http.Request) {
+	var req CreateSessionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	subject := callerSubject(r)
+	var refresh, family string
+	if req.Remember {
+		var err error
+		if refresh, family, err = app.issueRefreshToken(r.Context(), subject, ""); err != nil {
+			serverError(w, r, "Failed to create session", err)
+			return
+		}
+	}
+	s, err := app.startSession(w, r, subject, family)
+	if err != nil {
+		serverError(w, r, "Failed to create session", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, SessionCreatedResponse{SessionResponse: newSessionResponse(s, true), RefreshToken: refresh})
+}
+
+// listSessions serves GET /admin/sessions: the caller's signed-in devices,
+// most recently used first.
+func (app *App) listSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subject := callerSubject(r)
+	ids, err := app.RedisClient.SMembers(ctx, subjectSessionsKey+subject).Result()
+	if err != nil {
+		serverError(w, r, "Failed to load sessions", err)
+		return
+	}
+	current, _ := sessionFrom(ctx)
+	out := make([]SessionResponse, 0, len(ids))
+	for _, id := range ids {
+		s, err := app.loadSession(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			app.RedisClient.SRem(ctx, subjectSessionsKey+subject, id) // expired
+			continue
+		}
+		if err != nil {
+			serverError(w, r, "Failed to load sessions", err)
+			return
+		}
+		out = append(out, newSessionResponse(s, s.ID == current.ID))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen > out[j].LastSeen })
+	respondJSON(w, http.StatusOK, out)
+}
+
+// deleteSessions serves DELETE /admin/sessions?id=, signing out one of the
+// caller's sessions, or all of them without an id.
+func (app *App) deleteSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subject := callerSubject(r)
+	index := subjectSessionsKey + subject
+	ids := []string{r.URL.Query().Get("id")}
+	if ids[0] == "" {
+		var err error
+		if ids, err = app.RedisClient.SMembers(ctx, index).Result(); err != nil {
+			serverError(w, r, "Failed to load sessions", err)
+			return
+		}
+	} else if ok, err := app.RedisClient.SIsMember(ctx, index, ids[0]).Result(); err != nil {
+		serverError(w, r, "Failed to load sessions", err)
+		return
+	} else if !ok {
+		respondError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	current, _ := sessionFrom(ctx)
+	for _, id := range ids {
+		if err := app.RedisClient.Del(ctx, sessionPrefix+id).Err(); err != nil {
+			serverError(w, r, "Failed to delete sessions", err)
+			return
+		}
+		app.RedisClient.SRem(ctx, index, id)
+		if id == current.ID {
+			app.setSessionCookie(w, "")
+		}
+	}
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "logged out"})
+}
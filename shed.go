@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// --- Load Shedding ---
+
+// DefaultMaxInFlight is the default ceiling on concurrently served requests.
+const DefaultMaxInFlight = 1000
+
+// shedLoad rejects requests with 503 while more than limit are already in
+// flight, so an overloaded replica fails fast instead of queueing work past
+// its time budget. Exempt paths (health probes) are always served. A
+// non-positive limit disables shedding.
+func shedLoad(limit int, exempt ...string) func(http.Handler) http.Handler {
+	skip := map[string]bool{}
+	for _, path := range exempt {
+		skip[path] = true
+	}
+	var inFlight int64
+
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if atomic.AddInt64(&inFlight, 1) > int64(limit) {
+				atomic.AddInt64(&inFlight, -1)
+				w.Header().Set("Retry-After", "1")
+				respondError(w, http.StatusServiceUnavailable, "Server is overloaded, try again shortly")
+				return
+			}
+			defer atomic.AddInt64(&inFlight, -1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
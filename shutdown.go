@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// --- Graceful Shutdown ---
+
+const (
+	// DefaultDrainPeriod is how long /readyz fails before the server stops,
+	// so the load balancer notices and stops routing new requests here
+	DefaultDrainPeriod = 10 * time.Second
+	// DefaultShutdownTimeout bounds how long in-flight requests get to finish
+	DefaultShutdownTimeout = 5 * time.Second
+)
+
+// beginDrain makes the readiness probe fail from now on.
+func (app *App) beginDrain() {
+	app.draining.Store(true)
+}
+
+// endStreams ends every open event stream. Streams never go idle, so
+// without this http.Server.Shutdown would wait on them until it timed out.
+func (app *App) endStreams() {
+	app.stopStreams.Do(func() { close(app.streamsDone) })
+}
+
+// handleReadyz reports readiness: the app isn't draining and Redis answers.
+func (app *App) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if app.draining.Load() {
+		w.Header().Set("Retry-After", "5")
+		respondJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		return
+	}
+	app.handleHealthz(w, r)
+}
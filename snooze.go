@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Snoozing ---
+
+// Snooze presets, the values of SnoozeRequest.Until.
+const (
+	SnoozeHour     = "1h"
+	SnoozeTomorrow = "tomorrow"
+	SnoozeNextWeek = "next-week"
+)
+
+// snoozeMorning is the time of day, in the client's zone, a todo snoozed
+// to another day falls due.
+const snoozeMorning = 9 * time.Hour
+
+type SnoozeRequest struct {
+	Until string `json:"until"` // SnoozeHour, SnoozeTomorrow or SnoozeNextWeek
+}
+
+// snoozedUntil returns when a todo snoozed with preset at now in loc falls
+// due, reporting false for unknown presets. Tomorrow and next week (the
+// coming Monday) are at snoozeMorning.
+func snoozedUntil(preset string, now time.Time, loc *time.Location) (time.Time, bool) {
+	now = now.In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch preset {
+	case SnoozeHour:
+		return now.Add(time.Hour).Truncate(time.Second), true
+	case SnoozeTomorrow:
+		return midnight.AddDate(0, 0, 1).Add(snoozeMorning), true
+	case SnoozeNextWeek:
+		days := (8 - int(now.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return midnight.AddDate(0, 0, days).Add(snoozeMorning), true
+	}
+	return time.Time{}, false
+}
+
+// snoozeTodo serves POST /todos/{id}/snooze, pushing the due date of an
+// open todo back to a preset and counting the snooze for /stats. HTML
+// forms are redirected back.
+func (app *App) snoozeTodo(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	var req SnoozeRequest
+	isForm := strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	if isForm {
+		req.Until = r.FormValue("until")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	due, ok := snoozedUntil(req.Until, time.Now(), app.userLocation(r))
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "Validation failed", Fields: map[string]string{
+			"until": "must be " + SnoozeHour + ", " + SnoozeTomorrow + " or " + SnoozeNextWeek,
+		}})
+		return
+	}
+
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+	if todo.Completed {
+		respondError(w, http.StatusConflict, "Completed todos can't be snoozed")
+		return
+	}
+	if err := app.Todos.Update(ctx, objID, &store.Update{DueAt: &due, AddSnoozes: 1}); err != nil {
+		respondErr(w, r, err, "Failed to snooze")
+		return
+	}
+	todo.DueAt = &due
+	todo.Snoozes++
+	if isForm {
+		setFlash(w, FlashSuccess, "Snoozed \""+todo.Title+"\" until "+due.Format("Mon Jan 2, 15:04"))
+		redirectBack(w, r, "/todos/"+objID.Hex()+"/view")
+		return
+	}
+	respondJSON(w, http.StatusOK, newTodoResponse(todo))
+}
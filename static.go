@@ -0,0 +1,33 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// --- Static Assets ---
+
+// staticFiles holds the scripts the HTML pages load, built into the binary
+// so deployments stay a single file.
+//
+//go:embed static
+var staticFiles embed.FS
+
+// staticAssets serves GET /static/*. Directory listings are refused.
+func staticAssets() http.Handler {
+	root, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err) // the embed directive guarantees the directory
+	}
+	files := http.StripPrefix("/static/", http.FileServer(http.FS(root)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			respondError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,32 @@
+// Selection toolbar: keeps the count and select-all box in step with the
+// row checkboxes, and disables the actions until something is selected.
+(function () {
+    'use strict';
+
+    var form = document.getElementById('bulk-form');
+    if (!form) { return; }
+    var all = document.getElementById('bulk-all');
+    var count = document.getElementById('bulk-count');
+
+    function boxes() {
+        return Array.prototype.slice.call(document.querySelectorAll('.bulk-select'));
+    }
+
+    function update() {
+        var n = boxes().filter(function (box) { return box.checked; }).length;
+        count.textContent = n === 0 ? 'None selected' : n + ' selected';
+        all.checked = n > 0 && n === boxes().length;
+        all.indeterminate = n > 0 && n < boxes().length;
+        form.querySelectorAll('.bulk-action').forEach(function (button) { button.disabled = n === 0; });
+    }
+
+    all.addEventListener('change', function () {
+        boxes().forEach(function (box) { box.checked = all.checked; });
+        update();
+    });
+    document.addEventListener('change', function (e) {
+        if (e.target.classList.contains('bulk-select')) { update(); }
+    });
+    document.body.addEventListener('htmx:afterSwap', update);
+    update();
+})();
@@ -0,0 +1,129 @@
+// Keyboard shortcuts for the todo list:
+//   n  new todo          j / k  next / previous todo
+//   e  edit focused todo x      complete focused todo
+//   /  search            Esc    leave the search or cancel an edit
+(function () {
+    'use strict';
+
+    var list = document.getElementById('todo-list');
+    if (!list) { return; }
+
+    function items() {
+        return Array.prototype.slice.call(list.querySelectorAll('.todo-item'));
+    }
+
+    function visibleItems() {
+        return items().filter(function (item) { return !item.hidden; });
+    }
+
+    function current() {
+        var el = document.activeElement;
+        return el && el.closest ? el.closest('.todo-item') : null;
+    }
+
+    function move(step) {
+        var all = visibleItems();
+        if (!all.length) { return; }
+        var i = all.indexOf(current());
+        i = i < 0 ? (step > 0 ? 0 : all.length - 1) : Math.min(Math.max(i + step, 0), all.length - 1);
+        all[i].focus();
+    }
+
+    function announce(text) {
+        var status = document.getElementById('shortcut-status');
+        if (status) { status.textContent = text; }
+    }
+
+    function complete(item) {
+        fetch('/todos/' + item.dataset.id, {
+            method: 'PUT',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({completed: true})
+        }).then(function (resp) {
+            if (!resp.ok) { announce('Could not complete the todo.'); return; }
+            sessionStorage.setItem('focus-todo', item.dataset.id);
+            location.reload();
+        });
+    }
+
+    function filter(query) {
+        query = query.trim().toLowerCase();
+        var shown = 0;
+        items().forEach(function (item) {
+            var title = item.querySelector('.todo-title').textContent.toLowerCase();
+            item.hidden = query !== '' && title.indexOf(query) < 0;
+            if (!item.hidden) { shown++; }
+        });
+        announce(query === '' ? '' : shown + ' matching todo' + (shown === 1 ? '' : 's'));
+    }
+
+    function typing(el) {
+        if (!el || el.type === 'checkbox') { return false; }
+        return el.isContentEditable || /^(INPUT|TEXTAREA|SELECT)$/.test(el.tagName);
+    }
+
+    var search = document.getElementById('todo-search');
+    if (search) {
+        search.addEventListener('input', function () { filter(search.value); });
+        // Rows loaded by "load more" get the current filter too
+        document.body.addEventListener('htmx:afterSwap', function () { filter(search.value); });
+        search.addEventListener('keydown', function (e) {
+            if (e.key === 'Escape') {
+                search.value = '';
+                filter('');
+                search.blur();
+            } else if (e.key === 'Enter' && !e.defaultPrevented) {
+                // typeahead.js, loaded first, claims Enter on a suggestion
+                e.preventDefault();
+                move(1);
+            }
+        });
+    }
+
+    // Return focus to the todo a shortcut just reloaded the page for
+    var refocus = sessionStorage.getItem('focus-todo');
+    if (refocus) {
+        sessionStorage.removeItem('focus-todo');
+        var item = list.querySelector('.todo-item[data-id="' + refocus + '"]');
+        if (item) { item.focus(); }
+    }
+
+    document.addEventListener('keydown', function (e) {
+        if (e.ctrlKey || e.metaKey || e.altKey) { return; }
+        if (typing(e.target)) {
+            if (e.key === 'Escape' && e.target.closest('.todo-item')) {
+                var cancel = e.target.closest('.todo-item').querySelector('.inline-cancel');
+                if (cancel) { cancel.click(); }
+            }
+            return;
+        }
+        var item = current();
+        switch (e.key) {
+        case 'n':
+            var title = document.getElementById('new-todo-title');
+            if (title) { title.focus(); }
+            break;
+        case '/':
+            if (search) { search.focus(); }
+            break;
+        case 'j':
+            move(1);
+            break;
+        case 'k':
+            move(-1);
+            break;
+        case 'e':
+            if (!item) { return; }
+            sessionStorage.setItem('focus-todo', item.dataset.id);
+            item.querySelector('.inline-edit').click();
+            break;
+        case 'x':
+            if (!item) { return; }
+            complete(item);
+            break;
+        default:
+            return;
+        }
+        e.preventDefault();
+    });
+})();
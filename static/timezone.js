@@ -0,0 +1,10 @@
+// Time zone: tells the server the browser's zone in the tz cookie, so due
+// dates are shown and entered in local time from the next page on.
+(function () {
+    'use strict';
+
+    var zone;
+    try { zone = Intl.DateTimeFormat().resolvedOptions().timeZone; } catch (e) { return; }
+    if (!zone) { return; }
+    document.cookie = 'tz=' + encodeURIComponent(zone) + '; path=/; max-age=31536000; samesite=lax';
+})();
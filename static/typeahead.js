@@ -0,0 +1,86 @@
+// Typeahead for the header search box: suggests matching todos from
+// GET /todos/suggest as you type; choosing one opens it.
+(function () {
+    'use strict';
+
+    var input = document.getElementById('todo-search');
+    var box = document.getElementById('todo-suggestions');
+    if (!input || !box) { return; }
+
+    var timer = null;
+    var pending = null;
+    var active = -1;
+
+    function options() {
+        return Array.prototype.slice.call(box.querySelectorAll('[role=option]'));
+    }
+
+    function close() {
+        box.hidden = true;
+        box.innerHTML = '';
+        active = -1;
+        input.setAttribute('aria-expanded', 'false');
+        input.removeAttribute('aria-activedescendant');
+    }
+
+    function highlight(i) {
+        var all = options();
+        if (!all.length) { return; }
+        active = (i + all.length) % all.length;
+        all.forEach(function (el, j) {
+            el.setAttribute('aria-selected', j === active ? 'true' : 'false');
+            el.classList.toggle('active', j === active);
+        });
+        input.setAttribute('aria-activedescendant', all[active].id);
+    }
+
+    function render(matches) {
+        close();
+        if (!matches.length) { return; }
+        matches.forEach(function (m, i) {
+            var a = document.createElement('a');
+            a.id = 'todo-suggestion-' + i;
+            a.href = '/todos/' + m.id + '/view';
+            a.className = 'list-group-item list-group-item-action' + (m.completed ? ' completed' : '');
+            a.setAttribute('role', 'option');
+            a.setAttribute('aria-selected', 'false');
+            a.tabIndex = -1;
+            a.textContent = m.title;
+            box.appendChild(a);
+        });
+        box.hidden = false;
+        input.setAttribute('aria-expanded', 'true');
+    }
+
+    function suggest() {
+        var q = input.value.trim();
+        if (pending) { pending.abort(); }
+        if (q === '') { close(); return; }
+        pending = new AbortController();
+        fetch('/todos/suggest?q=' + encodeURIComponent(q), {signal: pending.signal})
+            .then(function (resp) { return resp.ok ? resp.json() : []; })
+            .then(render)
+            .catch(function () {}); // aborted by a newer keystroke, or timed out
+    }
+
+    input.addEventListener('input', function () {
+        clearTimeout(timer);
+        timer = setTimeout(suggest, 100);
+    });
+    input.addEventListener('keydown', function (e) {
+        if (box.hidden) { return; }
+        if (e.key === 'ArrowDown' || e.key === 'ArrowUp') {
+            e.preventDefault();
+            highlight(active + (e.key === 'ArrowDown' ? 1 : -1));
+        } else if (e.key === 'Enter' && active >= 0) {
+            e.preventDefault();
+            location.href = options()[active].href;
+        } else if (e.key === 'Escape') {
+            close();
+        }
+    });
+    input.addEventListener('blur', function () {
+        // Let a click on a suggestion land before the box goes away
+        setTimeout(close, 150);
+    });
+})();
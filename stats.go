@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Stats ---
+
+// StatsResponse summarizes all todos.
+type StatsResponse struct {
+	Todos     int            `json:"todos"`
+	Open      int            `json:"open"`
+	Completed int            `json:"completed"`
+	ByStatus  map[string]int `json:"byStatus"`
+	Pomodoros int            `json:"pomodoros"` // completed focus sessions
+	Snoozes   int            `json:"snoozes"`   // due dates pushed back with /snooze
+}
+
+func (app *App) handleStats(w http.ResponseWriter, r *http.Request) {
+	todos, err := app.Store.List(r.Context(), store.Query{Fields: []string{"status", "pomodoros", "snoozes", "completed"}})
+	if err != nil {
+		serverError(w, r, "Failed to load stats", err)
+		return
+	}
+
+	stats := StatsResponse{Todos: len(todos), ByStatus: map[string]int{}}
+	for _, s := range store.Statuses {
+		stats.ByStatus[s] = 0
+	}
+	for _, todo := range todos {
+		if todo.Completed {
+			stats.Completed++
+		} else {
+			stats.Open++
+		}
+		stats.ByStatus[todo.CurrentStatus()]++
+		stats.Pomodoros += todo.Pomodoros
+		stats.Snoozes += todo.Snoozes
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
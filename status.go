@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Status Workflow ---
+
+// statusTransitions lists the statuses each status may move to. Done todos
+// are reopened through in-progress.
+var statusTransitions = map[string][]string{
+	store.StatusTodo:       {store.StatusInProgress, store.StatusDone},
+	store.StatusInProgress: {store.StatusTodo, store.StatusDone},
+	store.StatusDone:       {store.StatusInProgress},
+}
+
+func canTransition(from, to string) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type StatusRequest struct {
+	Status string `json:"status"`
+}
+
+// setStatus serves POST /todos/{id}/status. Moving to the current status
+// is a no-op; any other move must be listed in statusTransitions.
+func (app *App) setStatus(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	var req StatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Status == "" || !store.ValidStatus(req.Status) {
+		writeError(w, http.StatusBadRequest, ErrorResponse{
+			Error:  "Validation failed",
+			Fields: map[string]string{"status": "must be todo, in-progress or done"},
+		})
+		return
+	}
+
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+
+	from := todo.CurrentStatus()
+	if from != req.Status {
+		if !canTransition(from, req.Status) {
+			writeError(w, http.StatusConflict, ErrorResponse{
+				Error:  "Invalid status transition",
+				Fields: map[string]string{"status": fmt.Sprintf("can't move from %s to %s", from, req.Status)},
+			})
+			return
+		}
+		if err := app.Todos.Update(ctx, objID, &store.Update{Status: &req.Status}); err != nil {
+			respondErr(w, r, err, "Failed to update")
+			return
+		}
+		todo.Status = req.Status
+	}
+	respondJSON(w, http.StatusOK, newTodoResponse(todo))
+}
+
+// --- Board ---
+
+// boardColumn is one status column of the board.
+type boardColumn struct {
+	Status string
+	Todos  []store.Todo
+}
+
+func (app *App) handleBoard(w http.ResponseWriter, r *http.Request) {
+	todos, err := app.getAllTodos(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to load todos", err)
+		return
+	}
+
+	columns := make([]boardColumn, len(store.Statuses))
+	index := map[string]int{}
+	for i, s := range store.Statuses {
+		columns[i].Status = s
+		index[s] = i
+	}
+	for _, todo := range todos {
+		i := index[todo.CurrentStatus()]
+		columns[i].Todos = append(columns[i].Todos, todo)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	app.Board.Execute(w, columns)
+}
+
+const boardTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Azure Go Todo - Board</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 50px; }
+        .column { background: #eef0f2; border-radius: 5px; padding: 10px; min-height: 300px; }
+        .column.over { background: #dde3ea; }
+        .card-todo { background: white; border-radius: 5px; padding: 10px; margin-bottom: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.05); cursor: grab; }
+    </style>
+</head>
+<body>
+<div class="container">
+    <h1 class="text-center mb-4">Board</h1>
+    <p class="text-center"><a href="/">Back to list</a></p>
+    <div class="row">
+        {{range .}}
+        <div class="col">
+            <h5 class="text-capitalize">{{.Status}} <span class="badge bg-secondary">{{len .Todos}}</span></h5>
+            <div class="column" data-status="{{.Status}}">
+                {{range .Todos}}
+                <div class="card-todo" draggable="true" data-id="{{.ID.Hex}}">
+                    <bdi>{{.Title}}</bdi>
+                    {{if .Priority}}<span class="badge bg-secondary">{{.Priority}}</span>{{end}}
+                </div>
+                {{end}}
+            </div>
+        </div>
+        {{end}}
+    </div>
+</div>
+<script>
+document.querySelectorAll('.card-todo').forEach(function (card) {
+    card.addEventListener('dragstart', function (e) {
+        e.dataTransfer.setData('text/plain', card.dataset.id);
+    });
+});
+document.querySelectorAll('.column').forEach(function (column) {
+    column.addEventListener('dragover', function (e) { e.preventDefault(); column.classList.add('over'); });
+    column.addEventListener('dragleave', function () { column.classList.remove('over'); });
+    column.addEventListener('drop', function (e) {
+        e.preventDefault();
+        column.classList.remove('over');
+        var id = e.dataTransfer.getData('text/plain');
+        fetch('/todos/' + id + '/status', {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({status: column.dataset.status})
+        }).then(function (resp) {
+            if (resp.ok) { location.reload(); return; }
+            return resp.json().then(function (body) {
+                alert((body.fields && body.fields.status) || body.error);
+            });
+        });
+    });
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Status Page ---
+
+const (
+	// DefaultHealthProbeInterval is how often dependencies are probed for
+	// the status page's history
+	DefaultHealthProbeInterval = time.Minute
+	// healthHistorySize is how many probes each dependency's ring buffer
+	// keeps: an hour at the default interval
+	healthHistorySize  = 60
+	healthProbeTimeout = 2 * time.Second
+
+	// Probes are instance-wide, so keys aren't tenant-scoped. A probe of
+	// Redis that fails can't be recorded in Redis; the gap shows instead.
+	healthHistoryPrefix = "health:history:"
+)
+
+// healthProbe checks one dependency the app needs.
+type healthProbe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthSample is one probe of a dependency. The page is public, so why
+// a probe failed is only logged.
+type HealthSample struct {
+	At        time.Time `json:"at"`
+	OK        bool      `json:"ok"`
+	LatencyMs int64     `json:"latencyMs"`
+}
+
+func runProbe(ctx context.Context, p healthProbe) HealthSample {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := p.Check(ctx)
+	if err != nil {
+		log.Printf("Health probe of %s failed: %v", p.Name, err)
+	}
+	return HealthSample{At: start.UTC(), OK: err == nil, LatencyMs: time.Since(start).Milliseconds()}
+}
+
+// recordHealth pushes s onto the dependency's ring buffer, dropping the
+// oldest sample once it's full.
+func (app *App) recordHealth(ctx context.Context, name string, s HealthSample) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = app.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, healthHistoryPrefix+name, data)
+		pipe.LTrim(ctx, healthHistoryPrefix+name, 0, healthHistorySize-1)
+		return nil
+	})
+	return err
+}
+
+// healthHistory returns the dependency's recorded probes, newest first.
+func (app *App) healthHistory(ctx context.Context, name string) ([]HealthSample, error) {
+	raw, err := app.RedisClient.LRange(ctx, healthHistoryPrefix+name, 0, healthHistorySize-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]HealthSample, 0, len(raw))
+	for _, item := range raw {
+		var s HealthSample
+		if err := json.Unmarshal([]byte(item), &s); err == nil {
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
+
+// probeHealth probes every dependency each interval until ctx is done,
+// recording the results for the status page.
+func (app *App) probeHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, p := range app.probes {
+			if err := app.recordHealth(ctx, p.Name, runProbe(ctx, p)); err != nil && ctx.Err() == nil {
+				log.Printf("Error recording %s health: %v", p.Name, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type DependencyStatus struct {
+	Name    string         `json:"name"`
+	Current HealthSample   `json:"current"`
+	Uptime  float64        `json:"uptimePercent"` // of the recorded probes
+	History []HealthSample `json:"history"`       // newest first
+}
+
+type StatusPageResponse struct {
+	Status        string             `json:"status"` // "ok" or "degraded"
+	Version       string             `json:"version"`
+	Commit        string             `json:"commit"`
+	StartedAt     string             `json:"startedAt"`
+	UptimeSeconds int64              `json:"uptimeSeconds"`
+	Dependencies  []DependencyStatus `json:"dependencies"`
+}
+
+// statusPage serves GET /status: uptime, build and the live and recent
+// health of each dependency, as HTML for browsers and JSON otherwise.
+func (app *App) statusPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	out := StatusPageResponse{
+		Status:        "ok",
+		Version:       version,
+		Commit:        commit,
+		StartedAt:     formatTime(app.started),
+		UptimeSeconds: int64(time.Since(app.started).Seconds()),
+		Dependencies:  []DependencyStatus{},
+	}
+	for _, p := range app.probes {
+		dep := DependencyStatus{Name: p.Name, Current: runProbe(ctx, p)}
+		if !dep.Current.OK {
+			out.Status = "degraded"
+		}
+		history, err := app.healthHistory(ctx, p.Name)
+		if err != nil {
+			history = []HealthSample{} // Redis is down, which its probe reports
+		}
+		dep.History = history
+		ok := 0
+		for _, s := range dep.History {
+			if s.OK {
+				ok++
+			}
+		}
+		if len(dep.History) > 0 {
+			dep.Uptime = 100 * float64(ok) / float64(len(dep.History))
+		}
+		out.Dependencies = append(out.Dependencies, dep)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html")
+		app.StatusPage.Execute(w, out)
+		return
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+const statusPageTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Status - Azure Go Todo</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 50px; }
+        .container { max-width: 700px; }
+        .history { display: flex; flex-direction: row-reverse; justify-content: flex-end; gap: 2px; }
+        .history span { width: 6px; height: 24px; border-radius: 1px; }
+    </style>
+</head>
+<body>
+<main class="container">
+    <h1 class="h3">Azure Todo App status</h1>
+    <div class="alert {{if eq .Status "ok"}}alert-success{{else}}alert-warning{{end}}" role="status">
+        {{if eq .Status "ok"}}All systems operational{{else}}Some dependencies are unavailable{{end}}
+    </div>
+    <dl class="row small">
+        <dt class="col-sm-3">Version</dt><dd class="col-sm-9">{{.Version}} ({{.Commit}})</dd>
+        <dt class="col-sm-3">Up since</dt><dd class="col-sm-9">{{.StartedAt}} ({{.UptimeSeconds}}s)</dd>
+    </dl>
+    {{range .Dependencies}}
+    <section class="card mb-3">
+        <div class="card-body">
+            <h2 class="h5 d-flex justify-content-between">
+                <span>{{.Name}}</span>
+                {{if .Current.OK}}<span class="badge bg-success">up, {{.Current.LatencyMs}} ms</span>{{else}}<span class="badge bg-danger">down</span>{{end}}
+            </h2>
+            {{if .History}}
+            <div class="history mb-1" aria-label="Recent probes, oldest first">
+                {{range .History}}<span class="{{if .OK}}bg-success{{else}}bg-danger{{end}}" title="{{.At.Format "15:04:05"}}{{if not .OK}}: down{{end}}"></span>{{end}}
+            </div>
+            <p class="small text-muted mb-0">{{printf "%.1f" .Uptime}}% of the last {{len .History}} probes succeeded</p>
+            {{else}}
+            <p class="small text-muted mb-0">No probes recorded yet.</p>
+            {{end}}
+        </div>
+    </section>
+    {{end}}
+</main>
+</body>
+</html>`
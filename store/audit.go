@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --- Audit Log ---
+
+// AuditEntry records an administrative action: who took it, what it was
+// and what it was taken on. Count is how many items it affected, for
+// actions on many at once.
+type AuditEntry struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	Actor  string             `json:"actor" bson:"actor"`
+	Action string             `json:"action" bson:"action"`
+	Target string             `json:"target,omitempty" bson:"target,omitempty"`
+	Count  int                `json:"count,omitempty" bson:"count,omitempty"`
+	At     time.Time          `json:"at" bson:"at"`
+}
+
+// AuditLog is an append-only record of administrative actions. Its
+// contract is verified by storetest.RunAudit: Recent returns at most
+// limit entries, newest first.
+type AuditLog interface {
+	RecordAudit(ctx context.Context, e AuditEntry) error
+	RecentAudit(ctx context.Context, limit int) ([]AuditEntry, error)
+}
+
+// MongoAudit stores audit entries in their own collection.
+type MongoAudit struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAudit(coll *mongo.Collection) *MongoAudit {
+	return &MongoAudit{coll: coll}
+}
+
+// EnsureIndexes creates the index serving RecentAudit.
+func (m *MongoAudit) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "at", Value: -1}},
+	})
+	return err
+}
+
+func (m *MongoAudit) RecordAudit(ctx context.Context, e AuditEntry) error {
+	_, err := m.coll.InsertOne(ctx, e)
+	return err
+}
+
+func (m *MongoAudit) RecentAudit(ctx context.Context, limit int) ([]AuditEntry, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := m.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := []AuditEntry{}
+	err = cursor.All(ctx, &entries)
+	return entries, err
+}
+
+// MemoryAudit is an in-process AuditLog, safe for concurrent use.
+type MemoryAudit struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func NewMemoryAudit() *MemoryAudit {
+	return &MemoryAudit{}
+}
+
+func (m *MemoryAudit) RecordAudit(ctx context.Context, e AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func (m *MemoryAudit) RecentAudit(ctx context.Context, limit int) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []AuditEntry{}
+	for i := len(m.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, m.entries[i])
+	}
+	return entries, nil
+}
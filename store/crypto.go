@@ -0,0 +1,373 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Field Encryption ---
+
+// encryptedPrefix marks an encrypted field value: enc:v1:<key ID>:<data>.
+const encryptedPrefix = "enc:v1:"
+
+// FieldCipher encrypts individual field values. Values it didn't encrypt,
+// such as those stored before encryption was turned on, decrypt to
+// themselves.
+type FieldCipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(value string) (string, error)
+}
+
+// Keyring is an AES-GCM FieldCipher over a set of data keys. New values
+// are encrypted with the current key; each value names its key, so older
+// keys keep decrypting after a rotation.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring returns a keyring of 32-byte keys by ID, encrypting with
+// current.
+func NewKeyring(keys map[string][]byte, current string) (*Keyring, error) {
+	k := &Keyring{current: current, aeads: map[string]cipher.AEAD{}}
+	for id, key := range keys {
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key ID %q contains a colon", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s is %d bytes, want 32", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	if k.aeads[current] == nil {
+		return nil, fmt.Errorf("current key %q is not in the keyring", current)
+	}
+	return k, nil
+}
+
+// Current returns the ID of the key new values are encrypted with.
+func (k *Keyring) Current() string { return k.current }
+
+// Encrypt seals plaintext with the current key. The empty string stays
+// empty so "no description" remains visible to queries.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.current))
+	return encryptedPrefix + k.current + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, data, _ := strings.Cut(rest, ":")
+	aead := k.aeads[id]
+	if aead == nil {
+		return "", fmt.Errorf("unknown data key %q", id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decrypting with data key %s: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// IsEncrypted reports whether value was sealed by a Keyring, and with
+// which key.
+func IsEncrypted(value string) (keyID string, ok bool) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", false
+	}
+	keyID, _, _ = strings.Cut(rest, ":")
+	return keyID, true
+}
+
+// crypt applies fn to each set field in place, stopping at the first error.
+func crypt(fn func(string) (string, error), fields ...*string) error {
+	for _, f := range fields {
+		if f == nil {
+			continue
+		}
+		v, err := fn(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+// sealTodo and openTodo convert a todo's sensitive fields.
+func sealTodo(c FieldCipher, todo Todo) (Todo, error) {
+	return todo, crypt(c.Encrypt, &todo.Title, &todo.Description)
+}
+
+func openTodo(c FieldCipher, todo Todo) (Todo, error) {
+	return todo, crypt(c.Decrypt, &todo.Title, &todo.Description)
+}
+
+// convertUpdate returns a copy of u with its sensitive fields converted.
+func convertUpdate(fn func(string) (string, error), u Update) (Update, error) {
+	if u.Title != nil {
+		title := *u.Title
+		u.Title = &title
+	}
+	if u.Description != nil {
+		description := *u.Description
+		u.Description = &description
+	}
+	return u, crypt(fn, u.Title, u.Description)
+}
+
+// convertEvent returns a copy of e with the sensitive fields of its todo
+// and changes converted.
+func convertEvent(fn func(string) (string, error), e Event) (Event, error) {
+	if e.Todo != nil {
+		todo := *e.Todo
+		if err := crypt(fn, &todo.Title, &todo.Description); err != nil {
+			return e, err
+		}
+		e.Todo = &todo
+	}
+	if e.Changes != nil {
+		u, err := convertUpdate(fn, *e.Changes)
+		if err != nil {
+			return e, err
+		}
+		e.Changes = &u
+	}
+	return e, nil
+}
+
+// WithEncryption stores todo titles and descriptions encrypted by c,
+// decrypting them on the way out. It belongs directly around the backend,
+// under decorators such as WithEvents that expect plaintext.
+func WithEncryption(s Store, c FieldCipher) Store {
+	return &encryptedStore{inner: s, cipher: c}
+}
+
+type encryptedStore struct {
+	inner  Store
+	cipher FieldCipher
+}
+
+func (e *encryptedStore) List(ctx context.Context, q Query) ([]Todo, error) {
+	if q.filtersTitle() {
+		return e.listByTitle(ctx, q)
+	}
+	todos, err := e.inner.List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for i := range todos {
+		if todos[i], err = openTodo(e.cipher, todos[i]); err != nil {
+			return nil, err
+		}
+	}
+	return todos, nil
+}
+
+// errEnough stops a stream once it has what it needs.
+var errEnough = errors.New("store: enough todos")
+
+// listByTitle runs a query filtering on titles, which are matched once
+// decrypted, as their stored form can't be. It reads the todos the other
+// filters keep, in order, until the page is full.
+func (e *encryptedStore) listByTitle(ctx context.Context, q Query) ([]Todo, error) {
+	inner := q
+	inner.Title, inner.TitleStart, inner.TitlePrefix, inner.Offset, inner.Limit = "", "", "", 0, 0
+	if len(q.Fields) > 0 {
+		inner.Fields = append(append([]string(nil), q.Fields...), "title")
+	}
+	titles := Query{Title: q.Title, TitleStart: q.TitleStart, TitlePrefix: q.TitlePrefix}
+	var todos []Todo
+	err := stream(ctx, e.inner, inner, func(todo Todo) error {
+		todo, err := openTodo(e.cipher, todo)
+		if err != nil {
+			return err
+		}
+		if titles.matches(todo) {
+			todos = append(todos, project(todo, q.Fields))
+		}
+		if q.Limit > 0 && len(todos) >= q.Offset+q.Limit {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, err
+	}
+	return paginate(todos, q), nil
+}
+
+func (e *encryptedStore) Count(ctx context.Context) (Counts, error) {
+	return CountTodos(ctx, e.inner)
+}
+
+func (e *encryptedStore) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	return ReportCompletions(ctx, e.inner, group, from, to)
+}
+
+func (e *encryptedStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	return ReportTagCounts(ctx, e.inner)
+}
+
+func (e *encryptedStore) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	if q.filtersTitle() {
+		todos, err := e.listByTitle(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if err := fn(todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return stream(ctx, e.inner, q, func(todo Todo) error {
+		todo, err := openTodo(e.cipher, todo)
+		if err != nil {
+			return err
+		}
+		return fn(todo)
+	})
+}
+
+func (e *encryptedStore) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	todo, err := e.inner.Get(ctx, id)
+	if err != nil {
+		return todo, err
+	}
+	return openTodo(e.cipher, todo)
+}
+
+func (e *encryptedStore) Create(ctx context.Context, todo Todo) error {
+	sealed, err := sealTodo(e.cipher, todo)
+	if err != nil {
+		return err
+	}
+	return e.inner.Create(ctx, sealed)
+}
+
+func (e *encryptedStore) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	sealed, err := convertUpdate(e.cipher.Encrypt, u)
+	if err != nil {
+		return err
+	}
+	return e.inner.Update(ctx, id, sealed)
+}
+
+func (e *encryptedStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return e.inner.Delete(ctx, id)
+}
+
+// EncryptEvents is WithEncryption for an event log.
+func EncryptEvents(log EventLog, c FieldCipher) EventLog {
+	return &encryptedEvents{inner: log, cipher: c}
+}
+
+type encryptedEvents struct {
+	inner  EventLog
+	cipher FieldCipher
+}
+
+func (e *encryptedEvents) Append(ctx context.Context, ev Event) error {
+	sealed, err := convertEvent(e.cipher.Encrypt, ev)
+	if err != nil {
+		return err
+	}
+	return e.inner.Append(ctx, sealed)
+}
+
+func (e *encryptedEvents) TodoEvents(ctx context.Context, id primitive.ObjectID) ([]Event, error) {
+	events, err := e.inner.TodoEvents(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		if events[i], err = convertEvent(e.cipher.Decrypt, events[i]); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (e *encryptedEvents) Replay(ctx context.Context, fn func(Event) error) error {
+	return e.inner.Replay(ctx, func(ev Event) error {
+		ev, err := convertEvent(e.cipher.Decrypt, ev)
+		if err != nil {
+			return err
+		}
+		return fn(ev)
+	})
+}
+
+// EncryptOutbox is WithEncryption for an outbox; messages are decrypted
+// when due, before they're relayed.
+func EncryptOutbox(o Outbox, c FieldCipher) Outbox {
+	return &encryptedOutbox{Outbox: o, cipher: c}
+}
+
+type encryptedOutbox struct {
+	Outbox
+	cipher FieldCipher
+}
+
+func (e *encryptedOutbox) Add(ctx context.Context, m OutboxMessage) error {
+	var err error
+	if m.Event, err = convertEvent(e.cipher.Encrypt, m.Event); err != nil {
+		return err
+	}
+	return e.Outbox.Add(ctx, m)
+}
+
+func (e *encryptedOutbox) Due(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	msgs, err := e.Outbox.Due(ctx, now, lease, limit)
+	for i := range msgs {
+		var cerr error
+		if msgs[i].Event, cerr = convertEvent(e.cipher.Decrypt, msgs[i].Event); cerr != nil {
+			return nil, cerr
+		}
+	}
+	return msgs, err
+}
+
+func (e *encryptedOutbox) DeadLetters(ctx context.Context, offset, limit int) ([]OutboxMessage, error) {
+	msgs, err := e.Outbox.DeadLetters(ctx, offset, limit)
+	for i := range msgs {
+		var cerr error
+		if msgs[i].Event, cerr = convertEvent(e.cipher.Decrypt, msgs[i].Event); cerr != nil {
+			return nil, cerr
+		}
+	}
+	return msgs, err
+}
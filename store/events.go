@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --- Events ---
+
+// Event types. Updates are named after what they change most visibly;
+// every update event carries the full Update regardless.
+const (
+	EventCreated   = "created"
+	EventRetitled  = "retitled"
+	EventCompleted = "completed"
+	EventReopened  = "reopened"
+	EventUpdated   = "updated"
+	EventDeleted   = "deleted"
+)
+
+// Event is one mutation of a todo. Created events carry the new todo,
+// update events the Update applied to it; outbox messages also carry the
+// todo as updated.
+type Event struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	TodoID  primitive.ObjectID `json:"todoId" bson:"todoId"`
+	Tenant  string             `json:"tenant,omitempty" bson:"tenant,omitempty"` // "" for the default tenant
+	Type    string             `json:"type" bson:"type"`
+	Todo    *Todo              `json:"todo,omitempty" bson:"todo,omitempty"`
+	Changes *Update            `json:"changes,omitempty" bson:"changes,omitempty"`
+	At      time.Time          `json:"at" bson:"at"`
+}
+
+// updateEventType names the event recording u.
+func updateEventType(u Update) string {
+	switch {
+	case u.Completed != nil && *u.Completed:
+		return EventCompleted
+	case u.Completed != nil:
+		return EventReopened
+	case u.Title != nil && reflect.DeepEqual(u, Update{Title: u.Title}):
+		return EventRetitled
+	default:
+		return EventUpdated
+	}
+}
+
+// EventLog is an append-only record of todo events, read back oldest
+// first.
+type EventLog interface {
+	Append(ctx context.Context, e Event) error
+	TodoEvents(ctx context.Context, id primitive.ObjectID) ([]Event, error)
+	Replay(ctx context.Context, fn func(Event) error) error
+}
+
+// Project applies e to the read model s.
+func Project(ctx context.Context, s Store, e Event) error {
+	switch {
+	case e.Type == EventCreated && e.Todo != nil:
+		return s.Create(ctx, *e.Todo)
+	case e.Type == EventDeleted:
+		return s.Delete(ctx, e.TodoID)
+	case e.Changes != nil:
+		return s.Update(ctx, e.TodoID, *e.Changes)
+	}
+	return nil
+}
+
+// Rebuild replays every event of log into s, which should be empty, and
+// returns how many were projected.
+func Rebuild(ctx context.Context, log EventLog, s Store) (int, error) {
+	n := 0
+	err := log.Replay(ctx, func(e Event) error {
+		if err := Project(ctx, s, e); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// WithEvents makes log the source of truth for s: every mutation is
+// appended to log and then projected onto s, which stays the read model
+// serving List and Get. Writes are validated before they're recorded so
+// the log only holds events the read model accepts.
+func WithEvents(s Store, log EventLog) Store {
+	return &eventStore{inner: s, log: log}
+}
+
+type eventStore struct {
+	inner Store
+	log   EventLog
+}
+
+func (e *eventStore) record(ctx context.Context, ev Event) error {
+	ev.ID, ev.At, ev.Tenant = primitive.NewObjectID(), time.Now().UTC(), TenantFrom(ctx)
+	if err := e.log.Append(ctx, ev); err != nil {
+		return err
+	}
+	return Project(ctx, e.inner, ev)
+}
+
+func (e *eventStore) List(ctx context.Context, q Query) ([]Todo, error) {
+	return e.inner.List(ctx, q)
+}
+
+func (e *eventStore) Count(ctx context.Context) (Counts, error) {
+	return CountTodos(ctx, e.inner)
+}
+
+func (e *eventStore) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	return ReportCompletions(ctx, e.inner, group, from, to)
+}
+
+func (e *eventStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	return ReportTagCounts(ctx, e.inner)
+}
+
+func (e *eventStore) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	return stream(ctx, e.inner, q, fn)
+}
+
+func (e *eventStore) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	return e.inner.Get(ctx, id)
+}
+
+func (e *eventStore) Create(ctx context.Context, todo Todo) error {
+	if err := validate(&todo.Title, &todo.Priority, &todo.Color, &todo.Status); err != nil {
+		return err
+	}
+	return e.record(ctx, Event{TodoID: todo.ID, Type: EventCreated, Todo: &todo})
+}
+
+// Update records nothing for unknown IDs, keeping them no-ops.
+func (e *eventStore) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	if err := validate(u.Title, u.Priority, u.Color, u.Status); err != nil {
+		return err
+	}
+	if _, err := e.inner.Get(ctx, id); errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return e.record(ctx, Event{TodoID: id, Type: updateEventType(u), Changes: &u})
+}
+
+func (e *eventStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := e.inner.Get(ctx, id); errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return e.record(ctx, Event{TodoID: id, Type: EventDeleted})
+}
+
+// MongoEvents stores events in their own collection, e.g. todo_events.
+type MongoEvents struct {
+	coll *mongo.Collection
+}
+
+func NewMongoEvents(coll *mongo.Collection) *MongoEvents {
+	return &MongoEvents{coll: coll}
+}
+
+// EnsureIndexes creates the index serving TodoEvents.
+func (m *MongoEvents) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "at", Value: 1}},
+	})
+	return err
+}
+
+func (m *MongoEvents) Append(ctx context.Context, e Event) error {
+	_, err := m.coll.InsertOne(ctx, e)
+	return err
+}
+
+func (m *MongoEvents) TodoEvents(ctx context.Context, id primitive.ObjectID) ([]Event, error) {
+	events := []Event{}
+	err := m.find(ctx, bson.M{"todoId": id}, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+func (m *MongoEvents) Replay(ctx context.Context, fn func(Event) error) error {
+	return m.find(ctx, bson.M{}, fn)
+}
+
+func (m *MongoEvents) find(ctx context.Context, filter bson.M, fn func(Event) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := m.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var e Event
+		if err := cursor.Decode(&e); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// MemoryEvents is an in-process EventLog, safe for concurrent use.
+type MemoryEvents struct {
+	mu     sync.RWMutex
+	events []Event
+}
+
+func NewMemoryEvents() *MemoryEvents {
+	return &MemoryEvents{}
+}
+
+func (m *MemoryEvents) Append(ctx context.Context, e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *MemoryEvents) TodoEvents(ctx context.Context, id primitive.ObjectID) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := []Event{}
+	for _, e := range m.events {
+		if e.TodoID == id {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryEvents) Replay(ctx context.Context, fn func(Event) error) error {
+	m.mu.RLock()
+	events := append([]Event(nil), m.events...)
+	m.mu.RUnlock()
+
+	for _, e := range events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package store
+
+import "math"
+
+// --- Locations ---
+
+// earthRadius is the mean Earth radius in meters, as used by MongoDB's
+// spherical geometry.
+const earthRadius = 6378100.0
+
+// Location is a GeoJSON point with an optional label, stored so a 2dsphere
+// index can serve Query.Near.
+type Location struct {
+	Type        string    `json:"type" bson:"type"`               // always "Point"
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"` // [lng, lat]
+	Label       string    `json:"label,omitempty" bson:"label,omitempty"`
+}
+
+func NewLocation(lat, lng float64, label string) *Location {
+	return &Location{Type: "Point", Coordinates: []float64{lng, lat}, Label: label}
+}
+
+// Valid reports whether l is a well-formed point.
+func (l *Location) Valid() bool {
+	return l != nil && l.Type == "Point" && len(l.Coordinates) == 2
+}
+
+func (l *Location) Lat() float64 { return l.Coordinates[1] }
+func (l *Location) Lng() float64 { return l.Coordinates[0] }
+
+// Circle is an area on the Earth's surface.
+type Circle struct {
+	Lat, Lng     float64
+	RadiusMeters float64
+}
+
+// Contains reports whether l lies within c.
+func (c Circle) Contains(l *Location) bool {
+	return l.Valid() && c.Distance(l) <= c.RadiusMeters
+}
+
+// Distance returns the great-circle distance in meters from the center of
+// c to l.
+func (c Circle) Distance(l *Location) float64 {
+	lat1, lat2 := c.Lat*math.Pi/180, l.Lat()*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (l.Lng() - c.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Memory is an in-process Store for tests and local experiments. It is
+// safe for concurrent use.
+type Memory struct {
+	mu    sync.RWMutex
+	todos map[primitive.ObjectID]Todo
+}
+
+func NewMemory() *Memory {
+	return &Memory{todos: map[primitive.ObjectID]Todo{}}
+}
+
+func (m *Memory) List(ctx context.Context, q Query) ([]Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	todos := make([]Todo, 0, len(m.todos))
+	for _, todo := range m.todos {
+		if q.matches(todo) {
+			todos = append(todos, todo)
+		}
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		return q.sortedBefore(todos[i], todos[j])
+	})
+	todos = paginate(todos, q)
+	for i := range todos {
+		todos[i] = project(todos[i], q.Fields)
+	}
+	return todos, nil
+}
+
+func (m *Memory) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	todos, err := m.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	todo, ok := m.todos[id]
+	if !ok {
+		return Todo{}, ErrNotFound
+	}
+	return todo, nil
+}
+
+// validate mirrors TodoSchema for the fields a write sets.
+func validate(title, priority, color, status *string) error {
+	if title != nil && *title == "" {
+		return &ValidationError{Fields: map[string]string{"title": "is required"}}
+	}
+	if priority != nil && !ValidPriority(*priority) {
+		return &ValidationError{Fields: map[string]string{"priority": "must be low, medium or high"}}
+	}
+	if color != nil && !ValidColor(*color) {
+		return &ValidationError{Fields: map[string]string{"color": colorRule}}
+	}
+	if status != nil && !ValidStatus(*status) {
+		return &ValidationError{Fields: map[string]string{"status": "must be todo, in-progress or done"}}
+	}
+	return nil
+}
+
+func (m *Memory) Create(ctx context.Context, todo Todo) error {
+	if err := validate(&todo.Title, &todo.Priority, &todo.Color, &todo.Status); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.todos[todo.ID]; ok {
+		return ErrConflict
+	}
+	m.todos[todo.ID] = todo
+	return nil
+}
+
+func (m *Memory) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	if err := validate(u.Title, u.Priority, u.Color, u.Status); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok {
+		return nil
+	}
+	u.apply(&todo)
+	m.todos[id] = todo
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.todos, id)
+	return nil
+}
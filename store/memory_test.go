@@ -0,0 +1,104 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+	"github.com/mkgakishi/go-azure-todo/store/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return store.NewMemory() })
+}
+
+func TestMemoryTransactions(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return store.WithTransactions(store.NewMemory(), store.NoTransactions) })
+}
+
+func TestMemoryEvents(t *testing.T) {
+	storetest.RunEvents(t, func(t *testing.T) store.EventLog { return store.NewMemoryEvents() })
+}
+
+func TestMemoryOutbox(t *testing.T) {
+	storetest.RunOutbox(t, func(t *testing.T) store.Outbox { return store.NewMemoryOutbox() })
+}
+
+func TestMemoryTemplates(t *testing.T) {
+	storetest.RunTemplates(t, func(t *testing.T) store.TemplateStore { return store.NewMemoryTemplates() })
+}
+
+func TestMemoryRefreshTokens(t *testing.T) {
+	storetest.RunRefreshTokens(t, func(t *testing.T) store.RefreshTokenStore { return store.NewMemoryRefreshTokens() })
+}
+
+func TestMemoryAudit(t *testing.T) {
+	storetest.RunAudit(t, func(t *testing.T) store.AuditLog { return store.NewMemoryAudit() })
+}
+
+func testCipher(t *testing.T) store.FieldCipher {
+	k, err := store.NewKeyring(map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestMemoryEncryption(t *testing.T) {
+	storetest.RunEncryption(t, func(t *testing.T) store.Store { return store.NewMemory() })
+	storetest.Run(t, func(t *testing.T) store.Store { return store.WithEncryption(store.NewMemory(), testCipher(t)) })
+	storetest.RunEvents(t, func(t *testing.T) store.EventLog { return store.EncryptEvents(store.NewMemoryEvents(), testCipher(t)) })
+	storetest.RunOutbox(t, func(t *testing.T) store.Outbox { return store.EncryptOutbox(store.NewMemoryOutbox(), testCipher(t)) })
+}
+
+func TestMemoryByTenant(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.ByTenant(func(string) (store.Store, error) { return store.NewMemory(), nil })
+	})
+}
+
+func TestByTenantOpening(t *testing.T) {
+	var mu sync.Mutex
+	opened := map[string]int{}
+	fail := true
+	s := store.ByTenant(func(tenant string) (store.Store, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		opened[tenant]++
+		if tenant == "flaky" && fail {
+			fail = false
+			return nil, errors.New("no indexes")
+		}
+		return store.NewMemory(), nil
+	})
+
+	ctx := store.WithTenant(context.Background(), "team")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.List(ctx, store.Query{}); err != nil {
+				t.Errorf("List: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if opened["team"] != 1 {
+		t.Errorf("team's store opened %d times, want once", opened["team"])
+	}
+
+	// A store that failed to open isn't kept
+	ctx = store.WithTenant(context.Background(), "flaky")
+	if _, err := s.List(ctx, store.Query{}); err == nil {
+		t.Fatal("List succeeded though the store failed to open")
+	}
+	if _, err := s.List(ctx, store.Query{}); err != nil {
+		t.Fatalf("List after a failed open: %v", err)
+	}
+	if opened["flaky"] != 2 {
+		t.Errorf("flaky's store opened %d times, want twice", opened["flaky"])
+	}
+}
@@ -0,0 +1,399 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Mongo stores todos in a MongoDB (or Cosmos DB Mongo API) collection.
+type Mongo struct {
+	coll    *mongo.Collection
+	primary *mongo.Collection // coll reading from the primary, for WithFreshReads
+}
+
+func NewMongo(coll *mongo.Collection) *Mongo {
+	primary, err := coll.Clone(options.Collection().SetReadPreference(readpref.Primary()).SetReadConcern(readconcern.Local()))
+	if err != nil {
+		primary = coll
+	}
+	return &Mongo{coll: coll, primary: primary}
+}
+
+// reader returns the collection to read from: the primary when ctx asks
+// for fresh reads, whatever MONGO_READ_PREFERENCE picks otherwise.
+func (m *Mongo) reader(ctx context.Context) *mongo.Collection {
+	if FreshReads(ctx) {
+		return m.primary
+	}
+	return m.coll
+}
+
+// filter selects the todos the query is restricted to.
+func filter(q Query) bson.M {
+	f := bson.M{}
+	if len(q.IDs) > 0 {
+		f["_id"] = bson.M{"$in": q.IDs}
+	}
+	if q.Completed != nil {
+		f["completed"] = *q.Completed
+	}
+	if q.Color != "" {
+		f["color"] = q.Color
+	}
+	if q.MaxEstimate > 0 {
+		f["estimateMinutes"] = bson.M{"$gt": 0, "$lte": q.MaxEstimate}
+	}
+	if q.MinProgress > 0 {
+		f["progressPercent"] = bson.M{"$gte": q.MinProgress}
+	}
+	if q.Near != nil {
+		f["location"] = bson.M{"$geoWithin": bson.M{"$centerSphere": bson.A{
+			bson.A{q.Near.Lng, q.Near.Lat}, q.Near.RadiusMeters / earthRadius,
+		}}}
+	}
+	if q.Title != "" {
+		f["titleKey"] = TitleKey(q.Title)
+	}
+	// Anchored and case-sensitive, on keys already folded, these regexes
+	// are index range scans
+	if q.TitleStart != "" {
+		f["titleKey"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(TitleKey(q.TitleStart))}
+	}
+	if q.TitlePrefix != "" {
+		prefix := TitleKey(q.TitlePrefix)
+		f["titleWords"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(truncateKey(prefix))}
+		if len(prefix) > titleWordBytes {
+			// Also the rest of it, on the todos the index found
+			f["$and"] = bson.A{bson.M{"titleKey": primitive.Regex{Pattern: "(^| )" + regexp.QuoteMeta(prefix)}}}
+		}
+	}
+	return f
+}
+
+// mongoTodo is a todo as stored, with its TitleKey and TitleWords so
+// title queries can use an index. Decoding ignores them.
+type mongoTodo struct {
+	Todo       `bson:",inline"`
+	TitleKey   string   `bson:"titleKey"`
+	TitleWords []string `bson:"titleWords"`
+}
+
+func newMongoTodo(todo Todo) mongoTodo {
+	return mongoTodo{Todo: todo, TitleKey: TitleKey(todo.Title), TitleWords: TitleWords(todo.Title)}
+}
+
+// findOptions applies the query's projection.
+func findOptions(q Query) *options.FindOptions {
+	opts := options.Find()
+	if len(q.Fields) > 0 {
+		projection := bson.D{}
+		seen := map[string]bool{}
+		for _, f := range q.Fields {
+			if !seen[f] {
+				seen[f] = true
+				projection = append(projection, bson.E{Key: f, Value: 1})
+			}
+		}
+		opts.SetProjection(projection)
+	}
+	return opts
+}
+
+// List pages server-side in the default order, which is indexed, so only
+// the page is read. Other orders are sorted and paged in memory.
+func (m *Mongo) List(ctx context.Context, q Query) ([]Todo, error) {
+	if q.Sort == "" {
+		todos := []Todo{}
+		err := m.Stream(ctx, q, func(todo Todo) error {
+			todos = append(todos, todo)
+			return nil
+		})
+		return todos, err
+	}
+
+	// The in-memory sort below needs pinned, createdAt and the sort field
+	// even when they weren't asked for
+	fetch := q
+	if len(q.Fields) > 0 {
+		fetch.Fields = append([]string{"pinned", "createdAt", strings.TrimPrefix(q.Sort, "-")}, q.Fields...)
+	}
+
+	// Note: No server-side sort on the Sort fields, which aren't indexed:
+	// Cosmos DB serverless doesn't include default indexes on custom fields
+	cursor, err := m.reader(ctx).Find(ctx, filter(q), findOptions(fetch))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	todos := []Todo{}
+	if err := cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+
+	// Sort in memory instead (works for reasonable dataset sizes)
+	sort.SliceStable(todos, func(i, j int) bool {
+		return q.sortedBefore(todos[i], todos[j])
+	})
+	todos = paginate(todos, q)
+	for i := range todos {
+		todos[i] = project(todos[i], q.Fields)
+	}
+	return todos, nil
+}
+
+// EnsureIndexes creates the indexes Stream and Query.Near rely on. Cosmos
+// DB only indexes _id by default and rejects sorts on unindexed fields.
+func (m *Mongo) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "pinned", Value: -1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+		{Keys: bson.D{{Key: "titleKey", Value: 1}}},
+		{Keys: bson.D{{Key: "titleWords", Value: 1}}},
+	})
+	return err
+}
+
+// BackfillTitleKeys stores the title keys of todos written without them,
+// by an older version or straight to the collection.
+func (m *Mongo) BackfillTitleKeys(ctx context.Context) error {
+	opts := options.Find().SetProjection(bson.M{"title": 1})
+	cursor, err := m.coll.Find(ctx, bson.M{"titleWords": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var todo Todo
+		if err := cursor.Decode(&todo); err != nil {
+			return err
+		}
+		set := bson.M{"titleKey": TitleKey(todo.Title), "titleWords": TitleWords(todo.Title)}
+		if _, err := m.coll.UpdateOne(ctx, bson.M{"_id": todo.ID}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Stream sorts server-side on pinned and createdAt and decodes one
+// document at a time. Sorted queries go through List, as their fields
+// aren't indexed.
+func (m *Mongo) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	if q.Sort != "" {
+		todos, err := m.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if err := fn(todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	opts := findOptions(q).SetSort(bson.D{{Key: "pinned", Value: -1}, {Key: "createdAt", Value: -1}})
+	if q.Offset > 0 {
+		opts.SetSkip(int64(q.Offset))
+	}
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	cursor, err := m.reader(ctx).Find(ctx, filter(q), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var todo Todo
+		if err := cursor.Decode(&todo); err != nil {
+			return err
+		}
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Count groups todos by state and by tag server-side. Two plain $group
+// pipelines rather than one $facet, which not every backend supports.
+func (m *Mongo) Count(ctx context.Context) (Counts, error) {
+	c := Counts{Tags: map[string]int{}}
+	var groups []struct {
+		Completed bool `bson:"_id"`
+		N         int  `bson:"n"`
+	}
+	if err := m.aggregate(ctx, &groups, bson.A{
+		bson.M{"$group": bson.M{"_id": bson.M{"$eq": bson.A{"$completed", true}}, "n": bson.M{"$sum": 1}}},
+	}); err != nil {
+		return Counts{}, err
+	}
+	for _, g := range groups {
+		c.Total += g.N
+		if g.Completed {
+			c.Completed += g.N
+		}
+	}
+	var tags []struct {
+		Tag string `bson:"_id"`
+		N   int    `bson:"n"`
+	}
+	if err := m.aggregate(ctx, &tags, bson.A{
+		bson.M{"$unwind": "$tags"},
+		bson.M{"$group": bson.M{"_id": "$tags", "n": bson.M{"$sum": 1}}},
+	}); err != nil {
+		return Counts{}, err
+	}
+	for _, t := range tags {
+		c.Tags[t.Tag] = t.N
+	}
+	return c, nil
+}
+
+func (m *Mongo) aggregate(ctx context.Context, results interface{}, pipeline bson.A) error {
+	cursor, err := m.reader(ctx).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+func (m *Mongo) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	var todo Todo
+	err := m.reader(ctx).FindOne(ctx, bson.M{"_id": id}).Decode(&todo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Todo{}, ErrNotFound
+	}
+	return todo, err
+}
+
+func (m *Mongo) Create(ctx context.Context, todo Todo) error {
+	_, err := m.coll.InsertOne(ctx, newMongoTodo(todo))
+	return validationError(err)
+}
+
+func (m *Mongo) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	set := bson.M{}
+	if u.Title != nil {
+		set["title"] = *u.Title
+		set["titleKey"] = TitleKey(*u.Title)
+		set["titleWords"] = TitleWords(*u.Title)
+	}
+	if u.Description != nil {
+		set["description"] = *u.Description
+	}
+	if u.Tags != nil {
+		set["tags"] = *u.Tags
+	}
+	if u.Priority != nil {
+		set["priority"] = *u.Priority
+	}
+	if u.BlockedBy != nil {
+		set["blockedBy"] = *u.BlockedBy
+	}
+	if u.Status != nil {
+		set["status"] = *u.Status
+	}
+	unset := bson.M{}
+	if u.Color != nil {
+		if *u.Color == "" {
+			unset["color"] = ""
+		} else {
+			set["color"] = *u.Color
+		}
+	}
+	if u.Location != nil {
+		if u.Location.Valid() {
+			set["location"] = u.Location
+		} else {
+			unset["location"] = ""
+		}
+	}
+	if u.DueAt != nil {
+		if u.DueAt.IsZero() {
+			unset["dueAt"] = ""
+		} else {
+			set["dueAt"] = *u.DueAt
+		}
+	}
+	if u.Estimate != nil {
+		set["estimateMinutes"] = *u.Estimate
+	}
+	if u.Progress != nil {
+		set["progressPercent"] = *u.Progress
+	}
+	if u.CalendarID != nil {
+		set["calendarEventId"] = *u.CalendarID
+	}
+	for k, v := range u.Custom {
+		if v == nil {
+			unset["custom."+k] = ""
+		} else {
+			set["custom."+k] = v
+		}
+	}
+	if u.Pinned != nil {
+		if *u.Pinned {
+			set["pinned"] = true
+		} else {
+			unset["pinned"] = ""
+		}
+	}
+	if u.MyDay != nil {
+		if *u.MyDay {
+			set["myDay"] = true
+		} else {
+			unset["myDay"] = ""
+		}
+	}
+	if u.Completed != nil {
+		set["completed"] = *u.Completed
+	}
+	if u.CompletedAt != nil {
+		if u.CompletedAt.IsZero() {
+			unset["completedAt"] = ""
+		} else {
+			set["completedAt"] = *u.CompletedAt
+		}
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	inc := bson.M{}
+	if u.AddPomodoros != 0 {
+		inc["pomodoros"] = u.AddPomodoros
+	}
+	if u.AddSnoozes != 0 {
+		inc["snoozes"] = u.AddSnoozes
+	}
+	if len(inc) > 0 {
+		update["$inc"] = inc
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	_, err := m.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return validationError(err)
+}
+
+func (m *Mongo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
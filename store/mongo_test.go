@@ -0,0 +1,38 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/mkgakishi/go-azure-todo/store/storetest"
+)
+
+// The Mongo suites run against a testcontainers MongoDB and are skipped
+// with -short or when Docker isn't available.
+
+func TestMongo(t *testing.T) {
+	storetest.Run(t, storetest.MongoContainer(t))
+}
+
+func TestMongoEncryption(t *testing.T) {
+	storetest.RunEncryption(t, storetest.MongoContainer(t))
+}
+
+func TestMongoEvents(t *testing.T) {
+	storetest.RunEvents(t, storetest.MongoEventContainer(t))
+}
+
+func TestMongoOutbox(t *testing.T) {
+	storetest.RunOutbox(t, storetest.MongoOutboxContainer(t))
+}
+
+func TestMongoTemplates(t *testing.T) {
+	storetest.RunTemplates(t, storetest.MongoTemplateContainer(t))
+}
+
+func TestMongoRefreshTokens(t *testing.T) {
+	storetest.RunRefreshTokens(t, storetest.MongoRefreshTokenContainer(t))
+}
+
+func TestMongoAudit(t *testing.T) {
+	storetest.RunAudit(t, storetest.MongoAuditContainer(t))
+}
@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --- Outbox ---
+
+// OutboxMessage is an event waiting to be relayed. Its ID doubles as the
+// dedup key consumers use, since delivery is at least once.
+type OutboxMessage struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Event       Event              `json:"event" bson:"event"`
+	Attempts    int                `json:"attempts" bson:"attempts"`
+	NextAttempt time.Time          `json:"nextAttempt" bson:"nextAttempt"`
+	SentAt      *time.Time         `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+	Dead        bool               `json:"dead,omitempty" bson:"dead,omitempty"` // gave up retrying
+	LastError   string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+}
+
+// Outbox persists messages alongside the mutations they describe.
+//
+//   - Due leases up to limit unsent, live messages whose NextAttempt has
+//     passed, oldest first, by pushing their NextAttempt to now+lease, so
+//     concurrent dispatchers rarely send the same message twice.
+//   - MarkFailed counts an attempt and retries at retryAt; the zero time
+//     gives up on the message.
+//   - DeadLetters pages through the messages given up on, newest first;
+//     a zero limit means all of them.
+//   - Revive makes a dead message due at now with no attempts counted, or
+//     returns ErrNotFound if there's no such dead message.
+//   - PurgeDead deletes the given dead messages, or all of them when ids
+//     is empty, returning how many it deleted.
+//   - InTransaction runs fn so the writes made through its context commit
+//     together, where the backend supports it.
+type Outbox interface {
+	Add(ctx context.Context, m OutboxMessage) error
+	Due(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error)
+	MarkSent(ctx context.Context, id primitive.ObjectID) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID, retryAt time.Time, reason string) error
+	DeadLetters(ctx context.Context, offset, limit int) ([]OutboxMessage, error)
+	Revive(ctx context.Context, id primitive.ObjectID, now time.Time) error
+	PurgeDead(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithOutbox adds a message to o for every mutation of s, in the same
+// transaction when o supports one. Messages for creates and updates carry
+// the todo as written.
+func WithOutbox(s Store, o Outbox) Store {
+	return &outboxStore{inner: s, outbox: o}
+}
+
+type outboxStore struct {
+	inner  Store
+	outbox Outbox
+}
+
+// write runs mutate and, if it changed a todo, queues ev.
+func (o *outboxStore) write(ctx context.Context, ev Event, mutate func(ctx context.Context) (bool, error)) error {
+	ev.ID, ev.At, ev.Tenant = primitive.NewObjectID(), time.Now().UTC(), TenantFrom(ctx)
+	return o.outbox.InTransaction(ctx, func(ctx context.Context) error {
+		changed, err := mutate(ctx)
+		if err != nil || !changed {
+			return err
+		}
+		if ev.Type != EventDeleted {
+			todo, err := o.inner.Get(ctx, ev.TodoID)
+			if err != nil {
+				return err
+			}
+			ev.Todo = &todo
+		}
+		return o.outbox.Add(ctx, OutboxMessage{ID: ev.ID, Event: ev, NextAttempt: ev.At})
+	})
+}
+
+func (o *outboxStore) List(ctx context.Context, q Query) ([]Todo, error) {
+	return o.inner.List(ctx, q)
+}
+
+func (o *outboxStore) Count(ctx context.Context) (Counts, error) {
+	return CountTodos(ctx, o.inner)
+}
+
+func (o *outboxStore) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	return ReportCompletions(ctx, o.inner, group, from, to)
+}
+
+func (o *outboxStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	return ReportTagCounts(ctx, o.inner)
+}
+
+func (o *outboxStore) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	return stream(ctx, o.inner, q, fn)
+}
+
+func (o *outboxStore) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	return o.inner.Get(ctx, id)
+}
+
+func (o *outboxStore) Create(ctx context.Context, todo Todo) error {
+	return o.write(ctx, Event{TodoID: todo.ID, Type: EventCreated}, func(ctx context.Context) (bool, error) {
+		return true, o.inner.Create(ctx, todo)
+	})
+}
+
+func (o *outboxStore) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	return o.write(ctx, Event{TodoID: id, Type: updateEventType(u), Changes: &u}, func(ctx context.Context) (bool, error) {
+		return o.exists(ctx, id, func() error { return o.inner.Update(ctx, id, u) })
+	})
+}
+
+func (o *outboxStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return o.write(ctx, Event{TodoID: id, Type: EventDeleted}, func(ctx context.Context) (bool, error) {
+		return o.exists(ctx, id, func() error { return o.inner.Delete(ctx, id) })
+	})
+}
+
+// exists runs mutate when id exists, reporting whether it did.
+func (o *outboxStore) exists(ctx context.Context, id primitive.ObjectID, mutate func() error) (bool, error) {
+	if _, err := o.inner.Get(ctx, id); errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, mutate()
+}
+
+// MongoOutbox stores messages in their own collection, e.g. outbox.
+type MongoOutbox struct {
+	coll         *mongo.Collection
+	transactions bool
+}
+
+// NewMongoOutbox returns an outbox in coll. With transactions, mutations
+// and their messages commit atomically; that needs a replica set (Cosmos
+// DB qualifies). Without, the message is written right after the
+// mutation and is lost if the process dies in between.
+func NewMongoOutbox(coll *mongo.Collection, transactions bool) *MongoOutbox {
+	return &MongoOutbox{coll: coll, transactions: transactions}
+}
+
+// EnsureIndexes creates the index serving Due.
+func (m *MongoOutbox) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sentAt", Value: 1}, {Key: "dead", Value: 1}, {Key: "nextAttempt", Value: 1}},
+	})
+	return err
+}
+
+func (m *MongoOutbox) Add(ctx context.Context, msg OutboxMessage) error {
+	_, err := m.coll.InsertOne(ctx, msg)
+	return err
+}
+
+func (m *MongoOutbox) Due(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	filter := bson.M{"sentAt": nil, "dead": bson.M{"$ne": true}, "nextAttempt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"nextAttempt": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "nextAttempt", Value: 1}, {Key: "_id", Value: 1}})
+
+	msgs := []OutboxMessage{}
+	for len(msgs) < limit {
+		var msg OutboxMessage
+		err := m.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&msg)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (m *MongoOutbox) MarkSent(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.coll.UpdateByID(ctx, id, bson.M{"$set": bson.M{"sentAt": time.Now().UTC()}, "$unset": bson.M{"lastError": ""}})
+	return err
+}
+
+func (m *MongoOutbox) MarkFailed(ctx context.Context, id primitive.ObjectID, retryAt time.Time, reason string) error {
+	set := bson.M{"lastError": reason, "nextAttempt": retryAt}
+	if retryAt.IsZero() {
+		set = bson.M{"lastError": reason, "dead": true}
+	}
+	_, err := m.coll.UpdateByID(ctx, id, bson.M{"$set": set, "$inc": bson.M{"attempts": 1}})
+	return err
+}
+
+func (m *MongoOutbox) DeadLetters(ctx context.Context, offset, limit int) ([]OutboxMessage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetSkip(int64(offset))
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := m.coll.Find(ctx, bson.M{"dead": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []OutboxMessage{}
+	if err := cursor.All(ctx, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (m *MongoOutbox) Revive(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	res, err := m.coll.UpdateOne(ctx, bson.M{"_id": id, "dead": true}, bson.M{
+		"$set":   bson.M{"attempts": 0, "nextAttempt": now},
+		"$unset": bson.M{"dead": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MongoOutbox) PurgeDead(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	filter := bson.M{"dead": true}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+	res, err := m.coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (m *MongoOutbox) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.transactions {
+		return fn(ctx)
+	}
+	return inMongoTransaction(ctx, m.coll.Database().Client(), fn)
+}
+
+// MemoryOutbox is an in-process Outbox, safe for concurrent use. It has no
+// transactions.
+type MemoryOutbox struct {
+	mu   sync.Mutex
+	msgs map[primitive.ObjectID]OutboxMessage
+}
+
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{msgs: map[primitive.ObjectID]OutboxMessage{}}
+}
+
+func (m *MemoryOutbox) Add(ctx context.Context, msg OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.msgs[msg.ID] = msg
+	return nil
+}
+
+func (m *MemoryOutbox) Due(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := []OutboxMessage{}
+	for _, msg := range m.msgs {
+		if msg.SentAt == nil && !msg.Dead && !msg.NextAttempt.After(now) {
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if !msgs[i].NextAttempt.Equal(msgs[j].NextAttempt) {
+			return msgs[i].NextAttempt.Before(msgs[j].NextAttempt)
+		}
+		return msgs[i].ID.Hex() < msgs[j].ID.Hex()
+	})
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	for _, msg := range msgs {
+		msg.NextAttempt = now.Add(lease)
+		m.msgs[msg.ID] = msg
+	}
+	return msgs, nil
+}
+
+func (m *MemoryOutbox) MarkSent(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if msg, ok := m.msgs[id]; ok {
+		now := time.Now().UTC()
+		msg.SentAt, msg.LastError = &now, ""
+		m.msgs[id] = msg
+	}
+	return nil
+}
+
+func (m *MemoryOutbox) MarkFailed(ctx context.Context, id primitive.ObjectID, retryAt time.Time, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if msg, ok := m.msgs[id]; ok {
+		msg.Attempts++
+		msg.LastError = reason
+		if retryAt.IsZero() {
+			msg.Dead = true
+		} else {
+			msg.NextAttempt = retryAt
+		}
+		m.msgs[id] = msg
+	}
+	return nil
+}
+
+func (m *MemoryOutbox) DeadLetters(ctx context.Context, offset, limit int) ([]OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := []OutboxMessage{}
+	for _, msg := range m.msgs {
+		if msg.Dead {
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID.Hex() > msgs[j].ID.Hex() })
+	if offset > len(msgs) {
+		offset = len(msgs)
+	}
+	msgs = msgs[offset:]
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+func (m *MemoryOutbox) Revive(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, ok := m.msgs[id]
+	if !ok || !msg.Dead {
+		return ErrNotFound
+	}
+	msg.Dead, msg.Attempts, msg.NextAttempt = false, 0, now
+	m.msgs[id] = msg
+	return nil
+}
+
+func (m *MemoryOutbox) PurgeDead(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	only := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		only[id] = true
+	}
+	var n int64
+	for id, msg := range m.msgs {
+		if msg.Dead && (len(only) == 0 || only[id]) {
+			delete(m.msgs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryOutbox) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
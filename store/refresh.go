@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRefreshTokenNotFound is returned for unknown or expired refresh tokens.
+var ErrRefreshTokenNotFound = errors.New("store: refresh token not found")
+
+// --- Refresh Tokens ---
+
+// RefreshToken is a long-lived credential traded for a new session. Only a
+// hash of the token is stored. Every rotation issues a token of the same
+// family, so presenting a used one again reveals a stolen token and
+// revokes the family.
+type RefreshToken struct {
+	ID        string     `json:"id" bson:"_id"` // hash of the token
+	Family    string     `json:"family" bson:"family"`
+	Subject   string     `json:"subject" bson:"subject"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+	Revoked   bool       `json:"revoked,omitempty" bson:"revoked,omitempty"`
+}
+
+// RefreshTokenStore persists refresh tokens. Its contract is verified by
+// storetest.RunRefreshTokens:
+//
+//   - GetRefreshToken and UseRefreshToken return ErrRefreshTokenNotFound
+//     for unknown IDs and tokens past ExpiresAt.
+//   - UseRefreshToken marks the token used at now and returns it as it was
+//     before, so a nil UsedAt means this is its first use.
+//   - RevokeRefreshFamily revokes every token of the family.
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, t RefreshToken) error
+	GetRefreshToken(ctx context.Context, id string, now time.Time) (RefreshToken, error)
+	UseRefreshToken(ctx context.Context, id string, now time.Time) (RefreshToken, error)
+	RevokeRefreshFamily(ctx context.Context, family string) error
+}
+
+// MongoRefreshTokens stores refresh tokens in their own collection.
+type MongoRefreshTokens struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRefreshTokens(coll *mongo.Collection) *MongoRefreshTokens {
+	return &MongoRefreshTokens{coll: coll}
+}
+
+// EnsureIndexes expires tokens from the collection and indexes families.
+func (m *MongoRefreshTokens) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "family", Value: 1}}},
+	})
+	return err
+}
+
+func (m *MongoRefreshTokens) CreateRefreshToken(ctx context.Context, t RefreshToken) error {
+	_, err := m.coll.InsertOne(ctx, t)
+	return err
+}
+
+func (m *MongoRefreshTokens) GetRefreshToken(ctx context.Context, id string, now time.Time) (RefreshToken, error) {
+	var t RefreshToken
+	err := m.coll.FindOne(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": now}}).Decode(&t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return t, ErrRefreshTokenNotFound
+	}
+	return t, err
+}
+
+func (m *MongoRefreshTokens) UseRefreshToken(ctx context.Context, id string, now time.Time) (RefreshToken, error) {
+	var t RefreshToken
+	err := m.coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"usedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return t, ErrRefreshTokenNotFound
+	}
+	return t, err
+}
+
+func (m *MongoRefreshTokens) RevokeRefreshFamily(ctx context.Context, family string) error {
+	_, err := m.coll.UpdateMany(ctx, bson.M{"family": family}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+// MemoryRefreshTokens is an in-process RefreshTokenStore, safe for
+// concurrent use.
+type MemoryRefreshTokens struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+}
+
+func NewMemoryRefreshTokens() *MemoryRefreshTokens {
+	return &MemoryRefreshTokens{tokens: map[string]RefreshToken{}}
+}
+
+func (m *MemoryRefreshTokens) CreateRefreshToken(ctx context.Context, t RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *MemoryRefreshTokens) GetRefreshToken(ctx context.Context, id string, now time.Time) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[id]
+	if !ok || !t.ExpiresAt.After(now) {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	return t, nil
+}
+
+func (m *MemoryRefreshTokens) UseRefreshToken(ctx context.Context, id string, now time.Time) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[id]
+	if !ok || !t.ExpiresAt.After(now) {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	used := t
+	used.UsedAt = &now
+	m.tokens[id] = used
+	return t, nil
+}
+
+func (m *MemoryRefreshTokens) RevokeRefreshFamily(ctx context.Context, family string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, t := range m.tokens {
+		if t.Family == family {
+			t.Revoked = true
+			m.tokens[id] = t
+		}
+	}
+	return nil
+}
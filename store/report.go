@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// --- Reports ---
+
+// Report groupings of completion times, by UTC day or ISO week.
+const (
+	GroupDay  = "day"
+	GroupWeek = "week"
+)
+
+// Period names the day ("2006-01-02") or ISO week ("2006-W01") t falls
+// in, in UTC.
+func Period(group string, t time.Time) string {
+	t = t.UTC()
+	if group == GroupWeek {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	}
+	return t.Format("2006-01-02")
+}
+
+// TagCount is how many todos carry a tag, and how many of those are done.
+type TagCount struct {
+	Tag       string `json:"tag" bson:"_id"`
+	Total     int    `json:"total" bson:"total"`
+	Completed int    `json:"completed" bson:"completed"`
+}
+
+// Reporter is implemented by stores that can aggregate reports without
+// loading the todos.
+type Reporter interface {
+	// Completions counts the todos completed in [from, to) by Period.
+	Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error)
+	// TagCounts counts the todos of each tag, most used first.
+	TagCounts(ctx context.Context) ([]TagCount, error)
+}
+
+// ReportCompletions runs Completions on s when it is a Reporter, or over
+// its streamed todos.
+func ReportCompletions(ctx context.Context, s Store, group string, from, to time.Time) (map[string]int, error) {
+	if r, ok := s.(Reporter); ok {
+		return r.Completions(ctx, group, from, to)
+	}
+	done := true
+	periods := map[string]int{}
+	err := stream(ctx, s, Query{Completed: &done, Fields: []string{"completedAt"}}, func(todo Todo) error {
+		if at := todo.CompletedAt; at != nil && !at.Before(from) && at.Before(to) {
+			periods[Period(group, *at)]++
+		}
+		return nil
+	})
+	return periods, err
+}
+
+// ReportTagCounts runs TagCounts on s when it is a Reporter, or over its
+// streamed todos.
+func ReportTagCounts(ctx context.Context, s Store) ([]TagCount, error) {
+	if r, ok := s.(Reporter); ok {
+		return r.TagCounts(ctx)
+	}
+	byTag := map[string]*TagCount{}
+	err := stream(ctx, s, Query{Fields: []string{"tags", "completed"}}, func(todo Todo) error {
+		for _, tag := range todo.Tags {
+			c := byTag[tag]
+			if c == nil {
+				c = &TagCount{Tag: tag}
+				byTag[tag] = c
+			}
+			c.Total++
+			if todo.Completed {
+				c.Completed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]TagCount, 0, len(byTag))
+	for _, c := range byTag {
+		counts = append(counts, *c)
+	}
+	sortTagCounts(counts)
+	return counts, nil
+}
+
+func sortTagCounts(counts []TagCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Total != counts[j].Total {
+			return counts[i].Total > counts[j].Total
+		}
+		return counts[i].Tag < counts[j].Tag
+	})
+}
+
+// periodFormats are the $dateToString formats matching Period.
+var periodFormats = map[string]string{
+	GroupDay:  "%Y-%m-%d",
+	GroupWeek: "%G-W%V",
+}
+
+func (m *Mongo) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	var groups []struct {
+		Period string `bson:"_id"`
+		N      int    `bson:"n"`
+	}
+	if err := m.aggregate(ctx, &groups, bson.A{
+		bson.M{"$match": bson.M{"completed": true, "completedAt": bson.M{"$gte": from, "$lt": to}}},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{"$dateToString": bson.M{"format": periodFormats[group], "date": "$completedAt"}},
+			"n":   bson.M{"$sum": 1},
+		}},
+	}); err != nil {
+		return nil, err
+	}
+	periods := make(map[string]int, len(groups))
+	for _, g := range groups {
+		periods[g.Period] = g.N
+	}
+	return periods, nil
+}
+
+func (m *Mongo) TagCounts(ctx context.Context) ([]TagCount, error) {
+	var counts []TagCount
+	if err := m.aggregate(ctx, &counts, bson.A{
+		bson.M{"$unwind": "$tags"},
+		bson.M{"$group": bson.M{
+			"_id":       "$tags",
+			"total":     bson.M{"$sum": 1},
+			"completed": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$completed", true}}, 1, 0}}},
+		}},
+	}); err != nil {
+		return nil, err
+	}
+	sortTagCounts(counts)
+	return counts, nil
+}
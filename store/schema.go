@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TodoSchema is the $jsonSchema validator attached to the todos collection.
+// Unknown properties are allowed so older binaries keep working while new
+// fields are rolled out.
+var TodoSchema = bson.M{
+	"bsonType": "object",
+	"required": bson.A{"title", "completed", "createdAt"},
+	"properties": bson.M{
+		"_id":             bson.M{"bsonType": "objectId"},
+		"title":           bson.M{"bsonType": "string", "minLength": 1, "description": "must be a non-empty string"},
+		"description":     bson.M{"bsonType": "string", "description": "must be a string"},
+		"tags":            bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}, "description": "must be an array of strings"},
+		"priority":        bson.M{"enum": bson.A{"", "low", "medium", "high"}, "description": "must be low, medium or high"},
+		"color":           bson.M{"bsonType": "string", "pattern": colorPattern.String(), "description": colorRule},
+		"blockedBy":       bson.M{"bsonType": "array", "items": bson.M{"bsonType": "objectId"}, "description": "must be an array of todo IDs"},
+		"status":          bson.M{"enum": bson.A{"", "todo", "in-progress", "done"}, "description": "must be todo, in-progress or done"},
+		"pomodoros":       bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0, "description": "must be a non-negative integer"},
+		"snoozes":         bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0, "description": "must be a non-negative integer"},
+		"location":        locationSchema,
+		"dueAt":           bson.M{"bsonType": "date", "description": "must be a date"},
+		"estimateMinutes": bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0, "description": "must be a non-negative integer"},
+		"progressPercent": bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 0, "maximum": 100, "description": "must be an integer from 0 to 100"},
+		"calendarEventId": bson.M{"bsonType": "string", "description": "must be a string"},
+		"custom":          bson.M{"bsonType": "object", "description": "must be an object"},
+		"pinned":          bson.M{"bsonType": "bool", "description": "must be a boolean"},
+		"myDay":           bson.M{"bsonType": "bool", "description": "must be a boolean"},
+		"completed":       bson.M{"bsonType": "bool", "description": "must be a boolean"},
+		"completedAt":     bson.M{"bsonType": "date", "description": "must be a date"},
+		"createdAt":       bson.M{"bsonType": "date", "description": "must be a date"},
+	},
+}
+
+// locationSchema validates a store.Location.
+var locationSchema = bson.M{
+	"bsonType": "object",
+	"required": bson.A{"type", "coordinates"},
+	"properties": bson.M{
+		"type":        bson.M{"enum": bson.A{"Point"}},
+		"coordinates": bson.M{"bsonType": "array", "minItems": 2, "maxItems": 2, "items": bson.M{"bsonType": "double"}},
+		"label":       bson.M{"bsonType": "string"},
+	},
+	"description": "must be a GeoJSON point",
+}
+
+// Mongo error codes used when applying and enforcing validators.
+const (
+	codeNamespaceNotFound         = 26
+	codeDocumentValidationFailure = 121
+)
+
+// ApplySchema attaches schema as the validator of the named collection,
+// creating the collection if it doesn't exist yet. In strict mode invalid
+// writes are rejected; otherwise MongoDB only logs a warning for them.
+func ApplySchema(ctx context.Context, db *mongo.Database, name string, schema bson.M, strict bool) error {
+	action := "warn"
+	if strict {
+		action = "error"
+	}
+
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: name},
+		{Key: "validator", Value: bson.M{"$jsonSchema": schema}},
+		{Key: "validationLevel", Value: "strict"},
+		{Key: "validationAction", Value: action},
+	}).Err()
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == codeNamespaceNotFound {
+		return db.RunCommand(ctx, bson.D{
+			{Key: "create", Value: name},
+			{Key: "validator", Value: bson.M{"$jsonSchema": schema}},
+			{Key: "validationLevel", Value: "strict"},
+			{Key: "validationAction", Value: action},
+		}).Err()
+	}
+	return err
+}
+
+// --- Validation Errors ---
+
+// ValidationError is returned by writes rejected by a collection validator.
+// Fields maps property names to the rule they failed.
+type ValidationError struct {
+	Fields map[string]string `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for field, reason := range e.Fields {
+		parts = append(parts, field+": "+reason)
+	}
+	return fmt.Sprintf("store: document failed validation (%s)", strings.Join(parts, "; "))
+}
+
+// Is makes every ValidationError match ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// validationError converts a DocumentValidationFailure write error into a
+// *ValidationError and a duplicate key into ErrConflict, and returns any
+// other error unchanged.
+func validationError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	var we mongo.WriteException
+	if !errors.As(err, &we) {
+		return err
+	}
+	for _, e := range we.WriteErrors {
+		if e.Code != codeDocumentValidationFailure {
+			continue
+		}
+		verr := &ValidationError{Fields: map[string]string{}}
+		var info struct {
+			Details struct {
+				Rules []schemaRule `bson:"schemaRulesNotSatisfied"`
+			} `bson:"details"`
+		}
+		if e.Details != nil && bson.Unmarshal(e.Details, &info) == nil {
+			for _, rule := range info.Details.Rules {
+				rule.collect(verr.Fields)
+			}
+		}
+		if len(verr.Fields) == 0 {
+			verr.Fields["document"] = "failed schema validation"
+		}
+		return verr
+	}
+	return err
+}
+
+// schemaRule mirrors the errInfo layout MongoDB 5.0+ reports for
+// $jsonSchema failures.
+type schemaRule struct {
+	Operator   string   `bson:"operatorName"`
+	Missing    []string `bson:"missingProperties"`
+	Properties []struct {
+		Name    string `bson:"propertyName"`
+		Details []struct {
+			Operator string `bson:"operatorName"`
+			Reason   string `bson:"reason"`
+		} `bson:"details"`
+	} `bson:"propertiesNotSatisfied"`
+}
+
+func (r schemaRule) collect(fields map[string]string) {
+	for _, name := range r.Missing {
+		fields[name] = "is required"
+	}
+	for _, prop := range r.Properties {
+		reason := "is invalid"
+		if len(prop.Details) > 0 {
+			reason = prop.Details[0].Operator
+			if prop.Details[0].Reason != "" {
+				reason += ": " + prop.Details[0].Reason
+			}
+		}
+		fields[prop.Name] = reason
+	}
+}
@@ -0,0 +1,581 @@
+// Package store defines the persistence contract for todos and the
+// backends that implement it.
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Kinds of failure, matched with errors.Is whatever the error's type.
+var (
+	// ErrNotFound is returned by Get when no todo exists for the given ID.
+	ErrNotFound = errors.New("store: todo not found")
+	// ErrValidation is matched by every *ValidationError.
+	ErrValidation = errors.New("store: validation failed")
+	// ErrConflict is returned by Create when a todo with the ID exists.
+	ErrConflict = errors.New("store: todo already exists")
+)
+
+// --- Models ---
+
+type Todo struct {
+	ID          primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Title       string                 `json:"title" bson:"title"`
+	Description string                 `json:"description,omitempty" bson:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty" bson:"tags,omitempty"`
+	Priority    string                 `json:"priority,omitempty" bson:"priority,omitempty"`
+	Color       string                 `json:"color,omitempty" bson:"color,omitempty"`         // see ValidColor
+	BlockedBy   []primitive.ObjectID   `json:"blockedBy,omitempty" bson:"blockedBy,omitempty"` // must be completed first
+	Status      string                 `json:"status,omitempty" bson:"status,omitempty"`
+	Pomodoros   int                    `json:"pomodoros,omitempty" bson:"pomodoros,omitempty"` // completed focus sessions
+	Snoozes     int                    `json:"snoozes,omitempty" bson:"snoozes,omitempty"`     // times the due date was pushed back
+	Location    *Location              `json:"location,omitempty" bson:"location,omitempty"`
+	DueAt       *time.Time             `json:"dueAt,omitempty" bson:"dueAt,omitempty"`
+	Estimate    int                    `json:"estimateMinutes,omitempty" bson:"estimateMinutes,omitempty"` // expected effort in minutes
+	Progress    int                    `json:"progressPercent,omitempty" bson:"progressPercent,omitempty"` // 0 to 100
+	CalendarID  string                 `json:"calendarEventId,omitempty" bson:"calendarEventId,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty" bson:"custom,omitempty"` // custom field values: strings and float64 numbers
+	Pinned      bool                   `json:"pinned,omitempty" bson:"pinned,omitempty"` // listed before unpinned todos
+	MyDay       bool                   `json:"myDay,omitempty" bson:"myDay,omitempty"`   // planned for today; cleared nightly
+	Completed   bool                   `json:"completed" bson:"completed"`
+	CompletedAt *time.Time             `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// Priorities are the valid values of Todo.Priority, lowest first. The
+// empty string means no priority.
+var Priorities = []string{"low", "medium", "high"}
+
+// ValidPriority reports whether p is empty or one of Priorities.
+func ValidPriority(p string) bool {
+	if p == "" {
+		return true
+	}
+	for _, v := range Priorities {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Colors is the named palette of Todo.Color, which may also be a
+// lowercase hex color such as #1e90ff or #f80.
+var Colors = []string{"red", "orange", "yellow", "green", "blue", "purple", "pink", "gray"}
+
+// colorPattern matches the colors ValidColor accepts.
+var colorPattern = regexp.MustCompile(`^(#[0-9a-f]{3}|#[0-9a-f]{6}|` + strings.Join(Colors, "|") + `)$`)
+
+// colorRule describes valid colors in validation errors.
+var colorRule = "must be " + strings.Join(Colors, ", ") + " or a hex color like #1e90ff"
+
+// ValidColor reports whether c is empty, a named color of Colors or a
+// lowercase #rgb or #rrggbb hex color.
+func ValidColor(c string) bool {
+	return c == "" || colorPattern.MatchString(c)
+}
+
+// Workflow statuses of Todo.Status, in board order. They are tracked
+// separately from Completed.
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in-progress"
+	StatusDone       = "done"
+)
+
+// Statuses are the valid values of Todo.Status. The empty string, stored
+// by todos that predate statuses, reads as StatusTodo.
+var Statuses = []string{StatusTodo, StatusInProgress, StatusDone}
+
+// ValidStatus reports whether s is empty or one of Statuses.
+func ValidStatus(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, v := range Statuses {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentStatus returns the todo's status, StatusTodo when unset.
+func (t Todo) CurrentStatus() string {
+	if t.Status == "" {
+		return StatusTodo
+	}
+	return t.Status
+}
+
+// Fields lists the projectable todo fields by their JSON/BSON name.
+var Fields = []string{"title", "description", "tags", "priority", "color", "blockedBy", "status", "pomodoros", "snoozes", "location", "dueAt", "estimateMinutes", "progressPercent", "calendarEventId", "custom", "pinned", "myDay", "completed", "completedAt", "createdAt"}
+
+// LeanFields are the fields list views need to render a row. Bulky fields
+// are left out so they aren't fetched and serialized for every list render.
+var LeanFields = []string{"title", "tags", "priority", "color", "blockedBy", "status", "pomodoros", "snoozes", "location", "dueAt", "estimateMinutes", "progressPercent", "calendarEventId", "custom", "pinned", "myDay", "completed", "completedAt", "createdAt"}
+
+// ValidField reports whether name is a projectable field.
+func ValidField(name string) bool {
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// project zeroes every field not named in fields, mirroring a Mongo
+// projection.
+func project(todo Todo, fields []string) Todo {
+	if len(fields) == 0 {
+		return todo
+	}
+	out := Todo{ID: todo.ID}
+	for _, f := range fields {
+		switch f {
+		case "title":
+			out.Title = todo.Title
+		case "description":
+			out.Description = todo.Description
+		case "tags":
+			out.Tags = todo.Tags
+		case "priority":
+			out.Priority = todo.Priority
+		case "color":
+			out.Color = todo.Color
+		case "blockedBy":
+			out.BlockedBy = todo.BlockedBy
+		case "status":
+			out.Status = todo.Status
+		case "pomodoros":
+			out.Pomodoros = todo.Pomodoros
+		case "snoozes":
+			out.Snoozes = todo.Snoozes
+		case "location":
+			out.Location = todo.Location
+		case "dueAt":
+			out.DueAt = todo.DueAt
+		case "estimateMinutes":
+			out.Estimate = todo.Estimate
+		case "progressPercent":
+			out.Progress = todo.Progress
+		case "calendarEventId":
+			out.CalendarID = todo.CalendarID
+		case "custom":
+			out.Custom = todo.Custom
+		case "pinned":
+			out.Pinned = todo.Pinned
+		case "myDay":
+			out.MyDay = todo.MyDay
+		case "completed":
+			out.Completed = todo.Completed
+		case "completedAt":
+			out.CompletedAt = todo.CompletedAt
+		case "createdAt":
+			out.CreatedAt = todo.CreatedAt
+		}
+	}
+	return out
+}
+
+// Query narrows what List and Stream return.
+type Query struct {
+	// Fields limits the returned fields to the named ones; ID is always
+	// returned. Empty means all fields.
+	Fields []string
+
+	// IDs restricts the result to the given todos; empty means all.
+	IDs []primitive.ObjectID
+
+	// Completed, when set, keeps only todos with that completion state.
+	Completed *bool
+
+	// Color, when set, keeps only todos labeled with that color.
+	Color string
+
+	// Near, when set, keeps only todos located within the circle.
+	Near *Circle
+
+	// Title, when set, keeps only todos whose TitleKey is the title's.
+	// TitleStart keeps only those whose TitleKey starts with its own, and
+	// TitlePrefix those with a word of the TitleKey doing so, the first
+	// word included.
+	Title       string
+	TitleStart  string
+	TitlePrefix string
+
+	// MaxEstimate, when set, keeps only todos estimated at most that many
+	// minutes; MinProgress only those at least that far along.
+	MaxEstimate int
+	MinProgress int
+
+	// Sort orders the result by one of Sorts instead of List order, which
+	// still breaks ties.
+	Sort string
+
+	// Offset skips that many todos of the ordered result and Limit caps how
+	// many are returned; zero means no limit.
+	Offset int
+	Limit  int
+}
+
+// matches reports whether todo passes the query's filters; the in-memory
+// counterpart of the Mongo filter.
+func (q Query) matches(todo Todo) bool {
+	if q.Completed != nil && todo.Completed != *q.Completed {
+		return false
+	}
+	if q.Color != "" && todo.Color != q.Color {
+		return false
+	}
+	if q.Near != nil && !q.Near.Contains(todo.Location) {
+		return false
+	}
+	if q.MaxEstimate > 0 && (todo.Estimate == 0 || todo.Estimate > q.MaxEstimate) {
+		return false
+	}
+	if q.MinProgress > 0 && todo.Progress < q.MinProgress {
+		return false
+	}
+	if q.Title != "" && TitleKey(todo.Title) != TitleKey(q.Title) {
+		return false
+	}
+	if q.TitleStart != "" && !strings.HasPrefix(TitleKey(todo.Title), TitleKey(q.TitleStart)) {
+		return false
+	}
+	if q.TitlePrefix != "" && !titleHasPrefix(todo.Title, q.TitlePrefix) {
+		return false
+	}
+	if len(q.IDs) == 0 {
+		return true
+	}
+	for _, id := range q.IDs {
+		if id == todo.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// filtersTitle reports whether the query filters on titles, which the
+// store can't do for titles encrypted at rest.
+func (q Query) filtersTitle() bool {
+	return q.Title != "" || q.TitleStart != "" || q.TitlePrefix != ""
+}
+
+// TitleKey is the form titles are compared in: NFC, lower case, with runs
+// of spaces as one, so "Buy  milk" and "buy milk" are the same title.
+func TitleKey(title string) string {
+	return strings.Join(strings.Fields(strings.ToLower(norm.NFC.String(title))), " ")
+}
+
+// titleWordBytes caps the TitleWords entries, keeping long titles' index
+// entries small. Longer prefixes are matched on their first bytes, then
+// checked against the whole TitleKey.
+const titleWordBytes = 64
+
+// TitleWords are the suffixes of the title's TitleKey starting at each of
+// its words, at most titleWordBytes long, which TitlePrefix matches.
+func TitleWords(title string) []string {
+	key := TitleKey(title)
+	var words []string
+	for i := 0; i < len(key); i++ {
+		if i == 0 || key[i-1] == ' ' {
+			words = append(words, truncateKey(key[i:]))
+		}
+	}
+	return words
+}
+
+// truncateKey cuts key to titleWordBytes, on a character boundary.
+func truncateKey(key string) string {
+	if len(key) <= titleWordBytes {
+		return key
+	}
+	end := titleWordBytes
+	for end > 0 && !utf8.RuneStart(key[end]) {
+		end--
+	}
+	return key[:end]
+}
+
+// titleHasPrefix reports whether a word of title's TitleKey starts with
+// prefix's.
+func titleHasPrefix(title, prefix string) bool {
+	key, prefix := TitleKey(title), TitleKey(prefix)
+	return strings.HasPrefix(key, prefix) || strings.Contains(key, " "+prefix)
+}
+
+// Sorts are the valid values of Query.Sort: a field, ascending, or a
+// field prefixed with "-", descending. Todos without an estimate sort last
+// either way.
+var Sorts = []string{"estimateMinutes", "-estimateMinutes", "progressPercent", "-progressPercent"}
+
+// ValidSort reports whether s is empty or one of Sorts.
+func ValidSort(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, v := range Sorts {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// listedBefore reports whether a comes before b in List order: pinned
+// todos first, then newest first.
+func listedBefore(a, b Todo) bool {
+	if a.Pinned != b.Pinned {
+		return a.Pinned
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+// sortedBefore reports whether a comes before b in the query's order.
+func (q Query) sortedBefore(a, b Todo) bool {
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "estimateMinutes":
+		if (a.Estimate == 0) != (b.Estimate == 0) {
+			return b.Estimate == 0
+		}
+		if a.Estimate != b.Estimate {
+			return (a.Estimate < b.Estimate) != strings.HasPrefix(q.Sort, "-")
+		}
+	case "progressPercent":
+		if a.Progress != b.Progress {
+			return (a.Progress < b.Progress) != strings.HasPrefix(q.Sort, "-")
+		}
+	}
+	return listedBefore(a, b)
+}
+
+// paginate applies the query's Offset and Limit to an ordered slice.
+func paginate(todos []Todo, q Query) []Todo {
+	if q.Offset > 0 {
+		if q.Offset >= len(todos) {
+			return []Todo{}
+		}
+		todos = todos[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(todos) {
+		todos = todos[:q.Limit]
+	}
+	return todos
+}
+
+// Update describes a partial update; nil fields are left untouched. The
+// tags are used when an update is recorded as an Event.
+type Update struct {
+	Title       *string                `json:"title,omitempty" bson:"title,omitempty"`
+	Description *string                `json:"description,omitempty" bson:"description,omitempty"`
+	Tags        *[]string              `json:"tags,omitempty" bson:"tags,omitempty"`
+	Priority    *string                `json:"priority,omitempty" bson:"priority,omitempty"`
+	Color       *string                `json:"color,omitempty" bson:"color,omitempty"` // "" removes it
+	BlockedBy   *[]primitive.ObjectID  `json:"blockedBy,omitempty" bson:"blockedBy,omitempty"`
+	Status      *string                `json:"status,omitempty" bson:"status,omitempty"`
+	Location    *Location              `json:"location,omitempty" bson:"location,omitempty"` // an empty Location removes it
+	DueAt       *time.Time             `json:"dueAt,omitempty" bson:"dueAt,omitempty"`       // the zero time removes it
+	Estimate    *int                   `json:"estimateMinutes,omitempty" bson:"estimateMinutes,omitempty"`
+	Progress    *int                   `json:"progressPercent,omitempty" bson:"progressPercent,omitempty"`
+	CalendarID  *string                `json:"calendarEventId,omitempty" bson:"calendarEventId,omitempty"`
+	Custom      map[string]interface{} `json:"custom,omitempty" bson:"custom,omitempty"` // merged into Todo.Custom; a nil value removes the field
+	Pinned      *bool                  `json:"pinned,omitempty" bson:"pinned,omitempty"`
+	MyDay       *bool                  `json:"myDay,omitempty" bson:"myDay,omitempty"`
+	Completed   *bool                  `json:"completed,omitempty" bson:"completed,omitempty"`
+	CompletedAt *time.Time             `json:"completedAt,omitempty" bson:"completedAt,omitempty"` // the zero time removes it
+
+	// AddPomodoros and AddSnoozes are added to the stored counts rather
+	// than replacing them, so concurrent increments aren't lost.
+	AddPomodoros int `json:"addPomodoros,omitempty" bson:"addPomodoros,omitempty"`
+	AddSnoozes   int `json:"addSnoozes,omitempty" bson:"addSnoozes,omitempty"`
+}
+
+// apply copies the set fields of u onto todo.
+func (u Update) apply(todo *Todo) {
+	if u.Title != nil {
+		todo.Title = *u.Title
+	}
+	if u.Description != nil {
+		todo.Description = *u.Description
+	}
+	if u.Tags != nil {
+		todo.Tags = *u.Tags
+	}
+	if u.Priority != nil {
+		todo.Priority = *u.Priority
+	}
+	if u.Color != nil {
+		todo.Color = *u.Color
+	}
+	if u.BlockedBy != nil {
+		todo.BlockedBy = *u.BlockedBy
+	}
+	if u.Status != nil {
+		todo.Status = *u.Status
+	}
+	if u.Location != nil {
+		todo.Location = nil
+		if u.Location.Valid() {
+			todo.Location = u.Location
+		}
+	}
+	if u.DueAt != nil {
+		todo.DueAt = nil
+		if !u.DueAt.IsZero() {
+			todo.DueAt = u.DueAt
+		}
+	}
+	if u.Estimate != nil {
+		todo.Estimate = *u.Estimate
+	}
+	if u.Progress != nil {
+		todo.Progress = *u.Progress
+	}
+	if u.CalendarID != nil {
+		todo.CalendarID = *u.CalendarID
+	}
+	if len(u.Custom) > 0 {
+		// Copied, as stored todos share their map with those handed out
+		custom := map[string]interface{}{}
+		for k, v := range todo.Custom {
+			custom[k] = v
+		}
+		for k, v := range u.Custom {
+			if v == nil {
+				delete(custom, k)
+			} else {
+				custom[k] = v
+			}
+		}
+		todo.Custom = custom
+		if len(custom) == 0 {
+			todo.Custom = nil
+		}
+	}
+	if u.Pinned != nil {
+		todo.Pinned = *u.Pinned
+	}
+	if u.MyDay != nil {
+		todo.MyDay = *u.MyDay
+	}
+	if u.Completed != nil {
+		todo.Completed = *u.Completed
+	}
+	if u.CompletedAt != nil {
+		todo.CompletedAt = nil
+		if !u.CompletedAt.IsZero() {
+			todo.CompletedAt = u.CompletedAt
+		}
+	}
+	todo.Pomodoros += u.AddPomodoros
+	todo.Snoozes += u.AddSnoozes
+}
+
+// --- Contract ---
+
+// Store is implemented by every todo backend. The behavioral contract is
+// verified by the storetest package:
+//
+//   - List returns all todos, pinned ones first and otherwise ordered by
+//     CreatedAt, newest first, and an empty (non-nil) slice when there
+//     are none. Fields left out of
+//     Query.Fields are returned as zero values; Query.IDs filters the
+//     result and Query.Offset and Query.Limit select a page of it.
+//   - Get returns ErrNotFound for unknown IDs.
+//   - Update and Delete on unknown IDs are no-ops and return nil.
+//   - Create and Update return a *ValidationError when the backend rejects
+//     the resulting document.
+type Store interface {
+	List(ctx context.Context, q Query) ([]Todo, error)
+	Get(ctx context.Context, id primitive.ObjectID) (Todo, error)
+	Create(ctx context.Context, todo Todo) error
+	Update(ctx context.Context, id primitive.ObjectID, u Update) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type freshReadsKey struct{}
+
+// WithFreshReads returns a context whose reads see every write already
+// acknowledged, e.g. by going to the primary rather than a secondary.
+func WithFreshReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshReadsKey{}, true)
+}
+
+// FreshReads reports whether ctx asks for fresh reads.
+func FreshReads(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshReadsKey{}).(bool)
+	return fresh
+}
+
+// Streamer is implemented by stores that can iterate todos in List order
+// without materializing the whole result set. fn is called once per todo;
+// returning an error stops the iteration and is passed back to the caller.
+type Streamer interface {
+	Stream(ctx context.Context, q Query, fn func(Todo) error) error
+}
+
+// Counts tallies todos by state and tag.
+type Counts struct {
+	Total     int            `json:"total"`
+	Completed int            `json:"completed"`
+	Tags      map[string]int `json:"tags"`
+}
+
+// Open is the number of todos not yet completed.
+func (c Counts) Open() int {
+	return c.Total - c.Completed
+}
+
+// Counter is implemented by stores that can count todos without loading
+// them.
+type Counter interface {
+	Count(ctx context.Context) (Counts, error)
+}
+
+// CountTodos counts s with Count when it is a Counter, or by streaming
+// its todos.
+func CountTodos(ctx context.Context, s Store) (Counts, error) {
+	if counter, ok := s.(Counter); ok {
+		return counter.Count(ctx)
+	}
+	c := Counts{Tags: map[string]int{}}
+	err := stream(ctx, s, Query{Fields: []string{"tags", "completed"}}, func(todo Todo) error {
+		c.Total++
+		if todo.Completed {
+			c.Completed++
+		}
+		for _, tag := range todo.Tags {
+			c.Tags[tag]++
+		}
+		return nil
+	})
+	return c, err
+}
+
+// stream iterates s with Stream when it is a Streamer, or from List.
+func stream(ctx context.Context, s Store, q Query, fn func(Todo) error) error {
+	if streamer, ok := s.(Streamer); ok {
+		return streamer.Stream(ctx, q, fn)
+	}
+	todos, err := s.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// AuditFactory returns an empty audit log for a single test.
+type AuditFactory func(t *testing.T) store.AuditLog
+
+// RunAudit executes the store.AuditLog contract cases.
+func RunAudit(t *testing.T, newLog AuditFactory) {
+	t.Helper()
+	for _, tc := range auditCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newLog(t))
+		})
+	}
+}
+
+var auditCases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, l store.AuditLog)
+}{
+	{"RecentNewestFirst", testAuditRecentNewestFirst},
+	{"RecentLimit", testAuditRecentLimit},
+}
+
+func recordAudit(ctx context.Context, t *testing.T, l store.AuditLog, actions ...string) {
+	t.Helper()
+	at := time.Now().UTC().Truncate(time.Millisecond)
+	for i, action := range actions {
+		e := store.AuditEntry{
+			ID:     primitive.NewObjectID(),
+			Actor:  "key:test",
+			Action: action,
+			At:     at.Add(time.Duration(i) * time.Second),
+		}
+		if err := l.RecordAudit(ctx, e); err != nil {
+			t.Fatalf("RecordAudit: %v", err)
+		}
+	}
+}
+
+func testAuditRecentNewestFirst(ctx context.Context, t *testing.T, l store.AuditLog) {
+	recordAudit(ctx, t, l, "first", "second", "third")
+	got, err := l.RecentAudit(ctx, 10)
+	if err != nil {
+		t.Fatalf("RecentAudit: %v", err)
+	}
+	if len(got) != 3 || got[0].Action != "third" || got[2].Action != "first" {
+		t.Errorf("RecentAudit = %+v, want third, second, first", got)
+	}
+}
+
+func testAuditRecentLimit(ctx context.Context, t *testing.T, l store.AuditLog) {
+	if got, err := l.RecentAudit(ctx, 10); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("RecentAudit on empty log = %v, %v, want an empty slice", got, err)
+	}
+	recordAudit(ctx, t, l, "first", "second", "third")
+	got, err := l.RecentAudit(ctx, 2)
+	if err != nil {
+		t.Fatalf("RecentAudit: %v", err)
+	}
+	if len(got) != 2 || got[0].Action != "third" {
+		t.Errorf("RecentAudit(2) = %+v, want the two newest", got)
+	}
+}
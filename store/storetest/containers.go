@@ -0,0 +1,113 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// MongoImage is the container image used by MongoContainer.
+var MongoImage = "mongo:6"
+
+// MongoContainer starts a throwaway MongoDB container via testcontainers for
+// the duration of t and returns a Factory handing out store.Mongo instances,
+// each backed by a fresh collection. It skips t when Docker is unavailable
+// or -short is set.
+func MongoContainer(t *testing.T) Factory {
+	t.Helper()
+	db := mongoContainer(t)
+	return func(t *testing.T) store.Store {
+		return store.NewMongo(freshCollection(t, db, "todos_"))
+	}
+}
+
+// MongoTemplateContainer is MongoContainer for store.MongoTemplates.
+func MongoTemplateContainer(t *testing.T) TemplateFactory {
+	t.Helper()
+	db := mongoContainer(t)
+	return func(t *testing.T) store.TemplateStore {
+		return store.NewMongoTemplates(freshCollection(t, db, "templates_"))
+	}
+}
+
+// MongoEventContainer is MongoContainer for store.MongoEvents.
+func MongoEventContainer(t *testing.T) EventFactory {
+	t.Helper()
+	db := mongoContainer(t)
+	return func(t *testing.T) store.EventLog {
+		return store.NewMongoEvents(freshCollection(t, db, "todo_events_"))
+	}
+}
+
+// MongoOutboxContainer is MongoContainer for store.MongoOutbox, without
+// transactions since the container is a standalone server.
+func MongoOutboxContainer(t *testing.T) OutboxFactory {
+	t.Helper()
+	db := mongoContainer(t)
+	return func(t *testing.T) store.Outbox {
+		return store.NewMongoOutbox(freshCollection(t, db, "outbox_"), false)
+	}
+}
+
+// MongoRefreshTokenContainer is MongoContainer for store.MongoRefreshTokens.
+func MongoRefreshTokenContainer(t *testing.T) RefreshTokenFactory {
+	t.Helper()
+	db := mongoContainer(t)
+	return func(t *testing.T) store.RefreshTokenStore {
+		return store.NewMongoRefreshTokens(freshCollection(t, db, "refresh_tokens_"))
+	}
+}
+
+// MongoAuditContainer is MongoContainer for store.MongoAudit.
+func MongoAuditContainer(t *testing.T) AuditFactory {
+	t.Helper()
+	db := mongoContainer(t)
+	return func(t *testing.T) store.AuditLog {
+		return store.NewMongoAudit(freshCollection(t, db, "audit_log_"))
+	}
+}
+
+func mongoContainer(t *testing.T) *mongo.Database {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping container-backed store in -short mode")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	container, err := mongodb.Run(ctx, MongoImage)
+	if err != nil {
+		t.Skipf("starting mongo container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Logf("terminating mongo container: %v", err)
+		}
+	})
+
+	uri, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("mongo connection string: %v", err)
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to mongo container: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	return client.Database("storetest")
+}
+
+func freshCollection(t *testing.T, db *mongo.Database, prefix string) *mongo.Collection {
+	coll := db.Collection(prefix + primitive.NewObjectID().Hex())
+	t.Cleanup(func() { coll.Drop(context.Background()) })
+	return coll
+}
@@ -0,0 +1,100 @@
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// RunEncryption executes the store.WithEncryption cases over stores from
+// newStore, which are inspected directly to check what's at rest.
+func RunEncryption(t *testing.T, newStore Factory) {
+	t.Helper()
+	for _, tc := range encryptionCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newStore(t))
+		})
+	}
+}
+
+var encryptionCases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, raw store.Store)
+}{
+	{"EncryptedAtRest", testEncryptedAtRest},
+	{"OldKeysDecrypt", testOldKeysDecrypt},
+	{"PlaintextPassesThrough", testPlaintextPassesThrough},
+}
+
+// testKeyring returns a keyring of the keys named by ids, encrypting with
+// the last.
+func testKeyring(t *testing.T, ids ...string) *store.Keyring {
+	t.Helper()
+	keys := map[string][]byte{}
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+	k, err := store.NewKeyring(keys, ids[len(ids)-1])
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func testEncryptedAtRest(ctx context.Context, t *testing.T, raw store.Store) {
+	s := store.WithEncryption(raw, testKeyring(t, "a"))
+	todo := newTodo("secret title", time.Now())
+	todo.Description = "secret description"
+	mustCreate(ctx, t, s, todo)
+	description := "new secret"
+	if err := s.Update(ctx, todo.ID, store.Update{Description: &description}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got := mustGet(ctx, t, s, todo.ID)
+	if got.Title != "secret title" || got.Description != "new secret" {
+		t.Errorf("decrypted = %q, %q", got.Title, got.Description)
+	}
+	stored := mustGet(ctx, t, raw, todo.ID)
+	for _, v := range []string{stored.Title, stored.Description} {
+		if id, ok := store.IsEncrypted(v); !ok || id != "a" || strings.Contains(v, "secret") {
+			t.Errorf("stored value %q is not encrypted with key a", v)
+		}
+	}
+}
+
+func testOldKeysDecrypt(ctx context.Context, t *testing.T, raw store.Store) {
+	old := newTodo("before rotation", time.Now())
+	mustCreate(ctx, t, store.WithEncryption(raw, testKeyring(t, "a")), old)
+
+	s := store.WithEncryption(raw, testKeyring(t, "a", "b"))
+	if got := mustGet(ctx, t, s, old.ID); got.Title != "before rotation" {
+		t.Errorf("title = %q after rotation", got.Title)
+	}
+	todo := newTodo("after rotation", time.Now())
+	mustCreate(ctx, t, s, todo)
+	if id, _ := store.IsEncrypted(mustGet(ctx, t, raw, todo.ID).Title); id != "b" {
+		t.Errorf("new title encrypted with key %q, want b", id)
+	}
+}
+
+func testPlaintextPassesThrough(ctx context.Context, t *testing.T, raw store.Store) {
+	todo := newTodo("written in the clear", time.Now())
+	mustCreate(ctx, t, raw, todo)
+
+	s := store.WithEncryption(raw, testKeyring(t, "a"))
+	todos, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 1 || todos[0].Title != "written in the clear" {
+		t.Errorf("List = %+v", todos)
+	}
+}
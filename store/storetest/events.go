@@ -0,0 +1,128 @@
+package storetest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// EventFactory returns an empty event log for a single test.
+type EventFactory func(t *testing.T) store.EventLog
+
+// RunEvents executes the store.EventLog contract cases, each through
+// store.WithEvents over an in-memory read model.
+func RunEvents(t *testing.T, newLog EventFactory) {
+	t.Helper()
+	for _, tc := range eventCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newLog(t))
+		})
+	}
+}
+
+var eventCases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, log store.EventLog)
+}{
+	{"TodoEventsInOrder", testTodoEventsInOrder},
+	{"NoEventForMissing", testNoEventForMissing},
+	{"RebuildMatches", testRebuildMatches},
+}
+
+func eventTypes(events []store.Event) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func testTodoEventsInOrder(ctx context.Context, t *testing.T, log store.EventLog) {
+	s := store.WithEvents(store.NewMemory(), log)
+	todo := newTodo("tracked", time.Now())
+	mustCreate(ctx, t, s, todo)
+	mustCreate(ctx, t, s, newTodo("other", time.Now()))
+
+	title, done, tags := "renamed", true, []string{"x"}
+	for _, u := range []store.Update{{Title: &title}, {Completed: &done}, {Tags: &tags}} {
+		if err := s.Update(ctx, todo.ID, u); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	if err := s.Delete(ctx, todo.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	events, err := log.TodoEvents(ctx, todo.ID)
+	if err != nil {
+		t.Fatalf("TodoEvents: %v", err)
+	}
+	want := []string{store.EventCreated, store.EventRetitled, store.EventCompleted, store.EventUpdated, store.EventDeleted}
+	if got := eventTypes(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("event types = %v, want %v", got, want)
+	}
+}
+
+func testNoEventForMissing(ctx context.Context, t *testing.T, log store.EventLog) {
+	s := store.WithEvents(store.NewMemory(), log)
+	id := primitive.NewObjectID()
+	title := "ghost"
+	if err := s.Update(ctx, id, store.Update{Title: &title}); err != nil {
+		t.Fatalf("Update(unknown): %v", err)
+	}
+	if err := s.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete(unknown): %v", err)
+	}
+	if err := s.Create(ctx, newTodo("", time.Now())); err == nil {
+		t.Fatalf("Create(untitled) succeeded")
+	}
+
+	n := 0
+	if err := log.Replay(ctx, func(store.Event) error { n++; return nil }); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("log holds %d events, want none", n)
+	}
+}
+
+func testRebuildMatches(ctx context.Context, t *testing.T, log store.EventLog) {
+	live := store.NewMemory()
+	s := store.WithEvents(live, log)
+	keep, gone := newTodo("keep", time.Now()), newTodo("gone", time.Now())
+	mustCreate(ctx, t, s, keep)
+	mustCreate(ctx, t, s, gone)
+
+	due, done := time.Now().UTC().Truncate(time.Millisecond), true
+	if err := s.Update(ctx, keep.ID, store.Update{DueAt: &due, Completed: &done, AddPomodoros: 2}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := s.Update(ctx, keep.ID, store.Update{DueAt: &time.Time{}, AddPomodoros: 1}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := s.Delete(ctx, gone.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	rebuilt := store.NewMemory()
+	if _, err := store.Rebuild(ctx, log, rebuilt); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	want, _ := live.List(ctx, store.Query{})
+	got, _ := rebuilt.List(ctx, store.Query{})
+	if len(got) != 1 || len(want) != 1 {
+		t.Fatalf("rebuilt %d todos, live has %d; want 1 each", len(got), len(want))
+	}
+	if got[0].ID != want[0].ID || got[0].Completed != want[0].Completed ||
+		got[0].Pomodoros != want[0].Pomodoros || got[0].DueAt != nil {
+		t.Errorf("rebuilt todo = %+v, want %+v", got[0], want[0])
+	}
+}
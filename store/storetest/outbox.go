@@ -0,0 +1,170 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// OutboxFactory returns an empty outbox for a single test.
+type OutboxFactory func(t *testing.T) store.Outbox
+
+// RunOutbox executes the store.Outbox contract cases, writing through
+// store.WithOutbox over an in-memory store.
+func RunOutbox(t *testing.T, newOutbox OutboxFactory) {
+	t.Helper()
+	for _, tc := range outboxCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newOutbox(t))
+		})
+	}
+}
+
+var outboxCases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, o store.Outbox)
+}{
+	{"QueuesMutations", testOutboxQueuesMutations},
+	{"DueLeases", testOutboxDueLeases},
+	{"MarkSent", testOutboxMarkSent},
+	{"MarkFailed", testOutboxMarkFailed},
+	{"DeadLetters", testOutboxDeadLetters},
+}
+
+// dueEvents leases everything due a minute from now.
+func dueEvents(ctx context.Context, t *testing.T, o store.Outbox) []store.OutboxMessage {
+	t.Helper()
+	msgs, err := o.Due(ctx, time.Now().Add(time.Minute), time.Hour, 100)
+	if err != nil {
+		t.Fatalf("Due: %v", err)
+	}
+	return msgs
+}
+
+func testOutboxQueuesMutations(ctx context.Context, t *testing.T, o store.Outbox) {
+	s := store.WithOutbox(store.NewMemory(), o)
+	todo := newTodo("relay me", time.Now())
+	mustCreate(ctx, t, s, todo)
+	done := true
+	if err := s.Update(ctx, todo.ID, store.Update{Completed: &done}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := s.Update(ctx, primitive.NewObjectID(), store.Update{Completed: &done}); err != nil {
+		t.Fatalf("Update(unknown): %v", err)
+	}
+	if err := s.Delete(ctx, todo.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	msgs := dueEvents(ctx, t, o)
+	var types []string
+	for _, m := range msgs {
+		types = append(types, m.Event.Type)
+	}
+	want := []string{store.EventCreated, store.EventCompleted, store.EventDeleted}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("queued %v, want %v", types, want)
+	}
+	if m := msgs[1]; m.Event.Todo == nil || !m.Event.Todo.Completed || m.ID != m.Event.ID {
+		t.Errorf("update message = %+v, want the completed todo and ID = event ID", m)
+	}
+}
+
+func testOutboxDueLeases(ctx context.Context, t *testing.T, o store.Outbox) {
+	s := store.WithOutbox(store.NewMemory(), o)
+	mustCreate(ctx, t, s, newTodo("once", time.Now()))
+
+	if got := len(dueEvents(ctx, t, o)); got != 1 {
+		t.Fatalf("first Due = %d messages, want 1", got)
+	}
+	if got := len(dueEvents(ctx, t, o)); got != 0 {
+		t.Errorf("Due while leased = %d messages, want 0", got)
+	}
+}
+
+func testOutboxMarkSent(ctx context.Context, t *testing.T, o store.Outbox) {
+	s := store.WithOutbox(store.NewMemory(), o)
+	mustCreate(ctx, t, s, newTodo("sent", time.Now()))
+
+	msg := dueEvents(ctx, t, o)[0]
+	if err := o.MarkSent(ctx, msg.ID); err != nil {
+		t.Fatalf("MarkSent: %v", err)
+	}
+	msgs, err := o.Due(ctx, time.Now().Add(2*time.Hour), time.Hour, 100)
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("Due after MarkSent = %v, %v; want none", msgs, err)
+	}
+}
+
+func testOutboxMarkFailed(ctx context.Context, t *testing.T, o store.Outbox) {
+	s := store.WithOutbox(store.NewMemory(), o)
+	mustCreate(ctx, t, s, newTodo("flaky", time.Now()))
+
+	msg := dueEvents(ctx, t, o)[0]
+	if err := o.MarkFailed(ctx, msg.ID, time.Now().Add(-time.Second), "boom"); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	msgs := dueEvents(ctx, t, o)
+	if len(msgs) != 1 || msgs[0].Attempts != 1 || msgs[0].LastError != "boom" {
+		t.Fatalf("Due after MarkFailed = %+v, want one retry with 1 attempt", msgs)
+	}
+
+	if err := o.MarkFailed(ctx, msg.ID, time.Time{}, "gave up"); err != nil {
+		t.Fatalf("MarkFailed(give up): %v", err)
+	}
+	msgs, err := o.Due(ctx, time.Now().Add(2*time.Hour), time.Hour, 100)
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("Due after giving up = %v, %v; want none", msgs, err)
+	}
+}
+
+func testOutboxDeadLetters(ctx context.Context, t *testing.T, o store.Outbox) {
+	s := store.WithOutbox(store.NewMemory(), o)
+	mustCreate(ctx, t, s, newTodo("first", time.Now()))
+	mustCreate(ctx, t, s, newTodo("second", time.Now()))
+	mustCreate(ctx, t, s, newTodo("alive", time.Now()))
+
+	msgs := dueEvents(ctx, t, o)
+	for _, msg := range msgs[:2] {
+		if err := o.MarkFailed(ctx, msg.ID, time.Time{}, "gave up"); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+	}
+	dead, err := o.DeadLetters(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	if len(dead) != 2 || dead[0].ID != msgs[1].ID || dead[0].LastError != "gave up" {
+		t.Fatalf("DeadLetters = %+v, want the 2 dead messages, newest first", dead)
+	}
+	if page, err := o.DeadLetters(ctx, 1, 1); err != nil || len(page) != 1 || page[0].ID != msgs[0].ID {
+		t.Errorf("DeadLetters(1, 1) = %+v, %v; want the oldest", page, err)
+	}
+
+	if err := o.Revive(ctx, msgs[0].ID, time.Now()); err != nil {
+		t.Fatalf("Revive: %v", err)
+	}
+	if err := o.Revive(ctx, msgs[2].ID, time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Revive(alive) = %v, want ErrNotFound", err)
+	}
+	due := dueEvents(ctx, t, o)
+	if len(due) != 1 || due[0].ID != msgs[0].ID || due[0].Attempts != 0 {
+		t.Errorf("Due after Revive = %+v, want the revived message with no attempts", due)
+	}
+
+	if n, err := o.PurgeDead(ctx, nil); err != nil || n != 1 {
+		t.Errorf("PurgeDead = %d, %v; want 1", n, err)
+	}
+	if dead, err := o.DeadLetters(ctx, 0, 0); err != nil || len(dead) != 0 {
+		t.Errorf("DeadLetters after PurgeDead = %+v, %v; want none", dead, err)
+	}
+}
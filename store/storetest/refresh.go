@@ -0,0 +1,115 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// RefreshTokenFactory returns an empty refresh token store for a single
+// test.
+type RefreshTokenFactory func(t *testing.T) store.RefreshTokenStore
+
+// RunRefreshTokens executes the store.RefreshTokenStore contract cases.
+func RunRefreshTokens(t *testing.T, newStore RefreshTokenFactory) {
+	t.Helper()
+	for _, tc := range refreshCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newStore(t))
+		})
+	}
+}
+
+var refreshCases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, s store.RefreshTokenStore)
+}{
+	{"UseMarksUsed", testRefreshUseMarksUsed},
+	{"MissingAndExpired", testRefreshMissingAndExpired},
+	{"RevokeFamily", testRefreshRevokeFamily},
+}
+
+func newRefreshToken(family string, now time.Time) store.RefreshToken {
+	return store.RefreshToken{
+		ID:        primitive.NewObjectID().Hex(),
+		Family:    family,
+		Subject:   "key:test",
+		CreatedAt: now.UTC().Truncate(time.Millisecond),
+		ExpiresAt: now.UTC().Add(time.Hour).Truncate(time.Millisecond),
+	}
+}
+
+func mustCreateRefresh(ctx context.Context, t *testing.T, s store.RefreshTokenStore, tok store.RefreshToken) {
+	t.Helper()
+	if err := s.CreateRefreshToken(ctx, tok); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+}
+
+func testRefreshUseMarksUsed(ctx context.Context, t *testing.T, s store.RefreshTokenStore) {
+	now := time.Now()
+	tok := newRefreshToken("f", now)
+	mustCreateRefresh(ctx, t, s, tok)
+
+	first, err := s.UseRefreshToken(ctx, tok.ID, now)
+	if err != nil {
+		t.Fatalf("UseRefreshToken: %v", err)
+	}
+	if first.UsedAt != nil || first.Subject != tok.Subject || first.Family != "f" {
+		t.Errorf("first use = %+v, want the unused token", first)
+	}
+	second, err := s.UseRefreshToken(ctx, tok.ID, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("UseRefreshToken again: %v", err)
+	}
+	if second.UsedAt == nil {
+		t.Errorf("second use returned UsedAt nil, want the first use recorded")
+	}
+}
+
+func testRefreshMissingAndExpired(ctx context.Context, t *testing.T, s store.RefreshTokenStore) {
+	now := time.Now()
+	if _, err := s.UseRefreshToken(ctx, "missing", now); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("UseRefreshToken(missing) error = %v, want ErrRefreshTokenNotFound", err)
+	}
+	tok := newRefreshToken("f", now)
+	mustCreateRefresh(ctx, t, s, tok)
+	later := now.Add(2 * time.Hour)
+	if _, err := s.GetRefreshToken(ctx, tok.ID, later); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("GetRefreshToken(expired) error = %v, want ErrRefreshTokenNotFound", err)
+	}
+	if _, err := s.UseRefreshToken(ctx, tok.ID, later); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("UseRefreshToken(expired) error = %v, want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func testRefreshRevokeFamily(ctx context.Context, t *testing.T, s store.RefreshTokenStore) {
+	now := time.Now()
+	a, b, other := newRefreshToken("f", now), newRefreshToken("f", now), newRefreshToken("g", now)
+	for _, tok := range []store.RefreshToken{a, b, other} {
+		mustCreateRefresh(ctx, t, s, tok)
+	}
+	if err := s.RevokeRefreshFamily(ctx, "f"); err != nil {
+		t.Fatalf("RevokeRefreshFamily: %v", err)
+	}
+	for _, tc := range []struct {
+		tok  store.RefreshToken
+		want bool
+	}{{a, true}, {b, true}, {other, false}} {
+		got, err := s.GetRefreshToken(ctx, tc.tok.ID, now)
+		if err != nil {
+			t.Fatalf("GetRefreshToken: %v", err)
+		}
+		if got.Revoked != tc.want {
+			t.Errorf("token of family %s revoked = %t, want %t", tc.tok.Family, got.Revoked, tc.want)
+		}
+	}
+}
@@ -0,0 +1,743 @@
+// Package storetest is a conformance suite for store.Store implementations.
+//
+// A backend's tests call Run with a factory returning a fresh, empty store:
+//
+//	func TestMongoStore(t *testing.T) {
+//		storetest.Run(t, storetest.MongoContainer(t))
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// Factory returns an empty store for a single test. Cleanup should be
+// registered on t.
+type Factory func(t *testing.T) store.Store
+
+// Run executes every contract case against stores produced by newStore.
+func Run(t *testing.T, newStore Factory) {
+	t.Helper()
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newStore(t))
+		})
+	}
+}
+
+var cases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, s store.Store)
+}{
+	{"ListEmpty", testListEmpty},
+	{"CreateGet", testCreateGet},
+	{"UnicodeTitles", testUnicodeTitles},
+	{"GetMissing", testGetMissing},
+	{"CreateConflict", testCreateConflict},
+	{"ListNewestFirst", testListNewestFirst},
+	{"StreamNewestFirst", testStreamNewestFirst},
+	{"PinnedFirst", testPinnedFirst},
+	{"ListProjection", testListProjection},
+	{"ListPage", testListPage},
+	{"ListIDs", testListIDs},
+	{"ListCompleted", testListCompleted},
+	{"ListColor", testListColor},
+	{"ListEffort", testListEffort},
+	{"ListTitle", testListTitle},
+	{"ListNear", testListNear},
+	{"Count", testCount},
+	{"ReportCompletions", testReportCompletions},
+	{"ReportTagCounts", testReportTagCounts},
+	{"UpdateTitle", testUpdateTitle},
+	{"UpdateCompleted", testUpdateCompleted},
+	{"UpdateDetails", testUpdateDetails},
+	{"UpdateBlockedBy", testUpdateBlockedBy},
+	{"UpdateCustom", testUpdateCustom},
+	{"UpdateAddPomodoros", testUpdateAddPomodoros},
+	{"UpdateAddSnoozes", testUpdateAddSnoozes},
+	{"UpdateMissing", testUpdateMissing},
+	{"Delete", testDelete},
+	{"DeleteMissing", testDeleteMissing},
+}
+
+// --- Helpers ---
+
+func newTodo(title string, createdAt time.Time) store.Todo {
+	return store.Todo{
+		ID:        primitive.NewObjectID(),
+		Title:     title,
+		CreatedAt: createdAt.UTC().Truncate(time.Millisecond),
+	}
+}
+
+func mustCreate(ctx context.Context, t *testing.T, s store.Store, todo store.Todo) {
+	t.Helper()
+	if err := s.Create(ctx, todo); err != nil {
+		t.Fatalf("Create(%q): %v", todo.Title, err)
+	}
+}
+
+func mustGet(ctx context.Context, t *testing.T, s store.Store, id primitive.ObjectID) store.Todo {
+	t.Helper()
+	got, err := s.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", id.Hex(), err)
+	}
+	return got
+}
+
+// --- Cases ---
+
+func testListEmpty(ctx context.Context, t *testing.T, s store.Store) {
+	todos, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if todos == nil || len(todos) != 0 {
+		t.Fatalf("List on empty store = %#v, want empty non-nil slice", todos)
+	}
+}
+
+func testCreateGet(ctx context.Context, t *testing.T, s store.Store) {
+	want := newTodo("Write contract tests", time.Now())
+	want.Description = "Cover every backend"
+	want.Tags = []string{"testing", "store"}
+	want.Priority = "high"
+	mustCreate(ctx, t, s, want)
+
+	got := mustGet(ctx, t, s, want.ID)
+	if got.ID != want.ID || got.Title != want.Title || got.Completed != want.Completed ||
+		got.Description != want.Description || got.Priority != want.Priority || !reflect.DeepEqual(got.Tags, want.Tags) {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, want.CreatedAt)
+	}
+}
+
+func testUnicodeTitles(ctx context.Context, t *testing.T, s store.Store) {
+	// Emoji with joiners, right-to-left text and zero-width characters
+	// come back byte for byte
+	titles := []string{
+		"Party \U0001F468\u200D\U0001F469\u200D\U0001F467 \U0001F389",
+		"שלום עולם",
+		"zero\u200Bwidth\u2060joiners",
+	}
+	now := time.Now()
+	for i, title := range titles {
+		todo := newTodo(title, now.Add(time.Duration(i)*time.Second))
+		mustCreate(ctx, t, s, todo)
+		if got := mustGet(ctx, t, s, todo.ID); got.Title != title {
+			t.Errorf("Get().Title = %q, want %q", got.Title, title)
+		}
+	}
+	todos, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != len(titles) {
+		t.Fatalf("List() returned %d todos, want %d", len(todos), len(titles))
+	}
+	for i, todo := range todos {
+		if want := titles[len(titles)-1-i]; todo.Title != want {
+			t.Errorf("List()[%d].Title = %q, want %q", i, todo.Title, want)
+		}
+	}
+}
+
+func testGetMissing(ctx context.Context, t *testing.T, s store.Store) {
+	_, err := s.Get(ctx, primitive.NewObjectID())
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get(unknown) error = %v, want store.ErrNotFound", err)
+	}
+}
+
+func testCreateConflict(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("once", time.Now())
+	mustCreate(ctx, t, s, todo)
+	todo.Title = "twice"
+	if err := s.Create(ctx, todo); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Create(existing) = %v, want ErrConflict", err)
+	}
+	if got := mustGet(ctx, t, s, todo.ID); got.Title != "once" {
+		t.Errorf("Create(existing) replaced the todo: title = %q", got.Title)
+	}
+}
+
+func testListNewestFirst(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	titles := []string{"oldest", "middle", "newest"}
+	for i, title := range titles {
+		mustCreate(ctx, t, s, newTodo(title, base.Add(time.Duration(i)*time.Minute)))
+	}
+
+	todos, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != len(titles) {
+		t.Fatalf("List returned %d todos, want %d", len(todos), len(titles))
+	}
+	for i, todo := range todos {
+		if want := titles[len(titles)-1-i]; todo.Title != want {
+			t.Errorf("List()[%d].Title = %q, want %q", i, todo.Title, want)
+		}
+	}
+}
+
+func testPinnedFirst(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	pinned := newTodo("pinned", base)
+	pinned.Pinned = true
+	mustCreate(ctx, t, s, pinned)
+	mustCreate(ctx, t, s, newTodo("newer", base.Add(time.Minute)))
+	unpinned := newTodo("unpinned", base.Add(2*time.Minute))
+	unpinned.Pinned = true
+	mustCreate(ctx, t, s, unpinned)
+	no := false
+	if err := s.Update(ctx, unpinned.ID, store.Update{Pinned: &no}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	want := []string{"pinned", "unpinned", "newer"}
+
+	todos, err := s.List(ctx, store.Query{Fields: []string{"title"}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var got []string
+	for _, todo := range todos {
+		got = append(got, todo.Title)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+
+	streamer, ok := s.(store.Streamer)
+	if !ok {
+		return
+	}
+	got = nil
+	err = streamer.Stream(ctx, store.Query{}, func(todo store.Todo) error {
+		got = append(got, todo.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stream = %v, want %v", got, want)
+	}
+}
+
+func testStreamNewestFirst(ctx context.Context, t *testing.T, s store.Store) {
+	streamer, ok := s.(store.Streamer)
+	if !ok {
+		t.Skip("store does not implement store.Streamer")
+	}
+
+	base := time.Now()
+	titles := []string{"oldest", "middle", "newest"}
+	for i, title := range titles {
+		mustCreate(ctx, t, s, newTodo(title, base.Add(time.Duration(i)*time.Minute)))
+	}
+
+	var got []string
+	err := streamer.Stream(ctx, store.Query{}, func(todo store.Todo) error {
+		got = append(got, todo.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if len(got) != len(titles) {
+		t.Fatalf("Stream yielded %d todos, want %d", len(got), len(titles))
+	}
+	for i, title := range got {
+		if want := titles[len(titles)-1-i]; title != want {
+			t.Errorf("Stream()[%d] = %q, want %q", i, title, want)
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = streamer.Stream(ctx, store.Query{}, func(store.Todo) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Stream with failing callback = (%v, %d calls), want (stop, 1)", err, calls)
+	}
+}
+
+func testCount(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	for i, tags := range [][]string{{"work"}, {"work", "home"}, nil} {
+		todo := newTodo("t", base.Add(time.Duration(i)*time.Minute))
+		todo.Tags = tags
+		todo.Completed = i == 0
+		mustCreate(ctx, t, s, todo)
+	}
+
+	got, err := store.CountTodos(ctx, s)
+	if err != nil {
+		t.Fatalf("CountTodos: %v", err)
+	}
+	if got.Total != 3 || got.Completed != 1 || got.Open() != 2 {
+		t.Errorf("CountTodos = %d total, %d completed, %d open; want 3, 1, 2", got.Total, got.Completed, got.Open())
+	}
+	if got.Tags["work"] != 2 || got.Tags["home"] != 1 || len(got.Tags) != 2 {
+		t.Errorf("CountTodos tags = %v, want work:2 home:1", got.Tags)
+	}
+}
+
+func testReportCompletions(ctx context.Context, t *testing.T, s store.Store) {
+	day := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC) // a Monday
+	for i, offset := range []int{0, 0, 1, 7, 30} {
+		todo := newTodo("t", day)
+		at := day.AddDate(0, 0, offset)
+		todo.Completed, todo.CompletedAt = true, &at
+		if i == 1 {
+			todo.Completed = false // reopened
+		}
+		mustCreate(ctx, t, s, todo)
+	}
+	from, to := day.AddDate(0, 0, -1), day.AddDate(0, 0, 14)
+
+	days, err := store.ReportCompletions(ctx, s, store.GroupDay, from, to)
+	if err != nil {
+		t.Fatalf("ReportCompletions(day): %v", err)
+	}
+	if want := map[string]int{"2026-03-02": 1, "2026-03-03": 1, "2026-03-09": 1}; !reflect.DeepEqual(days, want) {
+		t.Errorf("ReportCompletions(day) = %v, want %v", days, want)
+	}
+	weeks, err := store.ReportCompletions(ctx, s, store.GroupWeek, from, to)
+	if err != nil {
+		t.Fatalf("ReportCompletions(week): %v", err)
+	}
+	if want := map[string]int{"2026-W10": 2, "2026-W11": 1}; !reflect.DeepEqual(weeks, want) {
+		t.Errorf("ReportCompletions(week) = %v, want %v", weeks, want)
+	}
+}
+
+func testReportTagCounts(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	for i, tags := range [][]string{{"work"}, {"work", "home"}, {"errand"}} {
+		todo := newTodo("t", base.Add(time.Duration(i)*time.Minute))
+		todo.Tags = tags
+		todo.Completed = i == 1
+		mustCreate(ctx, t, s, todo)
+	}
+
+	got, err := store.ReportTagCounts(ctx, s)
+	if err != nil {
+		t.Fatalf("ReportTagCounts: %v", err)
+	}
+	want := []store.TagCount{
+		{Tag: "work", Total: 2, Completed: 1},
+		{Tag: "errand", Total: 1},
+		{Tag: "home", Total: 1, Completed: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReportTagCounts = %+v, want %+v", got, want)
+	}
+}
+
+func testListProjection(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	older := newTodo("older", base)
+	older.Completed = true
+	mustCreate(ctx, t, s, older)
+	mustCreate(ctx, t, s, newTodo("newer", base.Add(time.Minute)))
+
+	todos, err := s.List(ctx, store.Query{Fields: []string{"title"}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 2 || todos[0].Title != "newer" || todos[1].Title != "older" {
+		t.Fatalf("projected List = %+v, want [newer older]", todos)
+	}
+	if todos[1].ID != older.ID {
+		t.Errorf("projected List dropped the ID")
+	}
+	if todos[1].Completed || !todos[1].CreatedAt.IsZero() {
+		t.Errorf("projected List returned unselected fields: %+v", todos[1])
+	}
+}
+
+func testListPage(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	for i, title := range []string{"e", "d", "c", "b", "a"} {
+		mustCreate(ctx, t, s, newTodo(title, base.Add(time.Duration(-i)*time.Minute)))
+	}
+
+	for _, tc := range []struct {
+		offset, limit int
+		want          string
+	}{
+		{0, 2, "ed"},
+		{2, 2, "cb"},
+		{4, 2, "a"},
+		{5, 2, ""},
+		{1, 0, "dcba"},
+	} {
+		q := store.Query{Offset: tc.offset, Limit: tc.limit}
+		todos, err := s.List(ctx, q)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", q, err)
+		}
+		got := ""
+		for _, todo := range todos {
+			got += todo.Title
+		}
+		if got != tc.want {
+			t.Errorf("List(offset %d, limit %d) = %q, want %q", tc.offset, tc.limit, got, tc.want)
+		}
+		if todos == nil {
+			t.Errorf("List(offset %d, limit %d) returned nil", tc.offset, tc.limit)
+		}
+	}
+}
+
+func testListIDs(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	a, b, c := newTodo("a", base), newTodo("b", base.Add(time.Minute)), newTodo("c", base.Add(2*time.Minute))
+	for _, todo := range []store.Todo{a, b, c} {
+		mustCreate(ctx, t, s, todo)
+	}
+
+	todos, err := s.List(ctx, store.Query{IDs: []primitive.ObjectID{a.ID, c.ID, primitive.NewObjectID()}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 2 || todos[0].ID != c.ID || todos[1].ID != a.ID {
+		t.Fatalf("List by IDs = %+v, want [c a]", todos)
+	}
+}
+
+func testListCompleted(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	done := newTodo("done", base)
+	done.Completed = true
+	mustCreate(ctx, t, s, done)
+	mustCreate(ctx, t, s, newTodo("open", base.Add(time.Minute)))
+
+	for _, completed := range []bool{true, false} {
+		completed := completed
+		todos, err := s.List(ctx, store.Query{Completed: &completed})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(todos) != 1 || todos[0].Completed != completed {
+			t.Errorf("List(completed=%v) = %+v", completed, todos)
+		}
+	}
+}
+
+func testListColor(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	red := newTodo("red", base)
+	red.Color = "red"
+	mustCreate(ctx, t, s, red)
+	hex := newTodo("hex", base.Add(time.Minute))
+	hex.Color = "#1e90ff"
+	mustCreate(ctx, t, s, hex)
+	mustCreate(ctx, t, s, newTodo("plain", base.Add(2*time.Minute)))
+
+	todos, err := s.List(ctx, store.Query{Color: "#1e90ff"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 1 || todos[0].ID != hex.ID {
+		t.Errorf("List(color=#1e90ff) = %+v, want only %q", todos, hex.Title)
+	}
+
+	none := ""
+	if err := s.Update(ctx, red.ID, store.Update{Color: &none}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if todos, err = s.List(ctx, store.Query{Color: "red"}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 0 {
+		t.Errorf("List(color=red) after removing the color = %+v, want none", todos)
+	}
+}
+
+func testListEffort(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	quick := newTodo("quick", base)
+	quick.Estimate, quick.Progress = 15, 80
+	mustCreate(ctx, t, s, quick)
+	long := newTodo("long", base.Add(time.Minute))
+	long.Estimate, long.Progress = 240, 10
+	mustCreate(ctx, t, s, long)
+	mustCreate(ctx, t, s, newTodo("plain", base.Add(2*time.Minute)))
+
+	for _, c := range []struct {
+		q    store.Query
+		want []string
+	}{
+		{store.Query{Sort: "estimateMinutes"}, []string{"quick", "long", "plain"}},
+		{store.Query{Sort: "-estimateMinutes"}, []string{"long", "quick", "plain"}},
+		{store.Query{Sort: "-progressPercent", Fields: []string{"title"}}, []string{"quick", "long", "plain"}},
+		{store.Query{MaxEstimate: 60}, []string{"quick"}},
+		{store.Query{MinProgress: 10, Sort: "progressPercent"}, []string{"long", "quick"}},
+	} {
+		todos, err := s.List(ctx, c.q)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		var got []string
+		for _, todo := range todos {
+			got = append(got, todo.Title)
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("List(%+v) = %v, want %v", c.q, got, c.want)
+		}
+	}
+
+	progress := 100
+	if err := s.Update(ctx, long.ID, store.Update{Progress: &progress}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := s.Get(ctx, long.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Progress != 100 {
+		t.Errorf("Progress after update = %d, want 100", got.Progress)
+	}
+}
+
+func testListTitle(ctx context.Context, t *testing.T, s store.Store) {
+	base := time.Now()
+	// Long enough that its index entries are cut short
+	stripes := strings.Repeat("stripes ", 10)
+	for i, title := range []string{"Buy milk", "buy  MILK", "Call the bank", "Bank (holiday) hours", "Embankment walk", "Café run", "Zoo " + stripes + "zebra"} {
+		mustCreate(ctx, t, s, newTodo(title, base.Add(time.Duration(i)*time.Minute)))
+	}
+
+	for _, c := range []struct {
+		q    store.Query
+		want []string
+	}{
+		{store.Query{Title: "buy milk"}, []string{"buy  MILK", "Buy milk"}},
+		{store.Query{Title: "Buy milk", Limit: 1}, []string{"buy  MILK"}},
+		{store.Query{Title: "buy"}, nil},
+		{store.Query{Title: "CAFE\u0301 RUN"}, []string{"Café run"}},
+		{store.Query{TitlePrefix: "bank"}, []string{"Bank (holiday) hours", "Call the bank"}},
+		{store.Query{TitlePrefix: "BANK (", Fields: []string{"title"}}, []string{"Bank (holiday) hours"}},
+		{store.Query{TitlePrefix: "b", Offset: 1, Limit: 2}, []string{"Call the bank", "buy  MILK"}},
+		{store.Query{TitlePrefix: "ank"}, nil},
+		{store.Query{TitlePrefix: stripes + "ZEB"}, []string{"Zoo " + stripes + "zebra"}},
+		{store.Query{TitlePrefix: stripes + "zoo"}, nil},
+		{store.Query{TitleStart: "BUY  m"}, []string{"buy  MILK", "Buy milk"}},
+		{store.Query{TitleStart: "bank"}, []string{"Bank (holiday) hours"}},
+		{store.Query{TitleStart: "the bank"}, nil},
+		{store.Query{TitleStart: "b", Offset: 1, Limit: 1}, []string{"buy  MILK"}},
+	} {
+		todos, err := s.List(ctx, c.q)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		var got []string
+		for _, todo := range todos {
+			got = append(got, todo.Title)
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("List(%+v) = %v, want %v", c.q, got, c.want)
+		}
+	}
+}
+
+func testUpdateTitle(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("before", time.Now())
+	mustCreate(ctx, t, s, todo)
+
+	title := "after"
+	if err := s.Update(ctx, todo.ID, store.Update{Title: &title}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got := mustGet(ctx, t, s, todo.ID)
+	if got.Title != title {
+		t.Errorf("Title = %q, want %q", got.Title, title)
+	}
+	if got.Completed {
+		t.Errorf("Completed changed by title-only update")
+	}
+}
+
+func testUpdateCompleted(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("finish me", time.Now())
+	mustCreate(ctx, t, s, todo)
+
+	done := true
+	if err := s.Update(ctx, todo.ID, store.Update{Completed: &done}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got := mustGet(ctx, t, s, todo.ID)
+	if !got.Completed {
+		t.Errorf("Completed = false, want true")
+	}
+	if got.Title != todo.Title {
+		t.Errorf("Title changed by completed-only update: %q", got.Title)
+	}
+}
+
+func testListNear(ctx context.Context, t *testing.T, s store.Store) {
+	now := time.Now()
+	near := newTodo("near", now)
+	near.Location = store.NewLocation(51.5007, -0.1246, "Westminster")
+	far := newTodo("far", now.Add(time.Second))
+	far.Location = store.NewLocation(40.6892, -74.0445, "Liberty Island")
+	mustCreate(ctx, t, s, near)
+	mustCreate(ctx, t, s, far)
+	mustCreate(ctx, t, s, newTodo("nowhere", now.Add(2*time.Second)))
+
+	todos, err := s.List(ctx, store.Query{Near: &store.Circle{Lat: 51.5014, Lng: -0.1419, RadiusMeters: 5000}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 1 || todos[0].ID != near.ID || todos[0].Location.Label != "Westminster" {
+		t.Fatalf("List near = %+v, want only %q", todos, near.Title)
+	}
+}
+
+func testUpdateDetails(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("details", time.Now())
+	todo.Tags = []string{"old"}
+	mustCreate(ctx, t, s, todo)
+
+	description, tags, priority, status := "more context", []string{"new", "tags"}, "low", store.StatusInProgress
+	u := store.Update{Description: &description, Tags: &tags, Priority: &priority, Status: &status}
+	if err := s.Update(ctx, todo.ID, u); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got := mustGet(ctx, t, s, todo.ID)
+	if got.Description != description || got.Priority != priority || got.Status != status || !reflect.DeepEqual(got.Tags, tags) {
+		t.Errorf("after Update = %+v", got)
+	}
+	if got.Title != "details" {
+		t.Errorf("Update changed the title to %q", got.Title)
+	}
+}
+
+func testUpdateCustom(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("custom", time.Now())
+	todo.Custom = map[string]interface{}{"team": "web", "points": 3.0}
+	mustCreate(ctx, t, s, todo)
+	if got := mustGet(ctx, t, s, todo.ID); !reflect.DeepEqual(got.Custom, todo.Custom) {
+		t.Fatalf("created Custom = %#v, want %#v", got.Custom, todo.Custom)
+	}
+
+	u := store.Update{Custom: map[string]interface{}{"points": 5.0, "team": nil, "due": "2030-01-02"}}
+	if err := s.Update(ctx, todo.ID, u); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	want := map[string]interface{}{"points": 5.0, "due": "2030-01-02"}
+	if got := mustGet(ctx, t, s, todo.ID); !reflect.DeepEqual(got.Custom, want) {
+		t.Errorf("after Update Custom = %#v, want %#v", got.Custom, want)
+	}
+}
+
+func testUpdateAddPomodoros(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("focus", time.Now())
+	mustCreate(ctx, t, s, todo)
+
+	for i := 0; i < 2; i++ {
+		if err := s.Update(ctx, todo.ID, store.Update{AddPomodoros: 1}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	if got := mustGet(ctx, t, s, todo.ID); got.Pomodoros != 2 || got.Title != "focus" {
+		t.Errorf("after two increments = %+v, want 2 pomodoros", got)
+	}
+}
+
+// testUpdateAddSnoozes increments both counters in one update, alongside
+// a set field.
+func testUpdateAddSnoozes(ctx context.Context, t *testing.T, s store.Store) {
+	todo := newTodo("later", time.Now())
+	mustCreate(ctx, t, s, todo)
+
+	due := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := s.Update(ctx, todo.ID, store.Update{DueAt: &due, AddSnoozes: 1, AddPomodoros: 1}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	got := mustGet(ctx, t, s, todo.ID)
+	if got.Snoozes != 2 || got.Pomodoros != 2 || got.DueAt == nil || !got.DueAt.Equal(due) {
+		t.Errorf("after two snoozes = %+v, want 2 snoozes and 2 pomodoros due %s", got, due)
+	}
+}
+
+func testUpdateBlockedBy(ctx context.Context, t *testing.T, s store.Store) {
+	blocker := newTodo("blocker", time.Now())
+	todo := newTodo("blocked", time.Now())
+	mustCreate(ctx, t, s, blocker)
+	mustCreate(ctx, t, s, todo)
+
+	blockers := []primitive.ObjectID{blocker.ID}
+	if err := s.Update(ctx, todo.ID, store.Update{BlockedBy: &blockers}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := mustGet(ctx, t, s, todo.ID); !reflect.DeepEqual(got.BlockedBy, blockers) {
+		t.Errorf("BlockedBy = %v, want %v", got.BlockedBy, blockers)
+	}
+
+	none := []primitive.ObjectID{}
+	if err := s.Update(ctx, todo.ID, store.Update{BlockedBy: &none}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := mustGet(ctx, t, s, todo.ID); len(got.BlockedBy) != 0 {
+		t.Errorf("BlockedBy after clearing = %v, want none", got.BlockedBy)
+	}
+}
+
+func testUpdateMissing(ctx context.Context, t *testing.T, s store.Store) {
+	title := "ghost"
+	if err := s.Update(ctx, primitive.NewObjectID(), store.Update{Title: &title}); err != nil {
+		t.Fatalf("Update(unknown) = %v, want nil", err)
+	}
+	todos, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(todos) != 0 {
+		t.Fatalf("Update(unknown) created %d todos", len(todos))
+	}
+}
+
+func testDelete(ctx context.Context, t *testing.T, s store.Store) {
+	keep := newTodo("keep", time.Now())
+	drop := newTodo("drop", time.Now())
+	mustCreate(ctx, t, s, keep)
+	mustCreate(ctx, t, s, drop)
+
+	if err := s.Delete(ctx, drop.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, drop.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want store.ErrNotFound", err)
+	}
+	mustGet(ctx, t, s, keep.ID)
+}
+
+func testDeleteMissing(ctx context.Context, t *testing.T, s store.Store) {
+	if err := s.Delete(ctx, primitive.NewObjectID()); err != nil {
+		t.Fatalf("Delete(unknown) = %v, want nil", err)
+	}
+}
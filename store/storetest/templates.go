@@ -0,0 +1,113 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// TemplateFactory returns an empty template store for a single test.
+type TemplateFactory func(t *testing.T) store.TemplateStore
+
+// RunTemplates executes the store.TemplateStore contract cases.
+func RunTemplates(t *testing.T, newStore TemplateFactory) {
+	t.Helper()
+	for _, tc := range templateCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tc.run(ctx, t, newStore(t))
+		})
+	}
+}
+
+var templateCases = []struct {
+	name string
+	run  func(ctx context.Context, t *testing.T, s store.TemplateStore)
+}{
+	{"ListEmpty", testTemplatesEmpty},
+	{"CreateGet", testTemplateCreateGet},
+	{"GetMissing", testTemplateGetMissing},
+	{"ListByName", testTemplatesByName},
+	{"Delete", testTemplateDelete},
+}
+
+func newTemplate(name string) store.Template {
+	return store.Template{
+		ID:          primitive.NewObjectID(),
+		Name:        name,
+		Title:       name + " title",
+		Description: "steps for " + name,
+		Tags:        []string{"preset"},
+		Priority:    "medium",
+	}
+}
+
+func testTemplatesEmpty(ctx context.Context, t *testing.T, s store.TemplateStore) {
+	templates, err := s.ListTemplates(ctx)
+	if err != nil {
+		t.Fatalf("ListTemplates: %v", err)
+	}
+	if templates == nil || len(templates) != 0 {
+		t.Fatalf("ListTemplates on empty store = %#v, want empty non-nil slice", templates)
+	}
+}
+
+func testTemplateCreateGet(ctx context.Context, t *testing.T, s store.TemplateStore) {
+	want := newTemplate("Release checklist")
+	if err := s.CreateTemplate(ctx, want); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+	got, err := s.GetTemplate(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("GetTemplate: %v", err)
+	}
+	if got.Name != want.Name || got.Title != want.Title || got.Description != want.Description ||
+		got.Priority != want.Priority || len(got.Tags) != 1 || got.Tags[0] != "preset" {
+		t.Errorf("GetTemplate = %+v, want %+v", got, want)
+	}
+}
+
+func testTemplateGetMissing(ctx context.Context, t *testing.T, s store.TemplateStore) {
+	_, err := s.GetTemplate(ctx, primitive.NewObjectID())
+	if !errors.Is(err, store.ErrTemplateNotFound) {
+		t.Fatalf("GetTemplate(unknown) error = %v, want store.ErrTemplateNotFound", err)
+	}
+}
+
+func testTemplatesByName(ctx context.Context, t *testing.T, s store.TemplateStore) {
+	for _, name := range []string{"b", "c", "a"} {
+		if err := s.CreateTemplate(ctx, newTemplate(name)); err != nil {
+			t.Fatalf("CreateTemplate(%q): %v", name, err)
+		}
+	}
+	templates, err := s.ListTemplates(ctx)
+	if err != nil {
+		t.Fatalf("ListTemplates: %v", err)
+	}
+	if len(templates) != 3 || templates[0].Name != "a" || templates[2].Name != "c" {
+		t.Errorf("ListTemplates = %+v, want ordered by name", templates)
+	}
+}
+
+func testTemplateDelete(ctx context.Context, t *testing.T, s store.TemplateStore) {
+	tpl := newTemplate("gone")
+	if err := s.CreateTemplate(ctx, tpl); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+	if err := s.DeleteTemplate(ctx, tpl.ID); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if _, err := s.GetTemplate(ctx, tpl.ID); !errors.Is(err, store.ErrTemplateNotFound) {
+		t.Errorf("GetTemplate after delete error = %v, want store.ErrTemplateNotFound", err)
+	}
+	if err := s.DeleteTemplate(ctx, primitive.NewObjectID()); err != nil {
+		t.Errorf("DeleteTemplate(unknown) = %v, want nil", err)
+	}
+}
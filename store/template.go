@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrTemplateNotFound is returned by GetTemplate for unknown IDs.
+var ErrTemplateNotFound = errors.New("store: template not found")
+
+// --- Templates ---
+
+// Template is a named preset of todo fields for recurring tasks.
+type Template struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Title       string             `json:"title" bson:"title"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	Tags        []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Priority    string             `json:"priority,omitempty" bson:"priority,omitempty"`
+}
+
+// NewTodo returns a fresh, open todo carrying the template's fields.
+func (t Template) NewTodo(now time.Time) Todo {
+	return Todo{
+		ID:          primitive.NewObjectID(),
+		Title:       t.Title,
+		Description: t.Description,
+		Tags:        append([]string(nil), t.Tags...),
+		Priority:    t.Priority,
+		CreatedAt:   now,
+	}
+}
+
+// TemplateStore persists templates. Its contract is verified by
+// storetest.RunTemplates:
+//
+//   - ListTemplates returns all templates ordered by name, and an empty
+//     (non-nil) slice when there are none.
+//   - GetTemplate returns ErrTemplateNotFound for unknown IDs.
+//   - DeleteTemplate on an unknown ID is a no-op and returns nil.
+type TemplateStore interface {
+	ListTemplates(ctx context.Context) ([]Template, error)
+	GetTemplate(ctx context.Context, id primitive.ObjectID) (Template, error)
+	CreateTemplate(ctx context.Context, t Template) error
+	DeleteTemplate(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoTemplates stores templates in their own collection.
+type MongoTemplates struct {
+	coll *mongo.Collection
+}
+
+func NewMongoTemplates(coll *mongo.Collection) *MongoTemplates {
+	return &MongoTemplates{coll: coll}
+}
+
+func (m *MongoTemplates) ListTemplates(ctx context.Context) ([]Template, error) {
+	// Templates are few; sorting in memory avoids needing an index on name
+	cursor, err := m.coll.Find(ctx, bson.M{}, options.Find())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []Template{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	sortTemplates(templates)
+	return templates, nil
+}
+
+func (m *MongoTemplates) GetTemplate(ctx context.Context, id primitive.ObjectID) (Template, error) {
+	var t Template
+	err := m.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Template{}, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+func (m *MongoTemplates) CreateTemplate(ctx context.Context, t Template) error {
+	_, err := m.coll.InsertOne(ctx, t)
+	return err
+}
+
+func (m *MongoTemplates) DeleteTemplate(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// MemoryTemplates is an in-process TemplateStore, safe for concurrent use.
+type MemoryTemplates struct {
+	mu        sync.RWMutex
+	templates map[primitive.ObjectID]Template
+}
+
+func NewMemoryTemplates() *MemoryTemplates {
+	return &MemoryTemplates{templates: map[primitive.ObjectID]Template{}}
+}
+
+func (m *MemoryTemplates) ListTemplates(ctx context.Context) ([]Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	templates := make([]Template, 0, len(m.templates))
+	for _, t := range m.templates {
+		templates = append(templates, t)
+	}
+	sortTemplates(templates)
+	return templates, nil
+}
+
+func (m *MemoryTemplates) GetTemplate(ctx context.Context, id primitive.ObjectID) (Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.templates[id]
+	if !ok {
+		return Template{}, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+func (m *MemoryTemplates) CreateTemplate(ctx context.Context, t Template) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.templates[t.ID] = t
+	return nil
+}
+
+func (m *MemoryTemplates) DeleteTemplate(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.templates, id)
+	return nil
+}
+
+func sortTemplates(templates []Template) {
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+}
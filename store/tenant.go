@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Tenants ---
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose store calls are served by tenant's
+// backends.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant of ctx, "" for the default tenant.
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenants lazily opens and keeps one backend per tenant. Each is opened
+// once, outside the lock, so opening one tenant's doesn't hold up the
+// others; one that fails to open isn't kept, and the next call retries.
+type tenants[T any] struct {
+	mu      sync.Mutex
+	newFunc func(tenant string) (T, error)
+	byID    map[string]*tenantBackend[T]
+}
+
+// tenantBackend is a backend being opened, or opened once ready is closed.
+type tenantBackend[T any] struct {
+	ready chan struct{}
+	b     T
+	err   error
+}
+
+func newTenants[T any](newFunc func(tenant string) (T, error)) *tenants[T] {
+	return &tenants[T]{newFunc: newFunc, byID: map[string]*tenantBackend[T]{}}
+}
+
+func (t *tenants[T]) get(ctx context.Context) (T, error) {
+	tenant := TenantFrom(ctx)
+	t.mu.Lock()
+	b, ok := t.byID[tenant]
+	if !ok {
+		b = &tenantBackend[T]{ready: make(chan struct{})}
+		t.byID[tenant] = b
+	}
+	t.mu.Unlock()
+	if !ok {
+		t.open(tenant, b)
+	}
+	select {
+	case <-b.ready:
+		return b.b, b.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// open opens the backend of tenant, forgetting it if that fails.
+func (t *tenants[T]) open(tenant string, b *tenantBackend[T]) {
+	defer close(b.ready)
+	b.b, b.err = t.newFunc(tenant)
+	if b.err != nil {
+		t.mu.Lock()
+		delete(t.byID, tenant)
+		t.mu.Unlock()
+	}
+}
+
+// ByTenant routes every call to the store of the context's tenant,
+// opened by newStore on first use. Tenants never see each other's todos.
+func ByTenant(newStore func(tenant string) (Store, error)) Store {
+	return &tenantStore{stores: newTenants(newStore)}
+}
+
+type tenantStore struct {
+	stores *tenants[Store]
+}
+
+func (t *tenantStore) List(ctx context.Context, q Query) ([]Todo, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx, q)
+}
+
+func (t *tenantStore) Count(ctx context.Context) (Counts, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return Counts{}, err
+	}
+	return CountTodos(ctx, s)
+}
+
+func (t *tenantStore) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ReportCompletions(ctx, s, group, from, to)
+}
+
+func (t *tenantStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ReportTagCounts(ctx, s)
+}
+
+func (t *tenantStore) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return err
+	}
+	return stream(ctx, s, q, fn)
+}
+
+func (t *tenantStore) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return Todo{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (t *tenantStore) Create(ctx context.Context, todo Todo) error {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Create(ctx, todo)
+}
+
+func (t *tenantStore) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Update(ctx, id, u)
+}
+
+func (t *tenantStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Delete(ctx, id)
+}
+
+// TemplatesByTenant is ByTenant for template stores.
+func TemplatesByTenant(newStore func(tenant string) (TemplateStore, error)) TemplateStore {
+	return &tenantTemplates{stores: newTenants(newStore)}
+}
+
+type tenantTemplates struct {
+	stores *tenants[TemplateStore]
+}
+
+func (t *tenantTemplates) ListTemplates(ctx context.Context) ([]Template, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListTemplates(ctx)
+}
+
+func (t *tenantTemplates) GetTemplate(ctx context.Context, id primitive.ObjectID) (Template, error) {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return Template{}, err
+	}
+	return s.GetTemplate(ctx, id)
+}
+
+func (t *tenantTemplates) CreateTemplate(ctx context.Context, tpl Template) error {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return err
+	}
+	return s.CreateTemplate(ctx, tpl)
+}
+
+func (t *tenantTemplates) DeleteTemplate(ctx context.Context, id primitive.ObjectID) error {
+	s, err := t.stores.get(ctx)
+	if err != nil {
+		return err
+	}
+	return s.DeleteTemplate(ctx, id)
+}
+
+// EventsByTenant is ByTenant for event logs.
+func EventsByTenant(newLog func(tenant string) (EventLog, error)) EventLog {
+	return &tenantEvents{logs: newTenants(newLog)}
+}
+
+type tenantEvents struct {
+	logs *tenants[EventLog]
+}
+
+func (t *tenantEvents) Append(ctx context.Context, e Event) error {
+	l, err := t.logs.get(ctx)
+	if err != nil {
+		return err
+	}
+	return l.Append(ctx, e)
+}
+
+func (t *tenantEvents) TodoEvents(ctx context.Context, id primitive.ObjectID) ([]Event, error) {
+	l, err := t.logs.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return l.TodoEvents(ctx, id)
+}
+
+func (t *tenantEvents) Replay(ctx context.Context, fn func(Event) error) error {
+	l, err := t.logs.get(ctx)
+	if err != nil {
+		return err
+	}
+	return l.Replay(ctx, fn)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WithTimeout wraps s so every call runs under its own deadline of at most
+// d, independent of (and never longer than) the caller's context. A
+// non-positive d returns s unchanged.
+func WithTimeout(s Store, d time.Duration) Store {
+	if d <= 0 {
+		return s
+	}
+	return &timeoutStore{inner: s, timeout: d}
+}
+
+type timeoutStore struct {
+	inner   Store
+	timeout time.Duration
+}
+
+func (t *timeoutStore) List(ctx context.Context, q Query) ([]Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.inner.List(ctx, q)
+}
+
+func (t *timeoutStore) Count(ctx context.Context) (Counts, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return CountTodos(ctx, t.inner)
+}
+
+func (t *timeoutStore) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return ReportCompletions(ctx, t.inner, group, from, to)
+}
+
+func (t *timeoutStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return ReportTagCounts(ctx, t.inner)
+}
+
+// Stream bounds the whole iteration, not each document.
+func (t *timeoutStore) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return stream(ctx, t.inner, q, fn)
+}
+
+func (t *timeoutStore) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.inner.Get(ctx, id)
+}
+
+func (t *timeoutStore) Create(ctx context.Context, todo Todo) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.inner.Create(ctx, todo)
+}
+
+func (t *timeoutStore) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.inner.Update(ctx, id, u)
+}
+
+func (t *timeoutStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.inner.Delete(ctx, id)
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// --- Transactions ---
+
+// Transactions commits the writes made through fn's context together,
+// where the backend supports it. fn may run more than once when the
+// transaction hits a transient error, so it must not have other effects.
+type Transactions interface {
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// NoTransactions runs fn as is: each write commits on its own and a
+// failure leaves the earlier ones in place.
+var NoTransactions Transactions = noTransactions{}
+
+type noTransactions struct{}
+
+func (noTransactions) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// MongoTransactions runs fn in a transaction on client. Calls made within
+// one join it rather than starting their own.
+func MongoTransactions(client *mongo.Client) Transactions {
+	return mongoTransactions{client: client}
+}
+
+type mongoTransactions struct {
+	client *mongo.Client
+}
+
+func (m mongoTransactions) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return inMongoTransaction(ctx, m.client, fn)
+}
+
+func inMongoTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// WithTransactions runs each mutation of s in a transaction of tx, so the
+// writes the stores it wraps make along with it (events, outbox messages)
+// commit with it.
+func WithTransactions(s Store, tx Transactions) Store {
+	return &txStore{inner: s, tx: tx}
+}
+
+type txStore struct {
+	inner Store
+	tx    Transactions
+}
+
+func (t *txStore) List(ctx context.Context, q Query) ([]Todo, error) {
+	return t.inner.List(ctx, q)
+}
+
+func (t *txStore) Count(ctx context.Context) (Counts, error) {
+	return CountTodos(ctx, t.inner)
+}
+
+func (t *txStore) Completions(ctx context.Context, group string, from, to time.Time) (map[string]int, error) {
+	return ReportCompletions(ctx, t.inner, group, from, to)
+}
+
+func (t *txStore) TagCounts(ctx context.Context) ([]TagCount, error) {
+	return ReportTagCounts(ctx, t.inner)
+}
+
+func (t *txStore) Stream(ctx context.Context, q Query, fn func(Todo) error) error {
+	return stream(ctx, t.inner, q, fn)
+}
+
+func (t *txStore) Get(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	return t.inner.Get(ctx, id)
+}
+
+func (t *txStore) Create(ctx context.Context, todo Todo) error {
+	return t.tx.InTransaction(ctx, func(ctx context.Context) error { return t.inner.Create(ctx, todo) })
+}
+
+func (t *txStore) Update(ctx context.Context, id primitive.ObjectID, u Update) error {
+	return t.tx.InTransaction(ctx, func(ctx context.Context) error { return t.inner.Update(ctx, id, u) })
+}
+
+func (t *txStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return t.tx.InTransaction(ctx, func(ctx context.Context) error { return t.inner.Delete(ctx, id) })
+}
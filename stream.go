@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Streaming Responses ---
+
+// streamTodos writes the list as a JSON array one element at a time, straight
+// from the store's cursor, so memory stays flat regardless of list size.
+// view maps each todo to the value encoded for it. When cacheKey is set and
+// the list fits the cache budget, the encoded array is also cached there;
+// larger lists are streamed but not kept in memory.
+//
+// It reports false without writing anything if the stream couldn't be
+// opened, letting the caller fall back to the buffered path.
+func (app *App) streamTodos(w http.ResponseWriter, r *http.Request, streamer store.Streamer,
+	q store.Query, view func(store.Todo) interface{}, cacheKey string) bool {
+	ctx := r.Context()
+	started := false
+	cacheable := cacheKey != ""
+	var cache bytes.Buffer
+
+	write := func(p []byte) error {
+		if cacheable {
+			if !app.cacheBudget.fits(cache.Len() + len(p)) {
+				app.cacheBudget.oversized(cacheKey, cache.Len()+len(p))
+				cacheable = false
+				cache = bytes.Buffer{}
+			} else {
+				cache.Write(p)
+			}
+		}
+		_, err := w.Write(p)
+		return err
+	}
+
+	err := streamer.Stream(ctx, q, func(todo store.Todo) error {
+		sep := []byte(",")
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/json")
+			sep = []byte("[")
+		}
+		data, err := json.Marshal(view(todo))
+		if err != nil {
+			return err
+		}
+		if err := write(sep); err != nil {
+			return err
+		}
+		return write(data)
+	})
+
+	switch {
+	case err != nil && !started:
+		log.Printf("Error opening todo stream, falling back to buffered list: %v", err)
+		return false
+	case err != nil:
+		// Headers are gone; the best we can do is cut the response short
+		log.Printf("Error streaming todos: %v", err)
+		return true
+	case !started:
+		w.Header().Set("Content-Type", "application/json")
+		write([]byte("["))
+	}
+	write([]byte("]\n"))
+
+	if cacheable {
+		app.cacheList(ctx, cacheKey, cache.Bytes())
+	}
+	return true
+}
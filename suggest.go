@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Suggestions ---
+
+const (
+	// DefaultSuggestTimeout is the typeahead's time budget; it runs on
+	// every keystroke, so it gets far less than other reads
+	DefaultSuggestTimeout = 50 * time.Millisecond
+	// DefaultSuggestLimit is how many matches GET /todos/suggest returns
+	// without ?limit=
+	DefaultSuggestLimit = 8
+	// MaxSuggestLimit caps ?limit= on GET /todos/suggest
+	MaxSuggestLimit = 20
+)
+
+// SuggestionResponse is a typeahead match: just enough to show and open it.
+type SuggestionResponse struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+// suggestTodos serves GET /todos/suggest?q=&limit=: todos whose title, or
+// a word of it, starts with q. Open todos come first, then completed ones
+// if there's room; within each, titles starting with q rank above those
+// with a later word that does.
+func (app *App) suggestTodos(w http.ResponseWriter, r *http.Request) {
+	_, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 {
+		limit = DefaultSuggestLimit
+	}
+	if limit > MaxSuggestLimit {
+		limit = MaxSuggestLimit
+	}
+	q := cleanTitle(r.URL.Query().Get("q"))
+	if q == "" {
+		respondJSON(w, http.StatusOK, []SuggestionResponse{})
+		return
+	}
+
+	out := make([]SuggestionResponse, 0, limit)
+	for _, completed := range []bool{false, true} {
+		// Ranked before the limit is applied: titles starting with q, then
+		// enough of those with any word doing so to fill the rest, less
+		// the ones already taken
+		n := limit - len(out)
+		starts, err := app.Store.List(r.Context(), store.Query{
+			Fields:     []string{"title", "completed"},
+			Completed:  &completed,
+			TitleStart: q,
+			Limit:      n,
+		})
+		if err != nil {
+			serverError(w, r, "Failed to fetch todos", err)
+			return
+		}
+		todos := starts
+		if len(starts) < n {
+			words, err := app.Store.List(r.Context(), store.Query{
+				Fields:      []string{"title", "completed"},
+				Completed:   &completed,
+				TitlePrefix: q,
+				Limit:       n + len(starts),
+			})
+			if err != nil {
+				serverError(w, r, "Failed to fetch todos", err)
+				return
+			}
+			taken := make(map[primitive.ObjectID]bool, len(starts))
+			for _, todo := range starts {
+				taken[todo.ID] = true
+			}
+			for _, todo := range words {
+				if !taken[todo.ID] && len(todos) < n {
+					todos = append(todos, todo)
+				}
+			}
+		}
+		for _, todo := range todos {
+			out = append(out, SuggestionResponse{ID: todo.ID.Hex(), Title: todo.Title, Completed: todo.Completed})
+		}
+		if len(out) == limit {
+			break
+		}
+	}
+	respondJSON(w, http.StatusOK, out)
+}
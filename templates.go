@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Todo Templates ---
+
+// TemplateColName holds the todo templates.
+const TemplateColName = "templates"
+
+type CreateTemplateRequest struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+}
+
+// TemplateResponse is the wire representation of a store.Template.
+type TemplateResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+}
+
+func newTemplateResponse(t store.Template) TemplateResponse {
+	return TemplateResponse{
+		ID:          t.ID.Hex(),
+		Name:        t.Name,
+		Title:       t.Title,
+		Description: t.Description,
+		Tags:        t.Tags,
+		Priority:    t.Priority,
+	}
+}
+
+func (app *App) listTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := app.Templates.ListTemplates(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to list templates", err)
+		return
+	}
+	out := make([]TemplateResponse, 0, len(templates))
+	for _, t := range templates {
+		out = append(out, newTemplateResponse(t))
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+func (app *App) createTemplate(w http.ResponseWriter, r *http.Request) {
+	var req CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	fields := map[string]string{}
+	if strings.TrimSpace(req.Name) == "" {
+		fields["name"] = "is required"
+	}
+	req.Title = cleanTitle(req.Title)
+	if msg := titleError(req.Title); msg != "" {
+		fields["title"] = msg
+	}
+	if !store.ValidPriority(req.Priority) {
+		fields["priority"] = "must be low, medium or high"
+	}
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "Validation failed", Fields: fields})
+		return
+	}
+
+	t := store.Template{
+		ID:          primitive.NewObjectID(),
+		Name:        strings.TrimSpace(req.Name),
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Priority:    req.Priority,
+	}
+	if err := app.Templates.CreateTemplate(r.Context(), t); err != nil {
+		serverError(w, r, "Failed to create template", err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, newTemplateResponse(t))
+}
+
+// loadTemplate resolves the {id} URL parameter, answering 400 or 404 and
+// reporting false when there is no such template.
+func (app *App) loadTemplate(w http.ResponseWriter, r *http.Request) (store.Template, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return store.Template{}, false
+	}
+	t, err := app.Templates.GetTemplate(r.Context(), id)
+	if errors.Is(err, store.ErrTemplateNotFound) {
+		respondError(w, http.StatusNotFound, "Template not found")
+		return store.Template{}, false
+	}
+	if err != nil {
+		serverError(w, r, "Failed to fetch template", err)
+		return store.Template{}, false
+	}
+	return t, true
+}
+
+func (app *App) getTemplate(w http.ResponseWriter, r *http.Request) {
+	if t, ok := app.loadTemplate(w, r); ok {
+		respondJSON(w, http.StatusOK, newTemplateResponse(t))
+	}
+}
+
+func (app *App) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	if err := app.Templates.DeleteTemplate(r.Context(), id); err != nil {
+		serverError(w, r, "Failed to delete template", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "deleted"})
+}
+
+// createTodoFromTemplate serves POST /todos/from-template/{id}. HTML forms
+// (the home page's template menu) are redirected back home.
+func (app *App) createTodoFromTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+	isForm := strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	app.insertTodo(w, r, t.NewTodo(time.Now()), isForm)
+}
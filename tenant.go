@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Multi-Tenancy ---
+
+// Tenant resolution modes of TENANT_MODE.
+const (
+	TenantModeOff       = ""
+	TenantModeHeader    = "header"    // X-Tenant-ID
+	TenantModeSubdomain = "subdomain" // <tenant>.TENANT_DOMAIN
+)
+
+// tenantIDPattern keeps tenant IDs safe for collection names and Redis keys.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Tenant is a team hosted by this instance, with settings overriding the
+// instance-wide ones.
+type Tenant struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Duplicates string `json:"duplicates,omitempty"` // overrides DUPLICATE_TITLES
+	Moderation string `json:"moderation,omitempty"` // overrides CONTENT_MODERATION
+	Quotas
+	Retention
+	CustomFields []CustomField `json:"customFields,omitempty"` // replace CUSTOM_FIELDS
+}
+
+// tenancy resolves the tenant of each request.
+type tenancy struct {
+	mode   string
+	domain string
+	known  map[string]Tenant // the registry; other IDs are refused
+}
+
+// newTenancy reads TENANT_MODE, TENANT_DOMAIN and the registry in
+// TENANTS_FILE (a JSON list of tenants). It returns nil when tenancy is
+// off, serving everyone as the default tenant. Tenancy needs a registry:
+// every tenant gets its own collections and indexes, which callers naming
+// made-up tenants mustn't be able to create.
+func newTenancy() (*tenancy, error) {
+	t := &tenancy{mode: os.Getenv("TENANT_MODE"), domain: strings.ToLower(os.Getenv("TENANT_DOMAIN"))}
+	switch t.mode {
+	case TenantModeOff:
+		return nil, nil
+	case TenantModeHeader:
+	case TenantModeSubdomain:
+		if t.domain == "" {
+			return nil, fmt.Errorf("TENANT_MODE=subdomain needs TENANT_DOMAIN")
+		}
+	default:
+		return nil, fmt.Errorf("invalid TENANT_MODE=%q: use header or subdomain", t.mode)
+	}
+
+	path := os.Getenv("TENANTS_FILE")
+	if path == "" {
+		return nil, fmt.Errorf("TENANT_MODE=%s needs TENANTS_FILE", t.mode)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []Tenant
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	t.known = map[string]Tenant{}
+	for _, tenant := range list {
+		if !tenantIDPattern.MatchString(tenant.ID) {
+			return nil, fmt.Errorf("%s: invalid tenant ID %q", path, tenant.ID)
+		}
+		if tenant.Duplicates != "" && !validDuplicatePolicy(tenant.Duplicates) {
+			return nil, fmt.Errorf("%s: tenant %s: invalid duplicates %q", path, tenant.ID, tenant.Duplicates)
+		}
+		if tenant.Moderation != "" && !validModerationPolicy(tenant.Moderation) {
+			return nil, fmt.Errorf("%s: tenant %s: invalid moderation %q", path, tenant.ID, tenant.Moderation)
+		}
+		if tenant.PurgeCompletedDays < 0 || tenant.ArchiveCompletedDays < 0 {
+			return nil, fmt.Errorf("%s: tenant %s: retention days can't be negative", path, tenant.ID)
+		}
+		if err := checkCustomFields(tenant.CustomFields); err != nil {
+			return nil, fmt.Errorf("%s: tenant %s: %w", path, tenant.ID, err)
+		}
+		t.known[tenant.ID] = tenant
+	}
+	return t, nil
+}
+
+// requestTenant returns the tenant ID named by r, or "" when it names none.
+func (t *tenancy) requestTenant(r *http.Request) string {
+	if t.mode == TenantModeHeader {
+		return strings.ToLower(strings.TrimSpace(r.Header.Get("X-Tenant-ID")))
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+t.domain)
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// lookup returns the tenant with the given ID, reporting false for
+// unregistered IDs.
+func (t *tenancy) lookup(id string) (Tenant, bool) {
+	tenant, ok := t.known[id]
+	return tenant, ok
+}
+
+type tenantContextKey struct{}
+
+// tenantFrom returns the request's tenant settings; the zero Tenant is the
+// default tenant.
+func tenantFrom(ctx context.Context) Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(Tenant)
+	return tenant
+}
+
+// resolveTenant scopes every request except the exempt paths, and those
+// below exempt paths ending in "/", to its tenant: store calls reach the
+// tenant's collections and Redis keys get its prefix. Requests without a
+// known, enabled tenant are refused.
+func (app *App) resolveTenant(exempt ...string) func(http.Handler) http.Handler {
+	skip := map[string]bool{}
+	var prefixes []string
+	for _, path := range exempt {
+		if strings.HasSuffix(path, "/") {
+			prefixes = append(prefixes, path)
+		} else {
+			skip[path] = true
+		}
+	}
+	exempted := func(path string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		return skip[path]
+	}
+	return func(next http.Handler) http.Handler {
+		if app.tenancy == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempted(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id := app.tenancy.requestTenant(r)
+			if id == "" {
+				respondError(w, http.StatusBadRequest, "Missing tenant")
+				return
+			}
+			tenant, ok := app.tenancy.lookup(id)
+			if !ok {
+				respondError(w, http.StatusNotFound, "Unknown tenant")
+				return
+			}
+			if app.tenantDisabled(r.Context(), tenant.ID) {
+				respondError(w, http.StatusForbidden, "Tenant disabled")
+				return
+			}
+			ctx := store.WithTenant(r.Context(), tenant.ID)
+			ctx = context.WithValue(ctx, tenantContextKey{}, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// redisKey scopes key to the context's tenant.
+func redisKey(ctx context.Context, key string) string {
+	if tenant := store.TenantFrom(ctx); tenant != "" {
+		return "tenant:" + tenant + ":" + key
+	}
+	return key
+}
+
+// tenantStoreOptions are the instance-wide settings of each tenant's store.
+type tenantStoreOptions struct {
+	strictSchema  bool
+	eventSourcing bool
+	outbox        store.Outbox       // nil when no sink is configured
+	cipher        store.FieldCipher  // nil without FIELD_ENCRYPTION
+	transactions  store.Transactions // store.NoTransactions without MONGO_TRANSACTIONS
+}
+
+// encryptEvents encrypts the events of log with c, if any.
+func encryptEvents(log store.EventLog, c store.FieldCipher) store.EventLog {
+	if c == nil {
+		return log
+	}
+	return store.EncryptEvents(log, c)
+}
+
+// openTenantStore sets up the tenant's todo collections (validator,
+// indexes and optionally its event log) and returns its store, with an
+// error when the indexes couldn't be created. The store works without
+// them, only slower.
+func openTenantStore(ctx context.Context, db *mongo.Database, tenant string, opts tenantStoreOptions) (store.Store, error) {
+	// Attach collection validators (not supported by every backend, e.g. Cosmos DB)
+	name := collectionName(ColName, tenant)
+	if err := store.ApplySchema(ctx, db, name, store.TodoSchema, opts.strictSchema); err != nil {
+		log.Printf("Could not apply schema validator to %s: %v", name, err)
+	} else {
+		log.Printf("Schema validator applied to %s (strict=%t)", name, opts.strictSchema)
+	}
+
+	todoStore := store.NewMongo(db.Collection(name))
+	if err := todoStore.EnsureIndexes(ctx); err != nil {
+		return todoStore, fmt.Errorf("creating indexes on %s: %w", name, err)
+	}
+	if err := todoStore.BackfillTitleKeys(ctx); err != nil {
+		log.Printf("Could not store title keys in %s: %v", name, err)
+	}
+	st := store.Store(todoStore)
+	if opts.cipher != nil {
+		st = store.WithEncryption(st, opts.cipher)
+	}
+
+	// Optionally record every mutation in todo_events, projecting it onto todos
+	if opts.eventSourcing {
+		name := collectionName(EventColName, tenant)
+		events := store.NewMongoEvents(db.Collection(name))
+		if err := events.EnsureIndexes(ctx); err != nil {
+			return st, fmt.Errorf("creating indexes on %s: %w", name, err)
+		}
+		st = store.WithEvents(st, encryptEvents(events, opts.cipher))
+	}
+	if opts.outbox != nil {
+		st = store.WithOutbox(st, opts.outbox)
+	}
+	// Outermost, so an event, its projection and its outbox message commit together
+	if opts.transactions != store.NoTransactions {
+		st = store.WithTransactions(st, opts.transactions)
+	}
+	return st, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Tenant Administration ---
+
+// tenantsDisabledKey is the set of disabled tenant IDs. Like lockouts it's
+// instance-wide, so the key isn't tenant-scoped.
+const tenantsDisabledKey = "tenants:disabled"
+
+// tenantDisabled reports whether an admin disabled the tenant. Redis
+// errors let the request through.
+func (app *App) tenantDisabled(ctx context.Context, id string) bool {
+	disabled, err := app.RedisClient.SIsMember(ctx, tenantsDisabledKey, id).Result()
+	if err != nil {
+		log.Printf("Error checking whether tenant %s is disabled: %v", id, err)
+		return false
+	}
+	return disabled
+}
+
+type TenantResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+	Quotas   Quotas `json:"quotas"` // the tenant's own, overriding the defaults
+}
+
+// adminTenant resolves the {id} of an admin tenant route, answering 404
+// when tenancy is off or the tenant is unknown.
+func (app *App) adminTenant(w http.ResponseWriter, r *http.Request) (Tenant, bool) {
+	if app.tenancy == nil {
+		respondError(w, http.StatusNotFound, "Multi-tenancy is off")
+		return Tenant{}, false
+	}
+	tenant, ok := app.tenancy.lookup(chi.URLParam(r, "id"))
+	if !ok {
+		respondError(w, http.StatusNotFound, "Unknown tenant")
+		return Tenant{}, false
+	}
+	return tenant, true
+}
+
+// listTenants serves GET /admin/tenants: the tenants of TENANTS_FILE.
+func (app *App) listTenants(w http.ResponseWriter, r *http.Request) {
+	if app.tenancy == nil {
+		respondError(w, http.StatusNotFound, "Multi-tenancy is off")
+		return
+	}
+	disabled, err := app.RedisClient.SMembers(r.Context(), tenantsDisabledKey).Result()
+	if err != nil {
+		serverError(w, r, "Failed to load tenants", err)
+		return
+	}
+	tenants := map[string]TenantResponse{}
+	for id, t := range app.tenancy.known {
+		tenants[id] = TenantResponse{ID: id, Name: t.Name, Quotas: t.Quotas}
+	}
+	for _, id := range disabled {
+		if t, ok := app.tenancy.lookup(id); ok {
+			tenants[id] = TenantResponse{ID: id, Name: t.Name, Disabled: true, Quotas: t.Quotas}
+		}
+	}
+	out := make([]TenantResponse, 0, len(tenants))
+	for _, t := range tenants {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	respondJSON(w, http.StatusOK, out)
+}
+
+// setTenantDisabled serves POST /admin/tenants/{id}/disable and /enable.
+// Requests of a disabled tenant are refused with 403 until it's enabled
+// again; its data is kept.
+func (app *App) setTenantDisabled(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := app.adminTenant(w, r)
+		if !ok {
+			return
+		}
+		action, status := "tenant.enable", "enabled"
+		err := app.RedisClient.SRem(r.Context(), tenantsDisabledKey, tenant.ID).Err()
+		if disabled {
+			action, status = "tenant.disable", "disabled"
+			err = app.RedisClient.SAdd(r.Context(), tenantsDisabledKey, tenant.ID).Err()
+		}
+		if err != nil {
+			serverError(w, r, "Failed to update tenant", err)
+			return
+		}
+		app.audit(r, action, "tenant:"+tenant.ID)
+		respondJSON(w, http.StatusOK, StatusResponse{Status: status})
+	}
+}
+
+// resetTenantUsage serves DELETE /admin/tenants/{id}/usage: it starts the
+// tenant's daily quota over and recounts its todos.
+func (app *App) resetTenantUsage(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := app.adminTenant(w, r)
+	if !ok {
+		return
+	}
+	ctx := store.WithTenant(r.Context(), tenant.ID)
+	for _, key := range []string{dailyTodosKey(ctx, time.Now().UTC()), todoCountKey(ctx)} {
+		if err := app.RedisClient.Del(ctx, key).Err(); err != nil {
+			serverError(w, r, "Failed to reset usage", err)
+			return
+		}
+	}
+	app.audit(r, "tenant.reset_usage", "tenant:"+tenant.ID)
+	respondJSON(w, http.StatusOK, StatusResponse{Status: "reset"})
+}
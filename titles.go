@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// --- Titles ---
+
+// MaxTitleLength caps titles, counted in user-perceived characters
+// (grapheme clusters), so a family emoji counts once however many bytes
+// and code points it takes.
+const MaxTitleLength = 500
+
+// cleanTitle puts a title in the form it's stored in: NFC, so "é" typed
+// as e and a combining accent matches the precomposed one, without
+// surrounding spaces or invisible characters. Zero-width joiners inside
+// the title are kept; emoji sequences need them.
+func cleanTitle(title string) string {
+	return strings.TrimFunc(norm.NFC.String(title), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Cf, r) // format characters: ZWSP, ZWJ, BOM, ...
+	})
+}
+
+// titleError validates a cleaned title, returning what's wrong with it for
+// the "title" field, or "".
+func titleError(title string) string {
+	switch {
+	case title == "":
+		return "is required"
+	case uniseg.GraphemeClusterCount(title) > MaxTitleLength:
+		return fmt.Sprintf("must be at most %d characters", MaxTitleLength)
+	}
+	return ""
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- My Day ---
+
+// DefaultMyDaySchedule clears the todos planned for the day at midnight
+// UTC, unless CRON_MY_DAY says otherwise.
+const DefaultMyDaySchedule = "0 0 * * *"
+
+type MyDayRequest struct {
+	MyDay *bool `json:"myDay,omitempty"` // false takes it off today; defaults to true
+}
+
+// TodayResponse is the day's plan in the client's zone. Each open todo is
+// listed once, in the first section it belongs to.
+type TodayResponse struct {
+	Date     string         `json:"date"`
+	Overdue  []TodoResponse `json:"overdue"`
+	DueToday []TodoResponse `json:"dueToday"`
+	Planned  []TodoResponse `json:"planned"` // added to today or pinned
+}
+
+// dayPlan is the open todos of a day, by section.
+type dayPlan struct {
+	Overdue  []store.Todo
+	DueToday []store.Todo
+	Planned  []store.Todo
+}
+
+// planDay sorts the open todos into the sections of the day at now, in
+// now's zone.
+func planDay(todos []store.Todo, now time.Time) dayPlan {
+	var plan dayPlan
+	for _, todo := range todos {
+		switch {
+		case todo.Completed:
+		case todo.DueAt != nil && todo.DueAt.Before(now):
+			plan.Overdue = append(plan.Overdue, todo)
+		case todo.DueAt != nil && sameDay(todo.DueAt.In(now.Location()), now):
+			plan.DueToday = append(plan.DueToday, todo)
+		case todo.MyDay || todo.Pinned:
+			plan.Planned = append(plan.Planned, todo)
+		}
+	}
+	return plan
+}
+
+// todayPage is the data rendered by todayTemplate.
+type todayPage struct {
+	Date     string
+	Sections []todaySection // the non-empty ones
+	Due      map[primitive.ObjectID]*dueView
+	Flash    *Flash
+}
+
+type todaySection struct {
+	Title string
+	Class string // of the heading
+	Todos []store.Todo
+}
+
+// handleToday serves GET /today, as a page to browsers and JSON to
+// everyone else.
+func (app *App) handleToday(w http.ResponseWriter, r *http.Request) {
+	todos, err := app.getAllTodos(r.Context())
+	if err != nil {
+		serverError(w, r, "Failed to load todos", err)
+		return
+	}
+	now := time.Now().In(app.userLocation(r))
+	plan := planDay(todos, now)
+
+	w.Header().Add("Vary", "Accept")
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		page := todayPage{Date: now.Format(DueDateLayout), Flash: takeFlash(w, r)}
+		for _, s := range []todaySection{
+			{Title: "Overdue", Class: "text-danger", Todos: plan.Overdue},
+			{Title: "Due today", Todos: plan.DueToday},
+			{Title: "Planned", Todos: plan.Planned},
+		} {
+			if len(s.Todos) > 0 {
+				page.Sections = append(page.Sections, s)
+			}
+		}
+		page.Due = dueViews(append(plan.Overdue, plan.DueToday...), now, now.Location())
+		w.Header().Set("Content-Type", "text/html")
+		app.Today.Execute(w, page)
+		return
+	}
+	respondJSON(w, http.StatusOK, TodayResponse{
+		Date:     now.Format(DueDateLayout),
+		Overdue:  newTodoResponses(plan.Overdue),
+		DueToday: newTodoResponses(plan.DueToday),
+		Planned:  newTodoResponses(plan.Planned),
+	})
+}
+
+// addToToday serves POST /todos/{id}/add-to-today, planning a todo for
+// the day or, with myDay=false, taking it off. HTML forms are redirected
+// back.
+func (app *App) addToToday(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	myDay := true
+	isForm := strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	if isForm {
+		myDay = r.FormValue("myDay") != "false"
+	} else if r.ContentLength != 0 {
+		var req MyDayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.MyDay != nil {
+			myDay = *req.MyDay
+		}
+	}
+
+	ctx := r.Context()
+	todo, err := app.Store.Get(ctx, objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+	if todo.MyDay != myDay {
+		if err := app.Todos.Update(ctx, objID, &store.Update{MyDay: &myDay}); err != nil {
+			respondErr(w, r, err, "Failed to update")
+			return
+		}
+		todo.MyDay = myDay
+	}
+	if isForm {
+		if myDay {
+			setFlash(w, FlashSuccess, "Added \""+todo.Title+"\" to My Day")
+		} else {
+			setFlash(w, FlashSuccess, "Removed \""+todo.Title+"\" from My Day")
+		}
+		redirectBack(w, r, "/today")
+		return
+	}
+	respondJSON(w, http.StatusOK, newTodoResponse(todo))
+}
+
+// clearMyDay takes every tenant's todos off the day's plan, for the
+// next day to start empty.
+func (app *App) clearMyDay(ctx context.Context) error {
+	off := false
+	for _, tenant := range app.tenantIDs() {
+		ctx := store.WithTenant(ctx, tenant)
+		todos, err := app.Store.List(ctx, store.Query{Fields: []string{"myDay"}})
+		if err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+		err = app.Todos.Batch(ctx, func(ctx context.Context) error {
+			for _, todo := range todos {
+				if !todo.MyDay {
+					continue
+				}
+				if err := app.Todos.Update(ctx, todo.ID, &store.Update{MyDay: &off}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+const todayTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>My Day - Azure Go Todo</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 50px; }
+        .container { max-width: 700px; }
+    </style>
+</head>
+<body>
+<main class="container">
+    <p><a href="/">Back to list</a></p>
+    <h1 class="h3">My Day <small class="text-muted">{{.Date}}</small></h1>
+    {{with .Flash}}<div class="alert {{.Class}}" role="{{if eq .Kind "error"}}alert{{else}}status{{end}}">{{.Message}}</div>{{end}}
+    {{range .Sections}}
+    <h2 class="h5 {{.Class}}">{{.Title}}</h2>
+    <ul class="list-group mb-4">
+        {{range .Todos}}
+        <li class="list-group-item d-flex justify-content-between align-items-center">
+            <span><a href="/todos/{{.ID.Hex}}/view"><bdi>{{.Title}}</bdi></a>
+                {{with index $.Due .ID}}<span class="badge {{.Class}}" title="Due {{.At}}">{{.Label}}</span>{{end}}</span>
+            {{if .MyDay}}
+            <form action="/todos/{{.ID.Hex}}/add-to-today" method="POST">
+                <input type="hidden" name="myDay" value="false">
+                <button type="submit" class="btn btn-sm btn-outline-secondary">Remove</button>
+            </form>
+            {{end}}
+        </li>
+        {{end}}
+    </ul>
+    {{else}}
+    <p class="text-muted">Nothing planned. Add todos to My Day from their page.</p>
+    {{end}}
+</main>
+</body>
+</html>`
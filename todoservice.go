@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Todo Service ---
+
+// TodoService is the way changes reach the todos: it applies the rules
+// every change shares (validation, the duplicate, dependency and
+// moderation policies, quotas, cache invalidation, the todo counter)
+// between the handlers, which translate HTTP, and the store and Redis
+// caches. Its errors are the kinds respondErr maps: a *store.ValidationError
+// for invalid fields and a *RuleError for what a policy refuses. What a
+// policy lets through is noted in the context's Notices.
+type TodoService struct {
+	app *App // the store, caches and quotas in effect
+}
+
+// batchKey holds the *batch collecting the cache invalidations of Batch.
+type batchKey struct{}
+
+// batch is what a Batch changed, settled once it ends.
+type batch struct {
+	ids     []primitive.ObjectID
+	changed bool // some todo was written
+	recount bool // the number of todos changed by an unknown amount
+}
+
+// Get reads a todo from the store, bypassing the caches, for changes that
+// depend on its current state.
+func (s *TodoService) Get(ctx context.Context, id primitive.ObjectID) (store.Todo, error) {
+	return s.app.Store.Get(ctx, id)
+}
+
+// Create validates a new todo, normalizing its fields in place, applies
+// the policies to it and stores it within the tenant's quotas.
+func (s *TodoService) Create(ctx context.Context, todo *store.Todo) error {
+	if err := s.checkTodo(ctx, todo); err != nil {
+		return err
+	}
+	if len(todo.BlockedBy) > 0 {
+		g, err := s.app.dependencyGraph(ctx)
+		if err != nil {
+			return err
+		}
+		if err := checkBlockers(g, todo.ID, todo.BlockedBy); err != nil {
+			return err
+		}
+	}
+	if err := s.app.checkDuplicate(ctx, todo.Title); err != nil {
+		return err
+	}
+	if err := s.app.checkContent(ctx, "todo:"+todo.ID.Hex(), map[string]string{"title": todo.Title, "description": todo.Description}); err != nil {
+		return err
+	}
+	release, err := s.app.reserve(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.app.Store.Create(ctx, *todo); err != nil {
+		release()
+		return err
+	}
+	// Also retires a cached miss for the new ID
+	s.changed(ctx, false, todo.ID)
+	if b := batchFrom(ctx); b != nil {
+		b.recount = true
+	} else {
+		s.app.countCreatedTodo(ctx)
+	}
+	return nil
+}
+
+// Insert validates and stores a todo that isn't held to the quotas and
+// policies, as imports and restores are.
+func (s *TodoService) Insert(ctx context.Context, todo store.Todo) error {
+	if err := s.checkTodo(ctx, &todo); err != nil {
+		return err
+	}
+	if err := s.app.Store.Create(ctx, todo); err != nil {
+		return err
+	}
+	s.changed(ctx, true, todo.ID)
+	return nil
+}
+
+// Update validates the fields u sets, normalizing them in place, applies
+// the dependency and moderation policies and updates todo id.
+func (s *TodoService) Update(ctx context.Context, id primitive.ObjectID, u *store.Update) error {
+	if err := s.checkUpdate(ctx, u); err != nil {
+		return err
+	}
+	if err := s.checkDependencies(ctx, id, u); err != nil {
+		return err
+	}
+	if err := s.app.checkContent(ctx, "todo:"+id.Hex(), updatedText(*u)); err != nil {
+		return err
+	}
+	if err := s.app.Store.Update(ctx, id, *u); err != nil {
+		return err
+	}
+	s.changed(ctx, false, id)
+	return nil
+}
+
+func (s *TodoService) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if err := s.app.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.changed(ctx, true, id)
+	return nil
+}
+
+// DeleteUndoable deletes a todo like Delete, keeping a copy that Restore
+// can bring back for the app's undo window.
+func (s *TodoService) DeleteUndoable(ctx context.Context, id primitive.ObjectID) error {
+	if s.app.undoWindow <= 0 {
+		return s.Delete(ctx, id)
+	}
+	todo, err := s.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return s.Delete(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.Delete(ctx, id); err != nil {
+		return err
+	}
+	data, err := json.Marshal(todo)
+	if err == nil {
+		err = s.app.RedisClient.Set(ctx, undoKey(ctx, id), data, s.app.undoWindow).Err()
+	}
+	if err != nil {
+		log.Printf("Error keeping todo %s for undo: %v", id.Hex(), err)
+	}
+	return nil
+}
+
+// Restore brings back a todo deleted by DeleteUndoable within the undo
+// window, returning ErrNothingToUndo once it's past.
+func (s *TodoService) Restore(ctx context.Context, id primitive.ObjectID) (store.Todo, error) {
+	// Taking the key hands the copy to exactly one restore
+	data, err := s.app.takeKey(ctx, undoKey(ctx, id))
+	if errors.Is(err, redis.Nil) {
+		return store.Todo{}, ErrNothingToUndo
+	}
+	if err != nil {
+		return store.Todo{}, err
+	}
+	var todo store.Todo
+	if err := json.Unmarshal(data, &todo); err != nil {
+		return store.Todo{}, err
+	}
+	// It was within the quotas until deleted moments ago
+	if err := s.Insert(ctx, todo); err != nil {
+		return store.Todo{}, err
+	}
+	return todo, nil
+}
+
+// takeKey gets and deletes key in one transaction, handing its value to
+// exactly one caller, or fails with redis.Nil when it's not set. It
+// stands in for GETDEL, which needs Redis 6.2.
+func (app *App) takeKey(ctx context.Context, key string) ([]byte, error) {
+	var get *redis.StringCmd
+	_, err := app.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return get.Bytes()
+}
+
+// Batch runs fn, holding back the cache invalidations of the changes it
+// makes through s until fn returns, however it returns: the list version
+// is bumped once for the whole batch.
+func (s *TodoService) Batch(ctx context.Context, fn func(ctx context.Context) error) error {
+	if batchFrom(ctx) != nil {
+		return fn(ctx)
+	}
+	b := &batch{}
+	defer s.settle(ctx, b)
+	return fn(context.WithValue(ctx, batchKey{}, b))
+}
+
+// InTransaction is Batch in a transaction of the app's Transactions, so
+// caches are only invalidated once the changes are committed (or, without
+// transactions, once those made before a failure are in place).
+func (s *TodoService) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.Batch(ctx, func(ctx context.Context) error {
+		return s.app.Transactions.InTransaction(ctx, fn)
+	})
+}
+
+func batchFrom(ctx context.Context) *batch {
+	b, _ := ctx.Value(batchKey{}).(*batch)
+	return b
+}
+
+// changed invalidates the caches of ids, or records them for the batch.
+func (s *TodoService) changed(ctx context.Context, recount bool, ids ...primitive.ObjectID) {
+	if b := batchFrom(ctx); b != nil {
+		b.ids = append(b.ids, ids...)
+		b.changed = true
+		b.recount = b.recount || recount
+		return
+	}
+	s.app.invalidateTodos(ctx, ids...)
+	if recount {
+		s.app.forgetTodoCount(ctx)
+	}
+}
+
+func (s *TodoService) settle(ctx context.Context, b *batch) {
+	if b.changed {
+		s.app.invalidateTodos(ctx, b.ids...)
+	}
+	if b.recount {
+		s.app.forgetTodoCount(ctx)
+	}
+}
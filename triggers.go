@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Integration Triggers ---
+
+const (
+	// DefaultTriggerLimit is how many items a polling trigger returns
+	DefaultTriggerLimit = 50
+	// MaxTriggerLimit caps ?limit= on polling triggers
+	MaxTriggerLimit = 100
+)
+
+// TriggerItem is one element of a polling trigger. No-code platforms such
+// as Zapier and IFTTT remember the IDs they've seen and fire for new ones,
+// so ID is stable per event: the todo ID for new todos, and the todo ID
+// plus completion time for completions, so completing a reopened todo
+// fires again. It shadows the embedded todo's id, which moves to TodoID.
+type TriggerItem struct {
+	ID     string `json:"id"`
+	TodoID string `json:"todoId"`
+	TodoResponse
+}
+
+// triggerLimit reads ?limit= for a polling trigger.
+func triggerLimit(r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return DefaultTriggerLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > MaxTriggerLimit {
+		return 0, false
+	}
+	return n, true
+}
+
+// newTodosTrigger serves GET /integrations/triggers/new-todos, newest
+// first.
+func (app *App) newTodosTrigger(w http.ResponseWriter, r *http.Request) {
+	limit, ok := triggerLimit(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(MaxTriggerLimit))
+		return
+	}
+	todos, err := app.Store.List(r.Context(), store.Query{Fields: store.LeanFields, Limit: limit})
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	out := make([]TriggerItem, 0, len(todos))
+	for _, todo := range todos {
+		out = append(out, TriggerItem{ID: todo.ID.Hex(), TodoID: todo.ID.Hex(), TodoResponse: newTodoResponse(todo)})
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// completedTodosTrigger serves GET /integrations/triggers/completed-todos,
+// most recently completed first. Todos completed before completion times
+// were recorded sort last, by creation.
+func (app *App) completedTodosTrigger(w http.ResponseWriter, r *http.Request) {
+	limit, ok := triggerLimit(r)
+	if !ok {
+		respondError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(MaxTriggerLimit))
+		return
+	}
+	completed := true
+	todos, err := app.Store.List(r.Context(), store.Query{Fields: store.LeanFields, Completed: &completed})
+	if err != nil {
+		serverError(w, r, "Failed to fetch todos", err)
+		return
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		a, b := todos[i].CompletedAt, todos[j].CompletedAt
+		return a != nil && (b == nil || a.After(*b))
+	})
+	if len(todos) > limit {
+		todos = todos[:limit]
+	}
+
+	out := make([]TriggerItem, 0, len(todos))
+	for _, todo := range todos {
+		id := todo.ID.Hex()
+		if todo.CompletedAt != nil {
+			id += "-" + strconv.FormatInt(todo.CompletedAt.UnixMilli(), 36)
+		}
+		out = append(out, TriggerItem{ID: id, TodoID: todo.ID.Hex(), TodoResponse: newTodoResponse(todo)})
+	}
+	respondJSON(w, http.StatusOK, out)
+}
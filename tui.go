@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mkgakishi/go-azure-todo/client"
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Terminal UI ---
+
+// cmdTUI runs an interactive terminal UI against a remote server, using
+// the same stored configuration and API client as the `todo` commands.
+func cmdTUI(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	fs.Parse(args)
+
+	_, err := tea.NewProgram(newTUIModel(newCLIClient()), tea.WithAltScreen()).Run()
+	return err
+}
+
+// tuiRequestTimeout bounds each API call made by the TUI.
+const tuiRequestTimeout = 15 * time.Second
+
+var (
+	tuiTitleStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("33")).MarginBottom(1)
+	tuiCursorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
+	tuiMutedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	tuiDoneStyle   = tuiMutedStyle.Strikethrough(true)
+	tuiHelpStyle   = tuiMutedStyle.MarginTop(1)
+	tuiErrorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// Messages produced by API calls running in the background.
+type (
+	tuiTodosMsg  []store.Todo
+	tuiStatusMsg string
+	tuiErrMsg    struct{ err error }
+)
+
+type tuiModel struct {
+	client  *client.Client
+	todos   []store.Todo
+	cursor  int
+	adding  bool
+	input   textinput.Model
+	status  string
+	err     error
+	loading bool
+}
+
+func newTUIModel(c *client.Client) tuiModel {
+	input := textinput.New()
+	input.Placeholder = "What needs to be done?"
+	input.CharLimit = 200
+	return tuiModel{client: c, input: input, loading: true}
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return m.refresh()
+}
+
+// --- Commands ---
+
+func (m tuiModel) call(fn func(ctx context.Context) (tea.Msg, error)) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), tuiRequestTimeout)
+		defer cancel()
+		msg, err := fn(ctx)
+		if err != nil {
+			return tuiErrMsg{err}
+		}
+		return msg
+	}
+}
+
+func (m tuiModel) refresh() tea.Cmd {
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		todos, err := m.client.List(ctx)
+		return tuiTodosMsg(todos), err
+	})
+}
+
+// mutate runs fn and then reloads the list, reporting status on success.
+func (m tuiModel) mutate(status string, fn func(ctx context.Context) error) tea.Cmd {
+	return tea.Sequence(
+		m.call(func(ctx context.Context) (tea.Msg, error) {
+			return tuiStatusMsg(status), fn(ctx)
+		}),
+		m.refresh(),
+	)
+}
+
+// --- Update ---
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tuiTodosMsg:
+		m.todos, m.loading = msg, false
+		if m.cursor >= len(m.todos) {
+			m.cursor = len(m.todos) - 1
+		}
+		if m.cursor < 0 {
+			m.cursor = 0
+		}
+		return m, nil
+
+	case tuiStatusMsg:
+		m.status, m.err = string(msg), nil
+		return m, nil
+
+	case tuiErrMsg:
+		m.err, m.loading = msg.err, false
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.adding {
+			return m.updateAdding(msg)
+		}
+		return m.updateBrowsing(msg)
+	}
+	return m, nil
+}
+
+func (m tuiModel) updateAdding(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.adding = false
+		m.input.Blur()
+		return m, nil
+
+	case tea.KeyEnter:
+		title := strings.TrimSpace(m.input.Value())
+		m.adding = false
+		m.input.Blur()
+		m.input.SetValue("")
+		if title == "" {
+			return m, nil
+		}
+		return m, m.mutate("Added "+title, func(ctx context.Context) error {
+			_, err := m.client.Create(ctx, title)
+			return err
+		})
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m tuiModel) updateBrowsing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+
+	case "down", "j":
+		if m.cursor < len(m.todos)-1 {
+			m.cursor++
+		}
+
+	case "a", "n":
+		m.adding = true
+		return m, m.input.Focus()
+
+	case "r":
+		m.loading = true
+		return m, m.refresh()
+
+	case " ", "enter", "x":
+		if len(m.todos) == 0 {
+			return m, nil
+		}
+		todo := m.todos[m.cursor]
+		completed := !todo.Completed
+		status := "Completed " + todo.Title
+		if !completed {
+			status = "Reopened " + todo.Title
+		}
+		return m, m.mutate(status, func(ctx context.Context) error {
+			return m.client.Update(ctx, todo.ID.Hex(), client.Update{Completed: &completed})
+		})
+
+	case "d", "delete":
+		if len(m.todos) == 0 {
+			return m, nil
+		}
+		todo := m.todos[m.cursor]
+		return m, m.mutate("Deleted "+todo.Title, func(ctx context.Context) error {
+			return m.client.Delete(ctx, todo.ID.Hex())
+		})
+	}
+	return m, nil
+}
+
+// --- View ---
+
+func (m tuiModel) View() string {
+	var b strings.Builder
+	b.WriteString(tuiTitleStyle.Render("Azure Todo — " + m.client.BaseURL))
+	b.WriteString("\n")
+
+	switch {
+	case m.loading && len(m.todos) == 0:
+		b.WriteString("Loading…\n")
+	case len(m.todos) == 0:
+		b.WriteString("No tasks yet. Press a to add one.\n")
+	}
+
+	for i, todo := range m.todos {
+		cursor := "  "
+		if i == m.cursor {
+			cursor = tuiCursorStyle.Render("> ")
+		}
+		check, title := "[ ]", todo.Title
+		if todo.Completed {
+			check, title = "[x]", tuiDoneStyle.Render(todo.Title)
+		}
+		fmt.Fprintf(&b, "%s%s %s  %s\n", cursor, check, title,
+			tuiMutedStyle.Render(todo.CreatedAt.Local().Format("Jan 02, 15:04")))
+	}
+
+	if m.adding {
+		b.WriteString("\n" + m.input.View() + "\n")
+	}
+	if m.err != nil {
+		b.WriteString("\n" + tuiErrorStyle.Render("Error: "+m.err.Error()) + "\n")
+	} else if m.status != "" {
+		b.WriteString("\n" + m.status + "\n")
+	}
+
+	help := "↑/↓ move • space toggle • a add • d delete • r refresh • q quit"
+	if m.adding {
+		help = "enter save • esc cancel"
+	}
+	b.WriteString(tuiHelpStyle.Render(help))
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Delete Confirmation & Undo ---
+
+// DefaultUndoWindow is how long a deleted todo can be restored.
+const DefaultUndoWindow = 10 * time.Second
+
+// undoKey is the Redis key of the copy of a deleted todo kept for undo.
+func undoKey(ctx context.Context, id primitive.ObjectID) string {
+	return redisKey(ctx, "undo:"+id.Hex())
+}
+
+// confirmDelete serves GET /todos/{id}/delete, the page the list's delete
+// buttons lead to, which asks before posting the deletion.
+func (app *App) confirmDelete(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todo, err := app.Store.Get(r.Context(), objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to fetch todo")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	app.DeleteConfirm.Execute(w, todo)
+}
+
+// restoreTodo serves POST /todos/{id}/restore, undoing the deletion of a
+// todo within UNDO_WINDOW. HTML forms (the undo banner) are redirected
+// home.
+func (app *App) restoreTodo(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	todo, err := app.Todos.Restore(r.Context(), objID)
+	if err != nil {
+		respondErr(w, r, err, "Failed to restore todo")
+		return
+	}
+	if isHTMLForm(r) {
+		setFlash(w, FlashSuccess, "Restored \""+todo.Title+"\"")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	respondJSON(w, http.StatusCreated, newTodoResponse(todo))
+}
+
+const deleteConfirmTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Delete {{.Title}}? - Azure Go Todo</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 50px; }
+        .container { max-width: 600px; }
+        .completed { text-decoration: line-through; color: #888; }
+    </style>
+</head>
+<body>
+<main class="container">
+    <h1 class="h3">Delete this todo?</h1>
+    <ul class="list-group mb-4">
+        <li class="list-group-item {{if .Completed}}completed{{end}}">{{.Title}}</li>
+    </ul>
+    <form action="/todos/{{.ID.Hex}}/delete" method="POST">
+        <button type="submit" class="btn btn-danger" autofocus>Delete</button>
+        <a href="/" class="btn btn-outline-secondary">Cancel</a>
+    </form>
+</main>
+</body>
+</html>`
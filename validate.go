@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mkgakishi/go-azure-todo/store"
+)
+
+// --- Validation ---
+
+// Notices are what the warn policies let through a change, for the handler
+// to tell the client.
+type Notices struct {
+	DuplicateOf string               // the open todo a new title duplicates
+	BlockedBy   []primitive.ObjectID // open blockers of a todo completed anyway
+	Flagged     []string             // moderation categories of the text, sorted
+}
+
+// noticesKey holds the *Notices collecting the notices of a change.
+type noticesKey struct{}
+
+// withNotices returns a context collecting the notices of the changes
+// made with it.
+func withNotices(ctx context.Context) (context.Context, *Notices) {
+	n := &Notices{}
+	return context.WithValue(ctx, noticesKey{}, n), n
+}
+
+// noticesFrom returns the notices ctx collects, or ones nobody reads.
+func noticesFrom(ctx context.Context) *Notices {
+	if n, ok := ctx.Value(noticesKey{}).(*Notices); ok {
+		return n
+	}
+	return &Notices{}
+}
+
+// passNotices tells the client the notices of a change to the todo
+// target in headers, and audits flagged content.
+func (app *App) passNotices(w http.ResponseWriter, r *http.Request, target string, n *Notices) {
+	if n.DuplicateOf != "" {
+		w.Header().Set("X-Duplicate-Of", n.DuplicateOf)
+	}
+	for _, id := range n.BlockedBy {
+		w.Header().Add("X-Blocked-By", id.Hex())
+	}
+	if len(n.Flagged) > 0 {
+		w.Header().Set("X-Content-Flagged", strings.Join(n.Flagged, ","))
+		app.audit(r, "todo.flagged", target)
+	}
+}
+
+// addFields copies the per-field errors of from into fields.
+func addFields(fields, from map[string]string) {
+	for name, rule := range from {
+		fields[name] = rule
+	}
+}
+
+// validation is a *store.ValidationError naming fields, or nil when there
+// are none.
+func validation(fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &store.ValidationError{Fields: fields}
+}
+
+// checkTodo puts the fields of a new todo in the form they're stored in
+// and validates them.
+func (s *TodoService) checkTodo(ctx context.Context, todo *store.Todo) error {
+	fields := map[string]string{}
+	todo.Title = cleanTitle(todo.Title)
+	if msg := titleError(todo.Title); msg != "" {
+		fields["title"] = msg
+	}
+	if !store.ValidPriority(todo.Priority) {
+		addFields(fields, invalidPriority.Fields)
+	}
+	todo.Color = normalizeColor(todo.Color)
+	if !store.ValidColor(todo.Color) {
+		addFields(fields, invalidColor.Fields)
+	}
+	if !todo.Location.Valid() {
+		todo.Location = nil
+	}
+	addFields(fields, locationErrors(todo.Location))
+	addFields(fields, effortErrors(&todo.Estimate, &todo.Progress))
+	if len(todo.Custom) > 0 {
+		custom, errs := validateCustom(s.app.customFieldsFor(ctx), todo.Custom, false)
+		todo.Custom = custom
+		addFields(fields, errs)
+	}
+	return validation(fields)
+}
+
+// checkUpdate puts the fields u sets in the form they're stored in and
+// validates them.
+func (s *TodoService) checkUpdate(ctx context.Context, u *store.Update) error {
+	fields := map[string]string{}
+	if u.Title != nil {
+		title := cleanTitle(*u.Title)
+		if msg := titleError(title); msg != "" {
+			fields["title"] = msg
+		}
+		u.Title = &title
+	}
+	if u.Priority != nil && !store.ValidPriority(*u.Priority) {
+		addFields(fields, invalidPriority.Fields)
+	}
+	if u.Color != nil {
+		color := normalizeColor(*u.Color)
+		if !store.ValidColor(color) {
+			addFields(fields, invalidColor.Fields)
+		}
+		u.Color = &color
+	}
+	addFields(fields, locationErrors(u.Location))
+	addFields(fields, effortErrors(u.Estimate, u.Progress))
+	if u.Custom != nil {
+		custom, errs := validateCustom(s.app.customFieldsFor(ctx), u.Custom, true)
+		u.Custom = custom
+		addFields(fields, errs)
+	}
+	return validation(fields)
+}
+
+// checkDependencies validates the blockers u sets on todo id and applies
+// the blocked-completion policy when u completes it, stamping when it was
+// completed or reopened. Callers that stamp CompletedAt themselves have
+// applied the policy already.
+func (s *TodoService) checkDependencies(ctx context.Context, id primitive.ObjectID, u *store.Update) error {
+	completing := u.Completed != nil && *u.Completed && u.CompletedAt == nil
+	if u.BlockedBy != nil || completing {
+		g, err := s.app.dependencyGraph(ctx)
+		if err != nil {
+			return err
+		}
+		blockers := g[id].BlockedBy
+		if u.BlockedBy != nil {
+			blockers = *u.BlockedBy
+			if err := checkBlockers(g, id, blockers); err != nil {
+				return err
+			}
+		}
+		if completing {
+			if err := s.app.checkCompletable(ctx, g, blockers); err != nil {
+				return err
+			}
+			if !g[id].Completed {
+				now := time.Now()
+				u.CompletedAt = &now
+			}
+		}
+	}
+	if u.Completed != nil && !*u.Completed && u.CompletedAt == nil {
+		u.CompletedAt = &time.Time{}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "net/http"
+
+// --- Build Info ---
+
+// Set at build time with -ldflags, e.g.
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+}
+
+// handleVersion serves GET /version.
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, VersionResponse{Version: version, Commit: commit, BuildTime: buildTime})
+}
+
+// versionHeader tags every response with the running version, so a
+// misbehaving replica can be told apart during a rollout.
+func versionHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-App-Version", version)
+		next.ServeHTTP(w, r)
+	})
+}
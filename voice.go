@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// --- Voice Input ---
+
+const (
+	// MaxVoiceUpload caps audio uploads; Azure's short-audio API accepts
+	// up to 60 seconds anyway
+	MaxVoiceUpload = 10 << 20
+	// DefaultSpeechLanguage is used unless AZURE_SPEECH_LANGUAGE is set
+	DefaultSpeechLanguage = "en-US"
+)
+
+// transcriber turns a short audio clip into text.
+type transcriber interface {
+	Transcribe(ctx context.Context, audio io.Reader, contentType string) (string, error)
+}
+
+// newTranscriber returns the Azure Speech transcriber when AZURE_SPEECH_KEY
+// and AZURE_SPEECH_REGION are set, or nil to disable POST /todos/voice.
+func newTranscriber() transcriber {
+	key, region := os.Getenv("AZURE_SPEECH_KEY"), os.Getenv("AZURE_SPEECH_REGION")
+	if key == "" || region == "" {
+		return nil
+	}
+	language := os.Getenv("AZURE_SPEECH_LANGUAGE")
+	if language == "" {
+		language = DefaultSpeechLanguage
+	}
+	return &azureSpeech{
+		url: fmt.Sprintf("https://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1?language=%s",
+			region, url.QueryEscape(language)),
+		key:    key,
+		client: &http.Client{Timeout: envDuration("AI_TIMEOUT", DefaultAITimeout)},
+	}
+}
+
+// azureSpeech uses the Speech service's REST API for short audio.
+type azureSpeech struct {
+	url    string
+	key    string
+	client *http.Client
+}
+
+func (a *azureSpeech) Transcribe(ctx context.Context, audio io.Reader, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, audio)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Ocp-Apim-Subscription-Key", a.key)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	var out struct {
+		RecognitionStatus string `json:"RecognitionStatus"`
+		DisplayText       string `json:"DisplayText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if out.RecognitionStatus != "Success" {
+		return "", nil // silence or unintelligible; the caller answers 422
+	}
+	return out.DisplayText, nil
+}
+
+// voiceContentTypes are the audio formats the short-audio API accepts.
+var voiceContentTypes = map[string]bool{"audio/wav": true, "audio/ogg": true}
+
+type VoiceResponse struct {
+	Transcript string       `json:"transcript"`
+	Todo       TodoResponse `json:"todo"`
+}
+
+// createTodoFromVoice serves POST /todos/voice. The audio is either the raw
+// body (Content-Type audio/wav or audio/ogg) or the "audio" part of a
+// multipart form. The transcript goes through parseQuickAdd, so tag and
+// priority markers in it are applied, and the todo is validated like any
+// other; a transcript too long for a title is refused with 400.
+func (app *App) createTodoFromVoice(w http.ResponseWriter, r *http.Request) {
+	if app.transcriber == nil {
+		respondError(w, http.StatusNotFound, "Voice input is disabled")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxVoiceUpload)
+
+	audio, contentType := io.Reader(r.Body), r.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("audio")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Missing audio file")
+			return
+		}
+		defer file.Close()
+		audio, contentType = file, header.Header.Get("Content-Type")
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); !voiceContentTypes[mediaType] {
+		respondError(w, http.StatusUnsupportedMediaType, "Audio must be audio/wav or audio/ogg")
+		return
+	}
+
+	transcript, err := app.transcriber.Transcribe(r.Context(), audio, contentType)
+	if err != nil {
+		log.Printf("Error transcribing audio: %v", err)
+		respondError(w, http.StatusBadGateway, "Transcription is unavailable right now")
+		return
+	}
+	newTodo := parseQuickAdd(transcript)
+	if newTodo.Title == "" {
+		respondError(w, http.StatusUnprocessableEntity, "No speech recognized")
+		return
+	}
+
+	if !app.saveTodo(w, r, &newTodo) {
+		return
+	}
+	respondJSON(w, http.StatusCreated, VoiceResponse{Transcript: transcript, Todo: newTodoResponse(newTodo)})
+}